@@ -0,0 +1,98 @@
+package annuaire
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// Address is the postal address of a contact; every part is optional
+type Address struct {
+	Street  string `json:"street,omitempty" xml:"street,omitempty"`   // Number and street, e.g. "12 rue de la Paix"
+	City    string `json:"city,omitempty" xml:"city,omitempty"`       // City or locality
+	Zip     string `json:"zip,omitempty" xml:"zip,omitempty"`         // Postal code, kept as typed
+	Country string `json:"country,omitempty" xml:"country,omitempty"` // Country name, e.g. "France"
+}
+
+// maxAddressPart bounds each part of an address, in characters
+const maxAddressPart = 200
+
+// addressSeparator separates the parts of an address typed as one value
+const addressSeparator = ";"
+
+// osmSearchURL is the OpenStreetMap search page MapURL links to
+const osmSearchURL = "https://www.openstreetmap.org/search?query="
+
+// IsZero reports whether the address is empty
+func (a Address) IsZero() bool {
+	return a == Address{}
+}
+
+// String formats the address on one line, e.g. "12 rue de la Paix, 75002 Paris, France"
+func (a Address) String() string {
+	var parts []string
+	for _, part := range []string{a.Street, strings.TrimSpace(a.Zip + " " + a.City), a.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MapURL returns the OpenStreetMap search for the address, "" when it is empty
+func (a Address) MapURL() string {
+	if a.IsZero() {
+		return ""
+	}
+	return osmSearchURL + url.QueryEscape(a.String())
+}
+
+/**
+ * ParseAddress reads an address typed as one value, e.g. in a command-line flag
+ *
+ * @param {string} value - "street;zip;city;country"; trailing parts may be
+ *   left out, and each part is trimmed
+ * @return {Address} The address (zero for an empty value)
+ * @return {error} Returns an error if there are more than four parts
+ *
+ * Usage:
+ *   a, err := ParseAddress("12 rue de la Paix; 75002; Paris; France")
+ */
+func ParseAddress(value string) (Address, error) {
+	if strings.TrimSpace(value) == "" {
+		return Address{}, nil
+	}
+	parts := strings.Split(value, addressSeparator)
+	if len(parts) > 4 {
+		return Address{}, &FieldError{Field: "address", Message: fmt.Sprintf("invalid address %q (expected street;zip;city;country)", value)}
+	}
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+	return Address{
+		Street:  strings.TrimSpace(parts[0]),
+		Zip:     strings.TrimSpace(parts[1]),
+		City:    strings.TrimSpace(parts[2]),
+		Country: strings.TrimSpace(parts[3]),
+	}, nil
+}
+
+/**
+ * ValidateAddress checks an address before it is stored
+ *
+ * @param {Address} a - Address to check (the zero address is valid)
+ * @return {error} Returns an error if a part is longer than maxAddressPart
+ *   characters or holds a line break or control character
+ */
+func ValidateAddress(a Address) error {
+	for _, part := range []struct{ name, value string }{{"street", a.Street}, {"city", a.City}, {"zip", a.Zip}, {"country", a.Country}} {
+		if len([]rune(part.value)) > maxAddressPart {
+			return &FieldError{Field: "address", Message: fmt.Sprintf("invalid address: %s is longer than %d characters", part.name, maxAddressPart)}
+		}
+		if strings.IndexFunc(part.value, unicode.IsControl) >= 0 {
+			return &FieldError{Field: "address", Message: fmt.Sprintf("invalid address: %s holds a line break or control character", part.name)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package annuaire
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestParseAddress tests the one-value form of addresses and its formatting
+func TestParseAddress(t *testing.T) {
+	a, err := ParseAddress(" 12 rue de la Paix ; 75002;Paris; France")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Address{Street: "12 rue de la Paix", Zip: "75002", City: "Paris", Country: "France"}); a != want {
+		t.Errorf("Expected %+v, got %+v", want, a)
+	}
+	if a.String() != "12 rue de la Paix, 75002 Paris, France" {
+		t.Errorf("Unexpected formatting %q", a.String())
+	}
+	if url := a.MapURL(); url != "https://www.openstreetmap.org/search?query=12+rue+de+la+Paix%2C+75002+Paris%2C+France" {
+		t.Errorf("Unexpected map link %q", url)
+	}
+
+	if a, _ := ParseAddress(";;Lyon"); a != (Address{City: "Lyon"}) || a.String() != "Lyon" {
+		t.Errorf("Expected only the city, got %+v", a)
+	}
+	if a, _ := ParseAddress(""); !a.IsZero() || a.MapURL() != "" {
+		t.Errorf("Expected no address, got %+v", a)
+	}
+	if _, err := ParseAddress("a;b;c;d;e"); err == nil {
+		t.Error("Expected an error for five parts")
+	}
+	if err := ValidateAddress(Address{Street: "1 rue\nX"}); err == nil {
+		t.Error("Expected line breaks to be refused")
+	}
+	if err := ValidateAddress(Address{City: strings.Repeat("x", maxAddressPart+1)}); err == nil {
+		t.Error("Expected long parts to be refused")
+	}
+}
+
+// TestContactAddress tests that addresses are stored, exported, masked and diffed
+func TestContactAddress(t *testing.T) {
+	dir := NewDirectory()
+	address := Address{Street: "12 rue de la Paix", Zip: "75002", City: "Paris", Country: "France"}
+	if err := dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0612345678", Address: address}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.InsertContact(Contact{Name: "Bad", First: "X", Phone: "1", Address: Address{Zip: "\x00"}}); err == nil {
+		t.Error("Expected an invalid address to be refused")
+	}
+
+	var buf bytes.Buffer
+	if err := dir.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"address": {`) {
+		t.Errorf("Address missing from the export:\n%s", buf.String())
+	}
+	back, err := ReadJSON(&buf)
+	if err != nil || len(back) != 1 || back[0].Address != address {
+		t.Fatalf("Address not read back: %+v (%v)", back, err)
+	}
+
+	masked := MaskContact(back[0], []string{"address"})
+	if masked.Address != (Address{City: "Paris", Country: "France"}) {
+		t.Errorf("Expected only the city and country, got %+v", masked.Address)
+	}
+	records, _ := json.Marshal(RedactContacts(back, []string{"address"}))
+	if strings.Contains(string(records), "Paris") {
+		t.Errorf("Redacted records hold the address: %s", records)
+	}
+
+	moved := back[0]
+	moved.Address.City = "Lyon"
+	if fields := DiffFields(back[0], moved); len(fields) != 1 || fields[0] != "address" {
+		t.Errorf("Expected an address change, got %v", fields)
+	}
+}
@@ -1,29 +1,78 @@
 package annuaire
 
 import (
-	"encoding/json"
+	"bytes"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"sync"
+	"time"
 )
 
 // Contact represents a single contact entry in the directory
 // This structure defines the core data model for storing individual contact information
-// Each contact contains a last name, first name, and phone number
+// Each contact contains a last name, first name, and phone number,
+// plus an optional birthday, speed-dial code, verification date and photo,
+// custom fields for data the directory does not model, and the file it was
+// imported from
 type Contact struct {
-	Name  string `json:"name"`  // Last name of the contact (required, used as primary identifier)
-	First string `json:"first"` // First name of the contact (required)
-	Phone string `json:"phone"` // Phone number of the contact (required, part of composite key)
+	ID        int               `json:"id,omitempty"`        // Stable numeric identifier assigned by the directory
+	Name      string            `json:"name"`                // Last name of the contact (required, used as primary identifier)
+	First     string            `json:"first"`               // First name of the contact (required)
+	Phone     string            `json:"phone"`               // Phone number of the contact (required, part of composite key)
+	Birthday  string            `json:"birthday,omitempty"`  // Birthday as "YYYY-MM-DD" or "--MM-DD" (optional, see ParseBirthday)
+	Address   Address           `json:"address,omitzero"`    // Postal address (optional, see ValidateAddress)
+	Code      string            `json:"code,omitempty"`      // Speed-dial code such as "1" or "doc" (optional, unique, see ValidateCode)
+	VerifyBy  string            `json:"verify_by,omitempty"` // Date by which the contact must be confirmed again, "YYYY-MM-DD" (see NeedsVerification)
+	Photo     string            `json:"photo,omitempty"`     // Avatar as a base64 JPEG of PhotoSize pixels (optional, see NormalizePhoto)
+	Tags      []string          `json:"tags,omitempty"`      // Lower-cased labels such as "work" or "family" (optional, see ValidateTags)
+	Custom    map[string]string `json:"custom,omitempty"`    // Free-form fields such as "badge number" (optional, see ValidateCustomFields)
+	UpdatedAt time.Time         `json:"updated_at,omitzero"` // Last change made through the directory, in UTC (zero: unknown, e.g. older files)
+	Source    string            `json:"source,omitempty"`    // File the contact was last imported from, set by ImportContacts (empty: typed in)
+}
+
+// contactClock dates the changes of contacts; tests replace it to order them
+var contactClock = time.Now
+
+// touch dates a change of the contact, for the last-write-wins sync (see KeepNewest)
+func touch(c *Contact) {
+	c.UpdatedAt = contactClock().UTC()
+}
+
+// ContactRef identifies a contact by the fields of its composite key
+// It is used wherever a contact must be referenced from outside the directory
+// (settings files, pinned lists) without copying its whole data
+type ContactRef struct {
+	Name  string `json:"name"`  // Last name of the referenced contact
+	Phone string `json:"phone"` // Phone number of the referenced contact
+}
+
+// Ref returns the reference identifying this contact
+func (c Contact) Ref() ContactRef {
+	return ContactRef{Name: c.Name, Phone: c.Phone}
 }
 
 // Directory manages a collection of contacts using an in-memory map
 // The directory uses a composite key (name_phone) to allow multiple contacts
 // with the same name but different phone numbers
 // This design choice enables storing family members or business contacts with shared names
+//
+// All methods are safe for concurrent use, which the web server relies on
+// since every HTTP request is handled in its own goroutine
 type Directory struct {
-	contacts map[string]Contact // Internal storage using composite keys for uniqueness
+	mu          sync.RWMutex              // Guards every field below
+	contacts    map[string]Contact        // Internal storage using composite keys for uniqueness
+	index       contactIndex              // Keys by name, phone, ID and code, updated with contacts (see putLocked)
+	nextID      int                       // ID given to the next contact added
+	generation  uint64                    // Incremented by every mutation (see Generation)
+	storage     Storage                   // File access for JSON import/export (nil: OSStorage)
+	history     []HistoryEntry            // Modifications of this session, when no history file is set
+	historyFile string                    // Append-only audit log (see SetHistoryFile)
+	log         *appendLog                // Append-only storage of the contacts, if enabled (see OpenAppendLog)
+	hooks       map[Event][]func(Contact) // Called for every change, by event (see RegisterHook)
 }
 
 /**
@@ -39,6 +88,8 @@ type Directory struct {
 func NewDirectory() *Directory {
 	return &Directory{
 		contacts: make(map[string]Contact), // Initialize empty map for contact storage
+		index:    newContactIndex(0),       // Lookups kept in step with contacts
+		nextID:   1,                        // IDs start at 1 so that 0 means "not assigned"
 	}
 }
 
@@ -61,28 +112,76 @@ func NewDirectory() *Directory {
  *   }
  */
 func (d *Directory) AddContact(name, first, phone string) error {
+	return d.InsertContact(Contact{Name: name, First: first, Phone: phone})
+}
+
+/**
+ * InsertContact adds a complete contact, optional fields included
+ *
+ * @param {Contact} c - Contact to add (name, first name and phone are required)
+ * @return {error} Returns an error if validation fails or contact already exists
+ *
+ * Validation rules are those of AddContact, plus the birthday format and the
+ * speed-dial code uniqueness and the tags when they are set. The ID is always
+ * assigned by the directory, any value in c.ID is ignored
+ *
+ * Usage:
+ *   err := dir.InsertContact(Contact{Name: "Smith", First: "John", Phone: "555-1234", Birthday: "1985-04-12"})
+ */
+func (d *Directory) InsertContact(c Contact) error {
 	// Input validation - ensure all required fields are provided
-	if name == "" || first == "" || phone == "" {
-		return errors.New("all fields are required")
+	if err := requireFields(c); err != nil {
+		return err
+	}
+	if err := ValidateBirthday(c.Birthday); err != nil {
+		return err
+	}
+	if err := ValidateCode(c.Code); err != nil {
+		return err
+	}
+	if err := ValidateTags(c.Tags); err != nil {
+		return err
+	}
+	if err := ValidateAddress(c.Address); err != nil {
+		return err
+	}
+	if err := ValidateCustomFields(c.Custom); err != nil {
+		return err
 	}
 
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, err := d.insertLocked(c)
+	return err
+}
+
+// insertLocked stores a validated contact under a fresh ID and returns it
+// The caller must hold d.mu
+func (d *Directory) insertLocked(c Contact) (Contact, error) {
 	// Create composite key to allow multiple contacts with same name but different phones
 	// This design enables storing contacts like "Smith, John (home)" and "Smith, John (work)"
-	key := fmt.Sprintf("%s_%s", name, phone)
+	key := contactKey(c)
 
 	// Check for duplicate entries using the composite key
 	if _, exists := d.contacts[key]; exists {
-		return errors.New("a contact with this name and phone already exists")
+		return Contact{}, ErrDuplicateContact
 	}
-
-	// Store the contact with the composite key for fast lookup
-	d.contacts[key] = Contact{
-		Name:  name,
-		First: first,
-		Phone: phone,
+	if err := d.checkCodeLocked(c.Code, 0); err != nil {
+		return Contact{}, err
 	}
 
-	return nil
+	// Store the contact with the composite key for fast lookup
+	c.ID = d.nextID
+	c.Tags = NormalizeTags(c.Tags)
+	c.Custom = NormalizeCustomFields(c.Custom)
+	touch(&c)
+	d.nextID++
+	d.putLocked(c)
+	d.generation++
+	d.recordLocked(OpAdd, nil, &c, "")
+
+	return c, nil
 }
 
 /**
@@ -94,8 +193,9 @@ func (d *Directory) AddContact(name, first, phone string) error {
  *
  * Search behavior:
  * - Performs exact string matching (case-sensitive)
- * - Searches across name, first name, and phone fields
- * - Returns the first match found (order not guaranteed due to map iteration)
+ * - Searches across name, first name, and phone fields, in this order
+ * - Returns the oldest contact of the first field that matches
+ * - Takes constant time: the fields are indexed (see contactIndex)
  *
  * Usage:
  *   contact, found := dir.SearchContact("Smith")
@@ -104,27 +204,22 @@ func (d *Directory) AddContact(name, first, phone string) error {
  *   }
  */
 func (d *Directory) SearchContact(searchTerm string) (Contact, bool) {
-	// DEBUG: Log search initiation for troubleshooting search operations
-	log.Printf("SearchContact: Looking for '%s'", searchTerm)
-	// DEBUG: Display total contacts to verify directory state during search
-	log.Printf("Total contacts in directory: %d", len(d.contacts))
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
-	// Iterate through all contacts to find exact matches
-	for key, contact := range d.contacts {
-		// DEBUG: Log each contact being checked to trace search execution path
-		log.Printf("Checking contact: key='%s', name='%s', first='%s', phone='%s'",
-			key, contact.Name, contact.First, contact.Phone)
-
-		// Check if search term matches any of the contact's fields exactly
-		if contact.Name == searchTerm || contact.First == searchTerm || contact.Phone == searchTerm {
-			// DEBUG: Log successful match for debugging search results
-			log.Printf("Found match: %+v", contact)
+	// Contact data only reaches the logs when debug logging is enabled
+	slog.Debug("search contact", "term", searchTerm, "total", len(d.contacts))
+
+	// Look the term up in the index of each field
+	for _, index := range []map[string][]string{d.index.byName, d.index.byFirst, d.index.byPhone} {
+		if keys := index[searchTerm]; len(keys) > 0 {
+			contact := d.contacts[keys[0]]
+			slog.Debug("search match", "name", contact.Name, "first", contact.First, "phone", contact.Phone)
 			return contact, true
 		}
 	}
 
-	// DEBUG: Log when no match is found to help diagnose search issues
-	log.Printf("No match found for '%s'", searchTerm)
+	slog.Debug("search found no match", "term", searchTerm)
 	return Contact{}, false
 }
 
@@ -142,32 +237,32 @@ func (d *Directory) SearchContact(searchTerm string) (Contact, bool) {
  *   fmt.Printf("Found %d contacts named Smith", len(matches))
  */
 func (d *Directory) FilterContacts(searchTerm string) []Contact {
-	// DEBUG: Log filter operation start for debugging multi-match scenarios
-	log.Printf("FilterContacts: Looking for '%s'", searchTerm)
-	// DEBUG: Show directory size to verify data state before filtering
-	log.Printf("Total contacts in directory: %d", len(d.contacts))
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 
 	var matches []Contact
 
-	// Scan all contacts for matches
-	for key, contact := range d.contacts {
-		// DEBUG: Trace each contact evaluation during filtering process
-		log.Printf("Checking contact: key='%s', name='%s', first='%s', phone='%s'",
-			key, contact.Name, contact.First, contact.Phone)
-
-		// Apply same matching logic as SearchContact but collect all results
-		if contact.Name == searchTerm || contact.First == searchTerm || contact.Phone == searchTerm {
-			// DEBUG: Log each match found during filtering
-			log.Printf("Found match: %+v", contact)
-			matches = append(matches, contact)
+	// Collect the contacts indexed under the term, once each even when
+	// several of their fields match
+	seen := make(map[string]bool)
+	for _, index := range []map[string][]string{d.index.byName, d.index.byFirst, d.index.byPhone} {
+		for _, key := range index[searchTerm] {
+			if !seen[key] {
+				seen[key] = true
+				matches = append(matches, d.contacts[key])
+			}
 		}
 	}
 
-	// DEBUG: Report final filter results for verification
-	log.Printf("Found %d matches for '%s'", len(matches), searchTerm)
+	slog.Debug("filter contacts", "term", searchTerm, "total", len(d.contacts), "matches", len(matches))
 	return matches
 }
 
+// matchesTerm reports whether the last name, first name or phone of a contact is exactly the term
+func matchesTerm(c Contact, term string) bool {
+	return c.Name == term || c.First == term || c.Phone == term
+}
+
 /**
  * ListContacts returns a slice containing all contacts in the directory
  *
@@ -181,6 +276,9 @@ func (d *Directory) FilterContacts(searchTerm string) []Contact {
  *   fmt.Printf("Total contacts: %d", len(allContacts))
  */
 func (d *Directory) ListContacts() []Contact {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	// Pre-allocate slice with known capacity for better performance
 	contacts := make([]Contact, 0, len(d.contacts))
 
@@ -192,15 +290,17 @@ func (d *Directory) ListContacts() []Contact {
 }
 
 /**
- * DeleteContact removes the first contact with the specified name from the directory
+ * DeleteContact removes the contact with the specified name from the directory
  *
  * @param {string} name - Last name of the contact to delete
- * @return {error} Returns an error if no contact with the given name is found
+ * @return {error} Returns an error if no contact with the given name is found,
+ *   or an *AmbiguousReferenceError (matching ErrAmbiguous) listing the
+ *   candidates if several contacts share it
  *
  * Deletion behavior:
- * - Searches by last name only (not first name or phone)
- * - Removes the first matching contact found
- * - If multiple contacts have the same last name, only one is deleted
+ * - Searches by last name only (not first name or phone), in constant time
+ * - If multiple contacts have the same last name, none is deleted: pick one
+ *   of the candidates and call DeleteContactByID
  *
  * Usage:
  *   err := dir.DeleteContact("Smith")
@@ -209,22 +309,20 @@ func (d *Directory) ListContacts() []Contact {
  *   }
  */
 func (d *Directory) DeleteContact(name string) error {
-	found := false
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	// Search through all contacts to find the first match by last name
-	for key, contact := range d.contacts {
-		if contact.Name == name {
-			// Remove the contact from the map using its composite key
-			delete(d.contacts, key)
-			found = true
-			break // Exit after first match to maintain single-delete behavior
-		}
+	// Find the contact with this last name, refusing to pick among homonyms
+	key, err := d.uniqueByNameLocked(name)
+	if err != nil {
+		return err
 	}
 
-	// Return error if no matching contact was found
-	if !found {
-		return errors.New("contact not found")
-	}
+	// Remove the contact from the map using its composite key
+	contact := d.contacts[key]
+	d.removeLocked(key)
+	d.generation++
+	d.recordLocked(OpDelete, &contact, nil, "")
 	return nil
 }
 
@@ -234,13 +332,17 @@ func (d *Directory) DeleteContact(name string) error {
  * @param {string} name - Last name of the contact to update (used for lookup)
  * @param {string} newFirst - New first name (empty string means no change)
  * @param {string} newPhone - New phone number (empty string means no change)
- * @return {error} Returns an error if no contact with the given name is found
+ * @return {error} Returns an error if no contact with the given name is found,
+ *   or an *AmbiguousReferenceError (matching ErrAmbiguous) listing the
+ *   candidates if several contacts share it
  *
  * Update behavior:
  * - Searches by last name to find the contact
  * - Only updates fields that have non-empty values provided
  * - Preserves existing values for empty parameters
- * - Updates the first matching contact found
+ * - Updates nothing when the name is ambiguous: use SaveContact with one of
+ *   the candidates instead
+ * - Refuses a new phone already used by a contact with the same name
  *
  * Usage:
  *   // Update only phone number
@@ -250,24 +352,35 @@ func (d *Directory) DeleteContact(name string) error {
  *   err := dir.UpdateContact("Smith", "Jane", "555-8888")
  */
 func (d *Directory) UpdateContact(name, newFirst, newPhone string) error {
-	// Search for the contact to update by last name
-	for key, contact := range d.contacts {
-		if contact.Name == name {
-			// Update first name only if a new value is provided
-			if newFirst != "" {
-				contact.First = newFirst
-			}
-			// Update phone number only if a new value is provided
-			if newPhone != "" {
-				contact.Phone = newPhone
-			}
-			// Save the updated contact back to the map
-			d.contacts[key] = contact
-			return nil
-		}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Find the contact to update by last name, refusing to pick among homonyms
+	key, err := d.uniqueByNameLocked(name)
+	if err != nil {
+		return err
+	}
+
+	contact := d.contacts[key]
+	before := contact
+	// Update first name only if a new value is provided
+	if newFirst != "" {
+		contact.First = newFirst
+	}
+	// Update phone number only if a new value is provided
+	if newPhone != "" {
+		contact.Phone = newPhone
+	}
+	// The phone is part of the composite key, so store under the new key
+	if _, exists := d.contacts[contactKey(contact)]; exists && contactKey(contact) != key {
+		return ErrDuplicateContact
 	}
-	// Return error if no contact with the specified name exists
-	return errors.New("contact not found")
+	touch(&contact)
+	d.removeLocked(key)
+	d.putLocked(contact)
+	d.generation++
+	d.recordLocked(OpUpdate, &before, &contact, "")
+	return nil
 }
 
 /**
@@ -283,9 +396,45 @@ func (d *Directory) UpdateContact(name, newFirst, newPhone string) error {
  *   fmt.Printf("You have %d contacts", count)
  */
 func (d *Directory) ContactCount() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	return len(d.contacts)
 }
 
+/**
+ * Generation returns a counter that changes whenever the directory is modified
+ *
+ * @return {uint64} Current generation; two equal values mean no mutation happened in between
+ *
+ * Callers caching query results (filters, sorted lists, statistics) store the
+ * generation with each result and drop it as soon as the value moves
+ *
+ * Usage:
+ *   gen := dir.Generation()
+ *   // ... later
+ *   if dir.Generation() != gen {
+ *       // Recompute the cached result
+ *   }
+ */
+func (d *Directory) Generation() uint64 {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.generation
+}
+
+/**
+ * Clear removes every contact from the directory
+ *
+ * Usage:
+ *   dir.Clear()
+ *   // dir.ContactCount() == 0
+ */
+func (d *Directory) Clear() {
+	d.replaceContacts(nil, OpClear, "all contacts removed")
+}
+
 /**
  * ExportToJSON exports all contacts to a JSON file at the specified path
  *
@@ -294,8 +443,9 @@ func (d *Directory) ContactCount() int {
  *
  * File operations:
  * - Creates directory structure if it doesn't exist
- * - Overwrites existing files without warning
+ * - Overwrites existing files without warning, atomically (see OSStorage.WriteFile)
  * - Uses proper JSON formatting with indentation for readability
+ * - Compresses with gzip when the name ends with ".gz" (e.g. backup.json.gz)
  * - Converts internal map structure to array for standard JSON format
  *
  * Usage:
@@ -305,74 +455,200 @@ func (d *Directory) ContactCount() int {
  *   }
  */
 func (d *Directory) ExportToJSON(filename string) error {
-	// Create directory structure if it doesn't exist (recursive creation)
-	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-
-	// Convert internal map to slice for proper JSON array structure
-	// This ensures the JSON file contains a standard array format
-	contacts := make([]Contact, 0, len(d.contacts))
-	for _, contact := range d.contacts {
-		contacts = append(contacts, contact)
-	}
-
-	// Marshal to JSON with indentation for human readability
-	data, err := json.MarshalIndent(contacts, "", "  ")
-	if err != nil {
+	// Encode in memory first: the storage replaces the file atomically, so a
+	// failed export never leaves a truncated file behind
+	var buf bytes.Buffer
+	if err := d.ExportJSON(&buf); err != nil {
 		return err
 	}
+	return d.writeExport(filename, buf.Bytes())
+}
 
-	// Write JSON data to file with appropriate permissions
-	return os.WriteFile(filename, data, 0644)
+/**
+ * ExportJSON writes all contacts as an indented JSON array
+ *
+ * @param {io.Writer} w - Destination: an HTTP response, standard output, a buffer
+ * @return {error} Returns an error if JSON encoding or writing fails
+ *
+ * The output has the layout of ExportToJSON, so it can be imported back
+ * with ImportJSON or ImportFromJSON
+ *
+ * Usage:
+ *   err := dir.ExportJSON(os.Stdout)
+ */
+func (d *Directory) ExportJSON(w io.Writer) error {
+	return d.ExportContacts(d.ListContacts(), "json", w)
 }
 
 /**
- * ImportFromJSON imports contacts from a JSON file and replaces current data
+ * ImportFromJSON imports contacts from a JSON file
  *
  * @param {string} filename - Path to the JSON file to import
+ * @param {ImportOptions} opts - Strategy; the zero value replaces the current contacts
+ * @return {ImportResult} Number of contacts added, updated, skipped and left
+ *   out as invalid
  * @return {error} Returns an error if file doesn't exist or JSON parsing fails
  *
  * Import behavior:
- * - Completely replaces existing contacts (not additive)
- * - Expects JSON array format with Contact objects
+ * - Replaces existing contacts, or merges with them (see ImportContacts)
+ * - Expects JSON array format with Contact objects, possibly gzip-compressed
  * - Reconstructs internal composite keys from imported data
- * - Validates JSON structure but not individual contact data
+ * - Checks each contact like AddContact (see ValidateContact): an invalid one
+ *   is left out and counted in ImportResult.Invalid, the others are imported
  *
  * Usage:
- *   err := dir.ImportFromJSON("contacts.json")
+ *   _, err := dir.ImportFromJSON("contacts.json", annuaire.ImportOptions{})
  *   if err != nil {
  *       // Handle file not found or malformed JSON errors
  *   }
  */
-func (d *Directory) ImportFromJSON(filename string) error {
-	// Check if file exists before attempting to read
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return errors.New("file not found")
+func (d *Directory) ImportFromJSON(filename string, opts ImportOptions) (ImportResult, error) {
+	contacts, err := readJSONFile(d.currentStorage(), filename)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return d.ImportContacts(contacts, opts, filename), nil
+}
+
+/**
+ * ImportJSON imports contacts from a JSON stream
+ *
+ * @param {io.Reader} r - Source: an HTTP request body, standard input, a buffer
+ * @param {ImportOptions} opts - Strategy; the zero value replaces the current contacts
+ * @return {ImportResult} Number of contacts added, updated, skipped and left
+ *   out as invalid
+ * @return {error} Returns an error if the stream cannot be read or parsed
+ *
+ * The stream is read to the end before the directory changes, so a
+ * truncated or malformed document leaves the contacts untouched; invalid
+ * contacts are left out as with ImportFromJSON
+ *
+ * Usage:
+ *   result, err := dir.ImportJSON(os.Stdin, annuaire.ImportOptions{Strategy: annuaire.ImportMerge})
+ */
+func (d *Directory) ImportJSON(r io.Reader, opts ImportOptions) (ImportResult, error) {
+	contacts, err := ReadJSON(r)
+	if err != nil {
+		return ImportResult{}, err
 	}
+	return d.ImportContacts(contacts, opts, "JSON stream"), nil
+}
 
+/**
+ * ReadJSONFile reads a JSON contact array without touching any directory
+ *
+ * @param {string} filename - Path to the JSON file to read
+ * @return {[]Contact} Contacts stored in the file
+ * @return {error} Returns an error if file doesn't exist or JSON parsing fails
+ *
+ * This lets callers inspect (or normalize) records before committing them
+ */
+func ReadJSONFile(filename string) ([]Contact, error) {
+	return readJSONFile(OSStorage{}, filename)
+}
+
+// errFileNotFound is returned when a file to import does not exist
+var errFileNotFound = errors.New("file not found")
+
+// readJSONFile reads a JSON contact array through the given storage
+func readJSONFile(storage Storage, filename string) ([]Contact, error) {
 	// Read entire file content into memory
-	data, err := os.ReadFile(filename)
+	data, err := storage.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errFileNotFound
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return ReadJSON(bytes.NewReader(data))
+}
 
-	// Parse JSON array into slice of Contact structs
-	var contacts []Contact
-	if err := json.Unmarshal(data, &contacts); err != nil {
-		return err
+/**
+ * ReadJSON reads a JSON contact array without touching any directory
+ *
+ * @param {io.Reader} r - Stream holding a document written by ExportJSON (any
+ *   version, see DecodeContacts), possibly gzip-compressed (detected from the content)
+ * @return {[]Contact} Contacts stored in the stream
+ * @return {error} Returns an error if the stream cannot be read or parsed
+ */
+func ReadJSON(r io.Reader) ([]Contact, error) {
+	r, err := decompressed(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
 	}
 
-	// Clear existing contacts and rebuild internal map structure
-	d.contacts = make(map[string]Contact)
+	// Parse the document, upgrading older layouts; a truncated stream fails
+	// here, so a damaged read never replaces the current contacts
+	return DecodeContacts(data)
+}
+
+/**
+ * ReplaceContacts discards the current contacts and stores the given ones
+ *
+ * @param {[]Contact} contacts - New content of the directory
+ *
+ * This is the commit step of imports that were read (and possibly previewed)
+ * separately, e.g. with ReadContactsFile
+ */
+func (d *Directory) ReplaceContacts(contacts []Contact) {
+	d.replaceContacts(contacts, OpImport, fmt.Sprintf("%d contacts", len(contacts)))
+}
+
+// replaceContacts discards the current contacts and stores the given ones
+// Composite keys are rebuilt so every import path shares the same storage rules
+// The replacement is recorded in the history as op with the given details;
+// hooks see the discarded contacts as deleted and, for an import, the stored
+// ones as imported. It returns the number of contacts stored, lower than
+// len(contacts) when several share a name and phone
+func (d *Directory) replaceContacts(contacts []Contact, op, details string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	discarded := d.contacts
+
+	// Keep the IDs found in the data, and number the others (older files,
+	// foreign formats, duplicated IDs) after the highest one
+	used := make(map[int]bool, len(contacts))
+	d.nextID = 1
 	for _, contact := range contacts {
+		if contact.ID >= d.nextID {
+			d.nextID = contact.ID + 1
+		}
+	}
+
+	d.resetLocked(len(contacts))
+	d.generation++
+	for _, contact := range contacts {
+		if contact.ID <= 0 || used[contact.ID] {
+			contact.ID = d.nextID
+			d.nextID++
+		}
+		used[contact.ID] = true
+
 		// Reconstruct composite key for internal storage
-		key := fmt.Sprintf("%s_%s", contact.Name, contact.Phone)
-		d.contacts[key] = contact
+		d.putLocked(contact)
 	}
+	d.loadedLocked()
+	d.recordLocked(op, nil, nil, details)
 
-	return nil
+	if len(d.hooks) == 0 {
+		return len(d.contacts)
+	}
+	for _, contact := range sortedByID(discarded) {
+		if _, kept := d.contacts[contactKey(contact)]; !kept {
+			d.emitLocked(OnDelete, contact)
+		}
+	}
+	if op == OpImport {
+		for _, contact := range sortedByID(d.contacts) {
+			d.emitLocked(OnImport, contact)
+		}
+	}
+	return len(d.contacts)
 }
 
 /**
@@ -390,13 +666,16 @@ func (d *Directory) ImportFromJSON(filename string) error {
  *   dir.DebugPrintContacts() // Call when debugging contact storage issues
  */
 func (d *Directory) DebugPrintContacts() {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	fmt.Printf("=== DEBUG: Directory Contents ===\n")
 	fmt.Printf("Total contacts: %d\n", len(d.contacts))
 
 	// Display each contact with its internal storage key for debugging
 	for key, contact := range d.contacts {
-		fmt.Printf("Key: %s -> Name: %s, First: %s, Phone: %s\n",
-			key, contact.Name, contact.First, contact.Phone)
+		fmt.Printf("Key: %s -> ID: %d, Name: %s, First: %s, Phone: %s, Birthday: %s, Code: %s\n",
+			key, contact.ID, contact.Name, contact.First, contact.Phone, contact.Birthday, contact.Code)
 	}
 	fmt.Printf("================================\n")
 }
@@ -506,5 +785,6 @@ func (d *Directory) LoadFromJSON(nomFichier string) error {
 	if _, err := os.Stat(nomFichier); os.IsNotExist(err) {
 		return nil
 	}
-	return d.ImportFromJSON(nomFichier)
+	_, err := d.ImportFromJSON(nomFichier, ImportOptions{})
+	return err
 }
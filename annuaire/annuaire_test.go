@@ -1,7 +1,11 @@
 package annuaire
 
 import (
+	"bytes"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 // TestAddContact tests the AddContact functionality with various scenarios
@@ -160,3 +164,99 @@ func TestImportAndAddFunctionality(t *testing.T) {
 		t.Errorf("Expected Pierre's phone to be 11111, got %s", pierre.Phone)
 	}
 }
+
+// TestGeneration tests that every mutation moves the generation counter
+func TestGeneration(t *testing.T) {
+	dir := NewDirectory()
+	last := dir.Generation()
+	changed := func(step string) {
+		t.Helper()
+		if gen := dir.Generation(); gen == last {
+			t.Errorf("Generation unchanged after %s", step)
+		} else {
+			last = gen
+		}
+	}
+
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	changed("AddContact")
+	dir.UpdateContact("Dupont", "", "0612345678")
+	changed("UpdateContact")
+	dir.SetBirthday("Dupont", "1985-04-12")
+	changed("SetBirthday")
+
+	// Reads and failed mutations leave it alone
+	dir.ListContacts()
+	dir.DeleteContact("Martin")
+	if dir.Generation() != last {
+		t.Error("Generation changed without a mutation")
+	}
+
+	dir.DeleteContact("Dupont")
+	changed("DeleteContact")
+	dir.Clear()
+	changed("Clear")
+}
+
+// TestExportImportJSONStream tests a round trip through a stream, without any file
+func TestExportImportJSONStream(t *testing.T) {
+	source := NewDirectory()
+	source.AddContact("Dupont", "Jean", "0612345678")
+	source.AddContact("Martin", "Paul", "0698765432")
+
+	var buf bytes.Buffer
+	if err := source.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := NewDirectory()
+	dir.AddContact("Old", "Contact", "0000000000")
+	result, err := dir.ImportJSON(&buf, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := dir.ListContacts(), source.ListContacts()
+	SortContactsBy(got, SortByName, false)
+	SortContactsBy(want, SortByName, false)
+	if result.Added != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("Import did not restore the export: %+v", got)
+	}
+
+	// A truncated stream leaves the directory untouched
+	if _, err := dir.ImportJSON(strings.NewReader(`[{"name": "Dup`), ImportOptions{}); err == nil {
+		t.Error("Expected an error for a truncated stream")
+	}
+	if dir.ContactCount() != 2 {
+		t.Errorf("Failed import changed the directory: %d contacts", dir.ContactCount())
+	}
+}
+
+// TestUpdatedAt tests that changes made through the directory are dated
+func TestUpdatedAt(t *testing.T) {
+	defer func(clock func() time.Time) { contactClock = clock }(contactClock)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	contactClock = func() time.Time { return now }
+
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	contact, _ := dir.SearchContact("Dupont")
+	if !contact.UpdatedAt.Equal(now) {
+		t.Errorf("Added contact dated %v, expected %v", contact.UpdatedAt, now)
+	}
+
+	now = now.Add(time.Hour)
+	if err := dir.SaveContact(contact); err != nil {
+		t.Fatal(err)
+	}
+	if unchanged, _ := dir.SearchContact("Dupont"); !unchanged.UpdatedAt.Equal(contact.UpdatedAt) {
+		t.Error("Saving an unchanged contact should keep its date")
+	}
+	contact.Code = "1"
+	contact.UpdatedAt = time.Time{} // Ignored: only the directory dates changes
+	if err := dir.SaveContact(contact); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := dir.SearchContact("Dupont"); !changed.UpdatedAt.Equal(now) {
+		t.Errorf("Changed contact dated %v, expected %v", changed.UpdatedAt, now)
+	}
+}
@@ -0,0 +1,317 @@
+package annuaire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AppendLogExt replaces the extension of the data file for the append-only log
+const AppendLogExt = ".tp1log"
+
+// appendLogMagic starts every append-only log file (format version 1)
+const appendLogMagic = "TP1LOG\x00\x01"
+
+// Record types of the append-only log
+const (
+	logPut    byte = 'P' // Payload: the contact as JSON; replaces the contact with the same ID
+	logDelete byte = 'D' // Payload: the contact ID as a uvarint
+	logReset  byte = 'R' // No payload; removes every contact
+)
+
+// compactionSlack is the number of records tolerated beyond twice the live
+// contacts before the log is rewritten
+const compactionSlack = 64
+
+// appendLog is an append-only binary log of contact mutations, like a tiny bitcask
+//
+// Each record is: type (1 byte), payload length (uint32), payload, then the
+// CRC-32 of type and payload (uint32), integers in little endian. Replaying
+// the records rebuilds the directory; compaction rewrites the file with one
+// put per live contact once obsolete records dominate
+type appendLog struct {
+	path    string
+	file    *os.File // Open for appending
+	records int      // Records in the file, live or obsolete
+	err     error    // First write error, reported by SyncAppendLog
+}
+
+/**
+ * AppendLogPathFor returns the append-only log location for a data file
+ *
+ * @param {string} dataFile - Contacts file, e.g. "data/contacts.json"
+ * @return {string} Log file next to it, e.g. "data/contacts.tp1log"
+ */
+func AppendLogPathFor(dataFile string) string {
+	return strings.TrimSuffix(dataFile, filepath.Ext(dataFile)) + AppendLogExt
+}
+
+/**
+ * OpenAppendLog switches the directory to append-only log storage
+ *
+ * @param {string} path - Log file, see AppendLogPathFor
+ * @return {error} Returns an error if the log cannot be read or created
+ *
+ * If the log exists, its content replaces the contacts of the directory
+ * (without being recorded in the history). Otherwise it is created from the
+ * current contacts, which converts a directory loaded from JSON.
+ *
+ * From then on every mutation appends one or two records instead of
+ * rewriting the whole file; call SyncAppendLog before exiting
+ */
+func (d *Directory) OpenAppendLog(path string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	contacts, records, err := replayAppendLog(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		log := &appendLog{path: path}
+		if err := log.compact(d.contacts); err != nil {
+			return err
+		}
+		d.log = log
+		return nil
+	case err != nil:
+		return err
+	}
+
+	d.loadLocked(contacts)
+	log := &appendLog{path: path, records: records}
+	if log.file, err = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+		return err
+	}
+	d.log = log
+	return nil
+}
+
+/**
+ * SyncAppendLog flushes the append-only log to disk
+ *
+ * @return {error} Returns the first error met while appending since the log
+ *                 was opened, or the sync error; nil without an append-only log
+ */
+func (d *Directory) SyncAppendLog() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.log == nil {
+		return nil
+	}
+	if d.log.err != nil {
+		return d.log.err
+	}
+	return d.log.file.Sync()
+}
+
+/**
+ * ReadAppendLog reads the contacts stored in an append-only log file
+ *
+ * @param {string} path - Log file
+ * @return {[]Contact} Live contacts, e.g. to export them back to JSON
+ * @return {error} Returns an error if the file cannot be read
+ */
+func ReadAppendLog(path string) ([]Contact, error) {
+	contacts, _, err := replayAppendLog(path)
+	return contacts, err
+}
+
+// loadLocked replaces the contacts without recording an operation
+// The caller must hold d.mu
+func (d *Directory) loadLocked(contacts []Contact) {
+	d.resetLocked(len(contacts))
+	d.nextID = 1
+	for _, contact := range contacts {
+		d.putLocked(contact)
+		d.nextID = max(d.nextID, contact.ID+1)
+	}
+	d.loadedLocked()
+	d.generation++
+}
+
+// journalLocked appends the records describing an operation to the log
+// Single-contact operations append a put or a delete; imports and clears
+// append a reset followed by every contact. The caller must hold d.mu
+func (d *Directory) journalLocked(before, after *Contact) {
+	log := d.log
+	if log == nil || log.err != nil {
+		return
+	}
+
+	switch {
+	case after != nil:
+		log.err = log.appendPut(*after)
+	case before != nil:
+		log.err = log.append(logDelete, binary.AppendUvarint(nil, uint64(before.ID)))
+	default:
+		log.err = log.append(logReset, nil)
+		for _, contact := range d.contacts {
+			if log.err == nil {
+				log.err = log.appendPut(contact)
+			}
+		}
+	}
+	if log.err == nil && log.records > 2*len(d.contacts)+compactionSlack {
+		log.err = log.compact(d.contacts)
+	}
+	if log.err != nil {
+		slog.Error("cannot write append-only log", "file", log.path, "error", log.err)
+	}
+}
+
+// appendPut appends a put record for one contact
+func (l *appendLog) appendPut(c Contact) error {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return l.append(logPut, payload)
+}
+
+// append writes one record at the end of the log
+func (l *appendLog) append(kind byte, payload []byte) error {
+	_, err := l.file.Write(encodeLogRecord(kind, payload))
+	if err == nil {
+		l.records++
+	}
+	return err
+}
+
+// compact rewrites the log with one put per contact, atomically
+func (l *appendLog) compact(contacts map[string]Contact) error {
+	var buf bytes.Buffer
+	buf.WriteString(appendLogMagic)
+	for _, contact := range contacts {
+		payload, err := json.Marshal(contact)
+		if err != nil {
+			return err
+		}
+		buf.Write(encodeLogRecord(logPut, payload))
+	}
+	if err := (OSStorage{}).WriteFile(l.path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	if l.file != nil {
+		l.file.Close()
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	l.file, l.records = file, len(contacts)
+	return nil
+}
+
+// encodeLogRecord frames a record: type, length, payload, checksum
+func encodeLogRecord(kind byte, payload []byte) []byte {
+	record := make([]byte, 0, 9+len(payload))
+	record = append(record, kind)
+	record = binary.LittleEndian.AppendUint32(record, uint32(len(payload)))
+	record = append(record, payload...)
+	checksum := crc32.ChecksumIEEE(append([]byte{kind}, payload...))
+	return binary.LittleEndian.AppendUint32(record, checksum)
+}
+
+/**
+ * replayAppendLog rebuilds the contacts stored in a log file
+ *
+ * @param {string} path - Log file
+ * @return {[]Contact} Live contacts, in no particular order
+ * @return {int} Number of valid records read
+ * @return {error} Returns fs.ErrNotExist for a missing file, or an error for
+ *                 a file that is not an append-only log
+ *
+ * A torn or corrupted record ends the replay: it can only be the tail of an
+ * interrupted write, so the file is truncated after the last valid record
+ */
+func replayAppendLog(path string) ([]Contact, int, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(appendLogMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != appendLogMagic {
+		return nil, 0, fmt.Errorf("%s is not a tp1 append-only log", path)
+	}
+
+	live := make(map[int]Contact)
+	offset, records := int64(len(appendLogMagic)), 0
+	for {
+		kind, payload, size, err := readLogRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			slog.Warn("truncating damaged append-only log", "file", path, "offset", offset, "error", err)
+			if err := file.Truncate(offset); err != nil {
+				return nil, 0, err
+			}
+			break
+		}
+		offset += size
+		records++
+
+		switch kind {
+		case logPut:
+			var contact Contact
+			if err := json.Unmarshal(payload, &contact); err != nil {
+				return nil, 0, fmt.Errorf("%s: record %d: %w", path, records, err)
+			}
+			live[contact.ID] = contact
+		case logDelete:
+			id, _ := binary.Uvarint(payload)
+			delete(live, int(id))
+		case logReset:
+			clear(live)
+		}
+	}
+
+	contacts := make([]Contact, 0, len(live))
+	for _, contact := range live {
+		contacts = append(contacts, contact)
+	}
+	return contacts, records, nil
+}
+
+// readLogRecord reads one framed record and checks it
+// It returns io.EOF only at a clean record boundary
+func readLogRecord(r *bufio.Reader) (byte, []byte, int64, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if kind != logPut && kind != logDelete && kind != logReset {
+		return 0, nil, 0, fmt.Errorf("unknown record type %q", kind)
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header[:]))
+	var checksum [4]byte
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	}
+	if _, err := io.ReadFull(r, checksum[:]); err != nil {
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(append([]byte{kind}, payload...)) != binary.LittleEndian.Uint32(checksum[:]) {
+		return 0, nil, 0, errors.New("checksum mismatch")
+	}
+	return kind, payload, int64(9 + len(payload)), nil
+}
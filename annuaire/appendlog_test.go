@@ -0,0 +1,52 @@
+package annuaire
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestAppendLog tests replay, compaction and recovery from a torn write
+func TestAppendLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contacts"+AppendLogExt)
+
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	if err := dir.OpenAppendLog(path); err != nil {
+		t.Fatal(err)
+	}
+	dir.AddContact("Martin", "Paul", "0611111111")
+	dir.UpdateContact("Dupont", "Jeannot", "0699999999")
+	dir.DeleteContact("Martin")
+	for i := 0; i < 100; i++ {
+		dir.SetBirthday("Dupont", "1985-04-12")
+		dir.SetBirthday("Dupont", "1985-04-13")
+	}
+	if err := dir.SyncAppendLog(); err != nil {
+		t.Fatal(err)
+	}
+	if dir.log.records > 2+compactionSlack {
+		t.Errorf("Log not compacted: %d records for 1 contact", dir.log.records)
+	}
+
+	// A partial record at the end is dropped, the rest is kept
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.Write([]byte{logPut, 200, 0, 0, 0, '{'})
+	file.Close()
+
+	reopened := NewDirectory()
+	if err := reopened.OpenAppendLog(path); err != nil {
+		t.Fatal(err)
+	}
+	contacts := reopened.ListContacts()
+	if len(contacts) != 1 || contacts[0].First != "Jeannot" || contacts[0].Phone != "0699999999" || contacts[0].Birthday != "1985-04-13" {
+		t.Fatalf("Unexpected contacts after replay: %+v", contacts)
+	}
+	reopened.AddContact("Durand", "Marie", "0622222222")
+	if got, err := ReadAppendLog(path); err != nil || len(got) != 2 {
+		t.Errorf("Expected 2 contacts after appending past the torn record, got %v (%v)", got, err)
+	}
+	if contact, _ := reopened.ResolveContact("Durand"); contact.ID != 2 {
+		t.Errorf("Expected the ID following the replayed ones, got %d", contact.ID)
+	}
+}
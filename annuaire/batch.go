@@ -0,0 +1,83 @@
+package annuaire
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Statuses of an AddResult
+const (
+	AddAdded     = "added"     // Stored under a new ID
+	AddDuplicate = "duplicate" // Name and phone pair or speed-dial code already taken
+	AddInvalid   = "invalid"   // Fails validation (see ValidateContact)
+)
+
+// AddResult is the outcome of one contact passed to AddContacts
+type AddResult struct {
+	Contact Contact // Contact as stored, with its ID, or as given when refused
+	Status  string  // AddAdded, AddDuplicate or AddInvalid
+	Err     error   // Why the contact was refused, nil when added
+}
+
+/**
+ * AddContacts validates and adds many contacts in one call
+ *
+ * @param {[]Contact} contacts - Contacts to add; their IDs are ignored
+ * @return {[]AddResult} One result per contact, in the same order
+ * @return {error} nil when every contact was added, otherwise the errors of
+ *   the refused ones joined with errors.Join, each prefixed by its position
+ *   (starting at 1) and still matching its sentinel with errors.Is
+ *
+ * The contacts are added in order under a single lock, so a later contact
+ * with the same name and phone as an earlier one of the batch is a duplicate.
+ * Unlike ImportContacts, nothing is overwritten or dropped: each contact is
+ * either stored with all its fields or refused.
+ *
+ * Usage:
+ *   results, err := dir.AddContacts(contacts)
+ *   if err != nil {
+ *       // Some contacts were refused, see results[i].Status and Err
+ *   }
+ */
+func (d *Directory) AddContacts(contacts []Contact) ([]AddResult, error) {
+	results := make([]AddResult, len(contacts))
+	for i, contact := range contacts {
+		results[i] = AddResult{Contact: contact, Status: AddAdded}
+		if err := ValidateContact(contact); err != nil {
+			results[i].Status, results[i].Err = AddInvalid, err
+		}
+	}
+
+	d.mu.Lock()
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		stored, err := d.insertLocked(results[i].Contact)
+		if err != nil {
+			results[i].Status, results[i].Err = AddDuplicate, err
+			continue
+		}
+		results[i].Contact = stored
+	}
+	d.mu.Unlock()
+
+	var refused []error
+	for i, result := range results {
+		if result.Err != nil {
+			refused = append(refused, fmt.Errorf("contact %d: %w", i+1, result.Err))
+		}
+	}
+	return results, errors.Join(refused...)
+}
+
+// CountAdds returns how many results of AddContacts have the given status
+func CountAdds(results []AddResult, status string) int {
+	n := 0
+	for _, result := range results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
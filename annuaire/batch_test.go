@@ -0,0 +1,49 @@
+package annuaire
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestAddContacts tests that a batch reports each contact and keeps the valid ones
+func TestAddContacts(t *testing.T) {
+	dir := NewDirectory()
+	dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0123456789", Code: "1"})
+
+	results, err := dir.AddContacts([]Contact{
+		{Name: "Martin", First: "Paul", Phone: "0600000000", Birthday: "1985-04-12", ID: 42},
+		{Name: "Dupont", First: "Jean", Phone: "0123456789"},
+		{Name: "Durand", Phone: "0700000000"},
+		{Name: "Martin", First: "Paul", Phone: "0600000000"},
+		{Name: "Bernard", First: "Luc", Phone: "0800000000", Code: "1"},
+	})
+	want := []string{AddAdded, AddDuplicate, AddInvalid, AddDuplicate, AddDuplicate}
+	if len(results) != len(want) {
+		t.Fatalf("Expected %d results, got %d", len(want), len(results))
+	}
+	for i, result := range results {
+		if result.Status != want[i] {
+			t.Errorf("Contact %d: status %q, want %q (%v)", i+1, result.Status, want[i], result.Err)
+		}
+	}
+
+	if results[0].Contact.ID == 42 || results[0].Contact.ID == 0 {
+		t.Errorf("Added contact should get a fresh ID, got %d", results[0].Contact.ID)
+	}
+	if c, _ := dir.ResolveContact("Martin"); c.Birthday != "1985-04-12" {
+		t.Errorf("Optional fields should be kept, got %+v", c)
+	}
+	if dir.ContactCount() != 2 {
+		t.Errorf("Expected 2 contacts, got %d", dir.ContactCount())
+	}
+
+	if !errors.Is(err, ErrDuplicateContact) || !errors.Is(err, ErrInvalidInput) || !errors.Is(err, ErrCodeInUse) {
+		t.Errorf("Joined error should match every refusal, got %v", err)
+	}
+	if CountAdds(results, AddDuplicate) != 3 {
+		t.Errorf("Expected 3 duplicates, got %d", CountAdds(results, AddDuplicate))
+	}
+	if _, err := dir.AddContacts([]Contact{{Name: "Petit", First: "Anne", Phone: "0900000000"}}); err != nil {
+		t.Errorf("Batch without refusal should not fail: %v", err)
+	}
+}
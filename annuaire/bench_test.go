@@ -0,0 +1,163 @@
+package annuaire
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// Name parts combined by testContacts; shared last names make the name
+// indexes hold several keys, as in a real directory
+var (
+	testLastNames  = []string{"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand", "Leroy", "Moreau", "Simon", "Laurent", "Lefebvre", "Michel", "Garcia", "David", "Bertrand", "Roux", "Vincent", "Fournier"}
+	testFirstNames = []string{"Jean", "Marie", "Pierre", "Anne", "Luc", "Sophie", "Paul", "Julie", "Louis", "Camille", "Hugo", "Emma", "Léa", "Noé", "Chloé"}
+)
+
+/**
+ * testContacts generates a reproducible directory for tests and benchmarks
+ *
+ * @param {int} n - Number of contacts
+ * @return {[]Contact} Valid contacts with distinct phones: last names are
+ *   suffixed past the first few hundred so that lookups stay selective, a
+ *   third have a birthday and one in ten a speed-dial code
+ *
+ * The same n always gives the same contacts, so benchmark runs compare
+ */
+func testContacts(n int) []Contact {
+	random := rand.New(rand.NewPCG(uint64(n), 42))
+	contacts := make([]Contact, n)
+	for i := range contacts {
+		name := testLastNames[random.IntN(len(testLastNames))]
+		if i >= 10*len(testLastNames) {
+			name += strconv.Itoa(i / 10)
+		}
+		contacts[i] = Contact{
+			Name:  name,
+			First: testFirstNames[random.IntN(len(testFirstNames))],
+			Phone: fmt.Sprintf("0%d%08d", 1+random.IntN(7), i),
+		}
+		if random.IntN(3) == 0 {
+			contacts[i].Birthday = fmt.Sprintf("%d-%02d-%02d", 1950+random.IntN(60), 1+random.IntN(12), 1+random.IntN(28))
+		}
+		if i%10 == 0 {
+			contacts[i].Code = "c" + strconv.Itoa(i)
+		}
+	}
+	return contacts
+}
+
+// benchDirectory returns a directory loaded with n generated contacts
+func benchDirectory(b *testing.B, n int) (*Directory, []Contact) {
+	b.Helper()
+	contacts := testContacts(n)
+	dir := NewDirectory()
+	dir.ReplaceContacts(contacts)
+	return dir, contacts
+}
+
+// BenchmarkAdd measures InsertContact on a directory of 100k contacts
+func BenchmarkAdd(b *testing.B) {
+	dir, _ := benchDirectory(b, 100_000)
+	b.ResetTimer()
+	for i := range b.N {
+		if err := dir.InsertContact(Contact{Name: "Bench", First: "Jean", Phone: fmt.Sprintf("09%09d", i)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkSearch measures the exact lookups of SearchContact and
+// FilterContacts, name completion, and the substring scan of
+// FilterContactsLimited, on 100k contacts
+func BenchmarkSearch(b *testing.B) {
+	dir, contacts := benchDirectory(b, 100_000)
+	b.ResetTimer()
+	b.Run("name", func(b *testing.B) {
+		for i := range b.N {
+			if _, ok := dir.SearchContact(contacts[i%len(contacts)].Name); !ok {
+				b.Fatal("contact not found")
+			}
+		}
+	})
+	b.Run("phone", func(b *testing.B) {
+		for i := range b.N {
+			if _, ok := dir.SearchContact(contacts[i%len(contacts)].Phone); !ok {
+				b.Fatal("contact not found")
+			}
+		}
+	})
+	b.Run("filter", func(b *testing.B) {
+		for i := range b.N {
+			dir.FilterContacts(contacts[i%len(contacts)].Name)
+		}
+	})
+	b.Run("resolve-id", func(b *testing.B) {
+		for i := range b.N {
+			if _, err := dir.ResolveContact("#" + strconv.Itoa(1+i%len(contacts))); err != nil { // IDs start at 1
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("suggest", func(b *testing.B) {
+		for i := range b.N {
+			dir.SuggestNames(contacts[i%len(contacts)].Name[:3], 10)
+		}
+	})
+	b.Run("scan", func(b *testing.B) {
+		for range b.N {
+			dir.FilterContactsLimited(b.Context(), "Martin", SearchLimits{})
+		}
+	})
+}
+
+// BenchmarkDelete measures DeleteContact on 100k contacts; each deleted
+// contact is put back outside of the timer
+func BenchmarkDelete(b *testing.B) {
+	dir, contacts := benchDirectory(b, 100_000)
+	b.ResetTimer()
+	for i := range b.N {
+		b.StopTimer()
+		deleted, _ := dir.SearchContact(contacts[i%len(contacts)].Name) // The one DeleteContact removes
+		b.StartTimer()
+		if err := dir.DeleteContact(deleted.Name); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		dir.ImportContacts([]Contact{deleted}, ImportOptions{Strategy: ImportMergeSkip}, "bench")
+		b.StartTimer()
+	}
+}
+
+// BenchmarkImport10k measures ImportFromJSON of 10k contacts, replacing the directory
+func BenchmarkImport10k(b *testing.B) {
+	benchmarkImport(b, 10_000)
+}
+
+// BenchmarkImport100k measures ImportFromJSON of 100k contacts, replacing the directory
+func BenchmarkImport100k(b *testing.B) {
+	benchmarkImport(b, 100_000)
+}
+
+// benchmarkImport imports a generated JSON file of n contacts b.N times
+func benchmarkImport(b *testing.B, n int) {
+	source, _ := benchDirectory(b, n)
+	filename := filepath.Join(b.TempDir(), "contacts.json")
+	if err := source.ExportToJSON(filename); err != nil {
+		b.Fatal(err)
+	}
+
+	dir := NewDirectory()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := dir.ImportFromJSON(filename, ImportOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if dir.ContactCount() != n {
+		b.Fatalf("Imported %d contacts, want %d", dir.ContactCount(), n)
+	}
+}
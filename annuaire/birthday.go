@@ -0,0 +1,154 @@
+package annuaire
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BirthdayLayout is the stored birthday format (ISO 8601 date)
+// Birthdays whose year is unknown use the vCard form "--MM-DD"
+const BirthdayLayout = "2006-01-02"
+
+// UpcomingBirthday is a contact whose birthday falls in the requested window
+type UpcomingBirthday struct {
+	Contact Contact   // Contact celebrating
+	Date    time.Time // Next occurrence of the birthday (midnight, local time)
+	Days    int       // Days from today, 0 meaning today
+	Age     int       // Age reached on Date (0 when the birth year is unknown)
+}
+
+/**
+ * ParseBirthday parses a stored birthday
+ *
+ * @param {string} birthday - "YYYY-MM-DD", or "--MM-DD" when the year is unknown
+ * @return {time.Time} Parsed date (year 0 for "--MM-DD")
+ * @return {bool} True if the birth year is known
+ * @return {error} Returns an error for any other format or an impossible date
+ */
+func ParseBirthday(birthday string) (time.Time, bool, error) {
+	if rest, ok := strings.CutPrefix(birthday, "--"); ok {
+		// Parse with a leap year so that "--02-29" is accepted
+		date, err := time.Parse(BirthdayLayout, "2000-"+rest)
+		if err != nil {
+			return time.Time{}, false, &FieldError{Field: "birthday", Message: fmt.Sprintf("invalid birthday %q (expected YYYY-MM-DD or --MM-DD)", birthday)}
+		}
+		return time.Date(0, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), false, nil
+	}
+
+	date, err := time.Parse(BirthdayLayout, birthday)
+	if err != nil {
+		return time.Time{}, false, &FieldError{Field: "birthday", Message: fmt.Sprintf("invalid birthday %q (expected YYYY-MM-DD or --MM-DD)", birthday)}
+	}
+	// ISO dates compare as strings, and using the local date avoids time zone surprises
+	if birthday > time.Now().Format(BirthdayLayout) {
+		return time.Time{}, false, &FieldError{Field: "birthday", Message: fmt.Sprintf("invalid birthday %q (date is in the future)", birthday)}
+	}
+	return date, true, nil
+}
+
+/**
+ * ValidateBirthday checks a birthday before it is stored
+ *
+ * @param {string} birthday - Value to check (empty means no birthday and is valid)
+ * @return {error} Returns an error if the value cannot be parsed
+ */
+func ValidateBirthday(birthday string) error {
+	if birthday == "" {
+		return nil
+	}
+	_, _, err := ParseBirthday(birthday)
+	return err
+}
+
+/**
+ * SetBirthday sets or clears the birthday of the contact with the given last name
+ *
+ * @param {string} name - Last name of the contact (same lookup as UpdateContact)
+ * @param {string} birthday - "YYYY-MM-DD", "--MM-DD", or empty to remove the birthday
+ * @return {error} Returns an error if the format is invalid, no contact
+ *   matches, or several contacts share the name (*AmbiguousReferenceError)
+ *
+ * Usage:
+ *   err := dir.SetBirthday("Smith", "1985-04-12")
+ */
+func (d *Directory) SetBirthday(name, birthday string) error {
+	if err := ValidateBirthday(birthday); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, err := d.uniqueByNameLocked(name)
+	if err != nil {
+		return err
+	}
+	contact := d.contacts[key]
+	before := contact
+	contact.Birthday = birthday
+	touch(&contact)
+	d.putLocked(contact)
+	d.generation++
+	d.recordLocked(OpUpdate, &before, &contact, "")
+	return nil
+}
+
+/**
+ * UpcomingBirthdays lists the birthdays of the next days, today included
+ *
+ * @param {int} days - Size of the window in days (0 returns only today's birthdays)
+ * @return {[]UpcomingBirthday} Birthdays sorted by date, then by name
+ *
+ * People born on February 29 celebrate on March 1 in non-leap years
+ *
+ * Usage:
+ *   for _, b := range dir.UpcomingBirthdays(30) {
+ *       fmt.Printf("%s %s in %d days\n", b.Contact.First, b.Contact.Name, b.Days)
+ *   }
+ */
+func (d *Directory) UpcomingBirthdays(days int) []UpcomingBirthday {
+	return upcomingBirthdays(d.ListContacts(), time.Now(), days)
+}
+
+// upcomingBirthdays computes the birthdays within days of now (now is injected for tests)
+func upcomingBirthdays(contacts []Contact, now time.Time, days int) []UpcomingBirthday {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var upcoming []UpcomingBirthday
+	for _, contact := range contacts {
+		if contact.Birthday == "" {
+			continue
+		}
+		born, yearKnown, err := ParseBirthday(contact.Birthday)
+		if err != nil {
+			continue
+		}
+
+		// time.Date normalizes February 29 to March 1 in non-leap years
+		next := time.Date(today.Year(), born.Month(), born.Day(), 0, 0, 0, 0, today.Location())
+		if next.Before(today) {
+			next = time.Date(today.Year()+1, born.Month(), born.Day(), 0, 0, 0, 0, today.Location())
+		}
+		// Count calendar days, which stays correct across daylight saving changes
+		until := int(next.Sub(today).Round(24*time.Hour) / (24 * time.Hour))
+		if until > days {
+			continue
+		}
+
+		birthday := UpcomingBirthday{Contact: contact, Date: next, Days: until}
+		if yearKnown {
+			birthday.Age = next.Year() - born.Year()
+		}
+		upcoming = append(upcoming, birthday)
+	}
+
+	sort.Slice(upcoming, func(i, j int) bool {
+		if upcoming[i].Days != upcoming[j].Days {
+			return upcoming[i].Days < upcoming[j].Days
+		}
+		return lessAlphabetical(upcoming[i].Contact, upcoming[j].Contact)
+	})
+	return upcoming
+}
@@ -0,0 +1,64 @@
+package annuaire
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBirthdayValidation tests accepted and rejected birthday formats
+func TestBirthdayValidation(t *testing.T) {
+	for _, valid := range []string{"", "1985-04-12", "--02-29", "2000-02-29"} {
+		if err := ValidateBirthday(valid); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"12/04/1985", "1985-13-01", "1985-02-30", "2001-02-29", "--13-01", "2999-01-01"} {
+		if err := ValidateBirthday(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+
+	dir := NewDirectory()
+	if err := dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0123456789", Birthday: "12/04/1985"}); err == nil {
+		t.Error("InsertContact accepted an invalid birthday")
+	}
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	if err := dir.SetBirthday("Dupont", "1985-04-12"); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := dir.SearchContact("Dupont"); c.Birthday != "1985-04-12" {
+		t.Errorf("Birthday not stored: %+v", c)
+	}
+	if err := dir.SetBirthday("Martin", "1985-04-12"); err == nil {
+		t.Error("Expected error for unknown contact")
+	}
+}
+
+// TestUpcomingBirthdays tests the window, ordering, age and year wrap-around
+func TestUpcomingBirthdays(t *testing.T) {
+	contacts := []Contact{
+		{Name: "Martin", First: "Paul", Phone: "1", Birthday: "1990-01-03"},
+		{Name: "Dupont", First: "Jean", Phone: "2", Birthday: "1985-12-30"},
+		{Name: "Durand", First: "Anne", Phone: "3", Birthday: "--12-28"},
+		{Name: "Petit", First: "Luc", Phone: "4", Birthday: "1970-06-01"},
+		{Name: "Leroy", First: "Eva", Phone: "5"},
+	}
+	now := time.Date(2025, time.December, 28, 15, 0, 0, 0, time.UTC)
+
+	upcoming := upcomingBirthdays(contacts, now, 7)
+	var got []string
+	for _, b := range upcoming {
+		got = append(got, b.Contact.Name)
+	}
+	if strings.Join(got, ",") != "Durand,Dupont,Martin" {
+		t.Fatalf("Unexpected birthdays: %v", got)
+	}
+
+	if b := upcoming[0]; b.Days != 0 || b.Age != 0 {
+		t.Errorf("Expected today's birthday with unknown age, got %+v", b)
+	}
+	if b := upcoming[2]; b.Days != 6 || b.Age != 36 || b.Date.Year() != 2026 {
+		t.Errorf("Expected next year's birthday at 36, got %+v", b)
+	}
+}
@@ -0,0 +1,51 @@
+package annuaire
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestClearContacts tests the scopes of ClearContacts and the Source set by imports
+func TestClearContacts(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Typed", "In", "0600000000")
+	dir.ImportContacts([]Contact{
+		{Name: "Dupont", First: "Jean", Phone: "0123456789", Tags: []string{"work"}},
+		{Name: "Martin", First: "Paul", Phone: "0698765432"},
+	}, ImportOptions{Strategy: ImportMergeSkip, RecordSource: true}, "team.csv")
+	dir.ImportContacts([]Contact{
+		{Name: "Durand", First: "Marie", Phone: "0611111111", Tags: []string{"work"}, Source: "kept.csv"},
+	}, ImportOptions{Strategy: ImportMergeSkip}, "restore")
+
+	if sources := dir.ImportSources(); !slices.Equal(sources, []string{"kept.csv", "team.csv"}) {
+		t.Fatalf("Unexpected sources: %v", sources)
+	}
+
+	// Editing a contact keeps the file it came from
+	martin, _ := dir.ResolveContact("Martin")
+	martin.Phone = "0699999999"
+	if err := dir.SaveContact(martin); err != nil {
+		t.Fatal(err)
+	}
+
+	scope := ClearScope{Source: "team.csv"}
+	if n := dir.CountScope(scope); n != 2 {
+		t.Errorf("CountScope(%v) = %d, want 2", scope, n)
+	}
+	if n := dir.ClearContacts(scope); n != 2 || dir.ContactCount() != 2 {
+		t.Fatalf("Clearing team.csv removed %d, %d left", n, dir.ContactCount())
+	}
+	entries, _ := dir.History()
+	for _, entry := range entries[len(entries)-2:] {
+		if entry.Op != OpDelete || entry.Details != "clear contacts imported from team.csv" {
+			t.Errorf("Unexpected history entry: %+v", entry)
+		}
+	}
+
+	if n := dir.ClearContacts(ClearScope{Tag: "Work"}); n != 1 || dir.ContactCount() != 1 {
+		t.Errorf("Clearing the work tag removed %d, %d left", n, dir.ContactCount())
+	}
+	if n := dir.ClearContacts(ClearScope{}); n != 1 || dir.ContactCount() != 0 {
+		t.Errorf("Clearing every contact removed %d, %d left", n, dir.ContactCount())
+	}
+}
@@ -0,0 +1,102 @@
+package annuaire
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ImportDecoder reads the contacts of a document written by an ExportEncoder,
+// e.g. ReadJSON for EncodeJSON
+type ImportDecoder func(r io.Reader) ([]Contact, error)
+
+/**
+ * RegisterImportDecoder makes a document format readable by name
+ *
+ * @param {string} name - Format name, e.g. "toml"; register the encoder of the
+ *   same name with RegisterExportEncoder to write it as well
+ * @param {ImportDecoder} decoder - Function reading the contacts
+ * @param {...string} extensions - File extensions selecting the format, e.g. ".toml"
+ *
+ * Usage (in a third-party package):
+ *   func init() { annuaire.RegisterImportDecoder("toml", readTOML, ".toml") }
+ */
+func RegisterImportDecoder(name string, decoder ImportDecoder, extensions ...string) {
+	formatRegistry.Lock()
+	defer formatRegistry.Unlock()
+	name = strings.ToLower(name)
+	formatRegistry.decoders[name] = decoder
+	for _, extension := range extensions {
+		formatRegistry.extensions[strings.ToLower(extension)] = name
+	}
+}
+
+/**
+ * LookupImportDecoder returns the decoder registered under a name
+ *
+ * @param {string} name - Format name, e.g. "yaml" (empty selects "json")
+ * @return {ImportDecoder} The decoder
+ * @return {error} Returns an error listing the readable formats if the name is unknown
+ */
+func LookupImportDecoder(name string) (ImportDecoder, error) {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+	if name == "" {
+		name = "json"
+	}
+	decoder, ok := formatRegistry.decoders[strings.ToLower(name)]
+	if !ok {
+		return nil, errorOf(ErrInvalidInput, "unknown import format %q (available: %s)", name, strings.Join(sortedKeys(formatRegistry.decoders), ", "))
+	}
+	return decoder, nil
+}
+
+// ImportFormatNames returns the registered decoder names, sorted
+func ImportFormatNames() []string {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+	return sortedKeys(formatRegistry.decoders)
+}
+
+/**
+ * FormatForName returns the format a file name asks for
+ *
+ * @param {string} filename - File name; ".enc" and ".gz" are looked through,
+ *   so that "backup.yaml.gz" is YAML
+ * @return {string} Registered format of the extension, e.g. "yaml" for
+ *   ".yml", or "" when the extension selects none
+ */
+func FormatForName(filename string) string {
+	filename = uncompressedName(decryptedName(filename))
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+	return formatRegistry.extensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// decoderFor returns the decoder of a file name's format, also found by the
+// format name itself, e.g. "import.toml"; ReadJSON when there is none
+func decoderFor(filename string) ImportDecoder {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+	extension := strings.ToLower(filepath.Ext(filename))
+	name, ok := formatRegistry.extensions[extension]
+	if !ok {
+		name = strings.TrimPrefix(extension, ".")
+	}
+	if decoder, ok := formatRegistry.decoders[name]; ok {
+		return decoder
+	}
+	return ReadJSON
+}
+
+// checkVersion rejects the documents of a version this application cannot read
+func checkVersion(version int) error {
+	if version < 1 {
+		return fmt.Errorf("invalid contacts file version %d", version)
+	}
+	if version > FormatVersion {
+		return fmt.Errorf("contacts file version %d is newer than this application (version %d): upgrade it to read the file", version, FormatVersion)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package annuaire
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestFormatForName tests the format selected by file extensions
+func TestFormatForName(t *testing.T) {
+	for name, want := range map[string]string{
+		"contacts.json":     "json",
+		"contacts.YML":      "yaml",
+		"backup.yaml.gz":    "yaml",
+		"backup.xml.gz.enc": "xml",
+		"entries.ldif":      "ldif",
+		"contacts.csv":      "",
+		"contacts":          "",
+	} {
+		if got := FormatForName(name); got != want {
+			t.Errorf("FormatForName(%q) = %q, expected %q", name, got, want)
+		}
+	}
+}
+
+// TestReadContactsFileFormats tests that exports are read back by extension,
+// and that registered decoders are used
+func TestReadContactsFileFormats(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0612345678")
+	folder := t.TempDir()
+
+	for _, name := range []string{"out.yaml", "out.yml.gz", "out.xml", "out.json"} {
+		pipeline, err := NewExportPipeline(FormatForName(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		filename := filepath.Join(folder, name)
+		if err := dir.ExportWith(filename, pipeline); err != nil {
+			t.Fatal(err)
+		}
+		contacts, _, err := ReadContactsFile(filename, "")
+		if err != nil || len(contacts) != 1 || contacts[0].Phone != "0612345678" {
+			t.Errorf("%s: expected Dupont back, got %+v (%v)", name, contacts, err)
+		}
+	}
+
+	RegisterImportDecoder("lines", func(r io.Reader) ([]Contact, error) {
+		data, err := io.ReadAll(r)
+		var contacts []Contact
+		for _, line := range strings.Fields(string(data)) {
+			contacts = append(contacts, Contact{Name: line, First: line, Phone: "1"})
+		}
+		return contacts, err
+	}, ".lines")
+	defer func() {
+		formatRegistry.Lock()
+		delete(formatRegistry.decoders, "lines")
+		delete(formatRegistry.extensions, ".lines")
+		formatRegistry.Unlock()
+	}()
+	filename := filepath.Join(folder, "names.lines")
+	os.WriteFile(filename, []byte("Martin\nBernard\n"), 0600)
+	contacts, _, err := ReadContactsFile(filename, "")
+	if err != nil || len(contacts) != 2 || contacts[1].Name != "Bernard" {
+		t.Errorf("Registered decoder not used: %+v (%v)", contacts, err)
+	}
+	if _, err := LookupImportDecoder("toml"); err == nil || !strings.Contains(err.Error(), "lines, xml, yaml") {
+		t.Errorf("Expected the readable formats to be listed, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package annuaire
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// GzipExtension marks exports that are written gzip-compressed, e.g. backup.json.gz
+const GzipExtension = ".gz"
+
+// gzipMagic starts every gzip stream, whatever the file is called
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// IsCompressedName reports whether a file name asks for a gzip-compressed export
+func IsCompressedName(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), GzipExtension)
+}
+
+// uncompressedName drops the gzip extension, so that "contacts.csv.gz" is read
+// as CSV once decompressed
+func uncompressedName(filename string) string {
+	if IsCompressedName(filename) {
+		return filename[:len(filename)-len(GzipExtension)]
+	}
+	return filename
+}
+
+// writeExport writes an encoded export through the directory storage,
+// compressed when the file name ends with GzipExtension
+func (d *Directory) writeExport(filename string, data []byte) error {
+	if IsCompressedName(filename) {
+		var err error
+		if data, err = gzipBytes(data); err != nil {
+			return err
+		}
+	}
+	return d.currentStorage().WriteFile(filename, data)
+}
+
+// gzipBytes compresses data with the default compression level
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/**
+ * decompressed returns a stream that reads r uncompressed
+ *
+ * @param {io.Reader} r - Source that may or may not be gzip-compressed
+ * @return {io.Reader} The decompressed stream when r starts with the gzip
+ *   magic number, else r itself (with its first bytes buffered)
+ * @return {error} Returns an error if the gzip header is damaged
+ *
+ * The content decides, not the name, so a renamed backup or a compressed
+ * stream piped on standard input is still read
+ */
+func decompressed(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	if head, _ := buffered.Peek(len(gzipMagic)); !bytes.Equal(head, gzipMagic) {
+		return buffered, nil
+	}
+	return gzip.NewReader(buffered)
+}
@@ -0,0 +1,68 @@
+package annuaire
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCompressedExport tests that .gz exports are compressed and read back transparently
+func TestCompressedExport(t *testing.T) {
+	source := NewDirectory()
+	source.AddContact("Dupont", "Jean", "0612345678")
+	source.AddContact("Martin", "Paul", "0698765432")
+
+	filename := filepath.Join(t.TempDir(), "backup.json.gz")
+	if err := source.ExportToJSON(filename); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		t.Fatalf("Export is not compressed: %q", data[:min(len(data), 16)])
+	}
+
+	dir := NewDirectory()
+	if _, err := dir.ImportFromJSON(filename, ImportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if dir.ContactCount() != 2 {
+		t.Errorf("Expected 2 contacts, got %d", dir.ContactCount())
+	}
+
+	// The content decides: a renamed backup is still decompressed
+	renamed := filepath.Join(t.TempDir(), "backup.json")
+	os.WriteFile(renamed, data, 0644)
+	if contacts, _, err := ReadContactsFile(renamed, ""); err != nil || len(contacts) != 2 {
+		t.Errorf("Renamed backup: %d contacts, %v", len(contacts), err)
+	}
+}
+
+// TestCompressedForeignFormat tests that the extension before .gz selects the reader
+func TestCompressedForeignFormat(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(sampleVCards))
+	zw.Close()
+
+	filename := filepath.Join(t.TempDir(), "contacts.vcf.gz")
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	contacts, report, err := ReadContactsFile(filename, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contacts) != 2 || report == nil {
+		t.Errorf("Expected 2 contacts read as vCards, got %d", len(contacts))
+	}
+
+	if _, _, err := ReadContacts(strings.NewReader("\x1f\x8bnot gzip"), "backup.json", ""); err == nil {
+		t.Error("Expected an error for a damaged gzip header")
+	}
+}
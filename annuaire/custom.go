@@ -0,0 +1,111 @@
+package annuaire
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Bounds of the custom fields of one contact (see ValidateCustomFields)
+const (
+	maxCustomFields = 20
+	maxCustomKey    = 40
+	maxCustomValue  = 500
+)
+
+// customSeparator separates the key from the value of a custom field typed
+// as one value, e.g. "badge number=1234"
+const customSeparator = "="
+
+/**
+ * ParseCustomField reads a custom field typed as "key=value"
+ *
+ * @param {string} assignment - Field such as "badge number=1234"; an empty
+ *   value ("badge number=") asks to remove the field
+ * @return {string} Trimmed key
+ * @return {string} Trimmed value
+ * @return {error} A *FieldError on the "custom" field if there is no "=" or no key
+ */
+func ParseCustomField(assignment string) (string, string, error) {
+	key, value, found := strings.Cut(assignment, customSeparator)
+	if key = strings.TrimSpace(key); !found || key == "" {
+		return "", "", &FieldError{Field: "custom", Message: fmt.Sprintf("invalid custom field %q (expected key=value)", assignment)}
+	}
+	return key, strings.TrimSpace(value), nil
+}
+
+/**
+ * NormalizeCustomFields converts custom fields to the stored format
+ *
+ * @param {map[string]string} fields - Fields as typed or imported
+ * @return {map[string]string} A new map of trimmed keys and values, without
+ *   empty ones; nil when none is left, so that a contact without custom
+ *   fields encodes without a "custom" field
+ *
+ * The map is always copied, so that changing the fields of a stored contact
+ * never changes the contact held by the directory
+ */
+func NormalizeCustomFields(fields map[string]string) map[string]string {
+	var normalized map[string]string
+	for key, value := range fields {
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || value == "" {
+			continue
+		}
+		if normalized == nil {
+			normalized = make(map[string]string, len(fields))
+		}
+		normalized[key] = value
+	}
+	return normalized
+}
+
+/**
+ * ValidateCustomFields checks the custom fields of a contact before they are stored
+ *
+ * @param {map[string]string} fields - Fields to check (nil means none and is valid)
+ * @return {error} A *FieldError on the "custom" field unless there are at
+ *   most 20 fields, whose keys have 1 to 40 letters, digits, spaces, "-" or
+ *   "_", and whose values have at most 500 characters and no control character
+ */
+func ValidateCustomFields(fields map[string]string) error {
+	if len(fields) > maxCustomFields {
+		return &FieldError{Field: "custom", Message: fmt.Sprintf("too many custom fields (%d, at most %d)", len(fields), maxCustomFields)}
+	}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		name := strings.TrimSpace(key)
+		if name == "" || len([]rune(name)) > maxCustomKey {
+			return &FieldError{Field: "custom", Message: fmt.Sprintf("invalid custom field name %q (1 to %d characters)", name, maxCustomKey)}
+		}
+		for _, r := range name {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-' && r != '_' {
+				return &FieldError{Field: "custom", Message: fmt.Sprintf("invalid custom field name %q (letters, digits, spaces, \"-\" and \"_\" only)", name)}
+			}
+		}
+		value := fields[key]
+		if len([]rune(value)) > maxCustomValue {
+			return &FieldError{Field: "custom", Message: fmt.Sprintf("invalid custom field %q: value is longer than %d characters", name, maxCustomValue)}
+		}
+		if strings.IndexFunc(value, unicode.IsControl) >= 0 {
+			return &FieldError{Field: "custom", Message: fmt.Sprintf("invalid custom field %q: value holds a line break or control character", name)}
+		}
+	}
+	return nil
+}
+
+// CustomKeys returns the names of the custom fields of a contact, sorted
+func (c Contact) CustomKeys() []string {
+	return slices.Sorted(maps.Keys(c.Custom))
+}
+
+// FormatCustomFields writes custom fields on one line, sorted by key, e.g.
+// "badge number=1234; floor=3"
+func FormatCustomFields(fields map[string]string) string {
+	parts := make([]string, 0, len(fields))
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		parts = append(parts, key+customSeparator+fields[key])
+	}
+	return strings.Join(parts, "; ")
+}
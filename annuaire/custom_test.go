@@ -0,0 +1,114 @@
+package annuaire
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestParseCustomField tests the "key=value" form of custom fields
+func TestParseCustomField(t *testing.T) {
+	key, value, err := ParseCustomField(" badge number = 12=34 ")
+	if err != nil || key != "badge number" || value != "12=34" {
+		t.Errorf("Expected badge number=12=34, got %q=%q (%v)", key, value, err)
+	}
+	if key, value, err := ParseCustomField("floor="); err != nil || key != "floor" || value != "" {
+		t.Errorf("Expected an empty value, got %q=%q (%v)", key, value, err)
+	}
+	for _, invalid := range []string{"floor", "=3", " = 3"} {
+		if _, _, err := ParseCustomField(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+// TestValidateCustomFields tests the bounds of custom fields
+func TestValidateCustomFields(t *testing.T) {
+	if err := ValidateCustomFields(map[string]string{"badge number": "1234", "Étage_2-b": "3"}); err != nil {
+		t.Errorf("Expected valid fields, got %v", err)
+	}
+	tooMany := make(map[string]string)
+	for i := range maxCustomFields + 1 {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+	for name, fields := range map[string]map[string]string{
+		"empty name":    {" ": "x"},
+		"long name":     {strings.Repeat("k", maxCustomKey+1): "x"},
+		"punctuation":   {"a/b": "x"},
+		"long value":    {"note": strings.Repeat("x", maxCustomValue+1)},
+		"line break":    {"note": "a\nb"},
+		"too many keys": tooMany,
+	} {
+		err := ValidateCustomFields(fields)
+		if fieldErr, ok := err.(*FieldError); !ok || fieldErr.Field != "custom" {
+			t.Errorf("%s: expected a custom FieldError, got %v", name, err)
+		}
+	}
+
+	if fields := NormalizeCustomFields(map[string]string{" floor ": " 3 ", "empty": " "}); !reflect.DeepEqual(fields, map[string]string{"floor": "3"}) {
+		t.Errorf("Unexpected normalized fields %v", fields)
+	}
+	if fields := NormalizeCustomFields(map[string]string{"empty": ""}); fields != nil {
+		t.Errorf("Expected nil without fields, got %v", fields)
+	}
+}
+
+// TestContactCustomFields tests that custom fields are stored, copied,
+// exported, masked and diffed
+func TestContactCustomFields(t *testing.T) {
+	dir := NewDirectory()
+	custom := map[string]string{"badge number": "1234", "floor": " 3 "}
+	if err := dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0612345678", Custom: custom}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.InsertContact(Contact{Name: "Bad", First: "X", Phone: "1", Custom: map[string]string{"a/b": "x"}}); err == nil {
+		t.Error("Expected an invalid custom field to be refused")
+	}
+
+	// The directory keeps its own copy of the fields
+	custom["floor"] = "4"
+	contact, err := dir.ResolveContact("Dupont")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contact.Custom["floor"] != "3" {
+		t.Errorf("Expected the stored floor to stay 3, got %q", contact.Custom["floor"])
+	}
+	if keys := contact.CustomKeys(); !slices.Equal(keys, []string{"badge number", "floor"}) {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+
+	var buf bytes.Buffer
+	if err := dir.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"custom": {`) {
+		t.Errorf("Custom fields missing from the export:\n%s", buf.String())
+	}
+
+	masked := MaskContact(contact, []string{"custom"})
+	if masked.Custom["badge number"] != "1***" || contact.Custom["badge number"] != "1234" {
+		t.Errorf("Expected a masked copy, got %v (original %v)", masked.Custom, contact.Custom)
+	}
+	if records := RedactContacts([]Contact{contact}, []string{"custom"}); records[0]["custom"] != nil {
+		t.Errorf("Expected no custom fields in %v", records[0])
+	}
+
+	changed := contact
+	changed.Custom = map[string]string{"badge number": "1234"}
+	if fields := DiffFields(contact, changed); !slices.Equal(fields, []string{"custom"}) {
+		t.Errorf("Expected custom to differ, got %v", fields)
+	}
+	if err := dir.SaveContact(changed); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := dir.History()
+	if err != nil || len(entries) == 0 {
+		t.Fatalf("Expected history entries, got %v (%v)", entries, err)
+	}
+	if summary := entries[len(entries)-1].Summary(); !strings.Contains(summary, `custom "badge number=1234; floor=3" → "badge number=1234"`) {
+		t.Errorf("Unexpected history summary %q", summary)
+	}
+}
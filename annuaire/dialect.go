@@ -0,0 +1,585 @@
+package annuaire
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldNames lists the column (CSV) or attribute (LDIF) names a foreign address book
+// uses for each Contact field, in order of preference; matching is case-insensitive
+type FieldNames struct {
+	Last     []string // Candidates for Contact.Name
+	First    []string // Candidates for Contact.First
+	Full     []string // Display name, split when no last name is present
+	Phone    []string // Candidates for Contact.Phone, the first non-empty one wins
+	Birthday []string // Candidates for Contact.Birthday, as YYYY-MM-DD, --MM-DD or M/D/YYYY
+	Street   []string // Candidates for Contact.Address.Street
+	City     []string // Candidates for Contact.Address.City
+	Zip      []string // Candidates for Contact.Address.Zip
+	Country  []string // Candidates for Contact.Address.Country
+	Multi    string   // Separator of several values in one cell, of which the first is kept (empty: none)
+}
+
+// Dialect describes how a specific application names its exported fields
+// The same application often uses different names in its CSV and LDIF exports
+type Dialect struct {
+	Name string     // Identifier used with the -dialect flag
+	CSV  FieldNames // Column headers of CSV exports
+	LDIF FieldNames // Attribute names of LDIF exports
+}
+
+// dialects holds the known import presets indexed by name
+var dialects = map[string]Dialect{
+	// default reads files produced by this tool and standard LDAP entries
+	"default": {
+		Name: "default",
+		CSV: FieldNames{
+			Last:    []string{"name", "last name", "lastname"},
+			First:   []string{"first", "first name", "firstname"},
+			Phone:   []string{"phone", "telephone"},
+			Street:  []string{"street", "address"},
+			City:    []string{"city"},
+			Zip:     []string{"zip", "postal code", "postcode"},
+			Country: []string{"country"},
+		},
+		LDIF: standardLDIF,
+	},
+	// thunderbird matches the "Export" menu of the Thunderbird address book
+	"thunderbird": {
+		Name: "thunderbird",
+		CSV: FieldNames{
+			Last:    []string{"Last Name"},
+			First:   []string{"First Name"},
+			Full:    []string{"Display Name"},
+			Phone:   []string{"Mobile Number", "Work Phone", "Home Phone"},
+			Street:  []string{"Home Address", "Work Address"},
+			City:    []string{"Home City", "Work City"},
+			Zip:     []string{"Home ZipCode", "Work ZipCode"},
+			Country: []string{"Home Country", "Work Country"},
+		},
+		LDIF: FieldNames{
+			Last:    []string{"sn"},
+			First:   []string{"givenName"},
+			Full:    []string{"cn", "displayName"},
+			Phone:   []string{"mobile", "telephoneNumber", "homePhone"},
+			Street:  []string{"mozillaHomeStreet", "street"},
+			City:    []string{"mozillaHomeLocalityName", "l"},
+			Zip:     []string{"mozillaHomePostalCode", "postalCode"},
+			Country: []string{"mozillaHomeCountryName", "c"},
+		},
+	},
+	// google matches the CSV export of Google Contacts, in its current layout
+	// (First Name, Last Name) and the older one (Given Name, Family Name)
+	"google": {
+		Name: "google",
+		CSV: FieldNames{
+			Last:     []string{"Last Name", "Family Name"},
+			First:    []string{"First Name", "Given Name"},
+			Full:     []string{"Name", "File As"},
+			Phone:    []string{"Phone 1 - Value", "Phone 2 - Value", "Phone 3 - Value"},
+			Birthday: []string{"Birthday"},
+			Street:   []string{"Address 1 - Street"},
+			City:     []string{"Address 1 - City"},
+			Zip:      []string{"Address 1 - Postal Code"},
+			Country:  []string{"Address 1 - Country"},
+			Multi:    " ::: ",
+		},
+		LDIF: standardLDIF,
+	},
+	// outlook matches the CSV export of Outlook and Outlook.com
+	"outlook": {
+		Name: "outlook",
+		CSV: FieldNames{
+			Last:     []string{"Last Name"},
+			First:    []string{"First Name"},
+			Full:     []string{"Name", "Display Name"},
+			Phone:    []string{"Mobile Phone", "Primary Phone", "Home Phone", "Business Phone", "Other Phone"},
+			Birthday: []string{"Birthday"},
+			Street:   []string{"Home Street", "Business Street"},
+			City:     []string{"Home City", "Business City"},
+			Zip:      []string{"Home Postal Code", "Business Postal Code"},
+			Country:  []string{"Home Country/Region", "Business Country/Region"},
+		},
+		LDIF: standardLDIF,
+	},
+}
+
+// standardLDIF names the attributes of standard LDAP person entries, for
+// the dialects of applications that only export CSV
+var standardLDIF = FieldNames{
+	Last:    []string{"sn", "surname"},
+	First:   []string{"givenName", "gn"},
+	Full:    []string{"cn", "displayName"},
+	Phone:   []string{"telephoneNumber", "mobile", "homePhone"},
+	Street:  []string{"street"},
+	City:    []string{"l"},
+	Zip:     []string{"postalCode"},
+	Country: []string{"c", "co"},
+}
+
+// csvPresets maps the import formats naming a CSV export to their dialect
+var csvPresets = map[string]string{
+	"google-csv":      "google",
+	"outlook-csv":     "outlook",
+	"thunderbird-csv": "thunderbird",
+}
+
+// ldifMetadata lists LDIF attributes describing the entry rather than the person
+var ldifMetadata = map[string]bool{
+	"dn":                 true,
+	"objectclass":        true,
+	"modifytimestamp":    true,
+	"changetype":         true,
+	"version":            true,
+	"createtimestamp":    true,
+	"entryuuid":          true,
+	"structuralclass":    true,
+	"mozillausehtmlmail": true,
+}
+
+/**
+ * LookupDialect returns the import preset registered under a name
+ *
+ * @param {string} name - Dialect identifier (empty string selects "default")
+ * @return {Dialect} The matching preset
+ * @return {error} Returns an error listing the known dialects if the name is unknown
+ */
+func LookupDialect(name string) (Dialect, error) {
+	if name == "" {
+		name = "default"
+	}
+	dialect, ok := dialects[strings.ToLower(name)]
+	if !ok {
+		return Dialect{}, errorOf(ErrInvalidInput, "unknown dialect %q (available: %s)", name, strings.Join(DialectNames(), ", "))
+	}
+	return dialect, nil
+}
+
+/**
+ * DialectNames returns the names of every registered import preset
+ *
+ * @return {[]string} Sorted dialect names
+ */
+func DialectNames() []string {
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/**
+ * LookupCSVPreset returns the dialect of an import format naming a CSV export
+ *
+ * @param {string} format - Import format such as "google-csv" or "outlook-csv"
+ *   (see CSVPresetNames), case-insensitive
+ * @return {Dialect} The dialect reading the columns of that export
+ * @return {bool} False when format is not a CSV preset, e.g. "json"
+ */
+func LookupCSVPreset(format string) (Dialect, bool) {
+	name, ok := csvPresets[strings.ToLower(format)]
+	if !ok {
+		return Dialect{}, false
+	}
+	return dialects[name], true
+}
+
+/**
+ * CSVPresetNames returns the import formats accepted by LookupCSVPreset
+ *
+ * @return {[]string} Sorted format names, for help texts and forms
+ */
+func CSVPresetNames() []string {
+	names := make([]string, 0, len(csvPresets))
+	for name := range csvPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/**
+ * ReadCSV parses a CSV address book whose first row holds the column headers
+ *
+ * @param {io.Reader} r - CSV source (a leading UTF-8 byte order mark is tolerated)
+ * @param {Dialect} dialect - Preset describing the column names
+ * @return {[]Contact} Contacts built from rows having a name, first name and phone
+ * @return {*ImportReport} Counters for imported rows, skipped rows and ignored columns
+ * @return {error} Returns an error if the CSV is malformed or has no header
+ *
+ * Non-empty cells in columns that do not map to a Contact field are reported
+ * as unsupported, using the column header as property name
+ */
+func ReadCSV(r io.Reader, dialect Dialect) ([]Contact, *ImportReport, error) {
+	reader := csv.NewReader(bufio.NewReader(r))
+	reader.FieldsPerRecord = -1 // Thunderbird rows end with a trailing comma
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("empty CSV file")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	report := newImportReport()
+	var contacts []Contact
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Index the row by lower-cased header so lookups ignore case
+		values := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(row) {
+				values[strings.ToLower(strings.TrimSpace(column))] = strings.TrimSpace(row[i])
+			}
+		}
+
+		contact, used := mapFields(values, dialect.CSV)
+		for i, column := range header {
+			key := strings.ToLower(strings.TrimSpace(column))
+			if !used[key] && i < len(row) && strings.TrimSpace(row[i]) != "" {
+				report.Unsupported[strings.TrimSpace(column)]++
+			}
+		}
+		addMapped(&contacts, report, contact)
+	}
+	return contacts, report, nil
+}
+
+/**
+ * ReadLDIF parses the person entries of an LDIF address book export
+ *
+ * @param {io.Reader} r - LDIF source with entries separated by blank lines
+ * @param {Dialect} dialect - Preset describing the attribute names
+ * @return {[]Contact} Contacts built from entries having a name, first name and phone
+ * @return {*ImportReport} Counters for imported entries, skipped entries and ignored attributes
+ * @return {error} Returns an error if the stream cannot be read or holds invalid base64
+ *
+ * Folded lines, comments and base64 values ("attr:: ...") are supported, which
+ * covers the non-ASCII names Thunderbird encodes in base64
+ */
+func ReadLDIF(r io.Reader, dialect Dialect) ([]Contact, *ImportReport, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	report := newImportReport()
+	var contacts []Contact
+	var lines []string
+
+	// flush converts the accumulated lines of one entry into a contact
+	flush := func() error {
+		if len(lines) == 0 {
+			return nil
+		}
+		values := make(map[string]string)
+		var attributes []string
+		for _, line := range lines {
+			name, value, err := splitLDIFLine(line)
+			if err != nil {
+				return err
+			}
+			key := strings.ToLower(name)
+			if _, seen := values[key]; !seen {
+				values[key] = value
+				attributes = append(attributes, name)
+			}
+		}
+		lines = nil
+
+		// Entries without a dn are version lines or stray comments
+		if _, ok := values["dn"]; !ok {
+			return nil
+		}
+
+		contact, used := mapFields(values, dialect.LDIF)
+		for _, name := range attributes {
+			key := strings.ToLower(name)
+			if !used[key] && !ldifMetadata[key] && values[key] != "" {
+				report.Unsupported[name]++
+			}
+		}
+		addMapped(&contacts, report, contact)
+		return nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		switch {
+		case line == "":
+			if err := flush(); err != nil {
+				return nil, nil, err
+			}
+		case strings.HasPrefix(line, "#"):
+			// Comment line
+		case strings.HasPrefix(line, " ") && len(lines) > 0:
+			lines[len(lines)-1] += line[1:]
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, nil, err
+	}
+	return contacts, report, nil
+}
+
+/**
+ * ImportFromCSV imports contacts from a CSV address book and replaces current data
+ *
+ * @param {string} filename - Path to the CSV file
+ * @param {string} dialect - Name of the column preset (e.g. "thunderbird")
+ * @return {*ImportReport} Summary of imported, skipped and unsupported data
+ * @return {error} Returns an error if the dialect is unknown or the file cannot be parsed
+ */
+func (d *Directory) ImportFromCSV(filename, dialect string) (*ImportReport, error) {
+	return d.importWith(filename, dialect, ReadCSV)
+}
+
+/**
+ * ImportFromLDIF imports contacts from an LDIF address book and replaces current data
+ *
+ * @param {string} filename - Path to the LDIF file
+ * @param {string} dialect - Name of the attribute preset (e.g. "thunderbird")
+ * @return {*ImportReport} Summary of imported, skipped and unsupported data
+ * @return {error} Returns an error if the dialect is unknown or the file cannot be parsed
+ */
+func (d *Directory) ImportFromLDIF(filename, dialect string) (*ImportReport, error) {
+	return d.importWith(filename, dialect, ReadLDIF)
+}
+
+/**
+ * ReadContactsFile reads contacts from any supported import format
+ *
+ * @param {string} filename - Source file; its extension selects the reader
+ * @param {string} dialect - Column/attribute preset used for CSV and LDIF sources
+ * @return {[]Contact} Contacts read from the file (the directory is not modified)
+ * @return {*ImportReport} Import summary (foreign formats only, nil for JSON)
+ * @return {error} Returns an error if the file cannot be read or parsed
+ *
+ * Supported extensions:
+ * - .vcf, .vcard, .abbu, .zip: vCard and macOS Contacts archives
+ * - .csv: CSV address book read with the dialect
+ * - .ldif, .ldi: LDIF address book read with the dialect
+ * - .yaml, .yml, .xml, or any extension of RegisterImportDecoder: that format
+ * - anything else: JSON document written by ExportToJSON
+ *
+ * A name ending with ".gz" is decompressed first and read by the extension
+ * before it, e.g. backup.json.gz as JSON
+ */
+func ReadContactsFile(filename, dialectName string) ([]Contact, *ImportReport, error) {
+	if IsCompressedName(filename) {
+		file, err := os.Open(filename)
+		if os.IsNotExist(err) {
+			return nil, nil, errors.New("file not found")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		defer file.Close()
+		return ReadContacts(file, filename, dialectName)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".vcf", ".vcard", ".abbu", ".zip":
+		return ReadVCardFile(filename)
+	case ".csv":
+		return readFileWith(filename, dialectName, ReadCSV)
+	case ".ldif", ".ldi":
+		return readFileWith(filename, dialectName, ReadLDIF)
+	default:
+		if format := FormatForName(filename); format != "" && format != "json" {
+			return readFileWith(filename, dialectName, func(r io.Reader, _ Dialect) ([]Contact, *ImportReport, error) {
+				return ReadContacts(r, filename, dialectName)
+			})
+		}
+		contacts, err := ReadJSONFile(filename)
+		return contacts, nil, err
+	}
+}
+
+/**
+ * ReadContacts reads contacts from a stream in any supported import format
+ *
+ * @param {io.Reader} r - Content of the source, e.g. an uploaded file
+ * @param {string} filename - Name of the source; its extension selects the
+ *   reader like ReadContactsFile, and gzip-compressed content is detected
+ * @param {string} dialect - Column/attribute preset used for CSV and LDIF sources
+ * @return {[]Contact} Contacts read from the stream (no directory is modified)
+ * @return {*ImportReport} Import summary (foreign formats only, nil for JSON)
+ * @return {error} Returns an error if the stream cannot be read or parsed
+ *
+ * A zipped archive is read into memory, since zip entries are found from the
+ * end of the archive; .abbu bundles are directories and need ReadContactsFile
+ */
+func ReadContacts(r io.Reader, filename, dialectName string) ([]Contact, *ImportReport, error) {
+	r, err := decompressed(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	filename = uncompressedName(filename)
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".vcf", ".vcard", ".abbu":
+		return collectVCards(func(collect func(io.Reader) error) error { return collect(r) })
+	case ".zip":
+		return readZippedVCardStream(r)
+	case ".csv", ".ldif", ".ldi":
+		dialect, err := LookupDialect(dialectName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if strings.EqualFold(filepath.Ext(filename), ".csv") {
+			return ReadCSV(r, dialect)
+		}
+		return ReadLDIF(r, dialect)
+	default:
+		contacts, err := decoderFor(filename)(r)
+		return contacts, nil, err
+	}
+}
+
+// importWith reads a file with the given reader and replaces the directory content
+func (d *Directory) importWith(filename, dialectName string, read func(io.Reader, Dialect) ([]Contact, *ImportReport, error)) (*ImportReport, error) {
+	contacts, report, err := readFileWith(filename, dialectName, read)
+	if err != nil {
+		return nil, err
+	}
+	d.replaceContacts(contacts, OpImport, fmt.Sprintf("%d contacts from %s", len(contacts), filename))
+	return report, nil
+}
+
+// readFileWith opens a file and parses it with a dialect-aware reader
+func readFileWith(filename, dialectName string, read func(io.Reader, Dialect) ([]Contact, *ImportReport, error)) ([]Contact, *ImportReport, error) {
+	dialect, err := LookupDialect(dialectName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil, errors.New("file not found")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	return read(file, dialect)
+}
+
+// mapFields fills a contact from field values using the preset names
+// It returns the set of (lower-cased) field names that were consumed
+func mapFields(values map[string]string, names FieldNames) (Contact, map[string]bool) {
+	used := make(map[string]bool)
+	pick := func(candidates []string) string {
+		for _, candidate := range candidates {
+			key := strings.ToLower(candidate)
+			value := values[key]
+			if names.Multi != "" {
+				value, _, _ = strings.Cut(value, names.Multi)
+			}
+			if value = strings.TrimSpace(value); value != "" {
+				used[key] = true
+				return value
+			}
+		}
+		return ""
+	}
+
+	contact := Contact{
+		Name:  pick(names.Last),
+		First: pick(names.First),
+		Phone: pick(names.Phone),
+		Address: Address{
+			Street:  pick(names.Street),
+			City:    pick(names.City),
+			Zip:     pick(names.Zip),
+			Country: pick(names.Country),
+		},
+	}
+	// Dates that are not full birthdays (Outlook writes "0/0/00" for none)
+	// stay unused, so that the report counts them
+	for _, candidate := range names.Birthday {
+		key := strings.ToLower(candidate)
+		if birthday, ok := csvBirthday(values[key]); ok {
+			contact.Birthday, used[key] = birthday, true
+			break
+		}
+	}
+	// Fall back on the display name, reusing the vCard FN rule
+	if contact.Name == "" {
+		finishVCard(&contact, pick(names.Full))
+	} else {
+		for _, candidate := range names.Full {
+			used[strings.ToLower(candidate)] = true
+		}
+	}
+	return contact, used
+}
+
+// addMapped appends a mapped contact or counts it as skipped when incomplete
+func addMapped(contacts *[]Contact, report *ImportReport, contact Contact) {
+	if contact.Name == "" || contact.First == "" || contact.Phone == "" {
+		report.Skipped++
+		return
+	}
+	*contacts = append(*contacts, contact)
+	report.Imported++
+}
+
+// splitLDIFLine decodes an "attribute: value" or "attribute:: base64" line
+func splitLDIFLine(line string) (string, string, error) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return "", "", fmt.Errorf("invalid LDIF line %q", line)
+	}
+	name, value := line[:colon], line[colon+1:]
+
+	if strings.HasPrefix(value, ":") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+		if err != nil {
+			return "", "", fmt.Errorf("invalid base64 value for %s: %v", name, err)
+		}
+		return name, string(bytes.TrimSpace(decoded)), nil
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// csvBirthday converts the birthday cell of a CSV export: the vCard dates of
+// Google ("1985-04-12", "--04-12") or the month/day/year of Outlook ("4/12/1985")
+func csvBirthday(value string) (string, bool) {
+	if birthday, ok := vCardBirthday(value); ok {
+		return birthday, true
+	}
+	date, err := time.Parse("1/2/2006", strings.TrimSpace(value))
+	if err != nil {
+		return "", false
+	}
+	birthday := date.Format("2006-01-02")
+	return birthday, ValidateBirthday(birthday) == nil
+}
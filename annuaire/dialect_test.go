@@ -0,0 +1,135 @@
+package annuaire
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestReadCSVThunderbird tests the Thunderbird column preset
+func TestReadCSVThunderbird(t *testing.T) {
+	data := "\ufeffFirst Name,Last Name,Display Name,Nickname,Primary Email,Work Phone,Mobile Number,\r\n" +
+		"Jean,Dupont,Jean Dupont,,jean@example.com,0123456789,0612345678,\r\n" +
+		",,ACME Support,,support@acme.test,,,\r\n"
+
+	dialect, err := LookupDialect("thunderbird")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contacts, report, err := ReadCSV(strings.NewReader(data), dialect)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(contacts) != 1 || report.Skipped != 1 {
+		t.Fatalf("Expected 1 contact and 1 skipped row, got %d and %d", len(contacts), report.Skipped)
+	}
+
+	// Mobile Number is preferred over Work Phone
+	if c := contacts[0]; c.Name != "Dupont" || c.First != "Jean" || c.Phone != "0612345678" {
+		t.Errorf("Incorrect data: %+v", c)
+	}
+	if report.Unsupported["Primary Email"] != 2 {
+		t.Errorf("Expected Primary Email to be reported twice, got %v", report.Unsupported)
+	}
+}
+
+// TestReadLDIFThunderbird tests LDIF entries including base64 values
+func TestReadLDIFThunderbird(t *testing.T) {
+	data := "dn: cn=Jean Dupont,mail=jean@example.com\n" +
+		"objectclass: top\n" +
+		"objectclass: person\n" +
+		"givenName: Jean\n" +
+		"sn: Dupont\n" +
+		"cn: Jean Dupont\n" +
+		"mail: jean@example.com\n" +
+		"telephoneNumber: 0123456789\n" +
+		"\n" +
+		"dn: cn=Helene Leger\n" +
+		"givenName:: SMOpbMOobmU=\n" +
+		"sn: L\n" +
+		" eger\n" +
+		"mobile: 0700000000\n"
+
+	dialect, _ := LookupDialect("thunderbird")
+	contacts, report, err := ReadLDIF(strings.NewReader(data), dialect)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(contacts) != 2 {
+		t.Fatalf("Expected 2 contacts, got %d", len(contacts))
+	}
+	if c := contacts[1]; c.First != "Hélène" || c.Name != "Leger" || c.Phone != "0700000000" {
+		t.Errorf("Base64 or folded value not decoded: %+v", c)
+	}
+	if report.Unsupported["mail"] != 1 || report.Unsupported["objectclass"] != 0 {
+		t.Errorf("Unexpected unsupported report: %v", report.Unsupported)
+	}
+
+	if _, err := LookupDialect("unknown"); err == nil {
+		t.Error("Expected error for unknown dialect")
+	}
+}
+
+// TestReadCSVGoogle tests both layouts of Google Contacts exports
+func TestReadCSVGoogle(t *testing.T) {
+	dialect, ok := LookupCSVPreset("google-csv")
+	if !ok {
+		t.Fatal("google-csv is not a CSV preset")
+	}
+
+	current := "First Name,Middle Name,Last Name,Birthday,Labels,Phone 1 - Label,Phone 1 - Value\n" +
+		"Jean,,Dupont,1985-04-12,* myContacts,Mobile,+33 6 12 34 56 78 ::: 01 23 45 67 89\n" +
+		"Marie,,Curie,--11-07,,Work,0700000000\n"
+	contacts, report, err := ReadCSV(strings.NewReader(current), dialect)
+	if err != nil || len(contacts) != 2 {
+		t.Fatalf("Expected 2 contacts, got %d (%v)", len(contacts), err)
+	}
+	if c := contacts[0]; c.Name != "Dupont" || c.First != "Jean" || c.Phone != "+33 6 12 34 56 78" || c.Birthday != "1985-04-12" {
+		t.Errorf("Incorrect data: %+v", c)
+	}
+	if contacts[1].Birthday != "--11-07" {
+		t.Errorf("Birthday without a year not read: %+v", contacts[1])
+	}
+	if report.Unsupported["Labels"] != 1 || report.Unsupported["Phone 1 - Label"] != 2 {
+		t.Errorf("Unexpected unsupported report: %v", report.Unsupported)
+	}
+
+	older := "Name,Given Name,Family Name,Group Membership,Phone 1 - Type,Phone 1 - Value\n" +
+		"Jean Dupont,Jean,Dupont,* My Contacts,Mobile,0612345678\n"
+	contacts, _, err = ReadCSV(strings.NewReader(older), dialect)
+	if err != nil || len(contacts) != 1 || contacts[0].Name != "Dupont" || contacts[0].First != "Jean" {
+		t.Errorf("Older layout not read: %+v (%v)", contacts, err)
+	}
+}
+
+// TestReadCSVOutlook tests the Outlook preset and its month/day/year birthdays
+func TestReadCSVOutlook(t *testing.T) {
+	dialect, _ := LookupCSVPreset("Outlook-CSV")
+	data := "First Name,Middle Name,Last Name,Business Phone,Mobile Phone,Birthday,E-mail Address\r\n" +
+		"Jean,,Dupont,0123456789,0612345678,4/12/1985,jean@example.com\r\n" +
+		"Paul,,Martin,0600000000,,0/0/00,\r\n"
+
+	contacts, report, err := ReadCSV(strings.NewReader(data), dialect)
+	if err != nil || len(contacts) != 2 {
+		t.Fatalf("Expected 2 contacts, got %d (%v)", len(contacts), err)
+	}
+	// Mobile Phone is preferred over Business Phone
+	if c := contacts[0]; c.Phone != "0612345678" || c.Birthday != "1985-04-12" {
+		t.Errorf("Incorrect data: %+v", c)
+	}
+	if c := contacts[1]; c.Phone != "0600000000" || c.Birthday != "" {
+		t.Errorf("Incorrect data: %+v", c)
+	}
+	if report.Unsupported["Birthday"] != 1 {
+		t.Errorf("The empty Outlook date should be reported, got %v", report.Unsupported)
+	}
+
+	if _, ok := LookupCSVPreset("json"); ok {
+		t.Error("json is not a CSV preset")
+	}
+	for _, name := range CSVPresetNames() {
+		if preset, ok := LookupCSVPreset(name); !ok || preset.Name == "" {
+			t.Errorf("Preset %s has no dialect", name)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package annuaire
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ContactChange is a contact present in both directories with different values
+type ContactChange struct {
+	Before Contact  // Contact of the current directory
+	After  Contact  // Same contact in the other directory
+	Fields []string // Names of the fields that differ, e.g. "phone" (see DiffFields)
+}
+
+// DirectoryDiff describes how another copy differs from the current directory
+type DirectoryDiff struct {
+	Added   []Contact       // Only in the other directory
+	Removed []Contact       // Only in the current directory
+	Changed []ContactChange // In both, with different values
+}
+
+// IsZero reports whether both directories hold the same contacts
+func (d DirectoryDiff) IsZero() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String summarizes the diff, e.g. "2 added, 1 removed, 3 changed"
+func (d DirectoryDiff) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+/**
+ * DiffFields lists the fields that differ between two versions of a contact
+ *
+ * @param {Contact} a - First version
+ * @param {Contact} b - Second version
+ * @return {[]string} JSON names of the differing fields, in the order of
+ *   Contact; the ID is left out, as two copies number contacts independently
+ */
+func DiffFields(a, b Contact) []string {
+	var fields []string
+	for _, field := range []struct {
+		name   string
+		differ bool
+	}{
+		{"name", a.Name != b.Name},
+		{"first", a.First != b.First},
+		{"phone", a.Phone != b.Phone},
+		{"birthday", a.Birthday != b.Birthday},
+		{"address", a.Address != b.Address},
+		{"code", a.Code != b.Code},
+		{"verify_by", a.VerifyBy != b.VerifyBy},
+		{"photo", a.Photo != b.Photo},
+		{"tags", !slices.Equal(a.Tags, b.Tags)},
+		{"custom", !maps.Equal(a.Custom, b.Custom)},
+	} {
+		if field.differ {
+			fields = append(fields, field.name)
+		}
+	}
+	return fields
+}
+
+/**
+ * DiffContacts compares two lists of contacts
+ *
+ * @param {[]Contact} current - Contacts of the current directory
+ * @param {[]Contact} other - Contacts of the other copy, e.g. read with ReadContactsFile
+ * @return {DirectoryDiff} Added, removed and changed contacts, in ID order
+ *
+ * Contacts are paired by name and phone (the directory key) first. The ones
+ * left are paired when they have the same first and last name, ignoring
+ * case, preferring the same ID: that is how a new phone number shows up as a
+ * change rather than a removal and an addition
+ */
+func DiffContacts(current, other []Contact) DirectoryDiff {
+	current, other = slices.Clone(current), slices.Clone(other)
+	slices.SortFunc(current, compareCreated)
+	slices.SortFunc(other, compareCreated)
+
+	// pair[i] is the index in other of the contact paired with current[i], or -1
+	pair := make([]int, len(current))
+	paired := make([]bool, len(other))
+	byKey := make(map[string]int, len(current))
+	for i, c := range current {
+		pair[i] = -1
+		byKey[contactKey(c)] = i
+	}
+	for j, c := range other {
+		if i, ok := byKey[contactKey(c)]; ok && pair[i] < 0 {
+			pair[i], paired[j] = j, true
+		}
+	}
+	sameName := func(a, b Contact) bool {
+		return strings.EqualFold(a.Name, b.Name) && strings.EqualFold(a.First, b.First)
+	}
+	for _, sameID := range []bool{true, false} {
+		for i, c := range current {
+			if pair[i] >= 0 {
+				continue
+			}
+			for j, o := range other {
+				if !paired[j] && sameName(c, o) && (!sameID || c.ID == o.ID) {
+					pair[i], paired[j] = j, true
+					break
+				}
+			}
+		}
+	}
+
+	var diff DirectoryDiff
+	for i, c := range current {
+		if pair[i] < 0 {
+			diff.Removed = append(diff.Removed, c)
+		} else if fields := DiffFields(c, other[pair[i]]); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, ContactChange{Before: c, After: other[pair[i]], Fields: fields})
+		}
+	}
+	for j, o := range other {
+		if !paired[j] {
+			diff.Added = append(diff.Added, o)
+		}
+	}
+	return diff
+}
+
+// compareCreated orders contacts like lessCreated, for slices.SortFunc
+func compareCreated(a, b Contact) int {
+	switch {
+	case lessCreated(a, b):
+		return -1
+	case lessCreated(b, a):
+		return 1
+	}
+	return 0
+}
+
+// Diff compares the directory with another copy (see DiffContacts)
+func (d *Directory) Diff(other []Contact) DirectoryDiff {
+	return DiffContacts(d.ListContacts(), other)
+}
+
+// ConflictPolicy selects the version kept by Merge when a contact differs
+type ConflictPolicy string
+
+// Conflict policies of Merge
+const (
+	KeepOurs   ConflictPolicy = "ours"   // Keep the current version of changed contacts
+	KeepTheirs ConflictPolicy = "theirs" // Take the other copy's version of changed contacts
+	KeepNewest ConflictPolicy = "newest" // Take the version changed last (UpdatedAt), ours on a tie
+)
+
+// ConflictPolicies lists the accepted policy names, for help texts
+var ConflictPolicies = []ConflictPolicy{KeepOurs, KeepTheirs, KeepNewest}
+
+/**
+ * ParseConflictPolicy converts a policy name from a flag
+ *
+ * @param {string} name - "ours", "theirs" or "newest"
+ * @return {ConflictPolicy} The policy (KeepOurs for an empty name)
+ * @return {error} Returns an error listing the valid names for anything else
+ */
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	if name == "" {
+		return KeepOurs, nil
+	}
+	for _, policy := range ConflictPolicies {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+	return "", errorOf(ErrInvalidInput, "unknown conflict policy %q (ours, theirs, newest)", name)
+}
+
+// takesTheirs reports whether Merge replaces the current version of a changed contact
+func (p ConflictPolicy) takesTheirs(change ContactChange) bool {
+	switch p {
+	case KeepTheirs:
+		return true
+	case KeepNewest:
+		return change.After.UpdatedAt.After(change.Before.UpdatedAt)
+	}
+	return false
+}
+
+/**
+ * Merge brings the contacts of another copy into the directory
+ *
+ * @param {[]Contact} other - Contacts of the other copy
+ * @param {ConflictPolicy} policy - Version kept for contacts changed in both
+ * @param {string} source - Description recorded in the history, e.g. the file name
+ * @return {ImportResult} Contacts added, updated (KeepTheirs, or KeepNewest
+ *   when theirs changed last) and left as they were despite a difference
+ *   (KeepOurs, an older version of theirs, or a name and phone already
+ *   used by another contact; counted as skipped), and versions of theirs
+ *   refused by ValidateContact (counted as invalid)
+ *
+ * Merging never deletes nor overwrites a contact by accident: without a
+ * common ancestor, a contact missing from the other copy may just as well be
+ * new here, and only the contact a change belongs to is replaced. The other
+ * copy is remote data (see /api/sync): its contacts are checked with the
+ * rules of InsertContact before being stored. Added contacts get a fresh
+ * ID when theirs is taken, and lose a speed-dial code already in use, like
+ * ImportContacts; updated ones keep their current ID
+ */
+func (d *Directory) Merge(other []Contact, policy ConflictPolicy, source string) ImportResult {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := make([]Contact, 0, len(d.contacts))
+	for _, contact := range d.contacts {
+		current = append(current, contact)
+	}
+	diff := DiffContacts(current, other)
+
+	var result ImportResult
+	for _, contact := range diff.Added {
+		if ValidateContact(contact) != nil {
+			result.Invalid++
+			continue
+		}
+		if _, exists := d.contacts[contactKey(contact)]; exists {
+			result.Skipped++
+			continue
+		}
+		contact.Tags = NormalizeTags(contact.Tags)
+		contact.Custom = NormalizeCustomFields(contact.Custom)
+		if _, taken := d.keyOfLocked(contact.ID); contact.ID <= 0 || taken {
+			contact.ID = d.nextID
+		}
+		d.nextID = max(d.nextID, contact.ID+1)
+		if d.checkCodeLocked(contact.Code, contact.ID) != nil {
+			contact.Code = ""
+		}
+		d.putLocked(contact)
+		d.emitLocked(OnImport, contact)
+		result.Added++
+	}
+	for _, change := range diff.Changed {
+		if !policy.takesTheirs(change) {
+			result.Skipped++
+			continue
+		}
+		contact := change.After
+		contact.ID = change.Before.ID
+		if ValidateContact(contact) != nil {
+			result.Invalid++
+			continue
+		}
+		contact.Tags = NormalizeTags(contact.Tags)
+		contact.Custom = NormalizeCustomFields(contact.Custom)
+		// A new name or phone must not overwrite another current contact
+		if existing, exists := d.contacts[contactKey(contact)]; exists && existing.ID != contact.ID {
+			result.Skipped++
+			continue
+		}
+		if d.checkCodeLocked(contact.Code, contact.ID) != nil {
+			contact.Code = change.Before.Code
+		}
+		d.removeLocked(contactKey(change.Before))
+		d.putLocked(contact)
+		d.emitLocked(OnImport, contact)
+		result.Updated++
+	}
+
+	if result.Added > 0 || result.Updated > 0 {
+		d.generation++
+		d.recordLocked(OpImport, nil, nil, fmt.Sprintf("merge (%s) from %s: %s", policy, source, result))
+	}
+	return result
+}
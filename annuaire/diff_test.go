@@ -0,0 +1,141 @@
+package annuaire
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// diverged returns two copies of a directory edited separately: here Martin
+// was removed, there Dupont changed phone, Bernard got a tag and Curie was added
+func diverged() (here, there []Contact) {
+	here = []Contact{
+		{ID: 1, Name: "Dupont", First: "Jean", Phone: "0612345678"},
+		{ID: 2, Name: "Bernard", First: "Marie", Phone: "0611111111"},
+		{ID: 3, Name: "Lefebvre", First: "Luc", Phone: "0622222222"},
+	}
+	there = []Contact{
+		{ID: 1, Name: "Dupont", First: "Jean", Phone: "0699999999"},
+		{ID: 2, Name: "Bernard", First: "Marie", Phone: "0611111111", Tags: []string{"work"}},
+		{ID: 3, Name: "Lefebvre", First: "Luc", Phone: "0622222222"},
+		{ID: 4, Name: "Martin", First: "Paul", Phone: "0633333333"},
+		{ID: 3, Name: "Curie", First: "Marie", Phone: "0700000000"},
+	}
+	return here, there
+}
+
+// TestDiffContacts tests pairing by key, then by name, across independent IDs
+func TestDiffContacts(t *testing.T) {
+	here, there := diverged()
+	diff := DiffContacts(here, there)
+
+	if len(diff.Added) != 2 || diff.Added[0].Name != "Curie" || diff.Added[1].Name != "Martin" {
+		t.Errorf("Unexpected added contacts: %+v", diff.Added)
+	}
+	if len(diff.Removed) != 0 {
+		t.Errorf("Unexpected removed contacts: %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", diff.Changed)
+	}
+	if c := diff.Changed[0]; c.Before.Name != "Dupont" || !slices.Equal(c.Fields, []string{"phone"}) {
+		t.Errorf("A new phone should be a change of Dupont: %+v", c)
+	}
+	if c := diff.Changed[1]; c.Before.Name != "Bernard" || !slices.Equal(c.Fields, []string{"tags"}) {
+		t.Errorf("Unexpected change %+v", c)
+	}
+
+	if back := DiffContacts(there, here); len(back.Removed) != 2 || len(back.Added) != 0 {
+		t.Errorf("Reversed diff should remove what was added: %s", back)
+	}
+	if !DiffContacts(here, here).IsZero() {
+		t.Error("A directory should not differ from itself")
+	}
+}
+
+// TestMerge tests every conflict policy; merging never deletes
+func TestMerge(t *testing.T) {
+	for _, policy := range ConflictPolicies {
+		here, there := diverged()
+		dir := NewDirectory()
+		dir.ReplaceContacts(here)
+		dir.AddContact("Zola", "Emile", "0644444444") // Only here: kept
+
+		result := dir.Merge(there, policy, "other.json")
+		if result.Added != 2 || dir.ContactCount() != 6 {
+			t.Errorf("%s: %s, %d contacts", policy, result, dir.ContactCount())
+		}
+		dupont, _ := dir.SearchContact("Jean")
+		switch policy {
+		case KeepOurs:
+			if result.Skipped != 2 || dupont.Phone != "0612345678" {
+				t.Errorf("ours: %s, Dupont has %s", result, dupont.Phone)
+			}
+		case KeepTheirs:
+			if result.Updated != 2 || dupont.Phone != "0699999999" || dupont.ID != 1 {
+				t.Errorf("theirs: %s, Dupont is %+v", result, dupont)
+			}
+		case KeepNewest: // Undated on both sides: a tie, so ours wins
+			if result.Skipped != 2 || dupont.Phone != "0612345678" {
+				t.Errorf("newest: %s, Dupont has %s", result, dupont.Phone)
+			}
+		}
+		if curie, ok := dir.SearchContact("Curie"); !ok || curie.ID == 3 {
+			t.Errorf("%s: Curie should get a fresh ID, got %+v", policy, curie)
+		}
+	}
+
+	if _, err := ParseConflictPolicy("mine"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
+
+// TestMergeNewest tests the last-write-wins policy used by sync
+func TestMergeNewest(t *testing.T) {
+	monday := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	here, there := diverged()
+	// Dupont changed there last, Bernard here
+	here[0].UpdatedAt, there[0].UpdatedAt = monday, monday.Add(time.Hour)
+	here[1].UpdatedAt, there[1].UpdatedAt = monday.Add(2*time.Hour), monday.Add(time.Hour)
+	dir := NewDirectory()
+	dir.ReplaceContacts(here)
+
+	result := dir.Merge(there, KeepNewest, "other")
+	if result.Updated != 1 || result.Skipped != 1 || result.Added != 2 {
+		t.Errorf("Unexpected result %s", result)
+	}
+	if dupont, _ := dir.SearchContact("Jean"); dupont.Phone != "0699999999" || !dupont.UpdatedAt.Equal(there[0].UpdatedAt) {
+		t.Errorf("Their newer Dupont should win, got %+v", dupont)
+	}
+	if bernard, _ := dir.SearchContact("Bernard"); len(bernard.Tags) != 0 {
+		t.Errorf("Our newer Bernard should win, got %+v", bernard)
+	}
+}
+
+// TestMergeRemoteData tests that a merge leaves out invalid contacts and
+// never overwrites a contact through an addition of the same name and phone
+func TestMergeRemoteData(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+
+	result := dir.Merge([]Contact{
+		{ID: 1, Name: "Dupont", First: "Jean", Phone: "0123456789"},
+		{ID: 7, Name: "Dupont", First: "Jeannot", Phone: "0123456789"}, // Same key again: not paired, must not overwrite
+		{ID: 8, Name: "Martin", Phone: "0698765432"},                   // No first name
+		{ID: 9, Name: "Durand", First: "Marie", Phone: "0611111111", Tags: []string{"Work"}},
+	}, KeepTheirs, "remote")
+	if result != (ImportResult{Added: 1, Skipped: 1, Invalid: 1}) || dir.ContactCount() != 2 {
+		t.Errorf("Unexpected result %+v with %d contacts", result, dir.ContactCount())
+	}
+	if dupont, _ := dir.ResolveContact("Dupont"); dupont.First != "Jean" || dupont.ID != 1 {
+		t.Errorf("Dupont was overwritten: %+v", dupont)
+	}
+	if durand, _ := dir.ResolveContact("Durand"); !slices.Equal(durand.Tags, []string{"work"}) {
+		t.Errorf("Added tags should be normalized like InsertContact, got %q", durand.Tags)
+	}
+
+	result = dir.Merge([]Contact{{ID: 1, Name: "Dupont", First: "Jean", Phone: "0123456789", Birthday: "31/02/1990"}}, KeepTheirs, "remote")
+	if result != (ImportResult{Invalid: 1}) {
+		t.Errorf("An invalid change should be left out, got %+v", result)
+	}
+}
@@ -0,0 +1,216 @@
+package annuaire
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EncryptedExtension marks exports encrypted with a passphrase, e.g. backup.json.enc
+const EncryptedExtension = ".enc"
+
+// MinPassphraseLength is the shortest passphrase accepted by Encrypt
+const MinPassphraseLength = 8
+
+// Layout of an encrypted export: magic, PBKDF2 iterations (big-endian),
+// salt and nonce, then the AES-256-GCM ciphertext; the whole header is
+// authenticated with the data, so changing any byte fails the decryption
+const (
+	encryptIterations = 600000 // PBKDF2-HMAC-SHA256 rounds, as for the administrator password
+	encryptSaltSize   = 16
+	encryptKeySize    = 32 // AES-256
+	encryptNonceSize  = 12 // Standard GCM nonce
+)
+
+// encryptMagic starts every encrypted export; the last byte is the format version
+var encryptMagic = []byte("TP1ENC\x01")
+
+// encryptHeaderSize is the number of bytes before the ciphertext
+var encryptHeaderSize = len(encryptMagic) + 4 + encryptSaltSize + encryptNonceSize
+
+// ErrWrongPassphrase is returned when an encrypted export cannot be opened:
+// the passphrase is wrong, or the file was modified
+var ErrWrongPassphrase = errors.New("wrong passphrase or damaged file")
+
+/**
+ * Encrypt protects data with a passphrase
+ *
+ * @param {[]byte} data - Export to protect, e.g. the output of ExportJSON
+ * @param {string} passphrase - Secret of at least MinPassphraseLength characters
+ * @return {[]byte} Encrypted export, readable with Decrypt and the same passphrase
+ * @return {error} Returns an error for a short passphrase
+ *
+ * The key is derived from the passphrase with PBKDF2 and a random salt, and
+ * the data is sealed with AES-256-GCM, so the file can be stored on a cloud
+ * drive: without the passphrase it tells nothing but its size
+ */
+func Encrypt(data []byte, passphrase string) ([]byte, error) {
+	if len(passphrase) < MinPassphraseLength {
+		return nil, fmt.Errorf("passphrase must have at least %d characters", MinPassphraseLength)
+	}
+
+	header := make([]byte, encryptHeaderSize)
+	n := copy(header, encryptMagic)
+	binary.BigEndian.PutUint32(header[n:], encryptIterations)
+	if _, err := rand.Read(header[n+4:]); err != nil { // Salt and nonce
+		return nil, err
+	}
+
+	aead, err := newExportCipher(header, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := header[encryptHeaderSize-encryptNonceSize:]
+	return aead.Seal(header, nonce, data, header), nil
+}
+
+/**
+ * Decrypt opens an export protected by Encrypt
+ *
+ * @param {[]byte} data - Encrypted export
+ * @param {string} passphrase - Passphrase given to Encrypt
+ * @return {[]byte} Original export
+ * @return {error} ErrWrongPassphrase if the passphrase does not match or the
+ *   data was modified, or an error if data is not an encrypted export
+ */
+func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	if !IsEncrypted(data) || len(data) < encryptHeaderSize {
+		return nil, errors.New("not an encrypted export")
+	}
+	header := data[:encryptHeaderSize]
+	aead, err := newExportCipher(header, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nonce := header[encryptHeaderSize-encryptNonceSize:]
+	plain, err := aead.Open(nil, nonce, data[encryptHeaderSize:], header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// newExportCipher derives the AES-GCM cipher of an encrypted export from the
+// iterations and salt of its header
+func newExportCipher(header []byte, passphrase string) (cipher.AEAD, error) {
+	n := len(encryptMagic)
+	// Bound the rounds read from the file, so that a forged header cannot
+	// keep the import busy for hours
+	iterations := int(binary.BigEndian.Uint32(header[n:]))
+	if iterations < 1 || iterations > 10*encryptIterations {
+		return nil, errors.New("invalid encrypted export header")
+	}
+	salt := header[n+4 : n+4+encryptSaltSize]
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, encryptKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsEncryptedName reports whether a file name asks for an encrypted export
+func IsEncryptedName(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), EncryptedExtension)
+}
+
+// decryptedName drops the encryption extension, so that "contacts.json.enc"
+// is read as JSON once decrypted
+func decryptedName(filename string) string {
+	if IsEncryptedName(filename) {
+		return filename[:len(filename)-len(EncryptedExtension)]
+	}
+	return filename
+}
+
+// IsEncrypted reports whether data starts like an export protected by Encrypt
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptMagic)
+}
+
+/**
+ * IsEncryptedFile reports whether a file holds an export protected by Encrypt
+ *
+ * @param {string} filename - File to inspect; only its first bytes are read
+ * @return {bool} False as well when the file cannot be read, so that the
+ *   regular import reports the error
+ */
+func IsEncryptedFile(filename string) bool {
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	head := make([]byte, len(encryptMagic))
+	_, err = io.ReadFull(file, head)
+	return err == nil && IsEncrypted(head)
+}
+
+/**
+ * ReadEncrypted reads contacts from an encrypted export
+ *
+ * @param {io.Reader} r - Encrypted export, from a file or standard input
+ * @param {string} filename - Name of the source; once ".enc" is dropped, its
+ *   extension selects the reader like ReadContacts
+ * @param {string} dialect - Column/attribute preset used for CSV and LDIF sources
+ * @param {string} passphrase - Passphrase given to Encrypt
+ * @return {[]Contact} Contacts read from the decrypted export
+ * @return {*ImportReport} Import summary (foreign formats only, nil for JSON)
+ * @return {error} ErrWrongPassphrase, or an error if the export cannot be read
+ */
+func ReadEncrypted(r io.Reader, filename, dialect, passphrase string) ([]Contact, *ImportReport, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := Decrypt(data, passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ReadContacts(bytes.NewReader(plain), decryptedName(filename), dialect)
+}
+
+/**
+ * ExportEncrypted writes the directory to a file through a pipeline, encrypted
+ *
+ * @param {string} filename - Destination file, written through the directory
+ *   storage; compressed before encryption when the name ends with ".gz.enc"
+ * @param {ExportPipeline} pipeline - Stages to apply
+ * @param {string} passphrase - Secret needed to import the file again
+ * @return {error} Returns an error for a short passphrase, or if encoding or
+ *   writing fails
+ *
+ * Usage:
+ *   err := dir.ExportEncrypted("backup.json.enc", ExportPipeline{Encoder: EncodeJSON}, passphrase)
+ */
+func (d *Directory) ExportEncrypted(filename string, pipeline ExportPipeline, passphrase string) error {
+	var buf bytes.Buffer
+	if err := pipeline.Run(d.ListContacts(), &buf); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if IsCompressedName(decryptedName(filename)) {
+		var err error
+		if data, err = gzipBytes(data); err != nil {
+			return err
+		}
+	}
+	sealed, err := Encrypt(data, passphrase)
+	if err != nil {
+		return err
+	}
+	return d.currentStorage().WriteFile(filename, sealed)
+}
@@ -0,0 +1,70 @@
+package annuaire
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestEncryptRoundTrip tests that only the right passphrase opens an unmodified export
+func TestEncryptRoundTrip(t *testing.T) {
+	plain := []byte(`[{"name": "Dupont", "first": "Jean", "phone": "0612345678"}]`)
+	sealed, err := Encrypt(plain, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || bytes.Contains(sealed, []byte("Dupont")) {
+		t.Fatal("Encrypted export should hide its content")
+	}
+
+	if got, err := Decrypt(sealed, "correct horse"); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+	if _, err := Decrypt(sealed, "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := Decrypt(tampered, "correct horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected ErrWrongPassphrase for a modified file, got %v", err)
+	}
+
+	if _, err := Encrypt(plain, "short"); err == nil {
+		t.Error("Expected an error for a short passphrase")
+	}
+	if _, err := Decrypt(plain, "correct horse"); err == nil || errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Expected a format error for a plain export, got %v", err)
+	}
+}
+
+// TestExportEncrypted tests an encrypted, compressed backup read back by name
+func TestExportEncrypted(t *testing.T) {
+	source := NewDirectory()
+	source.AddContact("Dupont", "Jean", "0612345678")
+	source.AddContact("Martin", "Paul", "0698765432")
+
+	filename := filepath.Join(t.TempDir(), "backup.json.gz.enc")
+	if err := source.ExportEncrypted(filename, ExportPipeline{Encoder: EncodeJSON}, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncryptedFile(filename) {
+		t.Fatal("Export is not encrypted")
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	contacts, _, err := ReadEncrypted(file, filename, "", "correct horse")
+	if err != nil || len(contacts) != 2 {
+		t.Errorf("ReadEncrypted: %d contacts, %v", len(contacts), err)
+	}
+
+	if _, _, err := ReadEncrypted(strings.NewReader("[]"), "backup.json", "", "correct horse"); err == nil {
+		t.Error("Expected an error for a plain export")
+	}
+}
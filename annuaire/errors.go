@@ -0,0 +1,87 @@
+package annuaire
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the directory, to be tested with errors.Is; the actual
+// errors often carry a more precise message, e.g. the reference that did not
+// match or the field that is invalid
+var (
+	ErrContactNotFound  = errors.New("contact not found")                                 // No contact matches the ID, name or reference
+	ErrDuplicateContact = errors.New("a contact with this name and phone already exists") // The name and phone pair is taken
+	ErrCodeInUse        = errors.New("speed-dial code already used")                      // Another contact has the code
+	ErrInvalidInput     = errors.New("invalid input")                                     // A value is missing or malformed (see FieldError)
+	ErrAmbiguous        = errors.New("ambiguous reference")                               // Several contacts match (see AmbiguousReferenceError)
+)
+
+// FieldError reports an invalid contact field; it matches ErrInvalidInput
+type FieldError struct {
+	Field   string // JSON name of the field, e.g. "birthday" (see ContactFields)
+	Message string // Description shown to the user
+}
+
+// Error returns the description of the problem
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Unwrap makes errors.Is(err, ErrInvalidInput) true
+func (e *FieldError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+// kindError is an error with its own message that matches a sentinel error
+type kindError struct {
+	kind    error
+	message string
+}
+
+// Error returns the message of the error
+func (e *kindError) Error() string {
+	return e.message
+}
+
+// Unwrap returns the sentinel the error matches
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// errorOf formats a message for an error matching kind with errors.Is
+func errorOf(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, message: fmt.Sprintf(format, args...)}
+}
+
+// requireFields checks the mandatory fields of a contact
+func requireFields(c Contact) error {
+	for _, field := range []struct{ name, value string }{{"name", c.Name}, {"first", c.First}, {"phone", c.Phone}} {
+		if field.value == "" {
+			return &FieldError{Field: field.name, Message: "all fields are required"}
+		}
+	}
+	return nil
+}
+
+/**
+ * ValidateContact checks every field of a contact before it is stored
+ *
+ * @param {Contact} c - Contact to check
+ * @return {error} nil, or the *FieldError of each invalid field joined with
+ *   errors.Join, so that a form or an API client can report them all at once
+ *
+ * InsertContact and SaveContact stop at the first problem; this function
+ * applies the same rules without touching the directory, so uniqueness
+ * (name and phone, speed-dial code) is not checked
+ */
+func ValidateContact(c Contact) error {
+	var problems []error
+	for _, field := range []struct{ name, value string }{{"name", c.Name}, {"first", c.First}, {"phone", c.Phone}} {
+		if field.value == "" {
+			problems = append(problems, &FieldError{Field: field.name, Message: field.name + " is required"})
+		}
+	}
+	problems = append(problems, ValidateBirthday(c.Birthday), ValidateCode(c.Code), ValidateTags(c.Tags), ValidateAddress(c.Address),
+		ValidateCustomFields(c.Custom))
+	return errors.Join(problems...)
+}
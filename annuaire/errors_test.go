@@ -0,0 +1,67 @@
+package annuaire
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// TestSentinelErrors tests that directory errors can be told apart with errors.Is
+func TestSentinelErrors(t *testing.T) {
+	dir := NewDirectory()
+	dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0123456789", Code: "1"})
+
+	_, resolveErr := dir.ResolveContact("Martin")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"missing field", dir.InsertContact(Contact{Name: "Martin", Phone: "0600000000"}), ErrInvalidInput},
+		{"bad birthday", dir.InsertContact(Contact{Name: "Martin", First: "Paul", Phone: "0600000000", Birthday: "tomorrow"}), ErrInvalidInput},
+		{"duplicate", dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0123456789"}), ErrDuplicateContact},
+		{"code in use", dir.InsertContact(Contact{Name: "Martin", First: "Paul", Phone: "0600000000", Code: "1"}), ErrCodeInUse},
+		{"delete unknown", dir.DeleteContact("Martin"), ErrContactNotFound},
+		{"unknown reference", resolveErr, ErrContactNotFound},
+		{"unknown region", ValidateRegion("XX"), ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: %v does not match %v", tt.name, tt.err, tt.want)
+		}
+	}
+
+	// Messages stay specific, and field errors name their field
+	if resolveErr.Error() != `no contact matches "Martin"` {
+		t.Errorf("Unexpected message %q", resolveErr)
+	}
+	var fieldErr *FieldError
+	if err := ValidateBirthday("1990-13-01"); !errors.As(err, &fieldErr) || fieldErr.Field != "birthday" {
+		t.Errorf("Expected a birthday FieldError, got %v", err)
+	}
+	if err := dir.InsertContact(Contact{Name: "Martin", Phone: "0600000000"}); !errors.As(err, &fieldErr) || fieldErr.Field != "first" || err.Error() != "all fields are required" {
+		t.Errorf("Expected a first name FieldError, got %v", err)
+	}
+}
+
+// TestValidateContact tests that every invalid field is reported
+func TestValidateContact(t *testing.T) {
+	if err := ValidateContact(Contact{Name: "Dupont", First: "Jean", Phone: "0123456789", Code: "1"}); err != nil {
+		t.Fatalf("Valid contact refused: %v", err)
+	}
+
+	err := ValidateContact(Contact{Name: "Dupont", Birthday: "tomorrow", Code: "a-b"})
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("Expected ErrInvalidInput, got %v", err)
+	}
+	var fields []string
+	for _, problem := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fieldErr *FieldError
+		if errors.As(problem, &fieldErr) {
+			fields = append(fields, fieldErr.Field)
+		}
+	}
+	if want := []string{"first", "phone", "birthday", "code"}; !slices.Equal(fields, want) {
+		t.Errorf("Reported fields %v, want %v", fields, want)
+	}
+}
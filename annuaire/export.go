@@ -0,0 +1,267 @@
+package annuaire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ExportFilter decides whether a contact is part of an export
+type ExportFilter func(Contact) bool
+
+// ExportTransform rewrites a contact before it is encoded, e.g. to hide data
+type ExportTransform func(Contact) Contact
+
+// ExportEncoder writes the exported contacts in a file format
+type ExportEncoder func(w io.Writer, contacts []Contact) error
+
+// ExportPipeline describes an export as filter → transform → encoder → sink
+// The sink is the io.Writer given to Run: a file, an HTTP response, a buffer
+type ExportPipeline struct {
+	Filters    []ExportFilter    // Every filter must accept a contact for it to be exported
+	Transforms []ExportTransform // Applied in order to each exported contact
+	Encoder    ExportEncoder     // Output format (nil: EncodeJSON)
+}
+
+// formatRegistry holds the encoders, decoders and transforms selectable by
+// name, and the file extensions selecting a format (see codec.go)
+// Extensions register theirs from an init function, like database/sql drivers
+var formatRegistry = struct {
+	sync.RWMutex
+	encoders   map[string]ExportEncoder
+	decoders   map[string]ImportDecoder
+	transforms map[string]ExportTransform
+	extensions map[string]string // Lower-cased extension, e.g. ".yml", to format name
+}{
+	encoders: map[string]ExportEncoder{
+		"json": EncodeJSON,
+		"yaml": EncodeYAML,
+		"xml":  EncodeXML,
+		"sim":  SIMEncoder(DefaultSIMOptions(), nil),
+		"ldif": LDIFEncoder(DefaultLDIFOptions()),
+	},
+	decoders: map[string]ImportDecoder{
+		"json": ReadJSON,
+		"yaml": ReadYAML,
+		"xml":  ReadXML,
+	},
+	transforms: map[string]ExportTransform{
+		"pseudonymize":   Pseudonymize,
+		"strip-optional": StripOptional,
+	},
+	extensions: map[string]string{
+		".json": "json",
+		".yaml": "yaml",
+		".yml":  "yaml",
+		".xml":  "xml",
+		".ldif": "ldif",
+	},
+}
+
+/**
+ * RegisterExportEncoder makes an output format selectable by name
+ *
+ * @param {string} name - Format name, e.g. "xml" for the -format flag
+ * @param {ExportEncoder} encoder - Function writing the contacts
+ *
+ * Registering an existing name replaces it, so built-in formats can be overridden
+ *
+ * Usage (in a third-party package):
+ *   func init() { annuaire.RegisterExportEncoder("tsv", writeTSV) }
+ */
+func RegisterExportEncoder(name string, encoder ExportEncoder) {
+	formatRegistry.Lock()
+	defer formatRegistry.Unlock()
+	formatRegistry.encoders[strings.ToLower(name)] = encoder
+}
+
+/**
+ * RegisterExportTransform makes a transform selectable by name
+ *
+ * @param {string} name - Transform name, e.g. "crm-fields" for the -transform flag
+ * @param {ExportTransform} transform - Function rewriting each contact
+ */
+func RegisterExportTransform(name string, transform ExportTransform) {
+	formatRegistry.Lock()
+	defer formatRegistry.Unlock()
+	formatRegistry.transforms[strings.ToLower(name)] = transform
+}
+
+/**
+ * NewExportPipeline builds a pipeline from registered names
+ *
+ * @param {string} format - Encoder name (empty selects "json")
+ * @param {[]string} transforms - Transform names, applied in this order
+ * @return {ExportPipeline} Pipeline without filters, ready to Run
+ * @return {error} Returns an error listing the known names if one is unknown
+ */
+func NewExportPipeline(format string, transforms ...string) (ExportPipeline, error) {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+
+	if format == "" {
+		format = "json"
+	}
+	encoder, ok := formatRegistry.encoders[strings.ToLower(format)]
+	if !ok {
+		return ExportPipeline{}, errorOf(ErrInvalidInput, "unknown export format %q (available: %s)", format, strings.Join(sortedKeys(formatRegistry.encoders), ", "))
+	}
+
+	pipeline := ExportPipeline{Encoder: encoder}
+	for _, name := range transforms {
+		transform, ok := formatRegistry.transforms[strings.ToLower(name)]
+		if !ok {
+			return ExportPipeline{}, errorOf(ErrInvalidInput, "unknown export transform %q (available: %s)", name, strings.Join(sortedKeys(formatRegistry.transforms), ", "))
+		}
+		pipeline.Transforms = append(pipeline.Transforms, transform)
+	}
+	return pipeline, nil
+}
+
+// ExportFormatNames returns the registered encoder names, sorted
+func ExportFormatNames() []string {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+	return sortedKeys(formatRegistry.encoders)
+}
+
+// ExportTransformNames returns the registered transform names, sorted
+func ExportTransformNames() []string {
+	formatRegistry.RLock()
+	defer formatRegistry.RUnlock()
+	return sortedKeys(formatRegistry.transforms)
+}
+
+/**
+ * Run exports contacts through every stage of the pipeline
+ *
+ * @param {[]Contact} contacts - Contacts to export (not modified)
+ * @param {io.Writer} sink - Destination of the encoded data
+ * @return {error} Returns the first encoder or write error
+ *
+ * Contacts are encoded by ID, then alphabetically, whatever the order of the
+ * input, so exporting the same data twice gives identical files that can be
+ * kept under version control
+ */
+func (p ExportPipeline) Run(contacts []Contact, sink io.Writer) error {
+	ordered := slices.Clone(contacts)
+	sort.SliceStable(ordered, func(i, j int) bool { return lessCreated(ordered[i], ordered[j]) })
+
+	selected := make([]Contact, 0, len(contacts))
+	for _, contact := range ordered {
+		if p.accepts(contact) {
+			for _, transform := range p.Transforms {
+				contact = transform(contact)
+			}
+			selected = append(selected, contact)
+		}
+	}
+
+	encoder := p.Encoder
+	if encoder == nil {
+		encoder = EncodeJSON
+	}
+	return encoder(sink, selected)
+}
+
+// accepts reports whether every filter lets the contact through
+func (p ExportPipeline) accepts(c Contact) bool {
+	for _, filter := range p.Filters {
+		if !filter(c) {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * ExportWith writes the directory to a file through a pipeline
+ *
+ * @param {string} filename - Destination file, written through the directory
+ *   storage; gzip-compressed when it ends with ".gz"
+ * @param {ExportPipeline} pipeline - Stages to apply
+ * @return {error} Returns an error if encoding or writing fails
+ *
+ * The output is encoded in memory first, so a failing encoder never leaves
+ * a half-written file behind
+ */
+func (d *Directory) ExportWith(filename string, pipeline ExportPipeline) error {
+	var buf bytes.Buffer
+	if err := pipeline.Run(d.ListContacts(), &buf); err != nil {
+		return err
+	}
+	return d.writeExport(filename, buf.Bytes())
+}
+
+/**
+ * ExportContacts writes a chosen subset of contacts in a registered format
+ *
+ * @param {[]Contact} contacts - Contacts to export, e.g. the result of a search
+ * @param {string} format - Encoder name (see ExportFormatNames; empty selects "json")
+ * @param {io.Writer} w - Destination: a file, an HTTP response, a buffer
+ * @return {error} Returns an error if the format is unknown or encoding fails
+ *
+ * Usage:
+ *   matches := dir.Query(ctx, SearchQuery{Tag: "work"}, limits).Contacts
+ *   err := dir.ExportContacts(matches, "json", file)
+ */
+func (d *Directory) ExportContacts(contacts []Contact, format string, w io.Writer) error {
+	pipeline, err := NewExportPipeline(format)
+	if err != nil {
+		return err
+	}
+	return pipeline.Run(contacts, w)
+}
+
+/**
+ * ExportMatching returns a filter keeping the contacts of a search
+ *
+ * @param {string} text - Text contained in the name, first name or phone,
+ *   ignoring case (see MatchesQuery); empty keeps every contact
+ * @param {string} tag - Tag the contacts must carry; empty keeps every contact
+ * @return {ExportFilter} Filter for ExportPipeline.Filters
+ */
+func ExportMatching(text, tag string) ExportFilter {
+	return func(c Contact) bool {
+		return c.MatchesQuery(text) && (tag == "" || c.HasTag(tag))
+	}
+}
+
+// EncodeJSON writes contacts as the indented, versioned JSON document read by
+// ImportFromJSON (see Envelope)
+func EncodeJSON(w io.Writer, contacts []Contact) error {
+	if contacts == nil {
+		contacts = []Contact{} // An empty directory exports "contacts": [], not null
+	}
+	return writeEnvelope(w, contacts)
+}
+
+// Pseudonymize replaces the names with a stable pseudonym such as "Contact 3f2a9c"
+// The same person gets the same pseudonym in every export, so exports can
+// still be compared; phone numbers are kept
+func Pseudonymize(c Contact) Contact {
+	sum := sha256.Sum256([]byte(strings.ToLower(c.First + "\x00" + c.Name)))
+	c.Name = "Contact " + hex.EncodeToString(sum[:3])
+	c.First = "Anonymous"
+	return c
+}
+
+// StripOptional removes every optional field (birthday, code, verification date)
+func StripOptional(c Contact) Contact {
+	return Contact{ID: c.ID, Name: c.Name, First: c.First, Phone: c.Phone}
+}
+
+// sortedKeys returns the keys of a registry map in alphabetical order
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
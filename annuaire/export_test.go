@@ -0,0 +1,118 @@
+package annuaire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestExportPipeline tests filters, transforms and a registered encoder
+func TestExportPipeline(t *testing.T) {
+	RegisterExportEncoder("test-lines", func(w io.Writer, contacts []Contact) error {
+		for _, c := range contacts {
+			fmt.Fprintf(w, "%s %s %s\n", c.First, c.Name, c.Birthday)
+		}
+		return nil
+	})
+	RegisterExportTransform("test-upper", func(c Contact) Contact {
+		c.Name = strings.ToUpper(c.Name)
+		return c
+	})
+
+	pipeline, err := NewExportPipeline("test-lines", "strip-optional", "test-upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pipeline.Filters = append(pipeline.Filters, func(c Contact) bool { return c.Phone != "" })
+
+	contacts := []Contact{
+		{Name: "Dupont", First: "Jean", Phone: "0123", Birthday: "1985-04-12"},
+		{Name: "Martin", First: "Paul"},
+	}
+	var out bytes.Buffer
+	if err := pipeline.Run(contacts, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Jean DUPONT \n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+	if contacts[0].Name != "Dupont" {
+		t.Error("Run modified the input contacts")
+	}
+
+	if _, err := NewExportPipeline("json", "unknown"); err == nil || !strings.Contains(err.Error(), "pseudonymize") {
+		t.Errorf("Expected an error listing the transforms, got %v", err)
+	}
+	if a, b := Pseudonymize(contacts[0]), Pseudonymize(contacts[0]); !reflect.DeepEqual(a, b) || a.Name == "Dupont" {
+		t.Errorf("Pseudonyms should be stable and hide the name: %+v", a)
+	}
+}
+
+// TestExportContacts tests exporting the contacts kept by a search filter
+func TestExportContacts(t *testing.T) {
+	contacts := []Contact{
+		{ID: 1, Name: "Dupont", First: "Jean", Phone: "0612345678", Tags: []string{"work"}},
+		{ID: 2, Name: "Martin", First: "Paul", Phone: "0698765432", Tags: []string{"family"}},
+		{ID: 3, Name: "Dupond", First: "Marie", Phone: "0145678901"},
+	}
+	d := NewDirectory()
+	matches := slices.DeleteFunc(slices.Clone(contacts), func(c Contact) bool { return !ExportMatching("dupon", "")(c) })
+
+	var out bytes.Buffer
+	if err := d.ExportContacts(matches, "json", &out); err != nil {
+		t.Fatal(err)
+	}
+	if s := out.String(); !strings.Contains(s, "Dupont") || !strings.Contains(s, "Dupond") || strings.Contains(s, "Martin") {
+		t.Errorf("Unexpected export:\n%s", s)
+	}
+
+	if keep := ExportMatching("dupon", "WORK"); !keep(contacts[0]) || keep(contacts[1]) || keep(contacts[2]) {
+		t.Error("The tag should narrow the text filter")
+	}
+	if keep := ExportMatching("", ""); !keep(contacts[1]) {
+		t.Error("An empty filter should keep every contact")
+	}
+	if err := d.ExportContacts(contacts, "unknown", &out); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// TestExportIsStable tests that exports do not depend on the input order
+func TestExportIsStable(t *testing.T) {
+	// Date both runs alike, even across a second boundary
+	defer func(clock func() time.Time) { exportClock = clock }(exportClock)
+	exportClock = func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) }
+
+	contacts := []Contact{
+		{ID: 3, Name: "Martin", First: "Lucie", Phone: "3"},
+		{ID: 1, Name: "Dupont", First: "Jean", Phone: "1"},
+		{ID: 2, Name: "Bernard", First: "Marie", Phone: "2"},
+	}
+	reversed := slices.Clone(contacts)
+	slices.Reverse(reversed)
+
+	for _, format := range ExportFormatNames() {
+		pipeline, err := NewExportPipeline(format)
+		if err != nil {
+			t.Fatalf("NewExportPipeline(%s): %v", format, err)
+		}
+		var first, second bytes.Buffer
+		if err := pipeline.Run(contacts, &first); err != nil {
+			t.Fatal(err)
+		}
+		if err := pipeline.Run(reversed, &second); err != nil {
+			t.Fatal(err)
+		}
+		if first.String() != second.String() {
+			t.Errorf("%s export depends on the input order:\n%s\n---\n%s", format, first.String(), second.String())
+		}
+		if strings.Index(first.String(), "Dupont") > strings.Index(first.String(), "Bernard") {
+			t.Errorf("%s export is not sorted by ID:\n%s", format, first.String())
+		}
+	}
+}
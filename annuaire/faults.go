@@ -0,0 +1,93 @@
+package annuaire
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by FaultyStorage for the failures it simulates
+var ErrInjectedFault = errors.New("injected storage fault")
+
+// FaultyStorage wraps a Storage and simulates disk failures
+// It is meant for tests and for the -chaos development flag, to check that
+// callers report errors and never lose or corrupt the stored contacts
+type FaultyStorage struct {
+	Next              Storage       // Storage doing the real work
+	FailEveryNthWrite int           // Fail writes number N, 2N, 3N... (0: never)
+	Latency           time.Duration // Delay added before every operation
+	PartialReads      bool          // Return only the first half of every file read
+
+	mu     sync.Mutex // Guards writes
+	writes int        // Number of writes attempted so far
+}
+
+// ReadFile reads through Next, possibly slowly and truncated
+func (f *FaultyStorage) ReadFile(name string) ([]byte, error) {
+	time.Sleep(f.Latency)
+	data, err := f.Next.ReadFile(name)
+	if err != nil || !f.PartialReads {
+		return data, err
+	}
+	// Like a short read from a failing disk: no error, just missing bytes
+	return data[:len(data)/2], nil
+}
+
+// WriteFile writes through Next unless this write is scheduled to fail
+func (f *FaultyStorage) WriteFile(name string, data []byte) error {
+	time.Sleep(f.Latency)
+
+	f.mu.Lock()
+	f.writes++
+	fail := f.FailEveryNthWrite > 0 && f.writes%f.FailEveryNthWrite == 0
+	f.mu.Unlock()
+
+	if fail {
+		return fmt.Errorf("writing %s: %w", name, ErrInjectedFault)
+	}
+	return f.Next.WriteFile(name, data)
+}
+
+/**
+ * ParseFaultSpec builds a FaultyStorage from a comma-separated specification
+ *
+ * @param {string} spec - Faults such as "fail-write=3,latency=200ms,partial-read"
+ * @param {Storage} next - Storage wrapped by the result
+ * @return {*FaultyStorage} Configured wrapper
+ * @return {error} Returns an error for unknown faults or invalid values
+ *
+ * Faults:
+ * - fail-write=N    every Nth write fails with ErrInjectedFault
+ * - latency=DUR     every operation waits DUR (time.ParseDuration syntax)
+ * - partial-read    reads return the first half of the file only
+ */
+func ParseFaultSpec(spec string, next Storage) (*FaultyStorage, error) {
+	faulty := &FaultyStorage{Next: next}
+	for _, item := range strings.Split(spec, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch key {
+		case "fail-write":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid fail-write value %q (positive integer expected)", value)
+			}
+			faulty.FailEveryNthWrite = n
+		case "latency":
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid latency value %q (duration such as 200ms expected)", value)
+			}
+			faulty.Latency = d
+		case "partial-read":
+			faulty.PartialReads = true
+		case "":
+			// Tolerate empty items such as a trailing comma
+		default:
+			return nil, fmt.Errorf("unknown fault %q (fail-write, latency, partial-read)", key)
+		}
+	}
+	return faulty, nil
+}
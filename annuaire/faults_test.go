@@ -0,0 +1,62 @@
+package annuaire
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// TestFailedWriteKeepsPreviousFile tests that an injected write failure is
+// reported and leaves the last good file intact
+func TestFailedWriteKeepsPreviousFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "data", "contacts.json")
+	dir := NewDirectory()
+	dir.SetStorage(&FaultyStorage{Next: OSStorage{}, FailEveryNthWrite: 2})
+
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	if err := dir.ExportToJSON(filename); err != nil {
+		t.Fatalf("First write should succeed: %v", err)
+	}
+	dir.AddContact("Martin", "Paul", "0600000000")
+	if err := dir.ExportToJSON(filename); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Expected the injected fault, got %v", err)
+	}
+
+	contacts, err := ReadJSONFile(filename)
+	if err != nil || len(contacts) != 1 {
+		t.Errorf("Previous file damaged: %v, %d contacts", err, len(contacts))
+	}
+}
+
+// TestPartialReadDoesNotReplaceContacts tests that a truncated read is an error
+func TestPartialReadDoesNotReplaceContacts(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "contacts.json")
+	source := NewDirectory()
+	source.AddContact("Dupont", "Jean", "0123456789")
+	source.AddContact("Martin", "Paul", "0600000000")
+	if err := source.ExportToJSON(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := NewDirectory()
+	dir.AddContact("Durand", "Anne", "0700000000")
+	faulty, err := ParseFaultSpec("partial-read,latency=1ms", OSStorage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir.SetStorage(faulty)
+
+	if _, err := dir.ImportFromJSON(filename, ImportOptions{}); err == nil {
+		t.Fatal("Expected a truncated file to be rejected")
+	}
+	if dir.ContactCount() != 1 {
+		t.Errorf("Failed import modified the directory: %v", dir.ListContacts())
+	}
+
+	if _, err := ParseFaultSpec("fail-write=0", OSStorage{}); err == nil {
+		t.Error("Expected error for fail-write=0")
+	}
+	if _, err := ParseFaultSpec("explode", OSStorage{}); err == nil {
+		t.Error("Expected error for an unknown fault")
+	}
+}
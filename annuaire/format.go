@@ -0,0 +1,99 @@
+package annuaire
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FormatVersion is the version of the JSON layout written by ExportJSON and
+// the data file; ReadJSON upgrades older documents to it
+const FormatVersion = 2
+
+// Envelope is the JSON document written by ExportJSON:
+//
+//	{"version": 2, "exported_at": "2025-06-01T12:00:00Z", "contacts": [...]}
+//
+// Version 1 files are a bare array of contacts; they are still read
+type Envelope struct {
+	Version    int       `json:"version"`     // Layout of the document (see FormatVersion)
+	ExportedAt time.Time `json:"exported_at"` // When the document was written, in UTC to the second
+	Contacts   any       `json:"contacts"`    // Contacts, or redacted records (see RedactContacts)
+}
+
+// exportClock dates the envelopes; tests replace it to get identical exports
+var exportClock = time.Now
+
+// migrations[v] upgrades the contacts array of a version v document to
+// version v+1; a schema change adds its step here and bumps FormatVersion,
+// so that every older file is still read
+var migrations = map[int]func(contacts json.RawMessage) (json.RawMessage, error){
+	// Version 2 only added the envelope: the contacts array is unchanged
+	1: func(contacts json.RawMessage) (json.RawMessage, error) { return contacts, nil },
+}
+
+/**
+ * writeEnvelope writes records as an indented version FormatVersion document
+ *
+ * @param {io.Writer} w - Destination
+ * @param {any} contacts - Contacts or redacted records, already sorted
+ * @return {error} Returns an error if JSON encoding or writing fails
+ */
+func writeEnvelope(w io.Writer, contacts any) error {
+	data, err := json.MarshalIndent(Envelope{
+		Version:    FormatVersion,
+		ExportedAt: exportClock().UTC().Truncate(time.Second),
+		Contacts:   contacts,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+/**
+ * DecodeContacts parses a JSON export of any version
+ *
+ * @param {[]byte} data - Envelope written by ExportJSON, or the bare array of
+ *   version 1 files
+ * @return {[]Contact} Contacts, upgraded to the current layout
+ * @return {error} Returns an error for malformed JSON, or for a document
+ *   written by a newer version of the application
+ *
+ * Usage:
+ *   contacts, err := annuaire.DecodeContacts(data)
+ */
+func DecodeContacts(data []byte) ([]Contact, error) {
+	version, raw := 1, json.RawMessage(data)
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var envelope struct {
+			Version  int             `json:"version"`
+			Contacts json.RawMessage `json:"contacts"`
+		}
+		if err := json.Unmarshal(trimmed, &envelope); err != nil {
+			return nil, err
+		}
+		version, raw = envelope.Version, envelope.Contacts
+	}
+
+	if err := checkVersion(version); err != nil {
+		return nil, err
+	}
+	for ; version < FormatVersion; version++ {
+		var err error
+		if raw, err = migrations[version](raw); err != nil {
+			return nil, fmt.Errorf("upgrading contacts file from version %d: %w", version, err)
+		}
+	}
+
+	var contacts []Contact
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &contacts); err != nil {
+			return nil, err
+		}
+	}
+	return contacts, nil
+}
@@ -0,0 +1,49 @@
+package annuaire
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestDecodeContactsVersions tests reading the current envelope and the bare arrays of version 1
+func TestDecodeContactsVersions(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0612345678")
+	var buf bytes.Buffer
+	if err := dir.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var envelope Envelope
+	if err := json.Unmarshal(buf.Bytes(), &envelope); err != nil || envelope.Version != FormatVersion || envelope.ExportedAt.IsZero() {
+		t.Fatalf("Unexpected envelope %+v (%v):\n%s", envelope, err, buf.String())
+	}
+
+	for name, source := range map[string]string{
+		"current":   buf.String(),
+		"version 1": `[{"name": "Dupont", "first": "Jean", "phone": "0612345678"}]`,
+	} {
+		contacts, err := DecodeContacts([]byte(source))
+		if err != nil || len(contacts) != 1 || contacts[0].Name != "Dupont" {
+			t.Errorf("%s: %+v, %v", name, contacts, err)
+		}
+	}
+
+	if _, err := DecodeContacts([]byte(`{"version": 99, "contacts": []}`)); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Expected an error for a newer version, got %v", err)
+	}
+	if _, err := DecodeContacts([]byte(`{"contacts": []}`)); err == nil {
+		t.Error("Expected an error for a document without version")
+	}
+
+	// An empty directory exports an empty list, which reads back as no contacts
+	buf.Reset()
+	NewDirectory().ExportJSON(&buf)
+	if !strings.Contains(buf.String(), `"contacts": []`) {
+		t.Errorf("Empty export should hold an empty list:\n%s", buf.String())
+	}
+	if contacts, err := DecodeContacts(buf.Bytes()); err != nil || len(contacts) != 0 {
+		t.Errorf("Empty export: %+v, %v", contacts, err)
+	}
+}
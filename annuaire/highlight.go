@@ -0,0 +1,98 @@
+package annuaire
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Match locates the searched text in one field of a contact, for highlighting
+type Match struct {
+	Field string // "name", "first" or "phone"
+	Start int    // Byte offset of the matched text in the field
+	End   int    // Byte offset just after the matched text
+}
+
+// searchedFields are the fields a search term is looked for in, in display order
+var searchedFields = []string{"name", "first", "phone"}
+
+// searchedValue returns one of the searchedFields of a contact
+func searchedValue(c Contact, field string) string {
+	switch field {
+	case "name":
+		return c.Name
+	case "first":
+		return c.First
+	}
+	return c.Phone
+}
+
+/**
+ * FindMatches locates a search term in the name, first name and phone of a contact
+ *
+ * @param {Contact} c - Contact found by the search
+ * @param {string} term - Text searched, compared without regard to case
+ * @param {SearchOptions} opts - Matching mode of the search
+ * @return {[]Match} Every occurrence of the term, by field then position;
+ *   with opts.Phonetic, a name or first name that only sounds like the term
+ *   matches as a whole. Nil when the term is not found
+ *
+ * Usage:
+ *   FindMatches(Contact{Name: "Dupont", First: "Jean"}, "dup", SearchOptions{})
+ *   // [{Field: "name", Start: 0, End: 3}]
+ */
+func FindMatches(c Contact, term string, opts SearchOptions) []Match {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return nil
+	}
+	var matches []Match
+	for _, field := range searchedFields {
+		value := searchedValue(c, field)
+		for offset := 0; offset < len(value); {
+			start, end := indexFold(value[offset:], term)
+			if start < 0 {
+				break
+			}
+			matches = append(matches, Match{Field: field, Start: offset + start, End: offset + end})
+			offset += end
+		}
+	}
+	if matches == nil && opts.Phonetic && strings.ContainsFunc(term, unicode.IsLetter) {
+		key := PhoneticKey(term)
+		for _, field := range searchedFields[:2] {
+			if value := searchedValue(c, field); value != "" && PhoneticKey(value) == key {
+				matches = append(matches, Match{Field: field, Start: 0, End: len(value)})
+			}
+		}
+	}
+	return matches
+}
+
+// indexFold returns the byte range of the first occurrence of term in s,
+// ignoring case rune by rune (so that offsets stay valid even when a lower
+// case letter has another length), or -1, -1
+func indexFold(s, term string) (int, int) {
+	for i := 0; i < len(s); {
+		if n := prefixFold(s[i:], term); n > 0 {
+			return i, i + n
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return -1, -1
+}
+
+// prefixFold returns the length in s of a prefix equal to term without regard
+// to case, or 0 when s does not start with term
+func prefixFold(s, term string) int {
+	n := 0
+	for _, want := range term {
+		got, size := utf8.DecodeRuneInString(s[n:])
+		if size == 0 || !strings.EqualFold(string(got), string(want)) {
+			return 0
+		}
+		n += size
+	}
+	return n
+}
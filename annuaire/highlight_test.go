@@ -0,0 +1,52 @@
+package annuaire
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestFindMatches tests where a search term is located in a contact
+func TestFindMatches(t *testing.T) {
+	c := Contact{Name: "Dupont", First: "Jean-Dup", Phone: "0612061206"}
+	tests := []struct {
+		term     string
+		phonetic bool
+		want     []Match
+	}{
+		{"Dupont", false, []Match{{"name", 0, 6}}},
+		{"dup", false, []Match{{"name", 0, 3}, {"first", 5, 8}}},
+		{"1206", false, []Match{{"phone", 2, 6}, {"phone", 6, 10}}},
+		{" jean ", false, []Match{{"first", 0, 4}}},
+		{"Dupond", false, nil},
+		{"Dupond", true, []Match{{"name", 0, 6}}},
+		{"", true, nil},
+	}
+	for _, tt := range tests {
+		if got := FindMatches(c, tt.term, SearchOptions{Phonetic: tt.phonetic}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindMatches(%q, phonetic=%v) = %v, want %v", tt.term, tt.phonetic, got, tt.want)
+		}
+	}
+
+	// Offsets are in bytes of the field, whatever the case of accented letters
+	if got := FindMatches(Contact{Name: "Hélène Éric"}, "éRIC", SearchOptions{}); !reflect.DeepEqual(got, []Match{{"name", 9, 14}}) {
+		t.Errorf("Accented match = %v", got)
+	}
+}
+
+// TestFilterContactsWithMatches tests that search results carry their matches
+func TestFilterContactsWithMatches(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.AddContact("Dupond", "Paul", "0600000000")
+
+	result := dir.FilterContactsWith(context.Background(), "Dupont", SearchOptions{Phonetic: true}, SearchLimits{})
+	if len(result.Matches) != len(result.Contacts) || len(result.Contacts) != 2 {
+		t.Fatalf("Got %d contacts and %d matches", len(result.Contacts), len(result.Matches))
+	}
+	for i, c := range result.Contacts {
+		if want := []Match{{"name", 0, 6}}; !reflect.DeepEqual(result.Matches[i], want) {
+			t.Errorf("Matches of %s = %v, want %v", c.Name, result.Matches[i], want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package annuaire
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HistoryFileName is the audit log kept next to the data file
+const HistoryFileName = "history.jsonl"
+
+// maxMemoryHistory bounds the in-memory history kept when no file is set
+const maxMemoryHistory = 1000
+
+// Operations recorded in the history
+const (
+	OpAdd    = "add"
+	OpUpdate = "update"
+	OpDelete = "delete"
+	OpImport = "import"
+	OpClear  = "clear"
+	OpSearch = "search" // Recorded by RecordSearch for usage statistics, not a modification
+)
+
+// historyFields are the contact fields compared by HistoryEntry.Summary
+var historyFields = []string{"name", "first", "phone", "birthday", "address", "code", "verify_by", "custom"}
+
+// HistoryEntry is one recorded modification of the directory
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`              // When the operation happened
+	Op      string    `json:"op"`                // One of OpAdd, OpUpdate, OpDelete, OpImport, OpClear
+	Before  *Contact  `json:"before,omitempty"`  // Contact before an update or delete
+	After   *Contact  `json:"after,omitempty"`   // Contact after an add or update
+	Details string    `json:"details,omitempty"` // Free text, e.g. "42 contacts from backup.json"
+	Size    int       `json:"size"`              // Number of contacts after the operation
+}
+
+// IsModification reports whether the entry changed the directory (searches do not)
+func (e HistoryEntry) IsModification() bool {
+	return e.Op != OpSearch
+}
+
+// Concerns reports whether the entry is about the contact with the given ID
+func (e HistoryEntry) Concerns(id int) bool {
+	return (e.Before != nil && e.Before.ID == id) || (e.After != nil && e.After.ID == id)
+}
+
+// Summary describes the entry in one line, e.g. "update Jean Dupont: phone 0123 → 0612"
+func (e HistoryEntry) Summary() string {
+	name := func(c *Contact) string { return c.First + " " + c.Name }
+	switch {
+	case e.Before != nil && e.After != nil:
+		summary := e.Op + " " + name(e.After) + ":"
+		for _, field := range historyFields {
+			if before, after := fieldOf(*e.Before, field), fieldOf(*e.After, field); before != after {
+				summary += fmt.Sprintf(" %s %q → %q", field, before, after)
+			}
+		}
+		return summary
+	case e.After != nil:
+		return e.Op + " " + name(e.After)
+	case e.Before != nil:
+		return e.Op + " " + name(e.Before)
+	case e.Details != "":
+		return e.Op + " " + e.Details
+	}
+	return e.Op
+}
+
+/**
+ * HistoryPathFor returns the audit log location for a data file
+ *
+ * @param {string} dataFile - Contacts file, e.g. "data/contacts.json"
+ * @return {string} Log file in the same directory, e.g. "data/history.jsonl"
+ */
+func HistoryPathFor(dataFile string) string {
+	return filepath.Join(filepath.Dir(dataFile), HistoryFileName)
+}
+
+/**
+ * SetHistoryFile starts recording every modification in an append-only log
+ *
+ * @param {string} path - JSON Lines file, one entry per line (empty keeps the log in memory)
+ *
+ * Call it after loading the initial data, so that loading is not recorded
+ * as an import on every start
+ *
+ * Usage:
+ *   dir.ImportFromJSON("data/contacts.json", ImportOptions{})
+ *   dir.SetHistoryFile(HistoryPathFor("data/contacts.json"))
+ */
+func (d *Directory) SetHistoryFile(path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.historyFile = path
+}
+
+/**
+ * RecordSearch adds a search performed by the user to the history
+ *
+ * @param {string} term - Search term as typed
+ *
+ * Searches feed the local usage statistics (most-searched names); library
+ * calls such as FilterContacts are not recorded, only user interfaces call this
+ */
+func (d *Directory) RecordSearch(term string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.recordLocked(OpSearch, nil, nil, term)
+}
+
+/**
+ * History returns the recorded modifications, oldest first
+ *
+ * @return {[]HistoryEntry} Entries of the log file, or of this session when no file is set
+ * @return {error} Returns an error if the log file cannot be read; a missing
+ *                 file means an empty history
+ */
+func (d *Directory) History() ([]HistoryEntry, error) {
+	d.mu.RLock()
+	path := d.historyFile
+	memory := append([]HistoryEntry(nil), d.history...)
+	d.mu.RUnlock()
+
+	if path == "" {
+		return memory, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A torn last line (crash during append) must not hide the rest
+			slog.Warn("skipping unreadable history line", "file", path, "line", line, "error", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// recordLocked appends an entry to the history, and the modification to the
+// append-only storage when enabled, then calls the hooks (see RegisterHook);
+// the caller must hold d.mu
+// A failure to write the log is logged but does not undo the operation
+func (d *Directory) recordLocked(op string, before, after *Contact, details string) {
+	defer d.emitEntryLocked(op, before, after)
+
+	entry := HistoryEntry{Time: time.Now().UTC(), Op: op, Before: before, After: after, Details: details, Size: len(d.contacts)}
+	if op != OpSearch {
+		d.journalLocked(before, after)
+	}
+
+	if d.historyFile == "" {
+		if len(d.history) >= maxMemoryHistory {
+			d.history = d.history[1:]
+		}
+		d.history = append(d.history, entry)
+		return
+	}
+
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = appendLine(d.historyFile, data)
+	}
+	if err != nil {
+		slog.Warn("cannot record history", "file", d.historyFile, "op", op, "error", err)
+	}
+}
+
+// emitEntryLocked calls the hooks of a change of one contact; imports and
+// clears call them for each contact themselves. The caller must hold d.mu
+func (d *Directory) emitEntryLocked(op string, before, after *Contact) {
+	switch {
+	case op == OpAdd && after != nil:
+		d.emitLocked(OnAdd, *after)
+	case op == OpUpdate && after != nil:
+		d.emitLocked(OnUpdate, *after)
+	case op == OpDelete && before != nil:
+		d.emitLocked(OnDelete, *before)
+	}
+}
+
+// appendLine adds one line at the end of a file, creating it if needed
+func appendLine(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// fieldOf returns a field of a contact by its JSON name
+func fieldOf(c Contact, field string) string {
+	switch field {
+	case "name":
+		return c.Name
+	case "first":
+		return c.First
+	case "phone":
+		return c.Phone
+	case "birthday":
+		return c.Birthday
+	case "address":
+		return c.Address.String()
+	case "code":
+		return c.Code
+	case "verify_by":
+		return c.VerifyBy
+	case "custom":
+		return FormatCustomFields(c.Custom)
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package annuaire
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHistoryFile tests that modifications are appended to the log and read back
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), HistoryFileName)
+	dir := NewDirectory()
+	dir.ReplaceContacts([]Contact{{Name: "Martin", First: "Paul", Phone: "0600000000"}}) // Before logging starts
+	dir.SetHistoryFile(path)
+
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.UpdateContact("Dupont", "", "0612345678")
+	dir.DeleteContact("Martin")
+	dir.Clear()
+
+	// A second directory on the same file sees the same history
+	other := NewDirectory()
+	other.SetHistoryFile(path)
+	entries, err := other.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ops []string
+	for _, entry := range entries {
+		ops = append(ops, entry.Op)
+	}
+	if strings.Join(ops, ",") != "add,update,delete,clear" {
+		t.Fatalf("Unexpected operations: %v", ops)
+	}
+	if summary := entries[1].Summary(); summary != `update Jean Dupont: phone "0123456789" → "0612345678"` {
+		t.Errorf("Unexpected summary: %s", summary)
+	}
+	if !entries[1].Concerns(entries[0].After.ID) || entries[2].Concerns(entries[0].After.ID) {
+		t.Error("Concerns does not match contact IDs")
+	}
+}
+
+// TestHistoryInMemory tests the session history used without a file
+func TestHistoryInMemory(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.AddContact("Dupont", "Jean", "0123456789") // Duplicate, not recorded
+
+	entries, err := dir.History()
+	if err != nil || len(entries) != 1 || entries[0].Summary() != "add Jean Dupont" {
+		t.Errorf("Unexpected history: %v, %v", entries, err)
+	}
+}
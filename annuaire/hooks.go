@@ -0,0 +1,65 @@
+package annuaire
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// Event names a kind of change of the contacts that hooks can subscribe to
+type Event string
+
+// Events passed to the hooks of RegisterHook
+const (
+	OnAdd    Event = "add"    // A contact was added; the hook gets it with its ID
+	OnUpdate Event = "update" // A contact was changed; the hook gets its new version
+	OnDelete Event = "delete" // A contact was removed, one by one or by a clear or a replacing import
+	OnImport Event = "import" // A contact was added or overwritten by an import or a merge
+)
+
+// Events lists every event, e.g. to subscribe to all of them
+var Events = []Event{OnAdd, OnUpdate, OnDelete, OnImport}
+
+/**
+ * RegisterHook calls a function for every later change of one kind
+ *
+ * @param {Event} event - OnAdd, OnUpdate, OnDelete or OnImport
+ * @param {func(Contact)} hook - Receives the contact concerned, once per
+ *   contact: clearing 10 contacts calls the OnDelete hooks 10 times
+ *
+ * Hooks are called in the order they were registered, after the change is
+ * recorded in the history. They run while the directory is locked, in the
+ * goroutine making the change: a hook must return quickly and must not call
+ * the directory, e.g. by handing the contact over to a goroutine. Like
+ * SetHistoryFile, register hooks after loading the initial data, which would
+ * otherwise be reported as an import
+ *
+ * Usage:
+ *   dir.RegisterHook(annuaire.OnAdd, func(c annuaire.Contact) {
+ *       added <- c
+ *   })
+ */
+func (d *Directory) RegisterHook(event Event, hook func(Contact)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.hooks == nil {
+		d.hooks = make(map[Event][]func(Contact))
+	}
+	d.hooks[event] = append(d.hooks[event], hook)
+}
+
+// emitLocked calls the hooks of an event; the caller must hold d.mu
+func (d *Directory) emitLocked(event Event, contact Contact) {
+	for _, hook := range d.hooks[event] {
+		hook(contact)
+	}
+}
+
+// sortedByID returns the contacts of a map in ID order, so that hooks see
+// them in the same order on every run
+func sortedByID(contacts map[string]Contact) []Contact {
+	sorted := slices.Collect(maps.Values(contacts))
+	slices.SortFunc(sorted, func(a, b Contact) int { return cmp.Compare(a.ID, b.ID) })
+	return sorted
+}
@@ -0,0 +1,45 @@
+package annuaire
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRegisterHook tests the events seen by hooks, one per contact, for
+// single changes, imports and clears, but not for searches
+func TestRegisterHook(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Martin", "Paul", "0600000000") // Before the hooks
+
+	var events []string
+	for _, event := range Events {
+		dir.RegisterHook(event, func(c Contact) {
+			events = append(events, string(event)+" "+c.Name)
+		})
+	}
+	expect := func(step, want string) {
+		t.Helper()
+		if got := strings.Join(events, ", "); got != want {
+			t.Errorf("%s: got events %q, want %q", step, got, want)
+		}
+		events = nil
+	}
+
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.RecordSearch("Dupont")
+	dir.UpdateContact("Dupont", "", "0612345678")
+	dir.DeleteContact("Martin")
+	expect("single changes", "add Dupont, update Dupont, delete Martin")
+
+	dir.ImportContacts([]Contact{{Name: "Durand", First: "Marie", Phone: "0611111111"}}, ImportOptions{Strategy: ImportMergeSkip}, "team.csv")
+	expect("merge import", "import Durand")
+
+	dir.ImportContacts([]Contact{
+		{Name: "Durand", First: "Marie", Phone: "0611111111"},
+		{Name: "Leroy", First: "Luc", Phone: "0622222222"},
+	}, ImportOptions{}, "backup.json")
+	expect("replacing import", "delete Dupont, import Durand, import Leroy")
+
+	dir.ClearContacts(ClearScope{})
+	expect("clear", "delete Durand, delete Leroy")
+}
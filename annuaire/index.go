@@ -0,0 +1,76 @@
+package annuaire
+
+import (
+	"strings"
+	"unicode"
+)
+
+// OtherInitial groups the names that do not start with a letter from A to Z
+const OtherInitial = "#"
+
+// IndexLetters lists the initials of an alphabetical index, in order
+var IndexLetters = strings.Split("ABCDEFGHIJKLMNOPQRSTUVWXYZ"+OtherInitial, "")
+
+// baseLetters folds the accented capitals of French and neighbouring
+// languages to the letter they are filed under
+var baseLetters = map[rune]rune{
+	'À': 'A', 'Á': 'A', 'Â': 'A', 'Ã': 'A', 'Ä': 'A', 'Å': 'A', 'Æ': 'A',
+	'Ç': 'C',
+	'È': 'E', 'É': 'E', 'Ê': 'E', 'Ë': 'E',
+	'Ì': 'I', 'Í': 'I', 'Î': 'I', 'Ï': 'I',
+	'Ñ': 'N',
+	'Ò': 'O', 'Ó': 'O', 'Ô': 'O', 'Õ': 'O', 'Ö': 'O', 'Ø': 'O', 'Œ': 'O',
+	'Ù': 'U', 'Ú': 'U', 'Û': 'U', 'Ü': 'U',
+	'Ý': 'Y', 'Ÿ': 'Y',
+}
+
+// ContactGroup is the contacts filed under one initial
+type ContactGroup struct {
+	Initial  string    // Letter from A to Z, or OtherInitial
+	Contacts []Contact // Contacts in their original order
+}
+
+/**
+ * Initial returns the index letter a name is filed under
+ *
+ * @param {string} name - Last name
+ * @return {string} Capital letter from A to Z, accents removed ("Émile" is
+ *                  filed under E), or OtherInitial for digits, symbols and
+ *                  empty names
+ */
+func Initial(name string) string {
+	for _, r := range strings.TrimSpace(name) {
+		r = unicode.ToUpper(r)
+		if base, ok := baseLetters[r]; ok {
+			r = base
+		}
+		if r >= 'A' && r <= 'Z' {
+			return string(r)
+		}
+		return OtherInitial
+	}
+	return OtherInitial
+}
+
+/**
+ * GroupByInitial files contacts under the initial of their last name
+ *
+ * @param {[]Contact} contacts - Contacts, usually sorted alphabetically
+ * @return {[]ContactGroup} Non-empty groups in IndexLetters order; each keeps
+ *                          the order of contacts
+ */
+func GroupByInitial(contacts []Contact) []ContactGroup {
+	byInitial := make(map[string][]Contact)
+	for _, contact := range contacts {
+		initial := Initial(contact.Name)
+		byInitial[initial] = append(byInitial[initial], contact)
+	}
+
+	groups := make([]ContactGroup, 0, len(byInitial))
+	for _, initial := range IndexLetters {
+		if members := byInitial[initial]; len(members) > 0 {
+			groups = append(groups, ContactGroup{Initial: initial, Contacts: members})
+		}
+	}
+	return groups
+}
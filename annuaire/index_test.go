@@ -0,0 +1,40 @@
+package annuaire
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestGroupByInitial tests the alphabetical index of contacts
+func TestGroupByInitial(t *testing.T) {
+	for name, want := range map[string]string{
+		"Dupont": "D", "dupont": "D", "Émile": "E", "Œuvre": "O", " Martin": "M",
+		"42 Street": OtherInitial, "": OtherInitial, "Ñandú": "N", "Жуков": OtherInitial,
+	} {
+		if got := Initial(name); got != want {
+			t.Errorf("Initial(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	contacts := []Contact{
+		{Name: "Durand", First: "Paul"},
+		{Name: "Dupont", First: "Jean"},
+		{Name: "3M", First: "Support"},
+		{Name: "Bernard", First: "Luc"},
+		{Name: "Étienne", First: "Anne"},
+	}
+	groups := GroupByInitial(contacts)
+	var initials []string
+	for _, group := range groups {
+		initials = append(initials, group.Initial)
+	}
+	if got := fmt.Sprint(initials); got != "[B D E #]" {
+		t.Fatalf("Unexpected groups %s", got)
+	}
+	if d := groups[1].Contacts; len(d) != 2 || d[0].Name != "Durand" || d[1].Name != "Dupont" {
+		t.Errorf("Group D should keep the original order, got %v", d)
+	}
+	if len(GroupByInitial(nil)) != 0 {
+		t.Error("Expected no group for no contacts")
+	}
+}
@@ -0,0 +1,169 @@
+package annuaire
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// LDIFOptions sets where an LDIF export places its entries in the LDAP tree
+type LDIFOptions struct {
+	BaseDN string // Parent of every entry, e.g. "ou=people,dc=example,dc=com"
+}
+
+// DefaultLDIFOptions returns a base DN to replace with the one of the target server
+func DefaultLDIFOptions() LDIFOptions {
+	return LDIFOptions{BaseDN: "ou=contacts,dc=example,dc=com"}
+}
+
+// ldifLineLength is the width at which LDIF lines are folded (RFC 2849 advises 76)
+const ldifLineLength = 76
+
+// ldifObjectClasses makes each entry an inetOrgPerson, with its superclasses
+var ldifObjectClasses = []string{"top", "person", "organizationalPerson", "inetOrgPerson"}
+
+/**
+ * WriteLDIF writes contacts as inetOrgPerson entries, ready for ldapadd
+ *
+ * @param {io.Writer} w - Destination of the LDIF data (a "version: 1" line comes first)
+ * @param {[]Contact} contacts - Contacts to export
+ * @param {LDIFOptions} opts - Base DN of the entries (empty: DefaultLDIFOptions)
+ * @return {error} Returns an error if writing fails
+ *
+ * Mapping:
+ * - dn is uid=<ID>,<base DN>, so entries keep their name when a contact is
+ *   renamed; contacts without an ID are numbered by position
+ * - cn is "First Last", sn the last name, givenName the first name
+ * - telephoneNumber is the phone, businessCategory each tag, jpegPhoto the photo
+ * - street, l and postalCode hold the address, and postalAddress all of it
+ *   with the country ("$" separates its lines)
+ * - Birthdays, speed-dial codes, verification dates and custom fields have no
+ *   inetOrgPerson attribute and are left out
+ *
+ * Values that LDIF cannot write as is (accents, leading spaces...) are base64
+ * encoded, and long lines are folded, so ReadLDIF reads the file back
+ */
+func WriteLDIF(w io.Writer, contacts []Contact, opts LDIFOptions) error {
+	if opts.BaseDN == "" {
+		opts = DefaultLDIFOptions()
+	}
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "version: 1")
+
+	for i, contact := range contacts {
+		uid := contact.ID
+		if uid <= 0 {
+			uid = i + 1
+		}
+		out.WriteString("\n")
+		writeLDIFAttribute(out, "dn", "uid="+strconv.Itoa(uid)+","+opts.BaseDN)
+		for _, class := range ldifObjectClasses {
+			writeLDIFAttribute(out, "objectClass", class)
+		}
+		writeLDIFAttribute(out, "uid", strconv.Itoa(uid))
+		writeLDIFAttribute(out, "cn", strings.TrimSpace(contact.First+" "+contact.Name))
+		writeLDIFAttribute(out, "sn", contact.Name)
+		if contact.First != "" {
+			writeLDIFAttribute(out, "givenName", contact.First)
+		}
+		if contact.Phone != "" {
+			writeLDIFAttribute(out, "telephoneNumber", contact.Phone)
+		}
+		writeLDIFAddress(out, contact.Address)
+		for _, tag := range contact.Tags {
+			writeLDIFAttribute(out, "businessCategory", tag)
+		}
+		if contact.Photo != "" {
+			// The photo is base64 already: write it as the encoded value
+			writeLDIFLine(out, "jpegPhoto:: "+contact.Photo)
+		}
+	}
+	return out.Flush()
+}
+
+// LDIFEncoder adapts WriteLDIF to the export pipeline
+func LDIFEncoder(opts LDIFOptions) ExportEncoder {
+	return func(w io.Writer, contacts []Contact) error {
+		return WriteLDIF(w, contacts, opts)
+	}
+}
+
+/**
+ * ExportToLDIF exports all contacts to an LDIF file of inetOrgPerson entries
+ *
+ * @param {string} filename - Destination file, written through the directory storage
+ * @param {LDIFOptions} opts - Base DN of the entries
+ * @return {error} Returns an error if the file cannot be written
+ *
+ * Usage:
+ *   err := dir.ExportToLDIF("contacts.ldif", LDIFOptions{BaseDN: "ou=people,dc=example,dc=com"})
+ *   // then: ldapadd -x -D cn=admin,dc=example,dc=com -W -f contacts.ldif
+ */
+func (d *Directory) ExportToLDIF(filename string, opts LDIFOptions) error {
+	return d.ExportWith(filename, ExportPipeline{Encoder: LDIFEncoder(opts)})
+}
+
+// writeLDIFAddress writes the address attributes of an entry; inetOrgPerson
+// has no country attribute, so the country only appears in postalAddress
+func writeLDIFAddress(out *bufio.Writer, a Address) {
+	if a.IsZero() {
+		return
+	}
+	for _, attribute := range []struct{ name, value string }{{"street", a.Street}, {"l", a.City}, {"postalCode", a.Zip}} {
+		if attribute.value != "" {
+			writeLDIFAttribute(out, attribute.name, attribute.value)
+		}
+	}
+	var lines []string
+	for _, line := range []string{a.Street, strings.TrimSpace(a.Zip + " " + a.City), a.Country} {
+		if line != "" {
+			// "$" and "\" are escaped as in RFC 4517 postal addresses
+			lines = append(lines, strings.NewReplacer(`\`, `\5C`, "$", `\24`).Replace(line))
+		}
+	}
+	writeLDIFAttribute(out, "postalAddress", strings.Join(lines, "$"))
+}
+
+// writeLDIFAttribute writes "name: value", or "name:: base64" when the value
+// is not a safe LDIF string
+func writeLDIFAttribute(out *bufio.Writer, name, value string) {
+	if ldifSafe(value) {
+		writeLDIFLine(out, name+": "+value)
+	} else {
+		writeLDIFLine(out, name+":: "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+}
+
+// writeLDIFLine folds a line longer than ldifLineLength: each continuation
+// line starts with a space, which readers drop when unfolding. Lines are
+// ASCII (other values are base64 encoded), so any byte is a valid cut
+func writeLDIFLine(out *bufio.Writer, line string) {
+	width := ldifLineLength
+	for len(line) > width {
+		out.WriteString(line[:width] + "\n ")
+		line = line[width:]
+		width = ldifLineLength - 1 // Room for the leading space
+	}
+	out.WriteString(line + "\n")
+}
+
+// ldifSafe reports whether a value is a SAFE-STRING of RFC 2849: ASCII
+// without NUL, CR or LF, not starting with a space, colon or "<", and not
+// ending with a space
+func ldifSafe(value string) bool {
+	if value == "" {
+		return true
+	}
+	if value[0] == ' ' || value[0] == ':' || value[0] == '<' || value[len(value)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c == 0 || c == '\r' || c == '\n' || c > 127 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package annuaire
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestWriteLDIF tests the inetOrgPerson entries and reads them back
+func TestWriteLDIF(t *testing.T) {
+	contacts := []Contact{
+		{ID: 12, Name: "Dupont", First: "Jean", Phone: "0612345678", Birthday: "1985-04-12", Tags: []string{"family", "work"}},
+		{Name: "Léger", First: "Hélène", Phone: "0700000000"},
+		{ID: 3, Name: strings.Repeat("Long", 30), First: "Anne", Phone: "0611111111"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLDIF(&buf, contacts, LDIFOptions{BaseDN: "ou=people,dc=example,dc=org"}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"version: 1\n",
+		"\ndn: uid=12,ou=people,dc=example,dc=org\nobjectClass: top\n",
+		"objectClass: inetOrgPerson\n",
+		"cn: Jean Dupont\nsn: Dupont\ngivenName: Jean\ntelephoneNumber: 0612345678\n",
+		"businessCategory: family\nbusinessCategory: work\n",
+		"\ndn: uid=2,ou=people,dc=example,dc=org\n", // No ID: numbered by position
+		"sn:: TMOpZ2Vy\n", // "Léger" in base64
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) > ldifLineLength {
+			t.Errorf("Line not folded: %q", line)
+		}
+	}
+	if strings.Contains(out, "1985") {
+		t.Error("Birthdays have no inetOrgPerson attribute")
+	}
+
+	dialect, _ := LookupDialect("default")
+	back, report, err := ReadLDIF(strings.NewReader(out), dialect)
+	if err != nil || len(back) != 3 {
+		t.Fatalf("Expected 3 contacts back, got %d (%v)", len(back), err)
+	}
+	if back[1].Name != "Léger" || back[1].First != "Hélène" || back[2].Name != contacts[2].Name {
+		t.Errorf("Encoded or folded values not read back: %+v", back)
+	}
+	if report.Unsupported["businessCategory"] != 1 {
+		t.Errorf("Unexpected report: %v", report.Unsupported)
+	}
+}
+
+// TestLDIFExportFormat tests that -format ldif selects the encoder
+func TestLDIFExportFormat(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0612345678")
+
+	var buf bytes.Buffer
+	if err := dir.ExportContacts(dir.ListContacts(), "ldif", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "dn: uid=1,"+DefaultLDIFOptions().BaseDN) {
+		t.Errorf("Unexpected LDIF export:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,144 @@
+package annuaire
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// contactIndex maps field values to the storage keys of the contacts having
+// them, so that lookups by name, phone, ID or code do not scan every contact
+// Keys are kept in insertion order, so the "first" contact of a name is the
+// oldest one still stored
+type contactIndex struct {
+	byName  map[string][]string // Last name -> keys
+	byFirst map[string][]string // First name -> keys
+	byPhone map[string][]string // Phone -> keys (several names may share a phone)
+	byCode  map[string][]string // Lower-cased speed-dial code -> keys
+	byID    map[int]string      // ID -> key
+	names   []nameEntry         // Distinct last and first names, sorted for prefix search (see SuggestNames)
+	loading bool                // names is built once by sortNames instead of on each add
+}
+
+// newContactIndex returns an empty index sized for n contacts
+func newContactIndex(n int) contactIndex {
+	return contactIndex{
+		byName:  make(map[string][]string, n),
+		byFirst: make(map[string][]string, n),
+		byPhone: make(map[string][]string, n),
+		byCode:  make(map[string][]string),
+		byID:    make(map[int]string, n),
+	}
+}
+
+// add records the values of a contact stored under key
+func (x *contactIndex) add(key string, c Contact) {
+	x.byName[c.Name] = append(x.byName[c.Name], key)
+	x.byFirst[c.First] = append(x.byFirst[c.First], key)
+	x.byPhone[c.Phone] = append(x.byPhone[c.Phone], key)
+	if c.Code != "" {
+		code := strings.ToLower(c.Code)
+		x.byCode[code] = append(x.byCode[code], key)
+	}
+	x.byID[c.ID] = key
+	if !x.loading {
+		x.addName(c.Name)
+		x.addName(c.First)
+	}
+}
+
+// remove forgets the values of a contact that was stored under key
+func (x *contactIndex) remove(key string, c Contact) {
+	unlist(x.byName, c.Name, key)
+	unlist(x.byFirst, c.First, key)
+	unlist(x.byPhone, c.Phone, key)
+	if c.Code != "" {
+		unlist(x.byCode, strings.ToLower(c.Code), key)
+	}
+	if x.byID[c.ID] == key {
+		delete(x.byID, c.ID)
+	}
+	if !x.loading {
+		x.removeName(c.Name)
+		x.removeName(c.First)
+	}
+}
+
+// unlist removes key from the keys indexed under value
+func unlist(index map[string][]string, value, key string) {
+	keys := slices.DeleteFunc(index[value], func(k string) bool { return k == key })
+	if len(keys) == 0 {
+		delete(index, value)
+		return
+	}
+	index[value] = keys
+}
+
+// contactKey returns the composite key a contact is stored under
+func contactKey(c Contact) string {
+	return fmt.Sprintf("%s_%s", c.Name, c.Phone)
+}
+
+// putLocked stores a contact under its composite key, replacing the contact
+// already stored there, and keeps the index up to date
+// The caller must hold d.mu
+func (d *Directory) putLocked(c Contact) string {
+	key := contactKey(c)
+	if old, exists := d.contacts[key]; exists {
+		d.index.remove(key, old)
+	}
+	d.contacts[key] = c
+	d.index.add(key, c)
+	return key
+}
+
+// removeLocked deletes the contact stored under key
+// The caller must hold d.mu
+func (d *Directory) removeLocked(key string) {
+	if old, exists := d.contacts[key]; exists {
+		d.index.remove(key, old)
+		delete(d.contacts, key)
+	}
+}
+
+// resetLocked empties the storage before n contacts are loaded with
+// putLocked; the loading ends with loadedLocked
+// The caller must hold d.mu
+func (d *Directory) resetLocked(n int) {
+	d.contacts = make(map[string]Contact, n)
+	d.index = newContactIndex(n)
+	d.index.loading = true
+}
+
+// loadedLocked completes the index after the contacts given to resetLocked
+// The caller must hold d.mu
+func (d *Directory) loadedLocked() {
+	d.index.sortNames()
+	d.index.loading = false
+}
+
+// uniqueByNameLocked returns the key of the only contact with this last name,
+// ErrContactNotFound when there is none, or an *AmbiguousReferenceError
+// listing the contacts sharing it, rather than acting on any of them
+// The caller must hold d.mu
+func (d *Directory) uniqueByNameLocked(name string) (string, error) {
+	return d.uniqueKeyLocked(name, d.index.byName[name])
+}
+
+// uniqueKeyLocked returns the only key of keys, ErrContactNotFound when keys
+// is empty, or an *AmbiguousReferenceError for ref listing their contacts
+// The caller must hold d.mu
+func (d *Directory) uniqueKeyLocked(ref string, keys []string) (string, error) {
+	switch len(keys) {
+	case 0:
+		return "", ErrContactNotFound
+	case 1:
+		return keys[0], nil
+	}
+	matches := make([]Contact, len(keys))
+	for i, key := range keys {
+		matches[i] = d.contacts[key]
+	}
+	SortContacts(matches, nil)
+	return "", &AmbiguousReferenceError{Ref: ref, Matches: matches}
+}
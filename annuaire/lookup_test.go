@@ -0,0 +1,165 @@
+package annuaire
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// checkIndex fails the test if the index does not describe exactly the stored contacts
+func checkIndex(t *testing.T, d *Directory) {
+	t.Helper()
+	want := newContactIndex(0)
+	for _, contact := range d.ListContacts() {
+		want.add(contactKey(contact), contact)
+	}
+	for name, index := range map[string][2]map[string][]string{
+		"name":  {want.byName, d.index.byName},
+		"first": {want.byFirst, d.index.byFirst},
+		"phone": {want.byPhone, d.index.byPhone},
+		"code":  {want.byCode, d.index.byCode},
+	} {
+		if len(index[0]) != len(index[1]) {
+			t.Errorf("Index by %s has %d values, want %d", name, len(index[1]), len(index[0]))
+		}
+		for value, keys := range index[0] {
+			if len(index[1][value]) != len(keys) {
+				t.Errorf("Index by %s: %q -> %v, want %v", name, value, index[1][value], keys)
+			}
+		}
+	}
+	if len(d.index.byID) != len(want.byID) {
+		t.Errorf("Index by ID has %d entries, want %d", len(d.index.byID), len(want.byID))
+	}
+	if !slices.Equal(d.index.names, want.names) {
+		t.Errorf("Sorted names %v, want %v", d.index.names, want.names)
+	}
+}
+
+// TestIndexFollowsMutations tests that every mutation keeps the lookups in step
+func TestIndexFollowsMutations(t *testing.T) {
+	dir := NewDirectory()
+	dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0123456789", Code: "1"})
+	dir.AddContact("Dupont", "Marie", "0600000000")
+	dir.AddContact("Martin", "Jean", "0700000000")
+	checkIndex(t, dir)
+
+	if c, _ := dir.SearchContact("Dupont"); c.First != "Jean" {
+		t.Errorf("SearchContact should return the oldest Dupont, got %+v", c)
+	}
+	if c, ok := dir.SearchContact("0700000000"); !ok || c.Name != "Martin" {
+		t.Errorf("SearchContact by phone: got %+v, %v", c, ok)
+	}
+	if matches := dir.FilterContacts("Jean"); len(matches) != 2 {
+		t.Errorf("FilterContacts by first name: got %d matches, want 2", len(matches))
+	}
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"update", func() error { return dir.UpdateContact("Martin", "Paul", "0711111111") }},
+		{"birthday", func() error { return dir.SetBirthday("Martin", "1985-04-12") }},
+		{"delete", func() error {
+			// Two Dupont: the name alone deletes none of them
+			if err := dir.DeleteContact("Dupont"); !errors.Is(err, ErrAmbiguous) {
+				return fmt.Errorf("ambiguous name: got %v", err)
+			}
+			c, _ := dir.ResolveContact("Marie Dupont")
+			if err := dir.DeleteContactByID(c.ID); err != nil {
+				return err
+			}
+			return dir.DeleteContact("Dupont")
+		}},
+		{"save", func() error {
+			c, _ := dir.ResolveContact("Martin")
+			c.Code, c.Phone = "2", "0722222222"
+			return dir.SaveContact(c)
+		}},
+		{"merge", func() error {
+			dir.ImportContacts([]Contact{{Name: "Martin", First: "Luc", Phone: "0722222222"}, {Name: "Petit", First: "Anne", Phone: "08"}},
+				ImportOptions{Strategy: ImportMergeOverwrite}, "test")
+			return nil
+		}},
+		{"delete by ID", func() error {
+			c, _ := dir.ResolveContact("Petit")
+			return dir.DeleteContactByID(c.ID)
+		}},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		checkIndex(t, dir)
+	}
+
+	if err := dir.InsertContact(Contact{Name: "Bernard", First: "Luc", Phone: "09", Code: "1"}); err != nil {
+		t.Errorf("Code of the deleted contact should be free again: %v", err)
+	}
+	if err := dir.InsertContact(Contact{Name: "Durand", First: "Luc", Phone: "10", Code: "2"}); err != nil {
+		t.Errorf("Code overwritten by the merge should be free again: %v", err)
+	}
+	dir.Clear()
+	checkIndex(t, dir)
+	if _, ok := dir.SearchContact("Martin"); ok {
+		t.Error("Cleared directory still finds Martin")
+	}
+}
+
+// TestIndexScales tests that lookups stay correct on a large directory
+func TestIndexScales(t *testing.T) {
+	contacts := testContacts(100_000)
+	dir := NewDirectory()
+	dir.ReplaceContacts(contacts)
+
+	last := contacts[len(contacts)-1]
+	if c, ok := dir.SearchContact(last.Phone); !ok || c.Name != last.Name {
+		t.Errorf("SearchContact(%s): got %+v, %v", last.Phone, c, ok)
+	}
+	if err := dir.DeleteContact(contacts[50_000].Name); err != nil {
+		t.Errorf("DeleteContact: %v", err)
+	}
+	if err := dir.AddContact(contacts[1].Name, "Jean", contacts[1].Phone); err == nil {
+		t.Error("Duplicate of an imported contact was accepted")
+	}
+	if dir.ContactCount() != len(contacts)-1 {
+		t.Errorf("Expected %d contacts, got %d", len(contacts)-1, dir.ContactCount())
+	}
+}
+
+// TestSuggestNames tests the completion of last and first names
+func TestSuggestNames(t *testing.T) {
+	dir := NewDirectory()
+	dir.ReplaceContacts([]Contact{
+		{Name: "Dupont", First: "Jean", Phone: "01"},
+		{Name: "Dupont", First: "Marie", Phone: "02"},
+		{Name: "durand", First: "Dumas", Phone: "03"},
+		{Name: "Martin", First: "Dupont", Phone: "04"},
+	})
+	dir.AddContact("Dubois", "Luc", "05")
+
+	tests := []struct {
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{"du", 0, []string{"Dubois", "Dumas", "Dupont", "durand"}},
+		{"DU", 2, []string{"Dubois", "Dumas"}},
+		{"dupont", 10, []string{"Dupont"}},
+		{"x", 10, []string{}},
+	}
+	for _, tt := range tests {
+		if got := dir.SuggestNames(tt.prefix, tt.limit); !slices.Equal(got, tt.want) {
+			t.Errorf("SuggestNames(%q, %d) = %v, want %v", tt.prefix, tt.limit, got, tt.want)
+		}
+	}
+
+	// A name disappears with the last contact carrying it
+	dir.DeleteContact("Dubois")
+	dir.DeleteContact("Martin")
+	if got := dir.SuggestNames("du", 0); !slices.Equal(got, []string{"Dumas", "Dupont", "durand"}) {
+		t.Errorf("After deletions: %v", got)
+	}
+	checkIndex(t, dir)
+}
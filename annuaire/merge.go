@@ -0,0 +1,274 @@
+package annuaire
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ImportStrategy selects how imported contacts combine with the current ones
+type ImportStrategy string
+
+// Import strategies; the zero value behaves like ImportReplace
+const (
+	ImportReplace        ImportStrategy = "replace"               // Discard the current contacts
+	ImportMergeSkip      ImportStrategy = "merge-skip-duplicates" // Add new contacts, keep current ones unchanged
+	ImportMergeOverwrite ImportStrategy = "merge-overwrite"       // Add new contacts, update current ones from the file
+)
+
+// ImportStrategies lists the accepted strategy names, for help texts and forms
+var ImportStrategies = []ImportStrategy{ImportReplace, ImportMergeSkip, ImportMergeOverwrite}
+
+// ImportOptions controls how an import changes the directory
+type ImportOptions struct {
+	Strategy     ImportStrategy // How to treat existing contacts (empty: ImportReplace)
+	RecordSource bool           // Remember the source as the Source of the contacts imported (see ClearScope)
+}
+
+// ImportResult counts what an import did to the directory
+type ImportResult struct {
+	Added   int // Contacts that did not exist before
+	Updated int // Existing contacts overwritten (ImportMergeOverwrite only)
+	Skipped int // Existing contacts left unchanged (ImportMergeSkip only)
+	Invalid int // Contacts refused by ValidateContact, left out
+}
+
+// String summarizes the result, e.g. "3 added, 1 skipped"
+func (r ImportResult) String() string {
+	parts := []string{fmt.Sprintf("%d added", r.Added)}
+	if r.Updated > 0 {
+		parts = append(parts, fmt.Sprintf("%d updated", r.Updated))
+	}
+	if r.Skipped > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped", r.Skipped))
+	}
+	if r.Invalid > 0 {
+		parts = append(parts, fmt.Sprintf("%d invalid", r.Invalid))
+	}
+	return strings.Join(parts, ", ")
+}
+
+/**
+ * ParseImportStrategy converts a strategy name from a flag or a form
+ *
+ * @param {string} name - "replace", "merge-skip-duplicates" or "merge-overwrite"
+ * @return {ImportStrategy} The strategy (ImportReplace for an empty name)
+ * @return {error} Returns an error listing the valid names for anything else
+ */
+func ParseImportStrategy(name string) (ImportStrategy, error) {
+	if name == "" {
+		return ImportReplace, nil
+	}
+	for _, strategy := range ImportStrategies {
+		if string(strategy) == name {
+			return strategy, nil
+		}
+	}
+	return "", errorOf(ErrInvalidInput, "unknown import strategy %q (replace, merge-skip-duplicates, merge-overwrite)", name)
+}
+
+/**
+ * ImportContacts stores contacts read from a file according to a strategy
+ *
+ * @param {[]Contact} contacts - Contacts to import, e.g. from ReadContactsFile
+ * @param {ImportOptions} opts - Import strategy
+ * @param {string} source - Description recorded in the history, e.g. the file name
+ * @return {ImportResult} Number of contacts added, updated, skipped and
+ *   left out as invalid
+ *
+ * Merge rules:
+ * - Contacts failing ValidateContact (the rules of InsertContact) are left
+ *   out and counted as invalid, whatever the strategy
+ * - A duplicate is a contact with the same name and phone (the directory key);
+ *   when the file itself holds several, the last one is kept on replace
+ * - Existing contacts keep their ID; new ones get a fresh ID when theirs is taken
+ * - A speed-dial code already used by another contact is dropped from the
+ *   imported contact rather than failing the whole import
+ *
+ * With opts.RecordSource, the contacts added or overwritten remember source
+ * as their Source, so that they can be cleared together later (see
+ * ClearScope); otherwise the Source read from the file is kept
+ */
+func (d *Directory) ImportContacts(contacts []Contact, opts ImportOptions, source string) ImportResult {
+	var result ImportResult
+	valid := make([]Contact, 0, len(contacts))
+	for _, contact := range contacts {
+		if ValidateContact(contact) != nil {
+			result.Invalid++
+			continue
+		}
+		contact.Tags = NormalizeTags(contact.Tags)
+		contact.Custom = NormalizeCustomFields(contact.Custom)
+		if opts.RecordSource {
+			contact.Source = source
+		}
+		valid = append(valid, contact)
+	}
+
+	strategy := opts.Strategy
+	if strategy == "" || strategy == ImportReplace {
+		result.Added = d.replaceContacts(valid, OpImport, fmt.Sprintf("%d contacts from %s", len(valid), source))
+		return result
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, contact := range valid {
+		existing, exists := d.contacts[contactKey(contact)]
+		switch {
+		case exists && strategy == ImportMergeSkip:
+			result.Skipped++
+			continue
+		case exists:
+			contact.ID = existing.ID
+			result.Updated++
+		default:
+			if _, taken := d.keyOfLocked(contact.ID); contact.ID <= 0 || taken {
+				contact.ID = d.nextID
+			}
+			d.nextID = max(d.nextID, contact.ID+1)
+			result.Added++
+		}
+		if d.checkCodeLocked(contact.Code, contact.ID) != nil {
+			contact.Code = ""
+		}
+		d.putLocked(contact)
+		d.emitLocked(OnImport, contact)
+	}
+
+	d.generation++
+	d.recordLocked(OpImport, nil, nil, fmt.Sprintf("%s from %s: %s", strategy, source, result))
+	return result
+}
+
+// Actions of an ImportRow, i.e. what confirming the import does with the row
+const (
+	RowAdd     = "add"     // Stored as a new contact
+	RowUpdate  = "update"  // Overwrites the current contact with the same name and phone
+	RowSkip    = "skip"    // Same name and phone as a current contact, left unchanged
+	RowInvalid = "invalid" // Fails validation, never imported
+)
+
+// ImportRow is one contact of a file to import, as checked by PreviewImport
+type ImportRow struct {
+	Row      int      // Position in the file, starting at 1
+	Contact  Contact  // Contact read from the file
+	Action   string   // RowAdd, RowUpdate, RowSkip or RowInvalid
+	Errors   []string // Why the row is invalid
+	Warnings []string // Duplicates and dropped values worth a look before confirming
+}
+
+// ImportPreview describes what an import would do, before the directory changes
+type ImportPreview struct {
+	Strategy ImportStrategy // Strategy the preview was computed for
+	Rows     []ImportRow    // Every row of the file, in order
+	Removed  int            // Current contacts discarded (ImportReplace only)
+}
+
+/**
+ * PreviewImport checks the contacts of a file against the directory without
+ * modifying it
+ *
+ * @param {[]Contact} contacts - Contacts read from the file, e.g. with ReadContactsFile
+ * @param {ImportOptions} opts - Strategy the import will use
+ * @return {ImportPreview} One row per contact with its action, validation
+ *   errors (the rules of InsertContact) and duplicate warnings
+ *
+ * Passing Valid() of the preview to ImportContacts with the same options then
+ * performs exactly what the preview announced, as long as the directory did
+ * not change in between
+ */
+func (d *Directory) PreviewImport(contacts []Contact, opts ImportOptions) ImportPreview {
+	strategy := opts.Strategy
+	if strategy == "" {
+		strategy = ImportReplace
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	preview := ImportPreview{Strategy: strategy, Rows: make([]ImportRow, 0, len(contacts))}
+	if strategy == ImportReplace {
+		preview.Removed = len(d.contacts)
+	}
+	seen := make(map[string]int) // Composite key -> row of its first valid occurrence
+	for i, contact := range contacts {
+		row := ImportRow{Row: i + 1, Contact: contact, Action: RowAdd}
+		if contact.Name == "" {
+			row.Errors = append(row.Errors, "last name is required")
+		}
+		if contact.First == "" {
+			row.Errors = append(row.Errors, "first name is required")
+		}
+		if contact.Phone == "" {
+			row.Errors = append(row.Errors, "phone is required")
+		}
+		if err := ValidateBirthday(contact.Birthday); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		if err := ValidateCode(contact.Code); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		if err := ValidateTags(contact.Tags); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		if err := ValidateAddress(contact.Address); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		if err := ValidateCustomFields(contact.Custom); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+		if len(row.Errors) > 0 {
+			row.Action = RowInvalid
+			preview.Rows = append(preview.Rows, row)
+			continue
+		}
+
+		key := contactKey(contact)
+		if first, ok := seen[key]; ok {
+			row.Warnings = append(row.Warnings, fmt.Sprintf("same name and phone as row %d", first))
+		} else {
+			seen[key] = row.Row
+		}
+		existing, exists := d.contacts[key]
+		switch {
+		case strategy == ImportReplace:
+		case exists && strategy == ImportMergeSkip:
+			row.Action = RowSkip
+			row.Warnings = append(row.Warnings, fmt.Sprintf("already in the directory as %s %s", existing.First, existing.Name))
+		case exists:
+			row.Action = RowUpdate
+			if d.checkCodeLocked(contact.Code, existing.ID) != nil {
+				row.Warnings = append(row.Warnings, fmt.Sprintf("code %q is already used, it will be dropped", contact.Code))
+			}
+		default:
+			if d.checkCodeLocked(contact.Code, 0) != nil {
+				row.Warnings = append(row.Warnings, fmt.Sprintf("code %q is already used, it will be dropped", contact.Code))
+			}
+		}
+		preview.Rows = append(preview.Rows, row)
+	}
+	return preview
+}
+
+// Valid returns the contacts of the rows that pass validation, in file order
+func (p ImportPreview) Valid() []Contact {
+	contacts := make([]Contact, 0, len(p.Rows))
+	for _, row := range p.Rows {
+		if row.Action != RowInvalid {
+			contacts = append(contacts, row.Contact)
+		}
+	}
+	return contacts
+}
+
+// Count returns the number of rows with the given action, e.g. RowInvalid
+func (p ImportPreview) Count(action string) int {
+	count := 0
+	for _, row := range p.Rows {
+		if row.Action == action {
+			count++
+		}
+	}
+	return count
+}
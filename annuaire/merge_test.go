@@ -0,0 +1,123 @@
+package annuaire
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestImportStrategies tests replace and both merge strategies on the same data
+func TestImportStrategies(t *testing.T) {
+	incoming := []Contact{
+		{ID: 1, Name: "Dupont", First: "Jeannot", Phone: "0123456789", Code: "1"},
+		{ID: 1, Name: "Durand", First: "Marie", Phone: "0611111111", Code: "2"},
+	}
+	setup := func() *Directory {
+		dir := NewDirectory()
+		dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0123456789"})
+		dir.InsertContact(Contact{Name: "Martin", First: "Paul", Phone: "0622222222", Code: "2"})
+		return dir
+	}
+
+	dir := setup()
+	if result := dir.ImportContacts(incoming, ImportOptions{}, "test"); result.Added != 2 || dir.ContactCount() != 2 {
+		t.Errorf("Replace: unexpected result %+v with %d contacts", result, dir.ContactCount())
+	}
+
+	dir = setup()
+	result := dir.ImportContacts(incoming, ImportOptions{Strategy: ImportMergeSkip}, "test")
+	if result != (ImportResult{Added: 1, Skipped: 1}) || dir.ContactCount() != 3 {
+		t.Errorf("Merge-skip: unexpected result %+v with %d contacts", result, dir.ContactCount())
+	}
+	if c, _ := dir.SearchContact("Dupont"); c.First != "Jean" {
+		t.Errorf("Merge-skip changed an existing contact: %+v", c)
+	}
+	added, err := dir.ResolveContact("Durand")
+	if err != nil || added.ID != 3 || added.Code != "" {
+		t.Errorf("Expected Durand with a fresh ID and its taken code dropped, got %+v (%v)", added, err)
+	}
+
+	dir = setup()
+	result = dir.ImportContacts(incoming, ImportOptions{Strategy: ImportMergeOverwrite}, "test")
+	if result != (ImportResult{Added: 1, Updated: 1}) || dir.ContactCount() != 3 {
+		t.Errorf("Merge-overwrite: unexpected result %+v with %d contacts", result, dir.ContactCount())
+	}
+	if c, _ := dir.SearchContact("Dupont"); c.First != "Jeannot" || c.ID != 1 || c.Code != "1" {
+		t.Errorf("Merge-overwrite did not update Dupont in place: %+v", c)
+	}
+
+	if _, err := ParseImportStrategy("merge"); err == nil {
+		t.Error("Expected an unknown strategy to be rejected")
+	}
+}
+
+// TestPreviewImport tests the row actions, errors and warnings of an import preview
+func TestPreviewImport(t *testing.T) {
+	dir := NewDirectory()
+	dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "0123456789"})
+	dir.InsertContact(Contact{Name: "Martin", First: "Paul", Phone: "0622222222", Code: "2"})
+	incoming := []Contact{
+		{Name: "Dupont", First: "Jeannot", Phone: "0123456789"},
+		{Name: "Durand", First: "Marie", Phone: "0611111111", Code: "2"},
+		{Name: "Leroy", Phone: "0633333333", Birthday: "1990-13-01"},
+		{Name: "Durand", First: "Marie", Phone: "0611111111"},
+	}
+
+	preview := dir.PreviewImport(incoming, ImportOptions{Strategy: ImportMergeSkip})
+	actions := make([]string, len(preview.Rows))
+	for i, row := range preview.Rows {
+		actions[i] = row.Action
+	}
+	if want := []string{RowSkip, RowAdd, RowInvalid, RowAdd}; !slices.Equal(actions, want) {
+		t.Errorf("Actions = %v, want %v", actions, want)
+	}
+	if errs := preview.Rows[2].Errors; len(errs) != 2 || errs[0] != "first name is required" {
+		t.Errorf("Expected the missing first name and the bad birthday, got %q", errs)
+	}
+	if warnings := preview.Rows[1].Warnings; len(warnings) != 1 || warnings[0] != `code "2" is already used, it will be dropped` {
+		t.Errorf("Unexpected code warning %q", warnings)
+	}
+	if warnings := preview.Rows[3].Warnings; len(warnings) != 1 || warnings[0] != "same name and phone as row 2" {
+		t.Errorf("Unexpected duplicate warning %q", warnings)
+	}
+	if len(preview.Valid()) != 3 || preview.Count(RowInvalid) != 1 || preview.Removed != 0 {
+		t.Errorf("Unexpected counts: %d valid, %d invalid, %d removed", len(preview.Valid()), preview.Count(RowInvalid), preview.Removed)
+	}
+	if dir.ContactCount() != 2 {
+		t.Error("A preview must not modify the directory")
+	}
+
+	if preview := dir.PreviewImport(incoming, ImportOptions{Strategy: ImportMergeOverwrite}); preview.Rows[0].Action != RowUpdate {
+		t.Errorf("Merge-overwrite should update Dupont, got %s", preview.Rows[0].Action)
+	}
+	if preview := dir.PreviewImport(incoming, ImportOptions{}); preview.Strategy != ImportReplace || preview.Removed != 2 || preview.Rows[0].Action != RowAdd {
+		t.Errorf("Replace should remove the 2 current contacts and add every valid row: %+v", preview)
+	}
+}
+
+// TestImportInvalidContacts tests that every strategy leaves out and counts
+// the invalid contacts, and that a replace reports the contacts stored
+func TestImportInvalidContacts(t *testing.T) {
+	incoming := []Contact{
+		{Name: "Dupont", First: "Jean", Phone: "0123456789"},
+		{Name: "Dupont", First: "Jeannot", Phone: "0123456789"}, // Same key, replaces the row above
+		{Name: "Martin", Phone: "0698765432"},                   // No first name
+		{Name: "Durand", First: "Marie", Phone: "0611111111", Birthday: "1990-13-45"},
+	}
+
+	dir := NewDirectory()
+	result := dir.ImportContacts(incoming, ImportOptions{}, "test")
+	if result != (ImportResult{Added: 1, Invalid: 2}) || dir.ContactCount() != 1 {
+		t.Errorf("Replace: got %+v with %d contacts, want 1 added and 2 invalid", result, dir.ContactCount())
+	}
+	if got, _ := dir.ResolveContact("Dupont"); got.First != "Jeannot" {
+		t.Errorf("The last duplicate should be kept, got %+v", got)
+	}
+
+	for _, strategy := range []ImportStrategy{ImportMergeSkip, ImportMergeOverwrite} {
+		dir := NewDirectory()
+		result := dir.ImportContacts(incoming[2:], ImportOptions{Strategy: strategy}, "test")
+		if result != (ImportResult{Invalid: 2}) || dir.ContactCount() != 0 {
+			t.Errorf("%s: got %+v with %d contacts, want 2 invalid", strategy, result, dir.ContactCount())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package annuaire
+
+import (
+	"strings"
+)
+
+// NormalizationChange describes how one imported record would be rewritten
+type NormalizationChange struct {
+	Index  int     // Position of the record in the imported slice
+	Before Contact // Record as read from the source file
+	After  Contact // Record as it would be stored
+}
+
+// ChangedFields lists the names of the fields that differ ("name", "first", "phone")
+func (c NormalizationChange) ChangedFields() []string {
+	var fields []string
+	if c.Before.Name != c.After.Name {
+		fields = append(fields, "name")
+	}
+	if c.Before.First != c.After.First {
+		fields = append(fields, "first")
+	}
+	if c.Before.Phone != c.After.Phone {
+		fields = append(fields, "phone")
+	}
+	return fields
+}
+
+/**
+ * NormalizeContact returns a cleaned-up copy of a contact
+ *
+ * @param {Contact} c - Contact to normalize
+ * @return {Contact} Contact with normalized names and phone number
+ *
+ * Normalization rules:
+ * - Names are trimmed and runs of whitespace collapse to a single space
+ * - Phones lose their separators (spaces, dots, dashes, slashes, parentheses)
+ *   and the "(0)" trunk prefix, keeping digits and a leading "+", which is the
+ *   compact format used by the rest of the directory
+ *
+ * Usage:
+ *   clean := NormalizeContact(Contact{Name: " Dupont ", First: "Jean", Phone: "06.12.34.56.78"})
+ *   // clean.Phone == "0612345678"
+ */
+func NormalizeContact(c Contact) Contact {
+	c.Name = strings.Join(strings.Fields(c.Name), " ")
+	c.First = strings.Join(strings.Fields(c.First), " ")
+	c.Phone = NormalizePhone(c.Phone)
+	return c
+}
+
+/**
+ * NormalizePhone converts a phone number to the compact stored format
+ *
+ * @param {string} phone - Phone number as typed or imported
+ * @return {string} Digits with an optional leading "+"; values containing letters
+ *                  (extensions, vanity numbers) are only trimmed
+ */
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	compact := strings.ReplaceAll(phone, "(0)", "")
+
+	var b strings.Builder
+	for i, r := range compact {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case strings.ContainsRune(" .-/()\t", r):
+			// Separator, dropped
+		default:
+			// Anything else carries meaning the digits alone would lose
+			return phone
+		}
+	}
+	return b.String()
+}
+
+/**
+ * PreviewNormalization computes the normalization of a batch without applying it
+ *
+ * @param {[]Contact} contacts - Records about to be imported
+ * @return {[]NormalizationChange} One entry per record that normalization would modify
+ *
+ * Callers show the changes to the user and, once accepted, store the After values
+ */
+func PreviewNormalization(contacts []Contact) []NormalizationChange {
+	var changes []NormalizationChange
+	for i, contact := range contacts {
+		change := NormalizationChange{Index: i, Before: contact, After: NormalizeContact(contact)}
+		if len(change.ChangedFields()) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
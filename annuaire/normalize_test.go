@@ -0,0 +1,31 @@
+package annuaire
+
+import (
+	"testing"
+)
+
+// TestPreviewNormalization tests that only modified records are reported
+func TestPreviewNormalization(t *testing.T) {
+	contacts := []Contact{
+		{Name: "Martin", First: "Alice", Phone: "0123456789"},
+		{Name: "  Du  Pont ", First: "Jean", Phone: "+33 (0)6 12-34.56.78"},
+		{Name: "Vanity", First: "Call", Phone: "1-800-FLOWERS "},
+	}
+
+	changes := PreviewNormalization(contacts)
+	if len(changes) != 2 {
+		t.Fatalf("Expected 2 changes, got %d: %+v", len(changes), changes)
+	}
+
+	if changes[0].Index != 1 || changes[0].After.Name != "Du Pont" || changes[0].After.Phone != "+33612345678" {
+		t.Errorf("Unexpected normalization: %+v", changes[0].After)
+	}
+	if fields := changes[0].ChangedFields(); len(fields) != 2 {
+		t.Errorf("Expected name and phone to change, got %v", fields)
+	}
+
+	// Numbers with letters are only trimmed
+	if changes[1].After.Phone != "1-800-FLOWERS" {
+		t.Errorf("Vanity number should only be trimmed, got %q", changes[1].After.Phone)
+	}
+}
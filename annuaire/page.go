@@ -0,0 +1,38 @@
+package annuaire
+
+// Page is one page of a contact list, as shown by the web list and the JSON API
+type Page struct {
+	Contacts []Contact // Contacts of the page (empty past the last page)
+	Number   int       // Current page, starting at 1
+	PerPage  int       // Page size
+	Total    int       // Contacts on every page
+	Pages    int       // Number of pages (0 for an empty list)
+}
+
+/**
+ * Paginate cuts one page out of a contact list
+ *
+ * @param {[]Contact} contacts - Complete list, already sorted and filtered
+ * @param {int} number - Page number, starting at 1 (lower values mean 1)
+ * @param {int} perPage - Page size, at least 1
+ * @return {Page} Page sharing the memory of contacts; a page past the end is empty
+ */
+func Paginate(contacts []Contact, number, perPage int) Page {
+	perPage = max(perPage, 1)
+	page := Page{
+		Contacts: []Contact{},
+		Number:   max(number, 1),
+		PerPage:  perPage,
+		Total:    len(contacts),
+		Pages:    (len(contacts) + perPage - 1) / perPage,
+	}
+	if start := (page.Number - 1) * perPage; start < len(contacts) {
+		page.Contacts = contacts[start:min(start+perPage, len(contacts))]
+	}
+	return page
+}
+
+// Start returns the position of the first contact of the page in the complete list
+func (p Page) Start() int {
+	return (p.Number - 1) * p.PerPage
+}
@@ -0,0 +1,38 @@
+package annuaire
+
+import "testing"
+
+// TestPaginate tests cutting a contact list into pages
+func TestPaginate(t *testing.T) {
+	contacts := make([]Contact, 7)
+	for i := range contacts {
+		contacts[i] = Contact{ID: i + 1}
+	}
+
+	tests := []struct {
+		number, perPage      int
+		wantFirst, wantCount int
+		wantPages            int
+	}{
+		{1, 3, 1, 3, 3},
+		{3, 3, 7, 1, 3},
+		{4, 3, 0, 0, 3},
+		{0, 3, 1, 3, 3},
+		{1, 10, 1, 7, 1},
+		{1, 0, 1, 1, 7},
+	}
+	for _, tt := range tests {
+		page := Paginate(contacts, tt.number, tt.perPage)
+		if len(page.Contacts) != tt.wantCount || page.Pages != tt.wantPages || page.Total != 7 {
+			t.Errorf("Paginate(%d, %d) = %d contacts of %d pages, want %d of %d", tt.number, tt.perPage, len(page.Contacts), page.Pages, tt.wantCount, tt.wantPages)
+			continue
+		}
+		if tt.wantCount > 0 && page.Contacts[0].ID != tt.wantFirst {
+			t.Errorf("Paginate(%d, %d) starts with %d, want %d", tt.number, tt.perPage, page.Contacts[0].ID, tt.wantFirst)
+		}
+	}
+
+	if page := Paginate(nil, 1, 25); page.Pages != 0 || page.Contacts == nil {
+		t.Errorf("Empty list should give no page and a non-nil slice, got %+v", page)
+	}
+}
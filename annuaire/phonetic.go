@@ -0,0 +1,100 @@
+package annuaire
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// phoneticRules rewrite letter groups that sound alike in French names to a
+// single spelling; they are applied in order, after accents are removed
+var phoneticRules = strings.NewReplacer(
+	"SCH", "S", "CH", "S", "SH", "S", "PH", "F", "TH", "T", "GN", "NI", "BV", "V",
+	"GUE", "KE", "GUI", "KI", "QU", "K", "Q", "K", "CK", "K",
+	"CE", "SE", "CI", "SI", "CY", "SI", "C", "K",
+	"GE", "JE", "GI", "JI", "GY", "JI",
+	"EAU", "O", "AU", "O", "W", "V", "Z", "S", "X", "KS", "Y", "I",
+)
+
+// silentEndings are the final letters usually not pronounced in French names
+// ("Dupont", "Dupond" and "Dupon" sound the same)
+const silentEndings = "TDSXZ"
+
+/**
+ * PhoneticKey returns a key shared by names that sound alike in French
+ *
+ * @param {string} name - Last name or first name
+ * @return {string} First letter followed by the consonants heard in the name,
+ *   e.g. "DPN" for "Dupont", "Dupond" and "Dupon"; empty when the name has no
+ *   letter
+ *
+ * The key is computed in the spirit of Soundex, adapted to French spelling:
+ * accents are removed, groups written differently but pronounced the same
+ * (PH and F, C and K or S, EAU and O...) are unified, silent final consonants
+ * are dropped, then the vowels after the first letter and the doubled
+ * letters are removed
+ */
+func PhoneticKey(name string) string {
+	var letters strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if base, ok := baseLetters[r]; ok {
+			r = base
+		}
+		if r >= 'A' && r <= 'Z' {
+			letters.WriteRune(r)
+		}
+	}
+	word := strings.TrimRight(letters.String(), "H")
+	word = strings.TrimRight(word, silentEndings)
+	if word == "" {
+		return ""
+	}
+	word = phoneticRules.Replace(word)
+
+	key := []byte{word[0]}
+	for i := 1; i < len(word); i++ {
+		c := word[i]
+		if strings.IndexByte("AEIOUH", c) >= 0 || c == key[len(key)-1] {
+			continue
+		}
+		key = append(key, c)
+	}
+	return string(key)
+}
+
+// SearchOptions change how a search term matches contacts
+type SearchOptions struct {
+	Phonetic bool // Also match last and first names sounding like the term (see PhoneticKey)
+}
+
+/**
+ * FilterContactsWith is FilterContactsLimited with search options
+ *
+ * @param {context.Context} ctx - Context of the request
+ * @param {string} searchTerm - Last name, first name or phone number
+ * @param {SearchOptions} opts - Matching mode; the zero value matches exactly
+ * @param {SearchLimits} limits - Maximum number of matches and time budget
+ * @return {SearchResult} Matches found before a limit was reached, with the
+ *   place of the term in each of them (see FindMatches)
+ *
+ * Usage:
+ *   result := dir.FilterContactsWith(ctx, "Dupon", SearchOptions{Phonetic: true}, limits)
+ *   // result.Contacts holds Dupont and Dupond
+ */
+func (d *Directory) FilterContactsWith(ctx context.Context, searchTerm string, opts SearchOptions, limits SearchLimits) SearchResult {
+	key := ""
+	if opts.Phonetic && strings.ContainsFunc(searchTerm, unicode.IsLetter) {
+		key = PhoneticKey(searchTerm)
+	}
+	result := d.ScanContacts(ctx, func(c Contact) bool {
+		if matchesTerm(c, searchTerm) {
+			return true
+		}
+		return key != "" && (PhoneticKey(c.Name) == key || PhoneticKey(c.First) == key)
+	}, limits)
+	result.Matches = make([][]Match, len(result.Contacts))
+	for i, c := range result.Contacts {
+		result.Matches[i] = FindMatches(c, searchTerm, opts)
+	}
+	return result
+}
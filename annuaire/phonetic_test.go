@@ -0,0 +1,63 @@
+package annuaire
+
+import (
+	"context"
+	"testing"
+)
+
+// TestPhoneticKey tests that names sounding alike share a key
+func TestPhoneticKey(t *testing.T) {
+	same := [][]string{
+		{"Dupont", "Dupond", "Dupon", "DUPONT"},
+		{"Philippe", "Filipe", "Phillipe"},
+		{"Mathieu", "Matthieu"},
+		{"Céline", "Seline"},
+		{"Lefèvre", "Lefebvre", "Lefevre"},
+		{"Rousseau", "Rousso"},
+		{"Caron", "Karon"},
+	}
+	for _, names := range same {
+		want := PhoneticKey(names[0])
+		for _, name := range names[1:] {
+			if got := PhoneticKey(name); got != want {
+				t.Errorf("PhoneticKey(%q) = %q, want %q like %q", name, got, want, names[0])
+			}
+		}
+	}
+
+	different := [][2]string{{"Dupont", "Durand"}, {"Martin", "Bernard"}, {"Petit", "Pierre"}}
+	for _, pair := range different {
+		if PhoneticKey(pair[0]) == PhoneticKey(pair[1]) {
+			t.Errorf("%q and %q should not share the key %q", pair[0], pair[1], PhoneticKey(pair[0]))
+		}
+	}
+	if key := PhoneticKey("0123"); key != "" {
+		t.Errorf("A name without letters should have no key, got %q", key)
+	}
+}
+
+// TestPhoneticSearch tests the phonetic option of FilterContactsWith
+func TestPhoneticSearch(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.AddContact("Durand", "Philippe", "0600000000")
+
+	tests := []struct {
+		term     string
+		phonetic bool
+		want     int
+	}{
+		{"Dupon", false, 0},
+		{"Dupon", true, 1},
+		{"Dupond", true, 1},
+		{"Filipe", true, 1},
+		{"0123456789", true, 1},
+		{"0123", true, 0},
+	}
+	for _, tt := range tests {
+		result := dir.FilterContactsWith(context.Background(), tt.term, SearchOptions{Phonetic: tt.phonetic}, SearchLimits{})
+		if len(result.Contacts) != tt.want {
+			t.Errorf("FilterContactsWith(%q, phonetic=%v): %d matches, want %d", tt.term, tt.phonetic, len(result.Contacts), tt.want)
+		}
+	}
+}
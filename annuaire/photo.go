@@ -0,0 +1,106 @@
+package annuaire
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"strconv"
+
+	_ "image/gif" // Decoders accepted by NormalizePhoto
+	_ "image/png"
+)
+
+// PhotoSize is the width and height in pixels of the stored avatars
+const PhotoSize = 128
+
+// maxPhotoPixels bounds the images accepted by NormalizePhoto, so that a
+// small file declaring huge dimensions cannot exhaust memory once decoded
+const maxPhotoPixels = 40_000_000
+
+// photoQuality is the JPEG quality of the stored avatars
+const photoQuality = 85
+
+/**
+ * NormalizePhoto turns an uploaded image into a stored avatar
+ *
+ * @param {io.Reader} r - JPEG, PNG or GIF image
+ * @return {[]byte} JPEG of PhotoSize x PhotoSize pixels: the centre square of
+ *   the image, scaled by averaging the pixels it covers
+ * @return {error} Returns an error if the data is not a supported image or is
+ *   larger than 40 megapixels
+ *
+ * Re-encoding also drops the metadata of the original file (EXIF location...)
+ */
+func NormalizePhoto(r io.Reader) ([]byte, error) {
+	var data bytes.Buffer
+	config, format, err := image.DecodeConfig(io.TeeReader(r, &data))
+	if err != nil {
+		return nil, &FieldError{Field: "photo", Message: "not a JPEG, PNG or GIF image"}
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPhotoPixels {
+		return nil, &FieldError{Field: "photo", Message: fmt.Sprintf("image of %dx%d pixels is too large", config.Width, config.Height)}
+	}
+	src, _, err := image.Decode(io.MultiReader(&data, r))
+	if err != nil {
+		return nil, &FieldError{Field: "photo", Message: fmt.Sprintf("invalid %s image: %v", format, err)}
+	}
+
+	// Centre square of the source
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, PhotoSize, PhotoSize))
+	for y := range PhotoSize {
+		top, bottom := y0+y*side/PhotoSize, y0+max((y+1)*side/PhotoSize, y*side/PhotoSize+1)
+		for x := range PhotoSize {
+			left, right := x0+x*side/PhotoSize, x0+max((x+1)*side/PhotoSize, x*side/PhotoSize+1)
+			var sr, sg, sb, sa, n uint64
+			for sy := top; sy < bottom; sy++ {
+				for sx := left; sx < right; sx++ {
+					r, g, b, a := src.At(sx, sy).RGBA()
+					sr, sg, sb, sa, n = sr+uint64(r), sg+uint64(g), sb+uint64(b), sa+uint64(a), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(sr / n), G: uint16(sg / n), B: uint16(sb / n), A: uint16(sa / n)})
+		}
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: photoQuality}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// PhotoJPEG returns the decoded avatar of the contact, nil when it has none
+func (c Contact) PhotoJPEG() ([]byte, error) {
+	if c.Photo == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(c.Photo)
+}
+
+/**
+ * SetPhoto sets or removes the avatar of a contact
+ *
+ * @param {int} id - Contact ID
+ * @param {[]byte} photo - JPEG returned by NormalizePhoto, nil to go back to initials
+ * @return {error} Returns an error if no contact has this ID
+ */
+func (d *Directory) SetPhoto(id int, photo []byte) error {
+	contact, err := d.ResolveContact("#" + strconv.Itoa(id))
+	if err != nil {
+		return err
+	}
+	contact.Photo = ""
+	if photo != nil {
+		contact.Photo = base64.StdEncoding.EncodeToString(photo)
+	}
+	return d.SaveContact(contact)
+}
@@ -0,0 +1,86 @@
+package annuaire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// TestNormalizePhoto tests the cropping, scaling and validation of avatars
+func TestNormalizePhoto(t *testing.T) {
+	// 300x200: red on the left third, blue elsewhere; the centre square starts at x=50
+	src := image.NewRGBA(image.Rect(0, 0, 300, 200))
+	for y := range 200 {
+		for x := range 300 {
+			c := color.RGBA{B: 255, A: 255}
+			if x < 100 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	var upload bytes.Buffer
+	png.Encode(&upload, src)
+
+	photo, err := NormalizePhoto(&upload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(photo))
+	if err != nil || format != "jpeg" || img.Bounds().Dx() != PhotoSize || img.Bounds().Dy() != PhotoSize {
+		t.Fatalf("Expected a %dpx square JPEG, got %s %v (%v)", PhotoSize, format, img.Bounds(), err)
+	}
+	if r, _, b, _ := img.At(10, 64).RGBA(); r < b {
+		t.Error("The left quarter of the centre square should stay red")
+	}
+	if r, _, b, _ := img.At(100, 64).RGBA(); b < r {
+		t.Error("The right of the centre square should stay blue")
+	}
+
+	if _, err := NormalizePhoto(bytes.NewReader([]byte("GIF89a but not really"))); err == nil {
+		t.Error("Invalid image data should be refused")
+	}
+
+	// A tiny PNG declaring 10000x10000 pixels must be refused before decoding
+	var tiny bytes.Buffer
+	png.Encode(&tiny, image.NewGray(image.Rect(0, 0, 1, 1)))
+	bomb := tiny.Bytes()
+	binary.BigEndian.PutUint32(bomb[16:], 10000) // IHDR width
+	binary.BigEndian.PutUint32(bomb[20:], 10000) // IHDR height
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	if _, err := NormalizePhoto(bytes.NewReader(bomb)); err == nil || err.Error() != "image of 10000x10000 pixels is too large" {
+		t.Errorf("Expected the size to be refused, got %v", err)
+	}
+}
+
+// TestSetPhoto tests storing and removing the avatar of a contact
+func TestSetPhoto(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	contact, _ := dir.ResolveContact("Dupont")
+
+	var photo bytes.Buffer
+	jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, PhotoSize, PhotoSize)), nil)
+	if err := dir.SetPhoto(contact.ID, photo.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	contact, _ = dir.ResolveContact("Dupont")
+	if stored, err := contact.PhotoJPEG(); err != nil || !bytes.Equal(stored, photo.Bytes()) {
+		t.Errorf("Stored photo differs from the uploaded one (%v)", err)
+	}
+
+	if err := dir.SetPhoto(contact.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if contact, _ = dir.ResolveContact("Dupont"); contact.Photo != "" {
+		t.Error("Photo should be removed")
+	}
+	if err := dir.SetPhoto(99, photo.Bytes()); err == nil {
+		t.Error("Setting the photo of an unknown contact should fail")
+	}
+}
@@ -0,0 +1,147 @@
+package annuaire
+
+import (
+	"io"
+	"strings"
+	"unicode"
+)
+
+// ContactFields lists the field names accepted by privacy settings,
+// matching the JSON names of Contact
+var ContactFields = []string{"name", "first", "phone", "birthday", "address", "custom"}
+
+/**
+ * ValidateFields checks that every name refers to a Contact field
+ *
+ * @param {[]string} fields - Field names such as "phone"
+ * @return {error} Returns an error naming the first unknown field
+ */
+func ValidateFields(fields []string) error {
+	for _, field := range fields {
+		known := false
+		for _, candidate := range ContactFields {
+			if field == candidate {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return errorOf(ErrInvalidInput, "unknown field %q (available: %s)", field, strings.Join(ContactFields, ", "))
+		}
+	}
+	return nil
+}
+
+/**
+ * MaskValue hides the middle of a value while keeping it recognizable
+ *
+ * @param {string} value - Value to mask
+ * @return {string} Masked value
+ *
+ * Phone numbers keep their first and last two digits, the rest is shown as
+ * pairs of stars ("0612345612" becomes "06 ** ** ** 12"); other text keeps
+ * its first character only ("Dupont" becomes "D*****")
+ */
+func MaskValue(value string) string {
+	digits := make([]rune, 0, len(value))
+	for _, r := range value {
+		if unicode.IsDigit(r) {
+			digits = append(digits, r)
+		}
+	}
+
+	// Treat the value as a phone number when it is mostly digits
+	if len(digits) >= 6 && len(digits)*2 >= len([]rune(value)) {
+		var parts []string
+		parts = append(parts, string(digits[:2]))
+		for i := 2; i < len(digits)-2; i += 2 {
+			parts = append(parts, strings.Repeat("*", min(2, len(digits)-2-i)))
+		}
+		parts = append(parts, string(digits[len(digits)-2:]))
+		return strings.Join(parts, " ")
+	}
+
+	runes := []rune(value)
+	if len(runes) <= 1 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-1)
+}
+
+/**
+ * MaskContact returns a copy of a contact with the given fields masked
+ *
+ * @param {Contact} c - Contact to mask
+ * @param {[]string} fields - Field names to mask ("name", "first", "phone", "birthday", "address", "custom")
+ * @return {Contact} Contact suitable for read-only shares
+ */
+func MaskContact(c Contact, fields []string) Contact {
+	for _, field := range fields {
+		switch field {
+		case "name":
+			c.Name = MaskValue(c.Name)
+		case "first":
+			c.First = MaskValue(c.First)
+		case "phone":
+			c.Phone = MaskValue(c.Phone)
+		case "birthday":
+			// A partial date still helps guessing, so hide it entirely
+			if c.Birthday != "" {
+				c.Birthday = "****-**-**"
+			}
+		case "address":
+			// The city and country locate the contact without leading to the door
+			c.Address = Address{City: c.Address.City, Country: c.Address.Country}
+		case "custom":
+			// Keep the names, which say what is known, and mask the values
+			masked := make(map[string]string, len(c.Custom))
+			for key, value := range c.Custom {
+				masked[key] = MaskValue(value)
+			}
+			c.Custom = masked
+		}
+	}
+	return c
+}
+
+// RedactedJSONEncoder encodes contacts like EncodeJSON, without the given fields
+func RedactedJSONEncoder(fields []string) ExportEncoder {
+	return func(w io.Writer, contacts []Contact) error {
+		return writeEnvelope(w, RedactContacts(contacts, fields))
+	}
+}
+
+/**
+ * RedactContacts converts contacts to JSON-ready records without the given fields
+ *
+ * @param {[]Contact} contacts - Contacts to export
+ * @param {[]string} fields - Field names left out of every record
+ * @return {[]map[string]any} One record per contact, keyed by JSON field name
+ *
+ * Used by non-admin exports: sensitive fields are absent, not merely masked
+ */
+func RedactContacts(contacts []Contact, fields []string) []map[string]any {
+	excluded := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		excluded[field] = true
+	}
+
+	records := make([]map[string]any, 0, len(contacts))
+	for _, c := range contacts {
+		record := make(map[string]any, len(ContactFields))
+		for field, value := range map[string]string{"name": c.Name, "first": c.First, "phone": c.Phone, "birthday": c.Birthday} {
+			// Like the Contact JSON encoding, an empty birthday is omitted
+			if !excluded[field] && (value != "" || field != "birthday") {
+				record[field] = value
+			}
+		}
+		if !excluded["address"] && !c.Address.IsZero() {
+			record["address"] = c.Address
+		}
+		if !excluded["custom"] && len(c.Custom) > 0 {
+			record["custom"] = c.Custom
+		}
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,36 @@
+package annuaire
+
+import (
+	"testing"
+)
+
+// TestMaskValue tests phone and text masking
+func TestMaskValue(t *testing.T) {
+	cases := map[string]string{
+		"0612345612":     "06 ** ** ** 12",
+		"06 12 34 56 12": "06 ** ** ** 12",
+		"+33612345612":   "33 ** ** ** * 12",
+		"Dupont":         "D*****",
+		"":               "",
+	}
+	for value, want := range cases {
+		if got := MaskValue(value); got != want {
+			t.Errorf("MaskValue(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+// TestRedactContacts tests that excluded fields are absent from export records
+func TestRedactContacts(t *testing.T) {
+	records := RedactContacts([]Contact{{Name: "Dupont", First: "Jean", Phone: "0612345612"}}, []string{"phone"})
+	if _, ok := records[0]["phone"]; ok {
+		t.Error("Sensitive field was exported")
+	}
+	if records[0]["name"] != "Dupont" || records[0]["first"] != "Jean" {
+		t.Errorf("Unexpected record: %v", records[0])
+	}
+
+	if err := ValidateFields([]string{"phone", "email"}); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+}
@@ -0,0 +1,61 @@
+package annuaire
+
+import (
+	"context"
+	"strings"
+)
+
+// SearchQuery combines search criteria; a contact matches when it meets
+// every criterion that is set (AND), and empty criteria are ignored
+type SearchQuery struct {
+	Name  string // Last name prefix, ignoring case ("dup" matches Dupont)
+	First string // First name prefix, ignoring case
+	Phone string // Phone number prefix, ignoring separators ("06 12" matches 0612345678)
+	Tag   string // Tag carried by the contact, ignoring case (see HasTag)
+}
+
+// IsZero reports whether no criterion is set, i.e. the query matches every contact
+func (q SearchQuery) IsZero() bool {
+	return q == SearchQuery{}
+}
+
+/**
+ * Matches reports whether a contact meets every criterion of the query
+ *
+ * @param {Contact} c - Contact to test
+ * @return {bool} True if all the criteria that are set match
+ */
+func (q SearchQuery) Matches(c Contact) bool {
+	if q.Name != "" && !hasPrefixFold(c.Name, q.Name) {
+		return false
+	}
+	if q.First != "" && !hasPrefixFold(c.First, q.First) {
+		return false
+	}
+	if q.Phone != "" && !strings.HasPrefix(NormalizePhone(c.Phone), NormalizePhone(q.Phone)) {
+		return false
+	}
+	return q.Tag == "" || c.HasTag(q.Tag)
+}
+
+// hasPrefixFold reports whether value starts with prefix, ignoring case and
+// the spaces around the prefix
+func hasPrefixFold(value, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(value), strings.ToLower(strings.TrimSpace(prefix)))
+}
+
+/**
+ * Query finds the contacts matching every criterion of a structured query
+ *
+ * @param {context.Context} ctx - Context of the request; the scan stops when it is done
+ * @param {SearchQuery} q - Criteria combined with AND; the zero query matches every contact
+ * @param {SearchLimits} limits - Maximum number of matches and time budget
+ * @return {SearchResult} Matches found before a limit was reached
+ *
+ * Usage:
+ *   result := dir.Query(ctx, SearchQuery{Name: "Dupont", Phone: "06"}, limits)
+ *   // Dupont contacts with a mobile number
+ */
+func (d *Directory) Query(ctx context.Context, q SearchQuery, limits SearchLimits) SearchResult {
+	return d.ScanContacts(ctx, q.Matches, limits)
+}
@@ -0,0 +1,43 @@
+package annuaire
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// TestQuery tests that the criteria of a structured query combine with AND
+func TestQuery(t *testing.T) {
+	dir := NewDirectory()
+	dir.ReplaceContacts([]Contact{
+		{Name: "Dupont", First: "Jean", Phone: "0612345678", Tags: []string{"work"}},
+		{Name: "Dupont", First: "Marie", Phone: "0145678901", Tags: []string{"family"}},
+		{Name: "Dupond", First: "Jeanne", Phone: "06 98 76 54 32"},
+		{Name: "Martin", First: "Jean", Phone: "0699999999", Tags: []string{"work"}},
+	})
+
+	tests := []struct {
+		query SearchQuery
+		want  []string // First names of the matches
+	}{
+		{SearchQuery{Name: "Dupont", Phone: "06"}, []string{"Jean"}},
+		{SearchQuery{Name: "dup", Phone: "06 9"}, []string{"Jeanne"}},
+		{SearchQuery{First: "jean"}, []string{"Jean", "Jean", "Jeanne"}},
+		{SearchQuery{Tag: "WORK"}, []string{"Jean", "Jean"}},
+		{SearchQuery{Name: "Dupont", Tag: "work", First: "Marie"}, nil},
+		{SearchQuery{}, []string{"Jean", "Jean", "Jeanne", "Marie"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, c := range dir.Query(context.Background(), tt.query, SearchLimits{}).Contacts {
+			got = append(got, c.First)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Query(%+v) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	if !(SearchQuery{}).IsZero() || (SearchQuery{Tag: "work"}).IsZero() {
+		t.Error("IsZero should only be true for the empty query")
+	}
+}
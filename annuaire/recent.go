@@ -0,0 +1,36 @@
+package annuaire
+
+import "slices"
+
+/**
+ * RecentContacts lists the contacts added or changed last
+ *
+ * @param {int} n - Number of contacts wanted (0 or less returns none)
+ * @return {[]Contact} At most n contacts, the most recently changed first;
+ *   contacts never changed through the directory (no UpdatedAt, e.g. from an
+ *   older file) come last, the most recently added (highest ID) first
+ *
+ * Usage:
+ *   for _, c := range dir.RecentContacts(5) {
+ *       fmt.Printf("%s %s, %s\n", c.First, c.Name, c.UpdatedAt.Format(time.DateTime))
+ *   }
+ */
+func (d *Directory) RecentContacts(n int) []Contact {
+	if n <= 0 {
+		return nil
+	}
+	d.mu.RLock()
+	contacts := make([]Contact, 0, len(d.contacts))
+	for _, c := range d.contacts {
+		contacts = append(contacts, c)
+	}
+	d.mu.RUnlock()
+
+	slices.SortFunc(contacts, func(a, b Contact) int {
+		if order := b.UpdatedAt.Compare(a.UpdatedAt); order != 0 {
+			return order
+		}
+		return b.ID - a.ID
+	})
+	return contacts[:min(n, len(contacts))]
+}
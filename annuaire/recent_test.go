@@ -0,0 +1,46 @@
+package annuaire
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestRecentContacts tests that the contacts changed last come first
+func TestRecentContacts(t *testing.T) {
+	defer func(clock func() time.Time) { contactClock = clock }(contactClock)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	contactClock = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.AddContact("Martin", "Paul", "0600000000")
+	dir.AddContact("Durand", "Marie", "0700000000")
+	if err := dir.UpdateContact("Dupont", "Jean", "0123456780"); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(contacts []Contact) []string {
+		var list []string
+		for _, c := range contacts {
+			list = append(list, c.Name)
+		}
+		return list
+	}
+	tests := []struct {
+		n    int
+		want []string
+	}{
+		{2, []string{"Dupont", "Durand"}},
+		{10, []string{"Dupont", "Durand", "Martin"}},
+		{0, nil},
+	}
+	for _, tt := range tests {
+		if got := names(dir.RecentContacts(tt.n)); !slices.Equal(got, tt.want) {
+			t.Errorf("RecentContacts(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package annuaire
+
+import (
+	"strings"
+)
+
+// DefaultRegion is assumed for national phone numbers when none is configured
+const DefaultRegion = "FR"
+
+// callingCodes maps the supported regions (ISO 3166-1 alpha-2) to their
+// country calling code; all of them drop a national trunk prefix ("0" or "1")
+var callingCodes = map[string]string{
+	"BE": "32",
+	"CA": "1",
+	"CH": "41",
+	"DE": "49",
+	"ES": "34",
+	"FR": "33",
+	"GB": "44",
+	"LU": "352",
+	"NL": "31",
+	"US": "1",
+}
+
+// Regions lists the supported phone regions, sorted
+func Regions() []string {
+	return sortedKeys(callingCodes)
+}
+
+/**
+ * ValidateRegion checks that a phone region is supported
+ *
+ * @param {string} region - ISO 3166-1 alpha-2 code, e.g. "FR"
+ * @return {error} Returns an error listing the supported regions otherwise
+ */
+func ValidateRegion(region string) error {
+	if _, ok := callingCodes[region]; !ok {
+		return errorOf(ErrInvalidInput, "unsupported region %q (expected one of: %s)", region, strings.Join(Regions(), ", "))
+	}
+	return nil
+}
+
+/**
+ * InternationalPhone converts a phone number to the international "+" format
+ *
+ * @param {string} phone - Stored phone number, national or international
+ * @param {string} region - Region of national numbers, e.g. "FR"
+ * @return {string} e.g. "+33612345678" for "0612345678" in "FR"; numbers that
+ *                  cannot be converted (letters, unknown region) are returned normalized
+ *
+ * Usage:
+ *   href := "tel:" + InternationalPhone(contact.Phone, "FR")
+ */
+func InternationalPhone(phone, region string) string {
+	phone = NormalizePhone(phone)
+	code, ok := callingCodes[region]
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		return phone
+	case strings.HasPrefix(phone, "00") && len(phone) > 2:
+		return "+" + phone[2:]
+	case !ok || strings.Trim(phone, "0123456789") != "":
+		return phone
+	case code == "1" && len(phone) == 10:
+		// North American numbers are dialed without trunk prefix
+		return "+1" + phone
+	case code == "1" && len(phone) == 11 && phone[0] == '1':
+		return "+" + phone
+	case code != "1" && len(phone) > 1 && phone[0] == '0':
+		return "+" + code + phone[1:]
+	}
+	return phone
+}
@@ -0,0 +1,32 @@
+package annuaire
+
+import "testing"
+
+// TestInternationalPhone tests the conversion of national numbers per region
+func TestInternationalPhone(t *testing.T) {
+	tests := []struct {
+		phone, region, want string
+	}{
+		{"06 12 34 56 78", "FR", "+33612345678"},
+		{"0612345678", "BE", "+32612345678"},
+		{"+33612345678", "US", "+33612345678"},
+		{"0033612345678", "GB", "+33612345678"},
+		{"(555) 123-4567", "US", "+15551234567"},
+		{"1 555 123 4567", "CA", "+15551234567"},
+		{"0612345678", "XX", "0612345678"},
+		{"3615", "FR", "3615"},
+		{"1-800-FLOWERS", "US", "1-800-FLOWERS"},
+	}
+	for _, tt := range tests {
+		if got := InternationalPhone(tt.phone, tt.region); got != tt.want {
+			t.Errorf("InternationalPhone(%q, %s) = %q, want %q", tt.phone, tt.region, got, tt.want)
+		}
+	}
+
+	if err := ValidateRegion("FR"); err != nil {
+		t.Errorf("ValidateRegion(FR): %v", err)
+	}
+	if err := ValidateRegion("fr"); err == nil {
+		t.Error("Expected an error for a lowercase region")
+	}
+}
@@ -0,0 +1,313 @@
+package annuaire
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxCodeLength bounds speed-dial codes, which are meant to be typed quickly
+const maxCodeLength = 8
+
+// AmbiguousReferenceError is returned by ResolveContact when a reference
+// matches several contacts at the same precedence level, and by the methods
+// taking a last name (DeleteContact, UpdateContact, SetBirthday) when several
+// contacts share it
+type AmbiguousReferenceError struct {
+	Ref     string    // Reference as typed
+	Matches []Contact // Candidates, sorted alphabetically
+}
+
+// Error lists the candidates with their IDs so the user can pick one
+func (e *AmbiguousReferenceError) Error() string {
+	candidates := make([]string, len(e.Matches))
+	for i, c := range e.Matches {
+		candidates[i] = fmt.Sprintf("%s %s (#%d)", c.First, c.Name, c.ID)
+	}
+	return fmt.Sprintf("%q matches %d contacts: %s; use an ID such as #%d or more letters",
+		e.Ref, len(e.Matches), strings.Join(candidates, ", "), e.Matches[0].ID)
+}
+
+// Unwrap makes errors.Is(err, ErrAmbiguous) true, and errors.Is(err,
+// ErrInvalidInput) as well: the reference is too vague
+func (e *AmbiguousReferenceError) Unwrap() []error {
+	return []error{ErrAmbiguous, ErrInvalidInput}
+}
+
+/**
+ * ValidateCode checks a speed-dial code before it is stored
+ *
+ * @param {string} code - Code to check (empty means no code and is valid)
+ * @return {error} Returns an error unless the code has 1 to 8 letters or digits
+ */
+func ValidateCode(code string) error {
+	if code == "" {
+		return nil
+	}
+	if len([]rune(code)) > maxCodeLength {
+		return &FieldError{Field: "code", Message: fmt.Sprintf("invalid code %q (at most %d characters)", code, maxCodeLength)}
+	}
+	for _, r := range code {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return &FieldError{Field: "code", Message: fmt.Sprintf("invalid code %q (letters and digits only)", code)}
+		}
+	}
+	return nil
+}
+
+/**
+ * ResolveContact finds the single contact designated by a user-typed reference
+ *
+ * @param {string} ref - ID ("#12" or "12"), speed-dial code, exact name or name prefix
+ * @return {Contact} The designated contact
+ * @return {error} *AmbiguousReferenceError if several contacts match, or a
+ *                 "no contact matches" error matching ErrContactNotFound
+ *
+ * References are tried in this order, stopping at the first level with matches:
+ * 1. "#12" is always an ID
+ * 2. Speed-dial code (case-insensitive), so that "1" dials the contact coded "1"
+ * 3. Bare number as an ID
+ * 4. Exact last name or "First Last" full name (case-insensitive)
+ * 5. Prefix of the last name or first name (case-insensitive)
+ *
+ * Usage:
+ *   contact, err := dir.ResolveContact("dup")
+ */
+func (d *Directory) ResolveContact(ref string) (Contact, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return Contact{}, errorOf(ErrInvalidInput, "contact reference required")
+	}
+	if idText, ok := strings.CutPrefix(ref, "#"); ok {
+		id, err := strconv.Atoi(idText)
+		if err != nil {
+			return Contact{}, errorOf(ErrInvalidInput, "invalid contact ID %q", ref)
+		}
+		// IDs are indexed: no need to copy the whole directory
+		d.mu.RLock()
+		key, found := d.keyOfLocked(id)
+		contact := d.contacts[key]
+		d.mu.RUnlock()
+		if !found {
+			return Contact{}, errorOf(ErrContactNotFound, "no contact matches %q", ref)
+		}
+		return contact, nil
+	}
+	contacts := d.ListContacts()
+
+	lower := strings.ToLower(ref)
+	levels := []func(Contact) bool{
+		func(c Contact) bool { return c.Code != "" && strings.EqualFold(c.Code, ref) },
+		func(c Contact) bool { return strconv.Itoa(c.ID) == ref },
+		func(c Contact) bool {
+			return strings.EqualFold(c.Name, ref) || strings.EqualFold(c.First+" "+c.Name, ref)
+		},
+		func(c Contact) bool {
+			return strings.HasPrefix(strings.ToLower(c.Name), lower) || strings.HasPrefix(strings.ToLower(c.First), lower)
+		},
+	}
+	for _, match := range levels {
+		contact, err := resolveLevel(ref, contacts, match)
+		if err == nil || errors.As(err, new(*AmbiguousReferenceError)) {
+			return contact, err
+		}
+	}
+	return Contact{}, errorOf(ErrContactNotFound, "no contact matches %q", ref)
+}
+
+// resolveLevel applies one precedence level of ResolveContact
+func resolveLevel(ref string, contacts []Contact, match func(Contact) bool) (Contact, error) {
+	var matches []Contact
+	for _, c := range contacts {
+		if match(c) {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Contact{}, errorOf(ErrContactNotFound, "no contact matches %q", ref)
+	case 1:
+		return matches[0], nil
+	}
+	SortContacts(matches, nil)
+	return Contact{}, &AmbiguousReferenceError{Ref: ref, Matches: matches}
+}
+
+/**
+ * FindByPhone finds the single contact with a phone number
+ *
+ * @param {string} phone - Phone number, in the stored format or as typed
+ *   ("06 12 34 56 78" finds "0612345678")
+ * @return {Contact} The contact with this number
+ * @return {error} ErrContactNotFound if no contact has it, or an
+ *                 *AmbiguousReferenceError if several share it
+ *
+ * Unlike a last name, a number rarely designates several contacts, so scripts
+ * can delete or update a contact by phone without knowing its ID
+ */
+func (d *Directory) FindByPhone(phone string) (Contact, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	keys := d.index.byPhone[phone]
+	if len(keys) == 0 {
+		keys = d.index.byPhone[NormalizePhone(phone)]
+	}
+	key, err := d.uniqueKeyLocked(phone, keys)
+	if errors.Is(err, ErrContactNotFound) {
+		return Contact{}, errorOf(ErrContactNotFound, "no contact has phone %q", phone)
+	}
+	if err != nil {
+		return Contact{}, err
+	}
+	return d.contacts[key], nil
+}
+
+/**
+ * FindByFullName finds the single contact with a first and last name
+ *
+ * @param {string} first - First name (case-insensitive)
+ * @param {string} name - Last name (case-insensitive)
+ * @return {Contact} The contact with this full name
+ * @return {error} ErrContactNotFound if no contact has it, or an
+ *                 *AmbiguousReferenceError if several share it (same full
+ *                 name, different phones)
+ *
+ * Picks Jean Dupont out of the Duponts that make the last name alone ambiguous
+ */
+func (d *Directory) FindByFullName(first, name string) (Contact, error) {
+	first, name = strings.TrimSpace(first), strings.TrimSpace(name)
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var keys []string
+	for indexed, nameKeys := range d.index.byName {
+		if !strings.EqualFold(indexed, name) {
+			continue
+		}
+		for _, key := range nameKeys {
+			if strings.EqualFold(d.contacts[key].First, first) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	key, err := d.uniqueKeyLocked(first+" "+name, keys)
+	if errors.Is(err, ErrContactNotFound) {
+		return Contact{}, errorOf(ErrContactNotFound, "no contact is named %s %s", first, name)
+	}
+	if err != nil {
+		return Contact{}, err
+	}
+	return d.contacts[key], nil
+}
+
+/**
+ * SaveContact stores the new values of an existing contact, identified by its ID
+ *
+ * @param {Contact} c - Modified contact, typically obtained from ResolveContact
+ * @return {error} Returns an error if the ID is unknown, a field is invalid, or
+ *                 the name/phone pair or the speed-dial code is already taken
+ *
+ * Unlike UpdateContact, every field is replaced, so callers read the contact,
+ * change what they need and save it back
+ *
+ * Usage:
+ *   c, _ := dir.ResolveContact("dup")
+ *   c.Code = "1"
+ *   err := dir.SaveContact(c)
+ */
+func (d *Directory) SaveContact(c Contact) error {
+	if err := requireFields(c); err != nil {
+		return err
+	}
+	if err := ValidateBirthday(c.Birthday); err != nil {
+		return err
+	}
+	if err := ValidateCode(c.Code); err != nil {
+		return err
+	}
+	if err := ValidateTags(c.Tags); err != nil {
+		return err
+	}
+	if err := ValidateAddress(c.Address); err != nil {
+		return err
+	}
+	if err := ValidateCustomFields(c.Custom); err != nil {
+		return err
+	}
+	c.Tags = NormalizeTags(c.Tags)
+	c.Custom = NormalizeCustomFields(c.Custom)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	oldKey, found := d.keyOfLocked(c.ID)
+	if !found {
+		return ErrContactNotFound
+	}
+	key := contactKey(c)
+	if _, exists := d.contacts[key]; exists && key != oldKey {
+		return ErrDuplicateContact
+	}
+	if err := d.checkCodeLocked(c.Code, c.ID); err != nil {
+		return err
+	}
+
+	before := d.contacts[oldKey]
+	c.UpdatedAt = before.UpdatedAt    // Set by the directory only
+	c.Source = before.Source          // Set by imports only
+	if reflect.DeepEqual(before, c) { // Tags are normalized, so equal contacts are deeply equal
+		return nil // Nothing changed, nothing to record
+	}
+	touch(&c)
+	d.removeLocked(oldKey)
+	d.putLocked(c)
+	d.generation++
+	d.recordLocked(OpUpdate, &before, &c, "")
+	return nil
+}
+
+/**
+ * DeleteContactByID removes exactly the contact with the given ID
+ *
+ * @param {int} id - ID of the contact, e.g. from ResolveContact
+ * @return {error} Returns an error if no contact has this ID
+ */
+func (d *Directory) DeleteContactByID(id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key, found := d.keyOfLocked(id)
+	if !found {
+		return ErrContactNotFound
+	}
+	before := d.contacts[key]
+	d.removeLocked(key)
+	d.generation++
+	d.recordLocked(OpDelete, &before, nil, "")
+	return nil
+}
+
+// keyOfLocked returns the storage key of the contact with the given ID
+// The caller must hold d.mu
+func (d *Directory) keyOfLocked(id int) (string, bool) {
+	key, found := d.index.byID[id]
+	return key, found
+}
+
+// checkCodeLocked reports an error if another contact than exceptID uses code
+// The caller must hold d.mu
+func (d *Directory) checkCodeLocked(code string, exceptID int) error {
+	if code == "" {
+		return nil
+	}
+	for _, key := range d.index.byCode[strings.ToLower(code)] {
+		if contact := d.contacts[key]; contact.ID != exceptID {
+			return errorOf(ErrCodeInUse, "code %q is already used by %s %s", code, contact.First, contact.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package annuaire
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestResolveContact tests every reference kind and the ambiguity error
+func TestResolveContact(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.AddContact("Dupuis", "Marie", "0987654321")
+	dir.InsertContact(Contact{Name: "Martin", First: "Paul", Phone: "0600000000", Code: "1"})
+	dir.AddContact("Leroy", "Dupin", "0700000000")
+
+	cases := map[string]string{
+		"#1":           "Dupont", // ID
+		"2":            "Dupuis", // bare ID
+		"1":            "Martin", // speed-dial code wins over ID 1
+		"dupont":       "Dupont", // exact name, case-insensitive
+		"Marie Dupuis": "Dupuis", // full name
+		"mart":         "Martin", // prefix
+	}
+	for ref, want := range cases {
+		c, err := dir.ResolveContact(ref)
+		if err != nil || c.Name != want {
+			t.Errorf("ResolveContact(%q) = %s, %v; want %s", ref, c.Name, err, want)
+		}
+	}
+
+	// "dup" prefixes two last names and one first name
+	_, err := dir.ResolveContact("dup")
+	var ambiguous *AmbiguousReferenceError
+	if !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 3 {
+		t.Fatalf("Expected an ambiguity over 3 contacts, got %v", err)
+	}
+	if _, err := dir.ResolveContact("zzz"); err == nil || errors.As(err, &ambiguous) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
+
+// TestHomonyms tests that the methods taking a last name refuse to pick
+// among contacts sharing it
+func TestHomonyms(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Marie", "0600000000")
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.AddContact("Martin", "Paul", "0700000000")
+	generation := dir.Generation()
+
+	for name, err := range map[string]error{
+		"delete":   dir.DeleteContact("Dupont"),
+		"update":   dir.UpdateContact("Dupont", "", "0611111111"),
+		"birthday": dir.SetBirthday("Dupont", "1985-04-12"),
+	} {
+		var ambiguous *AmbiguousReferenceError
+		if !errors.As(err, &ambiguous) || !errors.Is(err, ErrAmbiguous) || !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("%s: expected an ambiguity, got %v", name, err)
+			continue
+		}
+		if len(ambiguous.Matches) != 2 || ambiguous.Matches[0].First != "Jean" || ambiguous.Matches[1].First != "Marie" {
+			t.Errorf("%s: candidates %+v, want Jean then Marie", name, ambiguous.Matches)
+		}
+	}
+	if dir.Generation() != generation || dir.ContactCount() != 3 {
+		t.Error("An ambiguous name changed the directory")
+	}
+
+	if err := dir.UpdateContact("Martin", "Luc", ""); err != nil {
+		t.Errorf("A unique name should still be updated: %v", err)
+	}
+	if err := dir.DeleteContact("Martin"); err != nil || dir.ContactCount() != 2 {
+		t.Errorf("A unique name should still be deleted: %v", err)
+	}
+}
+
+// TestFindByPhoneAndFullName tests the lookups that single out a homonym
+func TestFindByPhoneAndFullName(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Marie", "0600000000")
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.AddContact("Dupont", "Jean", "0700000000")
+	dir.AddContact("Martin", "Paul", "0700000000")
+
+	if c, err := dir.FindByPhone("06 00 00 00 00"); err != nil || c.First != "Marie" {
+		t.Errorf("FindByPhone should normalize the number, got %+v, %v", c, err)
+	}
+	if _, err := dir.FindByPhone("0700000000"); !errors.Is(err, ErrAmbiguous) {
+		t.Errorf("A shared phone should be ambiguous, got %v", err)
+	}
+	if _, err := dir.FindByPhone("0811111111"); !errors.Is(err, ErrContactNotFound) {
+		t.Errorf("An unknown phone should not be found, got %v", err)
+	}
+
+	if c, err := dir.FindByFullName("marie", "DUPONT"); err != nil || c.Phone != "0600000000" {
+		t.Errorf("FindByFullName should ignore case, got %+v, %v", c, err)
+	}
+	var ambiguous *AmbiguousReferenceError
+	if _, err := dir.FindByFullName("Jean", "Dupont"); !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 2 {
+		t.Errorf("Two Jean Dupont should be ambiguous, got %v", err)
+	}
+	if _, err := dir.FindByFullName("Paul", "Dupont"); !errors.Is(err, ErrContactNotFound) {
+		t.Errorf("Paul Dupont should not be found, got %v", err)
+	}
+}
+
+// TestSaveContact tests ID-based updates, rekeying and code uniqueness
+func TestSaveContact(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.InsertContact(Contact{Name: "Martin", First: "Paul", Phone: "0600000000", Code: "doc"})
+
+	jean, _ := dir.ResolveContact("Dupont")
+	jean.Phone = "0611111111"
+	if err := dir.SaveContact(jean); err != nil {
+		t.Fatal(err)
+	}
+	if matches := dir.FilterContacts("0611111111"); len(matches) != 1 || dir.ContactCount() != 2 {
+		t.Errorf("Phone change not stored under the new key: %v", dir.ListContacts())
+	}
+
+	jean.Code = "DOC"
+	if err := dir.SaveContact(jean); err == nil {
+		t.Error("Expected error for a code already in use")
+	}
+
+	if err := dir.DeleteContactByID(jean.ID); err != nil || dir.ContactCount() != 1 {
+		t.Errorf("DeleteContactByID failed: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package annuaire
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+// Default bounds of one search (see SearchLimits)
+const (
+	DefaultMaxResults    = 500
+	DefaultSearchTimeout = 2 * time.Second
+)
+
+// maxPatternLength bounds the regular expressions accepted by SearchRegex
+const maxPatternLength = 256
+
+// scanCheckInterval is the number of contacts examined between two deadline checks
+const scanCheckInterval = 1024
+
+// SearchLimits bound the work and the size of one query, so that a broad
+// search on a huge directory cannot stall the server or produce a huge response
+type SearchLimits struct {
+	MaxResults int           // Matches kept at most (0: unlimited)
+	Timeout    time.Duration // Time budget of the scan (0: none)
+}
+
+// SearchResult holds the matches of a bounded search
+type SearchResult struct {
+	Contacts  []Contact // Matches, in no particular order
+	Matches   [][]Match // Where the term was found in each of Contacts, in the same order (FilterContactsWith only)
+	Truncated bool      // Some matches were left out: too many of them, or out of time
+	TimedOut  bool      // The scan stopped on the time budget or a cancelled context
+	Scanned   int       // Contacts examined before the scan ended
+}
+
+/**
+ * ScanContacts collects the contacts accepted by a predicate, within limits
+ *
+ * @param {context.Context} ctx - Context of the request; the scan stops when it is done
+ * @param {func(Contact) bool} match - Predicate selecting the contacts
+ * @param {SearchLimits} limits - Maximum number of matches and time budget
+ * @return {SearchResult} Matches found before a limit was reached
+ *
+ * Usage:
+ *   result := dir.ScanContacts(r.Context(), func(c Contact) bool { return c.MatchesQuery(q) },
+ *       SearchLimits{MaxResults: 500, Timeout: 2 * time.Second})
+ *   if result.Truncated { ... }
+ */
+func (d *Directory) ScanContacts(ctx context.Context, match func(Contact) bool, limits SearchLimits) SearchResult {
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var result SearchResult
+	scanned := 0
+	for _, contact := range d.contacts {
+		if scanned++; scanned%scanCheckInterval == 0 && ctx.Err() != nil {
+			result.Truncated, result.TimedOut = true, true
+			break
+		}
+		if !match(contact) {
+			continue
+		}
+		if limits.MaxResults > 0 && len(result.Contacts) >= limits.MaxResults {
+			result.Truncated = true
+			break
+		}
+		result.Contacts = append(result.Contacts, contact)
+	}
+
+	result.Scanned = scanned
+	if result.Truncated {
+		slog.Debug("search truncated", "matches", len(result.Contacts), "scanned", scanned, "total", len(d.contacts), "timed_out", result.TimedOut)
+	}
+	return result
+}
+
+/**
+ * FilterContactsLimited is FilterContacts within search limits
+ *
+ * @param {context.Context} ctx - Context of the request
+ * @param {string} searchTerm - Exact last name, first name or phone number
+ * @param {SearchLimits} limits - Maximum number of matches and time budget
+ * @return {SearchResult} Matches found before a limit was reached
+ */
+func (d *Directory) FilterContactsLimited(ctx context.Context, searchTerm string, limits SearchLimits) SearchResult {
+	return d.ScanContacts(ctx, func(c Contact) bool { return matchesTerm(c, searchTerm) }, limits)
+}
+
+/**
+ * SearchRegex finds the contacts whose last name, first name or phone
+ * matches a regular expression
+ *
+ * @param {context.Context} ctx - Context of the request; the scan stops when it is done
+ * @param {string} pattern - Go regular expression (RE2 syntax), e.g. "^06" for
+ *   mobile numbers or "(?i)^du" for names starting with "du" in any case
+ * @param {SearchLimits} limits - Maximum number of matches and time budget
+ * @return {SearchResult} Matches found before a limit was reached
+ * @return {error} A *FieldError on the "pattern" field if the expression is
+ *   empty, longer than 256 bytes or invalid
+ *
+ * Go regular expressions run in time linear in the input, so a pattern
+ * cannot backtrack catastrophically; the limits still bound a broad search
+ * on a huge directory
+ *
+ * Usage:
+ *   result, err := dir.SearchRegex(ctx, "^06", SearchLimits{Timeout: time.Second})
+ */
+func (d *Directory) SearchRegex(ctx context.Context, pattern string, limits SearchLimits) (SearchResult, error) {
+	if pattern == "" {
+		return SearchResult{}, &FieldError{Field: "pattern", Message: "regular expression required"}
+	}
+	if len(pattern) > maxPatternLength {
+		return SearchResult{}, &FieldError{Field: "pattern", Message: fmt.Sprintf("regular expression too long (%d bytes, at most %d)", len(pattern), maxPatternLength)}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return SearchResult{}, &FieldError{Field: "pattern", Message: fmt.Sprintf("invalid regular expression: %v", err)}
+	}
+	return d.ScanContacts(ctx, func(c Contact) bool {
+		return re.MatchString(c.Name) || re.MatchString(c.First) || re.MatchString(c.Phone)
+	}, limits), nil
+}
@@ -0,0 +1,69 @@
+package annuaire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestScanContactsLimits tests the result limit and the time budget
+func TestScanContactsLimits(t *testing.T) {
+	dir := NewDirectory()
+	for i := range 3000 {
+		if err := dir.AddContact("Dupont", fmt.Sprintf("Jean%d", i), fmt.Sprintf("0%09d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := dir.FilterContactsLimited(context.Background(), "Dupont", SearchLimits{MaxResults: 10})
+	if len(result.Contacts) != 10 || !result.Truncated || result.TimedOut {
+		t.Errorf("Expected 10 truncated results, got %d (truncated=%v, timed out=%v)", len(result.Contacts), result.Truncated, result.TimedOut)
+	}
+
+	result = dir.FilterContactsLimited(context.Background(), "Jean42", SearchLimits{MaxResults: 10})
+	if len(result.Contacts) != 1 || result.Truncated {
+		t.Errorf("Expected one complete result, got %d (truncated=%v)", len(result.Contacts), result.Truncated)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = dir.FilterContactsLimited(ctx, "Dupont", SearchLimits{})
+	if !result.TimedOut || !result.Truncated || len(result.Contacts) >= 3000 {
+		t.Errorf("Expected the scan to stop on a cancelled context, got %d results (timed out=%v)", len(result.Contacts), result.TimedOut)
+	}
+}
+
+// TestSearchRegex tests regular expression searches and the refused patterns
+func TestSearchRegex(t *testing.T) {
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0612345678")
+	dir.AddContact("Durand", "Marie", "0123456789")
+	dir.AddContact("Martin", "Paul", "0698765432")
+
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"^06", 2},
+		{"^Du", 2},
+		{"(?i)^du", 2},
+		{"^du", 0},
+		{"^(Jean|Paul)$", 2},
+		{"89$", 1},
+	}
+	for _, tt := range tests {
+		result, err := dir.SearchRegex(context.Background(), tt.pattern, SearchLimits{})
+		if err != nil || len(result.Contacts) != tt.want {
+			t.Errorf("SearchRegex(%q): %d matches (%v), want %d", tt.pattern, len(result.Contacts), err, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{"", "([0-9]", strings.Repeat("a", maxPatternLength+1)} {
+		var fieldErr *FieldError
+		if _, err := dir.SearchRegex(context.Background(), pattern, SearchLimits{}); !errors.As(err, &fieldErr) || fieldErr.Field != "pattern" {
+			t.Errorf("SearchRegex(%.20q) should fail on the pattern, got %v", pattern, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package annuaire
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SIMOptions sets the field limits of a SIM-style export
+// Most SIM cards store 14 characters per name and 20 digits per number,
+// feature phones are often stricter, hence the configurable values
+type SIMOptions struct {
+	NameLimit   int // Maximum characters in the name column
+	NumberLimit int // Maximum digits in the number column (a leading + is not counted)
+}
+
+// DefaultSIMOptions returns the limits supported by typical SIM cards
+func DefaultSIMOptions() SIMOptions {
+	return SIMOptions{NameLimit: 14, NumberLimit: 20}
+}
+
+// SIMAdjustment reports how a contact was altered (or dropped) to fit the limits
+type SIMAdjustment struct {
+	Contact Contact // Original contact
+	Rule    string  // Applied rule, e.g. "name abbreviated" or "skipped: number too long"
+	Before  string  // Value before the rule was applied
+	After   string  // Value written to the export (empty when skipped)
+}
+
+/**
+ * WriteSIM writes contacts as "name,number" CSV rows constrained to SIM limits
+ *
+ * @param {io.Writer} w - Destination of the CSV data (a "name,number" header comes first)
+ * @param {[]Contact} contacts - Contacts to export
+ * @param {SIMOptions} opts - Name and number length limits (zero values use the defaults)
+ * @return {[]SIMAdjustment} Every truncation, cleanup or skip that was applied
+ * @return {error} Returns an error if writing fails
+ *
+ * Rules, in order:
+ * - Numbers keep only digits and dialing symbols (+ * #); other characters are removed
+ * - Numbers longer than the limit are skipped, since a cut number would dial someone else
+ * - Names are "First Last"; when too long the first name is reduced to an initial,
+ *   and if that is still too long the abbreviated name is cut at the limit
+ * - Rows are sorted by exported name, which is how phones display them anyway
+ */
+func WriteSIM(w io.Writer, contacts []Contact, opts SIMOptions) ([]SIMAdjustment, error) {
+	defaults := DefaultSIMOptions()
+	if opts.NameLimit <= 0 {
+		opts.NameLimit = defaults.NameLimit
+	}
+	if opts.NumberLimit <= 0 {
+		opts.NumberLimit = defaults.NumberLimit
+	}
+
+	type row struct{ name, number string }
+	var rows []row
+	var adjustments []SIMAdjustment
+
+	for _, contact := range contacts {
+		number := simNumber(contact.Phone)
+		if number != contact.Phone {
+			adjustments = append(adjustments, SIMAdjustment{contact, "number cleaned", contact.Phone, number})
+		}
+		if digits := len(strings.TrimPrefix(number, "+")); digits == 0 || digits > opts.NumberLimit {
+			rule := fmt.Sprintf("skipped: number longer than %d digits", opts.NumberLimit)
+			if digits == 0 {
+				rule = "skipped: no dialable digits"
+			}
+			adjustments = append(adjustments, SIMAdjustment{contact, rule, contact.Phone, ""})
+			continue
+		}
+
+		full := strings.TrimSpace(contact.First + " " + contact.Name)
+		name, rule := simName(contact, opts.NameLimit)
+		if rule != "" {
+			adjustments = append(adjustments, SIMAdjustment{contact, rule, full, name})
+		}
+		rows = append(rows, row{name, number})
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].name < rows[j].name })
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"name", "number"}); err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if err := writer.Write([]string{r.name, r.number}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return adjustments, writer.Error()
+}
+
+// SIMEncoder adapts WriteSIM to the export pipeline
+// The adjustments are passed to report, which may be nil
+func SIMEncoder(opts SIMOptions, report func([]SIMAdjustment)) ExportEncoder {
+	return func(w io.Writer, contacts []Contact) error {
+		adjustments, err := WriteSIM(w, contacts, opts)
+		if report != nil {
+			report(adjustments)
+		}
+		return err
+	}
+}
+
+/**
+ * ExportToSIM exports all contacts to a SIM-style CSV file
+ *
+ * @param {string} filename - Full path where the CSV file should be created
+ * @param {SIMOptions} opts - Name and number length limits
+ * @return {[]SIMAdjustment} Truncations and skips applied to fit the limits
+ * @return {error} Returns an error if the file cannot be written
+ *
+ * Usage:
+ *   adjustments, err := dir.ExportToSIM("sim.csv", DefaultSIMOptions())
+ */
+func (d *Directory) ExportToSIM(filename string, opts SIMOptions) ([]SIMAdjustment, error) {
+	var adjustments []SIMAdjustment
+	err := d.ExportWith(filename, ExportPipeline{Encoder: SIMEncoder(opts, func(adj []SIMAdjustment) { adjustments = adj })})
+	return adjustments, err
+}
+
+// simNumber keeps the characters a phone keypad can dial
+// A "+" is only meaningful as the first character (international prefix),
+// and the "(0)" trunk prefix written in "+33 (0)6..." is never dialed
+func simNumber(phone string) string {
+	var b strings.Builder
+	for i, r := range strings.ReplaceAll(strings.TrimSpace(phone), "(0)", "") {
+		switch {
+		case r >= '0' && r <= '9', r == '*', r == '#':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// simName builds the displayed name and the rule used to make it fit
+func simName(contact Contact, limit int) (string, string) {
+	full := strings.TrimSpace(contact.First + " " + contact.Name)
+	if utf8.RuneCountInString(full) <= limit {
+		return full, ""
+	}
+
+	// "Jean-Pierre Dupont" becomes "J. Dupont"
+	abbreviated := full
+	if first := []rune(contact.First); len(first) > 0 {
+		abbreviated = string(first[0]) + ". " + contact.Name
+		if utf8.RuneCountInString(abbreviated) <= limit {
+			return abbreviated, "name abbreviated"
+		}
+	}
+	// Cutting the abbreviated form keeps as much of the last name as possible
+	return strings.TrimSpace(string([]rune(abbreviated)[:limit])), fmt.Sprintf("name truncated to %d characters", limit)
+}
@@ -0,0 +1,38 @@
+package annuaire
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestWriteSIM tests the truncation, cleanup and skip rules of SIM exports
+func TestWriteSIM(t *testing.T) {
+	contacts := []Contact{
+		{Name: "Dupont-Lagrange", First: "Jean-Pierre", Phone: "+33 (0)6.12.34.56.78"},
+		{Name: "Martin", First: "Lucie", Phone: "0678123456"},
+		{Name: "Long", First: "Number", Phone: "0123456789012345678901234"},
+	}
+
+	var buf bytes.Buffer
+	adjustments, err := WriteSIM(&buf, contacts, SIMOptions{NameLimit: 12, NumberLimit: 20})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "name,number\nJ. Dupont-La,+33612345678\nLucie Martin,0678123456\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	rules := make([]string, 0, len(adjustments))
+	for _, adj := range adjustments {
+		rules = append(rules, adj.Rule)
+	}
+	joined := strings.Join(rules, "|")
+	for _, want := range []string{"number cleaned", "name truncated to 12 characters", "skipped: number longer than 20 digits"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Missing adjustment %q in %v", want, rules)
+		}
+	}
+}
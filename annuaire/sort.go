@@ -0,0 +1,139 @@
+package annuaire
+
+import (
+	"sort"
+	"strings"
+)
+
+/**
+ * SortContacts orders contacts alphabetically, keeping pinned contacts on top
+ *
+ * @param {[]Contact} contacts - Slice sorted in place
+ * @param {[]ContactRef} pinned - Contacts to list first, in this exact order
+ * @return {int} Number of pinned contacts found at the start of the slice
+ *
+ * Ordering rules:
+ * - Pinned contacts come first, following the order of the pinned list
+ * - Other contacts are sorted by last name, then first name, then phone
+ *   (case-insensitive), giving stable output across runs
+ * - References to contacts that no longer exist are simply ignored
+ *
+ * Usage:
+ *   contacts := dir.ListContacts()
+ *   pinnedCount := SortContacts(contacts, cfg.Pinned)
+ */
+func SortContacts(contacts []Contact, pinned []ContactRef) int {
+	rank := make(map[ContactRef]int, len(pinned))
+	for i, ref := range pinned {
+		if _, seen := rank[ref]; !seen {
+			rank[ref] = i
+		}
+	}
+
+	sort.SliceStable(contacts, func(i, j int) bool {
+		ri, pi := rank[contacts[i].Ref()]
+		rj, pj := rank[contacts[j].Ref()]
+		switch {
+		case pi && pj:
+			return ri < rj
+		case pi != pj:
+			return pi
+		}
+		return lessAlphabetical(contacts[i], contacts[j])
+	})
+
+	count := 0
+	for _, contact := range contacts {
+		if _, ok := rank[contact.Ref()]; !ok {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// lessAlphabetical compares contacts by last name, first name, then phone
+func lessAlphabetical(a, b Contact) bool {
+	if c := strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)); c != 0 {
+		return c < 0
+	}
+	if c := strings.Compare(strings.ToLower(a.First), strings.ToLower(b.First)); c != 0 {
+		return c < 0
+	}
+	return a.Phone < b.Phone
+}
+
+// Sort keys accepted by SortContactsBy
+const (
+	SortByName    = "name"    // Last name, first name, then phone
+	SortByFirst   = "first"   // First name, last name, then phone
+	SortByPhone   = "phone"   // Phone number, then name
+	SortByCreated = "created" // Order in which the contacts were added (their ID)
+)
+
+// SortKeys lists the keys accepted by SortContactsBy
+var SortKeys = []string{SortByName, SortByFirst, SortByPhone, SortByCreated}
+
+/**
+ * SortContactsBy orders contacts on one key, ignoring pins
+ *
+ * @param {[]Contact} contacts - Slice sorted in place
+ * @param {string} key - SortByName, SortByFirst, SortByPhone or SortByCreated
+ * @param {bool} descending - Reverse the order
+ * @return {error} Returns an error for unknown keys, leaving the slice untouched
+ *
+ * Ties are broken alphabetically, so pages of a listing never overlap
+ */
+func SortContactsBy(contacts []Contact, key string, descending bool) error {
+	var less func(a, b Contact) bool
+	switch key {
+	case SortByName:
+		less = lessAlphabetical
+	case SortByFirst:
+		less = func(a, b Contact) bool {
+			if c := strings.Compare(strings.ToLower(a.First), strings.ToLower(b.First)); c != 0 {
+				return c < 0
+			}
+			return lessAlphabetical(a, b)
+		}
+	case SortByPhone:
+		less = func(a, b Contact) bool {
+			if a.Phone != b.Phone {
+				return a.Phone < b.Phone
+			}
+			return lessAlphabetical(a, b)
+		}
+	case SortByCreated:
+		less = lessCreated
+	default:
+		return errorOf(ErrInvalidInput, "unknown sort key %q (expected one of: %s)", key, strings.Join(SortKeys, ", "))
+	}
+
+	sort.SliceStable(contacts, func(i, j int) bool {
+		if descending {
+			return less(contacts[j], contacts[i])
+		}
+		return less(contacts[i], contacts[j])
+	})
+	return nil
+}
+
+// MatchesQuery reports whether the name, first name or phone of a contact
+// contains the query, ignoring case; an empty query matches every contact
+func (c Contact) MatchesQuery(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	for _, value := range []string{c.Name, c.First, c.Phone} {
+		if strings.Contains(strings.ToLower(value), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// lessCreated compares contacts by ID, then alphabetically (contacts without ID first)
+func lessCreated(a, b Contact) bool {
+	if a.ID != b.ID {
+		return a.ID < b.ID
+	}
+	return lessAlphabetical(a, b)
+}
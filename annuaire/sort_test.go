@@ -0,0 +1,77 @@
+package annuaire
+
+import (
+	"testing"
+)
+
+// TestSortContactsWithPins tests that pinned contacts stay on top in pin order
+func TestSortContactsWithPins(t *testing.T) {
+	contacts := []Contact{
+		{Name: "Martin", First: "Lucie", Phone: "1"},
+		{Name: "Helpdesk", First: "IT", Phone: "2"},
+		{Name: "bernard", First: "Jean", Phone: "3"},
+		{Name: "Reception", First: "Desk", Phone: "4"},
+	}
+	pinned := []ContactRef{
+		{Name: "Reception", Phone: "4"},
+		{Name: "Helpdesk", Phone: "2"},
+		{Name: "Gone", Phone: "9"}, // Deleted contacts are ignored
+	}
+
+	count := SortContacts(contacts, pinned)
+	if count != 2 {
+		t.Errorf("Expected 2 pinned contacts, got %d", count)
+	}
+
+	want := []string{"Reception", "Helpdesk", "bernard", "Martin"}
+	for i, name := range want {
+		if contacts[i].Name != name {
+			t.Errorf("Position %d: expected %s, got %s", i, name, contacts[i].Name)
+		}
+	}
+}
+
+// TestSortContactsBy tests each sort key in both directions
+func TestSortContactsBy(t *testing.T) {
+	contacts := []Contact{
+		{ID: 2, Name: "Martin", First: "Lucie", Phone: "0300"},
+		{ID: 3, Name: "bernard", First: "Jean", Phone: "0100"},
+		{ID: 1, Name: "Dupont", First: "Marie", Phone: "0200"},
+	}
+	tests := []struct {
+		key        string
+		descending bool
+		want       []int
+	}{
+		{SortByName, false, []int{3, 1, 2}},
+		{SortByName, true, []int{2, 1, 3}},
+		{SortByFirst, false, []int{3, 2, 1}},
+		{SortByPhone, false, []int{3, 1, 2}},
+		{SortByCreated, false, []int{1, 2, 3}},
+		{SortByCreated, true, []int{3, 2, 1}},
+	}
+	for _, tt := range tests {
+		if err := SortContactsBy(contacts, tt.key, tt.descending); err != nil {
+			t.Fatalf("SortContactsBy(%s): %v", tt.key, err)
+		}
+		for i, id := range tt.want {
+			if contacts[i].ID != id {
+				t.Errorf("%s descending=%v: position %d is #%d, want #%d", tt.key, tt.descending, i, contacts[i].ID, id)
+			}
+		}
+	}
+
+	if err := SortContactsBy(contacts, "birthday", false); err == nil {
+		t.Error("Expected an error for an unknown sort key")
+	}
+}
+
+// TestMatchesQuery tests the case-insensitive substring match
+func TestMatchesQuery(t *testing.T) {
+	c := Contact{Name: "Dupont", First: "Jean", Phone: "0123456789"}
+	for query, want := range map[string]bool{"": true, "dup": true, "JEAN": true, "3456": true, "martin": false} {
+		if got := c.MatchesQuery(query); got != want {
+			t.Errorf("MatchesQuery(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package annuaire
+
+import (
+	"cmp"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultTopPrefixes is the number of phone prefixes kept by Stats when none is given
+const DefaultTopPrefixes = 5
+
+// Stats summarizes a directory for the stats command and GET /api/stats
+type Stats struct {
+	Total        int            `json:"total"`             // Contacts stored
+	WithBirthday int            `json:"with_birthday"`     // Contacts with a birthday
+	WithCode     int            `json:"with_code"`         // Contacts with a speed-dial code
+	WithPhoto    int            `json:"with_photo"`        // Contacts with a photo
+	Tagged       int            `json:"tagged"`            // Contacts with at least one tag
+	Initials     []InitialCount `json:"initials"`          // Contacts per initial of the last name, in IndexLetters order, empty letters left out
+	Prefixes     []PrefixCount  `json:"prefixes"`          // Most common phone prefixes, most frequent first
+	Tags         []TagCount     `json:"tags"`              // Contacts per tag, most frequent first
+	Duplicates   DuplicateStats `json:"duplicates"`        // Contacts that look like another one
+	Modified     time.Time      `json:"modified,omitzero"` // Last write of the data file (zero: never saved, see LastSaved)
+}
+
+// InitialCount is the number of contacts filed under one initial (see Initial)
+type InitialCount struct {
+	Initial string `json:"initial"`
+	Count   int    `json:"count"`
+}
+
+// PrefixCount is the number of contacts whose phone starts with a prefix (see PhonePrefix)
+type PrefixCount struct {
+	Prefix string `json:"prefix"`
+	Count  int    `json:"count"`
+}
+
+// TagCount is the number of contacts carrying a tag
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// DuplicateStats counts the contacts sharing identifying values with others
+// They are only hints: homonyms and shared landlines are legitimate
+type DuplicateStats struct {
+	SameName  int `json:"same_name"`  // Contacts with the first and last name of another one, ignoring case
+	SamePhone int `json:"same_phone"` // Contacts with the phone number of another one
+}
+
+/**
+ * PhonePrefix returns the part of a phone number telling its area or kind
+ *
+ * @param {string} phone - Phone number in any format (see NormalizePhone)
+ * @return {string} First two digits ("06" for a French mobile, "01" for
+ *   Paris), "+" and the first two digits of an international number, or
+ *   empty when the number has no leading digits
+ *
+ * Masked phone numbers show the same first two digits (see MaskValue), so
+ * prefixes reveal nothing a share does not
+ */
+func PhonePrefix(phone string) string {
+	phone = NormalizePhone(phone)
+	start := 0
+	if strings.HasPrefix(phone, "+") {
+		start = 1
+	}
+	if len(phone) < start+2 || !isDigits(phone[start:start+2]) {
+		return ""
+	}
+	return phone[:start+2]
+}
+
+// isDigits reports whether s only holds ASCII digits
+func isDigits(s string) bool {
+	return strings.Trim(s, "0123456789") == ""
+}
+
+/**
+ * Stats computes the summary of the directory
+ *
+ * @param {int} topPrefixes - Number of phone prefixes kept (0: DefaultTopPrefixes)
+ * @return {Stats} Counts of the current contacts; Modified is left for the
+ *   caller, which knows the data file (see LastSaved)
+ *
+ * Prefixes and tags with the same count are listed in increasing order, so
+ * the result does not change between calls
+ */
+func (d *Directory) Stats(topPrefixes int) Stats {
+	if topPrefixes <= 0 {
+		topPrefixes = DefaultTopPrefixes
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	stats := Stats{Total: len(d.contacts), Initials: []InitialCount{}, Prefixes: []PrefixCount{}, Tags: []TagCount{}}
+	initials := make(map[string]int)
+	tags := make(map[string]int)
+	prefixes := make(map[string]int)
+	names := make(map[string]int)
+	for _, contact := range d.contacts {
+		if contact.Birthday != "" {
+			stats.WithBirthday++
+		}
+		if contact.Code != "" {
+			stats.WithCode++
+		}
+		if contact.Photo != "" {
+			stats.WithPhoto++
+		}
+		if len(contact.Tags) > 0 {
+			stats.Tagged++
+		}
+		for _, tag := range contact.Tags {
+			tags[tag]++
+		}
+		initials[Initial(contact.Name)]++
+		if prefix := PhonePrefix(contact.Phone); prefix != "" {
+			prefixes[prefix]++
+		}
+		names[strings.ToLower(contact.First)+"\x00"+strings.ToLower(contact.Name)]++
+	}
+
+	for _, letter := range IndexLetters {
+		if n := initials[letter]; n > 0 {
+			stats.Initials = append(stats.Initials, InitialCount{Initial: letter, Count: n})
+		}
+	}
+	for prefix, n := range prefixes {
+		stats.Prefixes = append(stats.Prefixes, PrefixCount{Prefix: prefix, Count: n})
+	}
+	slices.SortFunc(stats.Prefixes, func(a, b PrefixCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Prefix, b.Prefix))
+	})
+	stats.Prefixes = stats.Prefixes[:min(len(stats.Prefixes), topPrefixes)]
+	for tag, n := range tags {
+		stats.Tags = append(stats.Tags, TagCount{Tag: tag, Count: n})
+	}
+	slices.SortFunc(stats.Tags, func(a, b TagCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Tag, b.Tag))
+	})
+
+	for _, n := range names {
+		if n > 1 {
+			stats.Duplicates.SameName += n
+		}
+	}
+	for _, keys := range d.index.byPhone {
+		if len(keys) > 1 {
+			stats.Duplicates.SamePhone += len(keys)
+		}
+	}
+	return stats
+}
+
+/**
+ * LastSaved returns when the contacts were last written to disk
+ *
+ * @param {string} dataFile - Contacts file of the directory
+ * @return {time.Time} Modification time of the append-only log when the
+ *   directory uses one, else of dataFile; zero when the file does not exist
+ */
+func (d *Directory) LastSaved(dataFile string) time.Time {
+	d.mu.RLock()
+	path := dataFile
+	if d.log != nil {
+		path = d.log.path
+	}
+	d.mu.RUnlock()
+
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
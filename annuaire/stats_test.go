@@ -0,0 +1,55 @@
+package annuaire
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestStats tests the counts, initials, prefixes and duplicate hints
+func TestStats(t *testing.T) {
+	dir := NewDirectory()
+	dir.ReplaceContacts([]Contact{
+		{Name: "Dupont", First: "Jean", Phone: "06 12 34 56 78", Birthday: "1985-04-12", Tags: []string{"work"}},
+		{Name: "dupont", First: "jean", Phone: "0145678901"},
+		{Name: "Émile", First: "Zola", Phone: "0145678901", Code: "1"},
+		{Name: "Martin", First: "Luc", Phone: "+33612345678"},
+		{Name: "42", First: "Bot", Phone: "0699999999"},
+	})
+
+	stats := dir.Stats(2)
+	if stats.Total != 5 || stats.WithBirthday != 1 || stats.WithCode != 1 || stats.Tagged != 1 || stats.WithPhoto != 0 {
+		t.Errorf("Unexpected counts: %+v", stats)
+	}
+	wantInitials := []InitialCount{{"D", 2}, {"E", 1}, {"M", 1}, {OtherInitial, 1}}
+	if !reflect.DeepEqual(stats.Initials, wantInitials) {
+		t.Errorf("Initials = %v, want %v", stats.Initials, wantInitials)
+	}
+	wantPrefixes := []PrefixCount{{"01", 2}, {"06", 2}} // +33 is cut by the limit
+	if !reflect.DeepEqual(stats.Prefixes, wantPrefixes) {
+		t.Errorf("Prefixes = %v, want %v", stats.Prefixes, wantPrefixes)
+	}
+	if want := []TagCount{{"work", 1}}; !reflect.DeepEqual(stats.Tags, want) {
+		t.Errorf("Tags = %v, want %v", stats.Tags, want)
+	}
+	if stats.Duplicates != (DuplicateStats{SameName: 2, SamePhone: 2}) {
+		t.Errorf("Duplicates = %+v", stats.Duplicates)
+	}
+
+	for phone, want := range map[string]string{"+44 20 7946": "+44", "6": "", "ext. 12": "", "": ""} {
+		if got := PhonePrefix(phone); got != want {
+			t.Errorf("PhonePrefix(%q) = %q, want %q", phone, got, want)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "contacts.json")
+	if !dir.LastSaved(file).IsZero() {
+		t.Error("A missing data file should give a zero time")
+	}
+	if err := dir.SaveDataFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if dir.LastSaved(file).IsZero() {
+		t.Error("LastSaved should return the time of the saved file")
+	}
+}
@@ -0,0 +1,157 @@
+package annuaire
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Storage abstracts the file operations of the directory
+// The default implementation works on the local disk; wrappers such as
+// FaultyStorage alter its behavior to test failure handling
+type Storage interface {
+	ReadFile(name string) ([]byte, error)     // Returns the whole content of a file
+	WriteFile(name string, data []byte) error // Replaces the content of a file
+}
+
+// OSStorage stores files on the local disk
+type OSStorage struct{}
+
+// ReadFile reads a file from disk
+func (OSStorage) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+/**
+ * WriteFile replaces a file atomically
+ *
+ * @param {string} name - Destination path (parent directories are created)
+ * @param {[]byte} data - New content
+ * @return {error} Returns an error if any step fails; the previous content is then intact
+ *
+ * The data goes to a temporary file in the same directory, which is synced
+ * and renamed over the destination, so a crash or a full disk never leaves
+ * a half-written contacts file behind
+ */
+func (OSStorage) WriteFile(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// Removing after a successful rename fails harmlessly
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+/**
+ * SetStorage changes where ExportToJSON and ImportFromJSON read and write
+ *
+ * @param {Storage} storage - Storage to use (nil restores OSStorage)
+ *
+ * Usage:
+ *   dir.SetStorage(&FaultyStorage{Next: OSStorage{}, FailEveryNthWrite: 3})
+ */
+func (d *Directory) SetStorage(storage Storage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.storage = storage
+}
+
+// currentStorage returns the configured storage, OSStorage by default
+func (d *Directory) currentStorage() Storage {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.storage == nil {
+		return OSStorage{}
+	}
+	return d.storage
+}
+
+// Formats of the contacts file, selected with the -storage flag
+const (
+	FormatJSON      = "json"      // Whole JSON array rewritten on every save (default)
+	FormatAppendLog = "appendlog" // Append-only binary log next to the JSON file (see OpenAppendLog)
+)
+
+/**
+ * LoadDataFile loads the contacts file in the given format
+ *
+ * @param {string} dataFile - JSON contacts file, e.g. "data/contacts.json"
+ * @param {string} format - FormatJSON (or empty) or FormatAppendLog
+ * @return {error} Returns an error if the file cannot be read; a missing
+ *                 file means an empty directory
+ *
+ * With FormatAppendLog the contacts live in AppendLogPathFor(dataFile); when
+ * that log does not exist yet it is created from the JSON file, if any
+ */
+func (d *Directory) LoadDataFile(dataFile, format string) error {
+	switch format {
+	case FormatJSON, "":
+	case FormatAppendLog:
+		logPath := AppendLogPathFor(dataFile)
+		if _, err := os.Stat(logPath); err == nil {
+			return d.OpenAppendLog(logPath)
+		}
+		if err := d.LoadDataFile(dataFile, FormatJSON); err != nil {
+			return err
+		}
+		return d.OpenAppendLog(logPath)
+	default:
+		return errorOf(ErrInvalidInput, "unknown storage format %q (%s, %s)", format, FormatJSON, FormatAppendLog)
+	}
+
+	// The file is the directory's own state: it is restored as saved, where
+	// ImportContacts would leave out the records failing today's validation
+	contacts, err := readJSONFile(d.currentStorage(), dataFile)
+	if errors.Is(err, errFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	d.replaceContacts(contacts, OpImport, fmt.Sprintf("%d contacts from %s", len(contacts), dataFile))
+	return nil
+}
+
+/**
+ * SaveDataFile persists the contacts in the format chosen by LoadDataFile
+ *
+ * @param {string} dataFile - JSON contacts file
+ * @return {error} Returns an error if the contacts may not be on disk
+ *
+ * The JSON file is rewritten entirely; the append-only log already holds
+ * every modification and is only synced
+ */
+func (d *Directory) SaveDataFile(dataFile string) error {
+	d.mu.RLock()
+	appendOnly := d.log != nil
+	d.mu.RUnlock()
+
+	if appendOnly {
+		return d.SyncAppendLog()
+	}
+	return d.ExportToJSON(dataFile)
+}
@@ -0,0 +1,86 @@
+package annuaire
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// nameEntry is a name of the sorted index used by SuggestNames
+type nameEntry struct {
+	fold  string // Lower-cased name, the sort and search key
+	value string // Name as stored
+}
+
+// compareNames orders the entries by folded name, then by stored name
+func compareNames(a nameEntry, b nameEntry) int {
+	return cmp.Or(strings.Compare(a.fold, b.fold), strings.Compare(a.value, b.value))
+}
+
+// addName inserts a name in the sorted index unless it is already there
+func (x *contactIndex) addName(name string) {
+	if name == "" {
+		return
+	}
+	entry := nameEntry{fold: strings.ToLower(name), value: name}
+	if i, found := slices.BinarySearchFunc(x.names, entry, compareNames); !found {
+		x.names = slices.Insert(x.names, i, entry)
+	}
+}
+
+// removeName drops a name from the sorted index once no contact carries it,
+// as last name or first name; the caller has already unlisted the contact
+func (x *contactIndex) removeName(name string) {
+	if len(x.byName[name])+len(x.byFirst[name]) > 0 {
+		return
+	}
+	if i, found := slices.BinarySearchFunc(x.names, nameEntry{fold: strings.ToLower(name), value: name}, compareNames); found {
+		x.names = slices.Delete(x.names, i, i+1)
+	}
+}
+
+// sortNames builds the sorted index in one pass, which is much faster than
+// inserting the names of a large import one by one
+func (x *contactIndex) sortNames() {
+	x.names = make([]nameEntry, 0, len(x.byName)+len(x.byFirst))
+	for _, index := range []map[string][]string{x.byName, x.byFirst} {
+		for name := range index {
+			if name != "" {
+				x.names = append(x.names, nameEntry{fold: strings.ToLower(name), value: name})
+			}
+		}
+	}
+	slices.SortFunc(x.names, compareNames)
+	x.names = slices.Compact(x.names) // A name used both as last and first name
+}
+
+/**
+ * SuggestNames completes the start of a name typed in a search box
+ *
+ * @param {string} prefix - Beginning of a last name or first name (case-insensitive)
+ * @param {int} limit - Maximum number of suggestions (0 or less: no limit)
+ * @return {[]string} Distinct last and first names starting with prefix, in
+ *   alphabetical order; each one is an exact search term for FilterContacts
+ *
+ * The names are kept sorted as contacts change, so a suggestion costs a
+ * binary search plus the names returned, whatever the size of the directory
+ *
+ * Usage:
+ *   names := dir.SuggestNames("du", 10) // ["Dubois", "Dupont", "Durand"]
+ */
+func (d *Directory) SuggestNames(prefix string, limit int) []string {
+	fold := strings.ToLower(prefix)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	start, _ := slices.BinarySearchFunc(d.index.names, nameEntry{fold: fold}, compareNames)
+	names := []string{}
+	for _, entry := range d.index.names[start:] {
+		if !strings.HasPrefix(entry.fold, fold) || (limit > 0 && len(names) == limit) {
+			break
+		}
+		names = append(names, entry.value)
+	}
+	return names
+}
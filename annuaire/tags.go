@@ -0,0 +1,141 @@
+package annuaire
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Bounds of the tags of one contact (see ValidateTags)
+const (
+	maxTags      = 20
+	maxTagLength = 32
+)
+
+/**
+ * ParseTags splits a comma-separated list of tags as typed by a user
+ *
+ * @param {string} list - Tags such as "Work, family"
+ * @return {[]string} Normalized tags (see NormalizeTags), nil for an empty list
+ */
+func ParseTags(list string) []string {
+	return NormalizeTags(strings.Split(list, ","))
+}
+
+/**
+ * NormalizeTags converts tags to the stored format
+ *
+ * @param {[]string} tags - Tags as typed or imported
+ * @return {[]string} Trimmed, lower-cased, sorted tags without duplicates or
+ *   empty values; nil when none is left, so that a contact without tags
+ *   encodes without a "tags" field
+ */
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+/**
+ * ValidateTags checks the tags of a contact before they are stored
+ *
+ * @param {[]string} tags - Tags to check (nil means no tag and is valid)
+ * @return {error} A *FieldError on the "tags" field unless there are at most
+ *   20 tags of 1 to 32 letters, digits, "-" or "_"
+ */
+func ValidateTags(tags []string) error {
+	if len(tags) > maxTags {
+		return &FieldError{Field: "tags", Message: fmt.Sprintf("too many tags (%d, at most %d)", len(tags), maxTags)}
+	}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len([]rune(tag)) > maxTagLength {
+			return &FieldError{Field: "tags", Message: fmt.Sprintf("invalid tag %q (1 to %d characters)", tag, maxTagLength)}
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+				return &FieldError{Field: "tags", Message: fmt.Sprintf("invalid tag %q (letters, digits, \"-\" and \"_\" only)", tag)}
+			}
+		}
+	}
+	return nil
+}
+
+// TagChange is the outcome of TagContacts
+type TagChange struct {
+	Matched  int `json:"matched"`  // Contacts accepted by the filter
+	Modified int `json:"modified"` // Matched contacts whose tags changed; the others already had (or lacked) the tag
+}
+
+/**
+ * TagContacts adds or removes a tag on every contact accepted by a filter
+ *
+ * @param {ExportFilter} match - Contacts to change, e.g. ExportMatching("ACME", "")
+ * @param {string} tag - Tag to add or remove, normalized like NormalizeTags
+ * @param {bool} add - True adds the tag, false removes it
+ * @return {TagChange} Number of matched and modified contacts
+ * @return {error} A *FieldError for an invalid tag, or one naming the first
+ *   contact that would have more than 20 tags; nothing is changed then
+ *
+ * The contacts are changed under a single lock, in ID order, each one
+ * recorded in the history as an update.
+ *
+ * Usage:
+ *   change, err := dir.TagContacts(ExportMatching("ACME", ""), "client", true)
+ */
+func (d *Directory) TagContacts(match ExportFilter, tag string, add bool) (TagChange, error) {
+	if err := ValidateTags([]string{tag}); err != nil {
+		return TagChange{}, err
+	}
+	tag = strings.ToLower(strings.TrimSpace(tag))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var change TagChange
+	var updated []Contact
+	for _, c := range d.contacts {
+		if !match(c) {
+			continue
+		}
+		change.Matched++
+		if c.HasTag(tag) == add {
+			continue
+		}
+		if add {
+			c.Tags = NormalizeTags(append(slices.Clone(c.Tags), tag))
+			if err := ValidateTags(c.Tags); err != nil {
+				return TagChange{}, fmt.Errorf("%s %s: %w", c.First, c.Name, err)
+			}
+		} else {
+			c.Tags = NormalizeTags(slices.DeleteFunc(slices.Clone(c.Tags), func(t string) bool { return t == tag }))
+		}
+		updated = append(updated, c)
+	}
+	if len(updated) == 0 {
+		return change, nil
+	}
+
+	slices.SortFunc(updated, func(a, b Contact) int { return cmp.Compare(a.ID, b.ID) })
+	for _, c := range updated {
+		before := d.contacts[contactKey(c)]
+		touch(&c)
+		d.putLocked(c)
+		d.recordLocked(OpUpdate, &before, &c, "")
+	}
+	d.generation++
+	change.Modified = len(updated)
+	return change, nil
+}
+
+// HasTag reports whether the contact carries a tag, ignoring case
+func (c Contact) HasTag(tag string) bool {
+	return slices.Contains(c.Tags, strings.ToLower(strings.TrimSpace(tag)))
+}
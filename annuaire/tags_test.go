@@ -0,0 +1,111 @@
+package annuaire
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// TestTags tests the normalization, validation and storage of tags
+func TestTags(t *testing.T) {
+	if got := ParseTags(" Work, family,,work "); !slices.Equal(got, []string{"family", "work"}) {
+		t.Errorf("ParseTags = %v", got)
+	}
+	if got := ParseTags(" , "); got != nil {
+		t.Errorf("ParseTags of an empty list = %v, want nil", got)
+	}
+
+	for _, tags := range [][]string{{"a b"}, {"x,y"}, {""}, {"abcdefghijklmnopqrstuvwxyz0123456"}, make([]string, maxTags+1)} {
+		var fieldErr *FieldError
+		if err := ValidateTags(tags); !errors.As(err, &fieldErr) || fieldErr.Field != "tags" {
+			t.Errorf("ValidateTags(%q) = %v, want a tags field error", tags, err)
+		}
+	}
+
+	dir := NewDirectory()
+	if err := dir.InsertContact(Contact{Name: "Dupont", First: "Jean", Phone: "01", Tags: []string{"Work", "club-5", "work"}}); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := dir.SearchContact("Dupont")
+	if !slices.Equal(c.Tags, []string{"club-5", "work"}) || !c.HasTag("WORK") || c.HasTag("family") {
+		t.Errorf("Stored tags %v", c.Tags)
+	}
+	if err := dir.InsertContact(Contact{Name: "Martin", First: "Luc", Phone: "02", Tags: []string{"bad tag"}}); err == nil {
+		t.Error("Invalid tag was accepted")
+	}
+
+	// Saving the same tags in another order changes nothing
+	generation := dir.Generation()
+	c.Tags = []string{"work", "Club-5"}
+	if err := dir.SaveContact(c); err != nil {
+		t.Fatal(err)
+	}
+	if dir.Generation() != generation {
+		t.Error("Saving unchanged tags modified the directory")
+	}
+	c.Tags = nil
+	if err := dir.SaveContact(c); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ = dir.SearchContact("Dupont"); c.Tags != nil {
+		t.Errorf("Tags should be cleared, got %v", c.Tags)
+	}
+}
+
+// TestTagContacts tests adding and removing a tag on the contacts of a search
+func TestTagContacts(t *testing.T) {
+	dir := NewDirectory()
+	for _, c := range []Contact{
+		{Name: "Dupont", First: "Jean", Phone: "01", Tags: []string{"client"}},
+		{Name: "Dupuis", First: "Anne", Phone: "02"},
+		{Name: "Martin", First: "Luc", Phone: "03"},
+	} {
+		if err := dir.InsertContact(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	change, err := dir.TagContacts(ExportMatching("dup", ""), " Client ", true)
+	if err != nil || change != (TagChange{Matched: 2, Modified: 1}) {
+		t.Fatalf("Adding a tag: %+v, %v", change, err)
+	}
+	if c, _ := dir.SearchContact("Dupuis"); !slices.Equal(c.Tags, []string{"client"}) || c.UpdatedAt.IsZero() {
+		t.Errorf("Tag not added: %+v", c)
+	}
+	if history, _ := dir.History(); len(history) != 4 || history[3].Op != OpUpdate || history[3].After.Name != "Dupuis" {
+		t.Errorf("Unexpected history %+v", history)
+	}
+
+	generation := dir.Generation()
+	if change, _ := dir.TagContacts(ExportMatching("", "client"), "client", true); change != (TagChange{Matched: 2}) || dir.Generation() != generation {
+		t.Errorf("Adding a tag twice: %+v", change)
+	}
+	if change, _ := dir.TagContacts(ExportMatching("", ""), "client", false); change != (TagChange{Matched: 3, Modified: 2}) {
+		t.Errorf("Removing a tag: %+v", change)
+	}
+	if c, _ := dir.SearchContact("Dupont"); c.Tags != nil {
+		t.Errorf("Tag not removed: %v", c.Tags)
+	}
+
+	var fieldErr *FieldError
+	if _, err := dir.TagContacts(ExportMatching("", ""), "bad tag", true); !errors.As(err, &fieldErr) {
+		t.Errorf("Invalid tag: %v", err)
+	}
+	full := make([]string, maxTags)
+	for i := range full {
+		full[i] = fmt.Sprintf("t%02d", i)
+	}
+	c, _ := dir.SearchContact("Martin")
+	c.Tags = full
+	if err := dir.SaveContact(c); err != nil {
+		t.Fatal(err)
+	}
+	generation = dir.Generation()
+	if _, err := dir.TagContacts(ExportMatching("", ""), "extra", true); !errors.As(err, &fieldErr) || dir.Generation() != generation {
+		t.Errorf("Too many tags: %v", err)
+	}
+	if c, _ := dir.SearchContact("Dupont"); c.HasTag("extra") {
+		t.Error("A refused change was partly applied")
+	}
+}
@@ -0,0 +1,199 @@
+package annuaire
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// usageChartWidth is the length of the longest bar in usage charts
+const usageChartWidth = 40
+
+// UsageDay aggregates the history entries of one calendar day
+type UsageDay struct {
+	Day      time.Time      // Midnight, local time
+	Ops      map[string]int // Number of entries per operation
+	Total    int            // Sum of Ops
+	Contacts int            // Directory size at the end of the day
+}
+
+// UsageTerm is a search term with the number of times it was searched
+type UsageTerm struct {
+	Term  string // Search term, lowercased
+	Count int    // Number of searches
+}
+
+// UsageReport summarizes the local history over a period
+type UsageReport struct {
+	Days        []UsageDay  // One entry per day of the period, oldest first
+	TopSearches []UsageTerm // Most searched terms, most frequent first
+}
+
+/**
+ * AnalyzeUsage computes usage statistics from the history
+ *
+ * @param {[]HistoryEntry} entries - History, oldest first (see Directory.History)
+ * @param {time.Time} now - End of the period (usually time.Now())
+ * @param {int} days - Length of the period in days, today included
+ * @return {UsageReport} Operations per day, directory growth and top searches
+ *
+ * Everything is computed from the local history file; nothing is sent anywhere
+ */
+func AnalyzeUsage(entries []HistoryEntry, now time.Time, days int) UsageReport {
+	if days < 1 {
+		days = 1
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start := today.AddDate(0, 0, 1-days)
+
+	report := UsageReport{Days: make([]UsageDay, days)}
+	for i := range report.Days {
+		report.Days[i] = UsageDay{Day: start.AddDate(0, 0, i), Ops: make(map[string]int)}
+	}
+
+	// Size before the period, carried over days without any entry
+	size := 0
+	active := make([]bool, days)
+	searches := make(map[string]int)
+	for _, entry := range entries {
+		local := entry.Time.In(now.Location())
+		if local.Before(start) {
+			size = entry.Size
+			continue
+		}
+		index := int(local.Sub(start).Hours() / 24)
+		if index >= days {
+			continue
+		}
+		day := &report.Days[index]
+		day.Ops[entry.Op]++
+		day.Total++
+		day.Contacts = entry.Size // Entries are in order: the last one of the day wins
+		active[index] = true
+		if entry.Op == OpSearch {
+			if term := strings.ToLower(strings.TrimSpace(entry.Details)); term != "" {
+				searches[term]++
+			}
+		}
+	}
+	for i := range report.Days {
+		if active[i] {
+			size = report.Days[i].Contacts
+		} else {
+			report.Days[i].Contacts = size
+		}
+	}
+
+	for term, count := range searches {
+		report.TopSearches = append(report.TopSearches, UsageTerm{term, count})
+	}
+	sort.Slice(report.TopSearches, func(i, j int) bool {
+		if report.TopSearches[i].Count != report.TopSearches[j].Count {
+			return report.TopSearches[i].Count > report.TopSearches[j].Count
+		}
+		return report.TopSearches[i].Term < report.TopSearches[j].Term
+	})
+	return report
+}
+
+/**
+ * WriteText renders the report as ASCII bar charts
+ *
+ * @param {io.Writer} w - Destination, typically os.Stdout
+ * @param {int} topN - Number of search terms to show
+ * @return {error} Returns an error if writing fails
+ */
+func (r UsageReport) WriteText(w io.Writer, topN int) error {
+	return r.writeText(w, topN, false)
+}
+
+/**
+ * WritePlainText renders the report as "label: value" lines without bars
+ *
+ * @param {io.Writer} w - Destination, typically os.Stdout
+ * @param {int} topN - Number of search terms to show
+ * @return {error} Returns an error if writing fails
+ *
+ * Same content as WriteText, for screen readers and scripts
+ */
+func (r UsageReport) WritePlainText(w io.Writer, topN int) error {
+	return r.writeText(w, topN, true)
+}
+
+// writeText renders the report with bar charts, or without them when plain
+func (r UsageReport) writeText(w io.Writer, topN int, plain bool) error {
+	var b strings.Builder
+	row := func(label string, value, maximum int, suffix string) {
+		if plain {
+			fmt.Fprintf(&b, "%s: %d%s\n", strings.TrimRight(label, " "), value, suffix)
+			return
+		}
+		fmt.Fprintf(&b, "%s │%s %d%s\n", label, usageBar(value, maximum), value, suffix)
+	}
+
+	maxOps, maxSize := 0, 0
+	for _, day := range r.Days {
+		maxOps = max(maxOps, day.Total)
+		maxSize = max(maxSize, day.Contacts)
+	}
+
+	fmt.Fprintf(&b, "Operations per day (last %d days)\n", len(r.Days))
+	for _, day := range r.Days {
+		row(day.Day.Format("2006-01-02"), day.Total, maxOps, usageBreakdown(day.Ops))
+	}
+
+	fmt.Fprintf(&b, "\nDirectory size\n")
+	for _, day := range r.Days {
+		row(day.Day.Format("2006-01-02"), day.Contacts, maxSize, "")
+	}
+
+	fmt.Fprintf(&b, "\nMost searched\n")
+	if len(r.TopSearches) == 0 {
+		fmt.Fprintf(&b, "(no searches recorded)\n")
+	}
+	top := r.TopSearches[:min(topN, len(r.TopSearches))]
+	maxCount, width := 0, 0
+	for _, t := range top {
+		maxCount = max(maxCount, t.Count)
+		width = max(width, len([]rune(t.Term)))
+	}
+	for _, t := range top {
+		padding := strings.Repeat(" ", width-len([]rune(t.Term)))
+		row(t.Term+padding, t.Count, maxCount, "")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// usageBar draws a bar proportional to value/maximum, padded to a fixed width
+func usageBar(value, maximum int) string {
+	length := 0
+	if maximum > 0 {
+		length = value * usageChartWidth / maximum
+		if value > 0 && length == 0 {
+			length = 1 // Keep small but non-zero values visible
+		}
+	}
+	return strings.Repeat("█", length) + strings.Repeat(" ", usageChartWidth-length)
+}
+
+// usageBreakdown lists the operations of a day, e.g. " (3 add, 1 search)"
+func usageBreakdown(ops map[string]int) string {
+	if len(ops) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(ops))
+	for op := range ops {
+		names = append(names, op)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, op := range names {
+		parts[i] = fmt.Sprintf("%d %s", ops[op], op)
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,58 @@
+package annuaire
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestAnalyzeUsage tests per-day counts, size carry-over and top searches
+func TestAnalyzeUsage(t *testing.T) {
+	now := time.Date(2025, time.March, 10, 18, 0, 0, 0, time.UTC)
+	at := func(daysAgo, hour int) time.Time {
+		return time.Date(2025, time.March, 10-daysAgo, hour, 0, 0, 0, time.UTC)
+	}
+	entries := []HistoryEntry{
+		{Time: at(10, 9), Op: OpImport, Size: 5}, // Before the period
+		{Time: at(2, 9), Op: OpAdd, Size: 6},
+		{Time: at(2, 10), Op: OpSearch, Details: "Dupont", Size: 6},
+		{Time: at(0, 8), Op: OpSearch, Details: "dupont ", Size: 6},
+		{Time: at(0, 9), Op: OpSearch, Details: "Martin", Size: 6},
+		{Time: at(0, 10), Op: OpDelete, Size: 5},
+	}
+
+	report := AnalyzeUsage(entries, now, 3)
+	if len(report.Days) != 3 {
+		t.Fatalf("Expected 3 days, got %d", len(report.Days))
+	}
+	sizes := []int{report.Days[0].Contacts, report.Days[1].Contacts, report.Days[2].Contacts}
+	if sizes[0] != 6 || sizes[1] != 6 || sizes[2] != 5 {
+		t.Errorf("Unexpected sizes: %v", sizes)
+	}
+	if report.Days[2].Total != 3 || report.Days[2].Ops[OpSearch] != 2 {
+		t.Errorf("Unexpected counts for today: %+v", report.Days[2])
+	}
+	if top := report.TopSearches; len(top) != 2 || top[0] != (UsageTerm{"dupont", 2}) {
+		t.Errorf("Unexpected top searches: %v", top)
+	}
+
+	var out strings.Builder
+	if err := report.WriteText(&out, 5); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "2025-03-10 │") || !strings.Contains(out.String(), "(1 delete, 2 search)") {
+		t.Errorf("Unexpected chart:\n%s", out.String())
+	}
+	out.Reset()
+	if err := report.WritePlainText(&out, 5); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "2025-03-10: 3 (1 delete, 2 search)\n") || !strings.Contains(out.String(), "dupont: 2\n") {
+		t.Errorf("Unexpected plain report:\n%s", out.String())
+	}
+	for _, r := range out.String() {
+		if r > 127 {
+			t.Fatalf("Plain report contains %q:\n%s", r, out.String())
+		}
+	}
+}
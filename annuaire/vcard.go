@@ -0,0 +1,453 @@
+package annuaire
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ImportReport summarizes the outcome of an import from a foreign contact format
+// Formats such as vCard carry many more properties than a Contact can hold,
+// so the report tells the user what was kept and what was left behind
+type ImportReport struct {
+	Imported    int            // Number of records converted into contacts
+	Skipped     int            // Number of records dropped because a required field was missing
+	Unsupported map[string]int // Occurrences of each property that has no Contact equivalent
+}
+
+// newImportReport returns a report ready to accumulate counters
+func newImportReport() *ImportReport {
+	return &ImportReport{Unsupported: make(map[string]int)}
+}
+
+/**
+ * UnsupportedSummary formats the ignored properties as a stable, human readable list
+ *
+ * @return {string} Comma separated "PROPERTY (count)" entries sorted by property name,
+ *                  or an empty string when nothing was ignored
+ *
+ * Usage:
+ *   fmt.Printf("Skipped properties: %s\n", report.UnsupportedSummary())
+ */
+func (r *ImportReport) UnsupportedSummary() string {
+	names := make([]string, 0, len(r.Unsupported))
+	for name := range r.Unsupported {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s (%d)", name, r.Unsupported[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// vCardMetadata lists structural properties that carry no user data
+// They are silently ignored instead of being reported as unsupported
+var vCardMetadata = map[string]bool{
+	"BEGIN":   true,
+	"END":     true,
+	"VERSION": true,
+	"PRODID":  true,
+	"REV":     true,
+	"UID":     true,
+	"FN":      true, // Display name, only used as a fallback when N is missing
+}
+
+/**
+ * ReadVCards parses every vCard (versions 2.1, 3.0 and 4.0) found in a stream
+ *
+ * @param {io.Reader} r - Source containing one or more BEGIN:VCARD ... END:VCARD blocks
+ * @return {[]Contact} Contacts built from the cards that had a name, first name and phone
+ * @return {*ImportReport} Counters for imported cards, skipped cards and ignored properties
+ * @return {error} Returns an error if the stream cannot be read
+ *
+ * Mapping rules:
+ * - N provides the last and first names (FN is used when N is absent)
+ * - The first TEL becomes the contact phone, additional numbers are reported as unsupported
+ * - BDAY becomes the birthday when it holds a full date ("19850412", "1985-04-12", "--0412")
+ * - The first ADR becomes the address (street, city, postal code and
+ *   country; the region is appended to the city), additional ones are reported
+ * - Every other property (EMAIL, ORG, PHOTO...) is counted in the report
+ *
+ * Usage:
+ *   contacts, report, err := ReadVCards(file)
+ */
+func ReadVCards(r io.Reader) ([]Contact, *ImportReport, error) {
+	lines, err := unfoldVCardLines(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := newImportReport()
+	var contacts []Contact
+	var current *Contact
+	var fullName string
+
+	for _, line := range lines {
+		name, params, value, ok := splitVCardLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VCARD"):
+			current = &Contact{}
+			fullName = ""
+			continue
+		case name == "END" && strings.EqualFold(value, "VCARD"):
+			if current != nil {
+				finishVCard(current, fullName)
+				if current.Name == "" || current.First == "" || current.Phone == "" {
+					report.Skipped++
+				} else {
+					contacts = append(contacts, *current)
+					report.Imported++
+				}
+			}
+			current = nil
+			continue
+		}
+
+		// Properties outside of a card block are meaningless
+		if current == nil {
+			continue
+		}
+
+		value = decodeVCardValue(params, value)
+
+		switch name {
+		case "N":
+			fields := splitVCardComponents(value)
+			current.Name = strings.TrimSpace(fields[0])
+			if len(fields) > 1 {
+				current.First = strings.TrimSpace(fields[1])
+			}
+		case "FN":
+			fullName = strings.TrimSpace(unescapeVCardText(value))
+		case "TEL":
+			phone := strings.TrimSpace(strings.TrimPrefix(value, "tel:"))
+			if current.Phone == "" {
+				current.Phone = phone
+			} else {
+				report.Unsupported["TEL (additional)"]++
+			}
+		case "BDAY":
+			if birthday, ok := vCardBirthday(value); ok {
+				current.Birthday = birthday
+			} else {
+				report.Unsupported["BDAY (partial date)"]++
+			}
+		case "ADR":
+			if current.Address.IsZero() {
+				current.Address = vCardAddress(value)
+			} else {
+				report.Unsupported["ADR (additional)"]++
+			}
+		default:
+			if !vCardMetadata[name] {
+				report.Unsupported[name]++
+			}
+		}
+	}
+
+	return contacts, report, nil
+}
+
+/**
+ * ReadVCardFile reads contacts from a vCard file or a macOS Contacts archive
+ *
+ * @param {string} filename - Path to a .vcf file, a .abbu bundle directory, or a zipped archive
+ * @return {[]Contact} Contacts collected from every enclosed vCard
+ * @return {*ImportReport} Combined report across all vCards read
+ * @return {error} Returns an error if the path is missing or holds no vCard at all
+ *
+ * Archive handling:
+ * - A .abbu bundle is a directory; every .vcf file inside it is read
+ * - A .zip archive (how bundles usually travel by mail) is scanned for .vcf entries
+ * - The bundle's internal SQLite database is not parsed, so an archive without
+ *   vCards is rejected with a hint to use "File > Export > Export vCard" instead
+ */
+func ReadVCardFile(filename string) ([]Contact, *ImportReport, error) {
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return nil, nil, errors.New("file not found")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return collectVCards(func(collect func(io.Reader) error) error {
+		switch {
+		case info.IsDir():
+			// .abbu bundles are plain directories on macOS
+			return filepath.WalkDir(filename, func(path string, entry os.DirEntry, err error) error {
+				if err != nil || entry.IsDir() || !isVCardName(path) {
+					return err
+				}
+				file, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				return collect(file)
+			})
+		case strings.EqualFold(filepath.Ext(filename), ".zip"):
+			archive, err := zip.OpenReader(filename)
+			if err != nil {
+				return err
+			}
+			defer archive.Close()
+			return readZippedVCards(&archive.Reader, collect)
+		default:
+			file, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			return collect(file)
+		}
+	})
+}
+
+// readZippedVCardStream reads the vCards of a zipped archive held in a stream
+func readZippedVCardStream(r io.Reader) ([]Contact, *ImportReport, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, err
+	}
+	return collectVCards(func(collect func(io.Reader) error) error {
+		return readZippedVCards(archive, collect)
+	})
+}
+
+// collectVCards combines the contacts and reports of every vCard stream that
+// walk hands to its collector, and fails when none of them holds a single
+// BEGIN:VCARD ... END:VCARD block, so that the wrong file is never imported
+// as an empty address book
+func collectVCards(walk func(collect func(io.Reader) error) error) ([]Contact, *ImportReport, error) {
+	report := newImportReport()
+	var contacts []Contact
+	found := 0
+
+	// Merge the result of one vCard stream into the combined outcome
+	err := walk(func(r io.Reader) error {
+		cards, partial, err := ReadVCards(r)
+		if err != nil {
+			return err
+		}
+		if partial.Imported+partial.Skipped > 0 {
+			found++
+		}
+		contacts = append(contacts, cards...)
+		report.Imported += partial.Imported
+		report.Skipped += partial.Skipped
+		for name, count := range partial.Unsupported {
+			report.Unsupported[name] += count
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if found == 0 {
+		return nil, nil, errorOf(ErrInvalidInput, "no vCard found (export a .vcf from Contacts with File > Export > Export vCard)")
+	}
+	return contacts, report, nil
+}
+
+/**
+ * ImportFromVCard imports contacts from a vCard file or macOS Contacts archive
+ *
+ * @param {string} filename - Path to a .vcf file, .abbu bundle or zipped archive
+ * @return {*ImportReport} Summary of imported, skipped and unsupported data
+ * @return {error} Returns an error if the source cannot be read
+ *
+ * Like ImportFromJSON, this completely replaces the existing contacts
+ *
+ * Usage:
+ *   report, err := dir.ImportFromVCard("Contacts.abbu")
+ */
+func (d *Directory) ImportFromVCard(filename string) (*ImportReport, error) {
+	contacts, report, err := ReadVCardFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	d.replaceContacts(contacts, OpImport, fmt.Sprintf("%d contacts from %s", len(contacts), filename))
+	return report, nil
+}
+
+// readZippedVCards feeds every .vcf entry of a zip archive to the collector
+func readZippedVCards(archive *zip.Reader, collect func(io.Reader) error) error {
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !isVCardName(entry.Name) {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = collect(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isVCardName reports whether a file name has a vCard extension
+func isVCardName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".vcf" || ext == ".vcard"
+}
+
+// unfoldVCardLines reads all lines and joins folded continuation lines
+// RFC 6350 folds long lines by starting the continuation with a space or tab
+func unfoldVCardLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024) // PHOTO values can be large
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		// vCard 2.1 quoted-printable soft line breaks end with "="
+		if len(lines) > 0 && strings.HasSuffix(lines[len(lines)-1], "=") &&
+			strings.Contains(strings.ToUpper(lines[len(lines)-1]), "QUOTED-PRINTABLE") {
+			lines[len(lines)-1] += "\n" + line
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// splitVCardLine separates "group.NAME;PARAMS:value" into its parts
+// The property name is upper-cased and any Apple "itemN." group prefix is removed
+func splitVCardLine(line string) (name, params, value string, ok bool) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return "", "", "", false
+	}
+	head, value := line[:colon], line[colon+1:]
+
+	name = head
+	if semi := strings.Index(head, ";"); semi >= 0 {
+		name, params = head[:semi], head[semi+1:]
+	}
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	return strings.ToUpper(strings.TrimSpace(name)), params, value, true
+}
+
+// decodeVCardValue applies the ENCODING parameter used by vCard 2.1 exports
+func decodeVCardValue(params, value string) string {
+	if !strings.Contains(strings.ToUpper(params), "QUOTED-PRINTABLE") {
+		return value
+	}
+	value = strings.ReplaceAll(value, "=\n", "")
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(value)))
+	if err != nil {
+		return value
+	}
+	return string(decoded)
+}
+
+// splitVCardComponents splits a structured value on unescaped semicolons
+// and unescapes each component; the result always has at least one element
+func splitVCardComponents(value string) []string {
+	var fields []string
+	var current strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			current.WriteRune(r)
+			escaped = true
+		case r == ';':
+			fields = append(fields, unescapeVCardText(current.String()))
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(fields, unescapeVCardText(current.String()))
+}
+
+// unescapeVCardText reverts the backslash escaping of vCard text values
+func unescapeVCardText(value string) string {
+	replacer := strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
+
+// vCardAddress converts the components of an ADR property: post office box,
+// extended address, street, locality, region, postal code and country
+func vCardAddress(value string) Address {
+	fields := splitVCardComponents(value)
+	for len(fields) < 7 {
+		fields = append(fields, "")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	street := strings.TrimSpace(strings.Join([]string{fields[0], fields[1], fields[2]}, " "))
+	city := fields[3]
+	if fields[4] != "" {
+		city = strings.TrimSpace(city + " " + fields[4])
+	}
+	return Address{Street: strings.Join(strings.Fields(street), " "), City: city, Zip: fields[5], Country: fields[6]}
+}
+
+// vCardBirthday converts a vCard date to the stored birthday format
+// vCard 3.0 uses "1985-04-12", vCard 4.0 "19850412" or "--0412" when the year
+// is unknown; times and partial dates such as "1985" are not birthdays we can use
+func vCardBirthday(value string) (string, bool) {
+	value, _, _ = strings.Cut(strings.TrimSpace(value), "T")
+	compact := strings.ReplaceAll(value, "-", "")
+
+	var birthday string
+	switch {
+	case strings.HasPrefix(value, "--") && len(compact) == 4:
+		birthday = "--" + compact[:2] + "-" + compact[2:]
+	case !strings.HasPrefix(value, "-") && len(compact) == 8:
+		birthday = compact[:4] + "-" + compact[4:6] + "-" + compact[6:]
+	default:
+		return "", false
+	}
+	return birthday, ValidateBirthday(birthday) == nil
+}
+
+// finishVCard fills the names from FN when the card has no usable N property
+// The last word of the display name is taken as the last name
+func finishVCard(c *Contact, fullName string) {
+	if c.Name != "" || fullName == "" {
+		return
+	}
+	words := strings.Fields(fullName)
+	c.Name = words[len(words)-1]
+	if len(words) > 1 && c.First == "" {
+		c.First = strings.Join(words[:len(words)-1], " ")
+	}
+}
@@ -0,0 +1,146 @@
+package annuaire
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sampleVCards mimics a macOS Contacts export with grouped and folded properties
+const sampleVCards = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"N:Dupont;Jean;;;\r\n" +
+	"FN:Jean Dupont\r\n" +
+	"BDAY:1985-04-12\r\n" +
+	"ADR;TYPE=HOME:;;12 rue de la Paix;Paris;;75002;France\r\n" +
+	"ADR;TYPE=WORK:;;1 avenue Foch;Lyon;;69006;France\r\n" +
+	"item1.TEL;type=CELL;type=pref:06 12 34 56 78\r\n" +
+	"TEL;type=WORK:01 23 45 67 89\r\n" +
+	"EMAIL;type=INTERNET:jean@example.com\r\n" +
+	"NOTE:A long note that is\r\n" +
+	"  folded on two lines\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"FN:Marie Curie\r\n" +
+	"TEL:0700000000\r\n" +
+	"BDAY:1867\r\n" +
+	"END:VCARD\r\n" +
+	"BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"N:;;;;\r\n" +
+	"ORG:ACME\r\n" +
+	"END:VCARD\r\n"
+
+// TestReadVCards tests name/phone mapping and the unsupported property report
+func TestReadVCards(t *testing.T) {
+	contacts, report, err := ReadVCards(strings.NewReader(sampleVCards))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(contacts) != 2 || report.Imported != 2 {
+		t.Fatalf("Expected 2 contacts, got %d (report %d)", len(contacts), report.Imported)
+	}
+	if report.Skipped != 1 {
+		t.Errorf("Expected the company-only card to be skipped, got %d skipped", report.Skipped)
+	}
+
+	jean := contacts[0]
+	if jean.Name != "Dupont" || jean.First != "Jean" || jean.Phone != "06 12 34 56 78" || jean.Birthday != "1985-04-12" {
+		t.Errorf("Incorrect data: %+v", jean)
+	}
+	if want := (Address{Street: "12 rue de la Paix", City: "Paris", Zip: "75002", Country: "France"}); jean.Address != want {
+		t.Errorf("Expected the first ADR %+v, got %+v", want, jean.Address)
+	}
+
+	marie := contacts[1]
+	if marie.Name != "Curie" || marie.First != "Marie" || marie.Birthday != "" {
+		t.Errorf("FN fallback failed: %+v", marie)
+	}
+
+	summary := report.UnsupportedSummary()
+	for _, want := range []string{"EMAIL (1)", "NOTE (1)", "ORG (1)", "TEL (additional) (1)", "ADR (additional) (1)", "BDAY (partial date) (1)"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Summary %q is missing %q", summary, want)
+		}
+	}
+}
+
+// TestImportFromVCardBundle tests reading the vCards enclosed in a .abbu directory
+func TestImportFromVCardBundle(t *testing.T) {
+	bundle := filepath.Join(t.TempDir(), "Contacts.abbu")
+	if err := os.MkdirAll(bundle, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bundle, "export.vcf"), []byte(sampleVCards), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := NewDirectory()
+	dir.AddContact("Old", "Contact", "0000000000")
+
+	report, err := dir.ImportFromVCard(bundle)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Imported != 2 || dir.ContactCount() != 2 {
+		t.Errorf("Expected 2 contacts after import, got %d", dir.ContactCount())
+	}
+
+	// An archive holding only the Contacts database is rejected
+	empty := filepath.Join(t.TempDir(), "Empty.abbu")
+	os.MkdirAll(empty, 0755)
+	if _, err := dir.ImportFromVCard(empty); err == nil {
+		t.Error("Expected error for archive without vCards")
+	}
+}
+
+// TestImportFromVCardNoCard tests that a file without any vCard block is
+// refused and leaves the directory as it was, rather than emptying it
+func TestImportFromVCardNoCard(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notes.vcf")
+	if err := os.WriteFile(file, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0612345678")
+	if _, err := dir.ImportFromVCard(file); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Expected ErrInvalidInput for a file without vCards, got %v", err)
+	}
+	if dir.ContactCount() != 1 {
+		t.Errorf("A refused import must keep the contacts, %d left", dir.ContactCount())
+	}
+	if _, _, err := ReadContacts(strings.NewReader("hello\n"), "notes.vcf", ""); err == nil {
+		t.Error("Expected an error for a stream without vCards")
+	}
+}
+
+// TestReadContactsStream tests picking the reader of an upload from its name
+func TestReadContactsStream(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	entry, _ := zw.Create("Contacts/export.vcf")
+	entry.Write([]byte(sampleVCards))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, source := range map[string]string{
+		"export.vcf":   sampleVCards,
+		"Contacts.zip": archive.String(),
+		"backup.json":  `[{"name": "Dupont", "first": "Jean", "phone": "0612345678"}, {"name": "Curie", "first": "Marie", "phone": "0700000000"}]`,
+	} {
+		contacts, _, err := ReadContacts(strings.NewReader(source), name, "")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if len(contacts) != 2 {
+			t.Errorf("%s: expected 2 contacts, got %d", name, len(contacts))
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package annuaire
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultVerifyMonths is the time a confirmed contact stays trusted when
+// settings do not choose another period
+const DefaultVerifyMonths = 12
+
+/**
+ * VerifyByDate computes the "verify by" date of a contact confirmed now
+ *
+ * @param {time.Time} now - Time of the confirmation
+ * @param {int} months - Months before the contact must be confirmed again
+ * @return {string} Date as "YYYY-MM-DD", ready to store in Contact.VerifyBy
+ */
+func VerifyByDate(now time.Time, months int) string {
+	return now.AddDate(0, months, 0).Format(BirthdayLayout)
+}
+
+/**
+ * NeedsVerification reports whether a contact is due for confirmation
+ *
+ * @param {time.Time} now - Current time
+ * @return {bool} True if the contact was never confirmed or its verify-by date has passed
+ *
+ * Contacts added before the workflow existed, or imported from a file, have
+ * no date: nobody vouched for their number, so they are due as well
+ */
+func (c Contact) NeedsVerification(now time.Time) bool {
+	// ISO dates compare as strings
+	return c.VerifyBy == "" || c.VerifyBy < now.Format(BirthdayLayout)
+}
+
+/**
+ * NeedingVerification returns the review queue of the directory
+ *
+ * @param {time.Time} now - Current time
+ * @return {[]Contact} Contacts due for confirmation, most overdue first
+ *
+ * Contacts that were never confirmed come first, then by verify-by date,
+ * then alphabetically
+ */
+func (d *Directory) NeedingVerification(now time.Time) []Contact {
+	var due []Contact
+	for _, contact := range d.ListContacts() {
+		if contact.NeedsVerification(now) {
+			due = append(due, contact)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].VerifyBy != due[j].VerifyBy {
+			return due[i].VerifyBy < due[j].VerifyBy
+		}
+		return lessAlphabetical(due[i], due[j])
+	})
+	return due
+}
@@ -0,0 +1,24 @@
+package annuaire
+
+import (
+	"testing"
+	"time"
+)
+
+// TestNeedingVerification tests which contacts are due and in which order
+func TestNeedingVerification(t *testing.T) {
+	now := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.Local)
+	dir := NewDirectory()
+	dir.InsertContact(Contact{Name: "Martin", First: "Paul", Phone: "01", VerifyBy: VerifyByDate(now, 12)})
+	dir.InsertContact(Contact{Name: "Durand", First: "Marie", Phone: "02", VerifyBy: "2025-01-31"})
+	dir.InsertContact(Contact{Name: "Bernard", First: "Luc", Phone: "03"})
+	dir.InsertContact(Contact{Name: "Petit", First: "Anne", Phone: "04", VerifyBy: "2025-03-10"})
+
+	due := dir.NeedingVerification(now)
+	if len(due) != 2 || due[0].Name != "Bernard" || due[1].Name != "Durand" {
+		t.Fatalf("Expected Bernard (never verified) then Durand, got %v", due)
+	}
+	if VerifyByDate(now, 12) != "2026-03-10" {
+		t.Errorf("Unexpected verify-by date %s", VerifyByDate(now, 12))
+	}
+}
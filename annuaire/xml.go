@@ -0,0 +1,143 @@
+package annuaire
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// xmlDocument is the root element written by EncodeXML:
+//
+//	<contacts version="2" exported_at="2025-06-01T12:00:00Z">
+//	  <contact id="1"><name>Dupont</name>...<tags><tag>work</tag></tags>
+//	    <custom><field name="badge number">1234</field></custom></contact>
+//	</contacts>
+type xmlDocument struct {
+	XMLName    xml.Name     `xml:"contacts"`
+	Version    int          `xml:"version,attr,omitempty"`
+	ExportedAt string       `xml:"exported_at,attr,omitempty"`
+	Contacts   []xmlContact `xml:"contact"`
+}
+
+// xmlCustom lists the custom fields of a contact
+type xmlCustom struct {
+	Fields []xmlCustomField `xml:"field"`
+}
+
+// xmlCustomField is a custom field of a contact: <field name="badge number">1234</field>
+type xmlCustomField struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// xmlContact is a contact element, with the field names of the JSON export
+type xmlContact struct {
+	ID        int        `xml:"id,attr,omitempty"`
+	Name      string     `xml:"name"`
+	First     string     `xml:"first"`
+	Phone     string     `xml:"phone"`
+	Birthday  string     `xml:"birthday,omitempty"`
+	Address   *Address   `xml:"address"` // nil when the contact has none
+	Code      string     `xml:"code,omitempty"`
+	VerifyBy  string     `xml:"verify_by,omitempty"`
+	Photo     string     `xml:"photo,omitempty"`
+	Tags      []string   `xml:"tags>tag,omitempty"`
+	Custom    *xmlCustom `xml:"custom"` // nil when the contact has no custom field
+	UpdatedAt string     `xml:"updated_at,omitempty"`
+}
+
+/**
+ * EncodeXML writes contacts as an indented XML document
+ *
+ * @param {io.Writer} w - Destination of the document
+ * @param {[]Contact} contacts - Contacts to write, in order
+ * @return {error} Returns an error if writing fails
+ *
+ * The root element carries the version and export date of the JSON
+ * envelope; each contact is a <contact id="..."> element (see xmlDocument)
+ */
+func EncodeXML(w io.Writer, contacts []Contact) error {
+	document := xmlDocument{
+		Version:    FormatVersion,
+		ExportedAt: exportClock().UTC().Truncate(time.Second).Format(time.RFC3339),
+		Contacts:   make([]xmlContact, 0, len(contacts)),
+	}
+	for _, c := range contacts {
+		element := xmlContact{ID: c.ID, Name: c.Name, First: c.First, Phone: c.Phone, Birthday: c.Birthday,
+			Code: c.Code, VerifyBy: c.VerifyBy, Photo: c.Photo, Tags: c.Tags}
+		if !c.Address.IsZero() {
+			element.Address = &c.Address
+		}
+		if len(c.Custom) > 0 {
+			element.Custom = &xmlCustom{}
+			for _, key := range c.CustomKeys() {
+				element.Custom.Fields = append(element.Custom.Fields, xmlCustomField{Name: key, Value: c.Custom[key]})
+			}
+		}
+		if !c.UpdatedAt.IsZero() {
+			element.UpdatedAt = c.UpdatedAt.UTC().Format(time.RFC3339Nano)
+		}
+		document.Contacts = append(document.Contacts, element)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+/**
+ * ReadXML reads contacts from an XML document written by EncodeXML
+ *
+ * @param {io.Reader} r - XML document, possibly gzip-compressed
+ * @return {[]Contact} Contacts of the document
+ * @return {error} Returns an error for invalid XML, an unsupported version or a bad date
+ *
+ * A document without a version attribute is read as the current version,
+ * so that hand-written files need only the <contact> elements
+ */
+func ReadXML(r io.Reader) ([]Contact, error) {
+	r, err := decompressed(r)
+	if err != nil {
+		return nil, err
+	}
+	var document xmlDocument
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, err
+	}
+	if document.Version == 0 {
+		document.Version = FormatVersion
+	}
+	if err := checkVersion(document.Version); err != nil {
+		return nil, err
+	}
+
+	contacts := make([]Contact, 0, len(document.Contacts))
+	for i, element := range document.Contacts {
+		contact := Contact{ID: element.ID, Name: element.Name, First: element.First, Phone: element.Phone, Birthday: element.Birthday,
+			Code: element.Code, VerifyBy: element.VerifyBy, Photo: element.Photo, Tags: element.Tags}
+		if element.Address != nil {
+			contact.Address = *element.Address
+		}
+		if element.Custom != nil {
+			contact.Custom = make(map[string]string, len(element.Custom.Fields))
+			for _, field := range element.Custom.Fields {
+				contact.Custom[field.Name] = field.Value
+			}
+		}
+		if element.UpdatedAt != "" {
+			if contact.UpdatedAt, err = time.Parse(time.RFC3339Nano, element.UpdatedAt); err != nil {
+				return nil, fmt.Errorf("xml: contact %d: invalid updated_at %q", i+1, element.UpdatedAt)
+			}
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, nil
+}
@@ -0,0 +1,53 @@
+package annuaire
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestXMLRoundTrip tests that EncodeXML output is read back unchanged
+func TestXMLRoundTrip(t *testing.T) {
+	defer func(clock func() time.Time) { exportClock = clock }(exportClock)
+	exportClock = func() time.Time { return time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC) }
+
+	contacts := []Contact{
+		{ID: 1, Name: "Dupont", First: "Jean", Phone: "0612345678", Tags: []string{"work"}, UpdatedAt: time.Date(2025, 5, 1, 8, 0, 0, 0, time.UTC)},
+		{ID: 2, Name: "Léger & <Fils>", First: "Hélène", Phone: "0700000000", Birthday: "1990-01-02", Address: Address{City: "Lyon", Country: "France"},
+			Custom: map[string]string{"badge number": "12 & 13"}},
+	}
+	var buf bytes.Buffer
+	if err := EncodeXML(&buf, contacts); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<contacts version="2" exported_at="2025-06-01T12:00:00Z">`,
+		`<contact id="1">`,
+		"<tags>\n      <tag>work</tag>\n    </tags>",
+		"Léger &amp; &lt;Fils&gt;",
+		`<field name="badge number">12 &amp; 13</field>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, buf.String())
+		}
+	}
+
+	back, err := ReadXML(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, contacts) {
+		t.Errorf("Round trip changed the contacts:\n got %+v\nwant %+v", back, contacts)
+	}
+
+	// Hand-written documents may leave the version out
+	back, err = ReadXML(strings.NewReader("<contacts><contact><name>Martin</name><first>Lucie</first><phone>1</phone></contact></contacts>"))
+	if err != nil || len(back) != 1 || back[0].Name != "Martin" {
+		t.Errorf("Expected Martin, got %+v (%v)", back, err)
+	}
+	if _, err := ReadXML(strings.NewReader(`<contacts version="9"></contacts>`)); err == nil {
+		t.Error("Expected newer versions to be refused")
+	}
+}
@@ -0,0 +1,510 @@
+package annuaire
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * EncodeYAML writes contacts as a YAML document with the fields of the JSON export
+ *
+ * @param {io.Writer} w - Destination of the document
+ * @param {[]Contact} contacts - Contacts to write, in order
+ * @return {error} Returns an error if writing fails
+ *
+ * The document mirrors the JSON envelope, so a file can be edited by hand
+ * and read back with ReadYAML:
+ *
+ *   version: 2
+ *   exported_at: 2025-06-01T12:00:00Z
+ *   contacts:
+ *     - id: 1
+ *       name: Dupont
+ *       first: Jean
+ *       phone: "0601020304"
+ *       address:
+ *         city: Paris
+ *       tags:
+ *         - work
+ *
+ * Strings are quoted whenever YAML could read them as another type, e.g.
+ * phone numbers, which would otherwise lose their leading zero
+ */
+func EncodeYAML(w io.Writer, contacts []Contact) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "version: %d\n", FormatVersion)
+	fmt.Fprintf(out, "exported_at: %s\n", exportClock().UTC().Truncate(time.Second).Format(time.RFC3339))
+	if len(contacts) == 0 {
+		out.WriteString("contacts: []\n")
+		return out.Flush()
+	}
+
+	out.WriteString("contacts:\n")
+	for _, contact := range contacts {
+		prefix := "  - "
+		field := func(name, value string) {
+			if value != "" {
+				fmt.Fprintf(out, "%s%s: %s\n", prefix, name, yamlString(value))
+				prefix = "    "
+			}
+		}
+		if contact.ID > 0 {
+			fmt.Fprintf(out, "%sid: %d\n", prefix, contact.ID)
+			prefix = "    "
+		}
+		// Required fields are written even when empty, like in JSON
+		for _, required := range []struct{ name, value string }{{"name", contact.Name}, {"first", contact.First}, {"phone", contact.Phone}} {
+			fmt.Fprintf(out, "%s%s: %s\n", prefix, required.name, yamlString(required.value))
+			prefix = "    "
+		}
+		field("birthday", contact.Birthday)
+		if !contact.Address.IsZero() {
+			out.WriteString("    address:\n")
+			for _, part := range []struct{ name, value string }{{"street", contact.Address.Street}, {"city", contact.Address.City},
+				{"zip", contact.Address.Zip}, {"country", contact.Address.Country}} {
+				if part.value != "" {
+					fmt.Fprintf(out, "      %s: %s\n", part.name, yamlString(part.value))
+				}
+			}
+		}
+		field("code", contact.Code)
+		field("verify_by", contact.VerifyBy)
+		field("photo", contact.Photo)
+		if len(contact.Tags) > 0 {
+			out.WriteString("    tags:\n")
+			for _, tag := range contact.Tags {
+				fmt.Fprintf(out, "      - %s\n", yamlString(tag))
+			}
+		}
+		if len(contact.Custom) > 0 {
+			out.WriteString("    custom:\n")
+			for _, key := range contact.CustomKeys() {
+				fmt.Fprintf(out, "      %s: %s\n", yamlString(key), yamlString(contact.Custom[key]))
+			}
+		}
+		if !contact.UpdatedAt.IsZero() {
+			field("updated_at", contact.UpdatedAt.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	return out.Flush()
+}
+
+// yamlPlain matches the strings written without quotes: they start with a
+// letter and hold no character YAML gives a meaning to
+var yamlPlain = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9 ._@+-]*$`)
+
+// yamlString writes a scalar, double-quoted unless it reads back as the same string
+func yamlString(value string) string {
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		return strconv.Quote(value)
+	}
+	if yamlPlain.MatchString(value) && !strings.HasSuffix(value, " ") {
+		return value
+	}
+	// Go escapes (\n, \t, \x00, é...) are valid YAML escapes
+	return strconv.Quote(value)
+}
+
+/**
+ * ReadYAML reads contacts from a YAML document written by EncodeYAML
+ *
+ * @param {io.Reader} r - YAML document, possibly gzip-compressed
+ * @return {[]Contact} Contacts of the document
+ * @return {error} Returns an error for invalid YAML or a field of the wrong type
+ *
+ * The reader understands the block mappings and sequences, flow lists such
+ * as "tags: [work, family]", quoted strings and comments, which is what
+ * contact files need; anchors, multi-line strings and multiple documents are
+ * not supported. A top-level list of contacts is read as a version 1 file,
+ * and a document without a version as the current one
+ */
+func ReadYAML(r io.Reader) ([]Contact, error) {
+	r, err := decompressed(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseYAML(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	version, items := FormatVersion, root
+	if document, ok := root.(map[string]any); ok {
+		if value, ok := document["version"]; ok {
+			text, _ := value.(string)
+			if version, err = strconv.Atoi(text); err != nil {
+				return nil, fmt.Errorf("invalid contacts file version %q", text)
+			}
+		}
+		items = document["contacts"]
+	} else if root != nil {
+		version = 1
+	}
+	if err := checkVersion(version); err != nil {
+		return nil, err
+	}
+	if items == nil {
+		return nil, nil
+	}
+	list, ok := items.([]any)
+	if !ok {
+		return nil, fmt.Errorf("yaml: contacts must be a list")
+	}
+
+	contacts := make([]Contact, 0, len(list))
+	for i, item := range list {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("yaml: contact %d is not a mapping", i+1)
+		}
+		contact, err := yamlContact(fields)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: contact %d: %w", i+1, err)
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, nil
+}
+
+// yamlContact builds a contact from its parsed fields; unknown fields are
+// ignored, as ReadJSON does
+func yamlContact(fields map[string]any) (Contact, error) {
+	var contact Contact
+	text := func(name string) (string, error) {
+		switch value := fields[name].(type) {
+		case nil:
+			return "", nil
+		case string:
+			return value, nil
+		}
+		return "", fmt.Errorf("field %q must be a string", name)
+	}
+	for name, target := range map[string]*string{
+		"name": &contact.Name, "first": &contact.First, "phone": &contact.Phone,
+		"birthday": &contact.Birthday, "code": &contact.Code, "verify_by": &contact.VerifyBy, "photo": &contact.Photo,
+	} {
+		value, err := text(name)
+		if err != nil {
+			return contact, err
+		}
+		*target = value
+	}
+
+	if id, err := text("id"); err != nil {
+		return contact, err
+	} else if id != "" {
+		if contact.ID, err = strconv.Atoi(id); err != nil {
+			return contact, fmt.Errorf("invalid id %q", id)
+		}
+	}
+	if updated, err := text("updated_at"); err != nil {
+		return contact, err
+	} else if updated != "" {
+		if contact.UpdatedAt, err = time.Parse(time.RFC3339Nano, updated); err != nil {
+			return contact, fmt.Errorf("invalid updated_at %q", updated)
+		}
+	}
+	switch address := fields["address"].(type) {
+	case nil:
+	case map[string]any:
+		for name, target := range map[string]*string{
+			"street": &contact.Address.Street, "city": &contact.Address.City, "zip": &contact.Address.Zip, "country": &contact.Address.Country,
+		} {
+			switch value := address[name].(type) {
+			case nil:
+			case string:
+				*target = value
+			default:
+				return contact, fmt.Errorf("field \"address.%s\" must be a string", name)
+			}
+		}
+	default:
+		return contact, fmt.Errorf("field \"address\" must be a mapping")
+	}
+	switch tags := fields["tags"].(type) {
+	case nil:
+	case []any:
+		for _, tag := range tags {
+			value, ok := tag.(string)
+			if !ok {
+				return contact, fmt.Errorf("tags must be strings")
+			}
+			contact.Tags = append(contact.Tags, value)
+		}
+	default:
+		return contact, fmt.Errorf("field \"tags\" must be a list")
+	}
+	switch custom := fields["custom"].(type) {
+	case nil:
+	case map[string]any:
+		contact.Custom = make(map[string]string, len(custom))
+		for key, value := range custom {
+			switch value := value.(type) {
+			case nil:
+			case string:
+				contact.Custom[key] = value
+			default:
+				return contact, fmt.Errorf("field \"custom.%s\" must be a string", key)
+			}
+		}
+	default:
+		return contact, fmt.Errorf("field \"custom\" must be a mapping")
+	}
+	return contact, nil
+}
+
+// yamlLine is a significant line of a YAML document
+type yamlLine struct {
+	number int    // Line number, for errors
+	indent int    // Leading spaces
+	text   string // Content without indentation and comment
+}
+
+// yamlParser builds the nodes of a document: map[string]any for mappings,
+// []any for sequences, string for scalars and nil for empty values
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses the subset of YAML described by ReadYAML
+func parseYAML(data string) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if content := strings.TrimLeft(raw, " \t"); content != "" && strings.Contains(raw[:len(raw)-len(content)], "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs cannot indent", i+1)
+		}
+		text := strings.TrimRight(stripYAMLComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (i == 0 && strings.HasPrefix(trimmed, "%")) {
+			continue
+		}
+		if trimmed == "---" {
+			if len(p.lines) > 0 {
+				return nil, fmt.Errorf("yaml: line %d: only one document is supported", i+1)
+			}
+			continue
+		}
+		if trimmed == "..." {
+			break
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	node, err := p.node(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("yaml: line %d: unexpected indentation", p.lines[p.pos].number)
+	}
+	return node, nil
+}
+
+// node parses the mapping, sequence or scalar starting at the current line
+func (p *yamlParser) node(indent int) (any, error) {
+	line := p.lines[p.pos]
+	if line.text == "-" || strings.HasPrefix(line.text, "- ") {
+		return p.sequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.mapping(indent)
+	}
+	p.pos++
+	return yamlScalar(line.text, line.number)
+}
+
+// sequence parses the "- item" lines at the given indentation
+func (p *yamlParser) sequence(indent int) ([]any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if line.text != "-" && !strings.HasPrefix(line.text, "- ") {
+			break
+		}
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if rest == "" {
+			// The item is on the next, more indented lines
+			p.pos++
+			item, err := p.child(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		// Read the rest of the line as if it started its own line, so that
+		// "- name: x" opens a mapping aligned on "name"
+		p.lines[p.pos] = yamlLine{number: line.number, indent: indent + len(line.text) - len(rest), text: rest}
+		item, err := p.node(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// mapping parses the "key: value" lines at the given indentation
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	fields := map[string]any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		key, value, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: expected \"key: value\"", line.number)
+		}
+		if _, duplicate := fields[key]; duplicate {
+			return nil, fmt.Errorf("yaml: line %d: duplicate key %q", line.number, key)
+		}
+		p.pos++
+
+		var err error
+		switch {
+		case value != "":
+			fields[key], err = yamlScalar(value, line.number)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && (p.lines[p.pos].text == "-" || strings.HasPrefix(p.lines[p.pos].text, "- ")):
+			// A sequence may sit at the indentation of its key
+			fields[key], err = p.sequence(indent)
+		default:
+			fields[key], err = p.child(indent)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// child parses the node nested under a line of the given indentation, nil if there is none
+func (p *yamlParser) child(indent int) (any, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+		return nil, nil
+	}
+	return p.node(p.lines[p.pos].indent)
+}
+
+// splitYAMLKey splits "key: value" (or "key:" before a nested node) outside quotes
+func splitYAMLKey(text string) (key, value string, ok bool) {
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") {
+		return "", "", false // Flow collection, not a key
+	}
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++ // Escaped character
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			key = strings.TrimSpace(text[:i])
+			if unquoted, err := yamlScalar(key, 0); err == nil {
+				key, _ = unquoted.(string)
+			}
+			return key, strings.TrimSpace(text[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment removes a "#" comment, which starts a line or follows a space outside quotes
+func stripYAMLComment(line string) string {
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" [,:-", rune(line[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlScalar converts a value: a quoted string, a flow list "[a, b]", or a
+// plain string ("~" and "null" being empty)
+func yamlScalar(text string, number int) (any, error) {
+	switch {
+	case text == "~" || text == "null" || text == "Null" || text == "NULL":
+		return nil, nil
+	case strings.HasPrefix(text, `"`):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: invalid quoted string %s", number, text)
+		}
+		return value, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("yaml: line %d: invalid quoted string %s", number, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("yaml: line %d: unterminated list %s", number, text)
+		}
+		items := []any{}
+		for _, item := range splitYAMLFlow(text[1 : len(text)-1]) {
+			value, err := yamlScalar(item, number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case strings.HasPrefix(text, "{"), strings.HasPrefix(text, "&"), strings.HasPrefix(text, "*"),
+		strings.HasPrefix(text, "|"), strings.HasPrefix(text, ">"), strings.HasPrefix(text, "!"):
+		return nil, fmt.Errorf("yaml: line %d: unsupported value %s", number, text)
+	}
+	return text, nil
+}
+
+// splitYAMLFlow splits the items of a flow list on the commas outside quotes
+func splitYAMLFlow(text string) []string {
+	var items []string
+	quote, start := byte(0), 0
+	for i := 0; i <= len(text); i++ {
+		switch {
+		case i == len(text) || (quote == 0 && text[i] == ','):
+			if item := strings.TrimSpace(text[start:i]); item != "" {
+				items = append(items, item)
+			}
+			start = i + 1
+		case quote != 0:
+			if text[i] == '\\' && quote == '"' {
+				i++
+			} else if text[i] == quote {
+				quote = 0
+			}
+		case text[i] == '"' || text[i] == '\'':
+			quote = text[i]
+		}
+	}
+	return items
+}
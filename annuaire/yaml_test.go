@@ -0,0 +1,86 @@
+package annuaire
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestYAMLRoundTrip tests that EncodeYAML output is read back unchanged
+func TestYAMLRoundTrip(t *testing.T) {
+	contacts := []Contact{
+		{ID: 1, Name: "Dupont", First: "Jean", Phone: "0612345678", Birthday: "--04-12", Tags: []string{"family", "work"},
+			Address:   Address{Street: "12 rue de la Paix", Zip: "75002", City: "Paris"},
+			Custom:    map[string]string{"badge number": "1234", "1st floor": "yes: left"},
+			UpdatedAt: time.Date(2025, 6, 1, 12, 0, 0, 5, time.UTC)},
+		{ID: 2, Name: "O'Brien: \"Junior\"", First: "Hélène # not a comment", Phone: "+33 6 00", Code: "yes", Photo: "/9j/4AAQ=="},
+		{Name: "Null", First: "", Phone: "\\"},
+	}
+	var buf bytes.Buffer
+	if err := EncodeYAML(&buf, contacts); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "phone: \"0612345678\"\n") {
+		t.Errorf("Phone numbers must be quoted:\n%s", buf.String())
+	}
+
+	back, err := ReadYAML(&buf)
+	if err != nil {
+		t.Fatalf("ReadYAML failed: %v\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(back, contacts) {
+		t.Errorf("Round trip changed the contacts:\n got %+v\nwant %+v", back, contacts)
+	}
+}
+
+// TestReadYAMLHandWritten tests the layouts a person is likely to type
+func TestReadYAMLHandWritten(t *testing.T) {
+	document := `# Contacts to import
+contacts:
+- name: Dupont      # aligned with its key
+  first: 'Jean'
+  phone: 0612345678
+  tags: [work, "family"]
+-
+  name: Martin
+  first: Lucie
+  phone: "0700000000"
+  tags:
+  - friends
+`
+	contacts, err := ReadYAML(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Contact{
+		{Name: "Dupont", First: "Jean", Phone: "0612345678", Tags: []string{"work", "family"}},
+		{Name: "Martin", First: "Lucie", Phone: "0700000000", Tags: []string{"friends"}},
+	}
+	if !reflect.DeepEqual(contacts, want) {
+		t.Errorf("Expected %+v, got %+v", want, contacts)
+	}
+
+	if _, err := ReadYAML(strings.NewReader("- {name: x}\n")); err == nil {
+		t.Error("Expected flow mappings to be refused")
+	}
+	// A bare list is a version 1 file
+	contacts, err = ReadYAML(strings.NewReader("- name: Dupont\n  first: Jean\n  phone: \"1\"\n"))
+	if err != nil || len(contacts) != 1 || contacts[0].Name != "Dupont" {
+		t.Errorf("Bare list not read: %+v (%v)", contacts, err)
+	}
+
+	for _, invalid := range []string{
+		"version: 99\ncontacts: []\n",
+		"contacts:\n  - name: [a]\n",
+		"contacts:\n  - id: one\n",
+		"contacts:\n  - name: a\n   first: b\n",
+		"contacts:\n\t- name: a\n",
+		"contacts: x\n",
+	} {
+		if _, err := ReadYAML(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
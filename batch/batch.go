@@ -0,0 +1,145 @@
+// Package batch queues items in memory and hands them over together in the
+// background, so that producers never wait for a slow destination
+//
+// It is the send loop shared by the trace exporter (package tracing) and the
+// change notifier (package mailer): both bound their queue, send a little
+// after the first item so that a burst goes at once, and send the last items
+// on shutdown
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Config describes a queue; every field but Send is optional
+type Config[T any] struct {
+	Send   func(ctx context.Context, items []T, dropped int) error // Delivers the items, oldest first, and the number dropped since the previous call
+	Wait   func(now time.Time) time.Duration                       // Wait of the first item queued at now for the others (nil: sent at once)
+	Failed func(err error)                                         // Receives the errors of the sends made in the background (nil: ignored)
+	Max    int                                                     // Items kept at most; beyond, new items are only counted (zero: no limit)
+	Full   int                                                     // Items sent without waiting for Wait (zero: always wait)
+}
+
+// Queue hands the items it is given to Config.Send in the background
+type Queue[T any] struct {
+	cfg Config[T]
+
+	mu      sync.Mutex
+	items   []T // Items waiting for the next send
+	dropped int // Items dropped since the last send, the queue being full
+
+	wake     chan struct{} // Signals the first item of a send, or a full queue
+	stop     chan struct{} // Closed by Shutdown
+	stopped  chan struct{} // Closed once the send loop has returned
+	stopOnce sync.Once
+}
+
+/**
+ * New starts a queue
+ *
+ * @param {Config[T]} cfg - Delivery, schedule and limits
+ * @return {*Queue[T]} Running queue; call Shutdown before exiting so that the
+ *   last items are sent
+ */
+func New[T any](cfg Config[T]) *Queue[T] {
+	q := &Queue[T]{
+		cfg:     cfg,
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Add queues an item; it only takes a lock and never waits for a send
+func (q *Queue[T]) Add(item T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.cfg.Max > 0 && len(q.items) >= q.cfg.Max {
+		q.dropped++
+		return
+	}
+	q.items = append(q.items, item)
+	if len(q.items) == 1 || len(q.items) == q.cfg.Full {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// full reports whether enough items wait to be sent without waiting more
+func (q *Queue[T]) full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.cfg.Full > 0 && len(q.items) >= q.cfg.Full
+}
+
+// run sends the queued items once they have waited long enough, until Shutdown
+func (q *Queue[T]) run() {
+	defer close(q.stopped)
+	var timer *time.Timer
+	var due <-chan time.Time
+	for {
+		select {
+		case <-q.wake:
+			if !q.full() && q.cfg.Wait != nil {
+				if due == nil {
+					timer = time.NewTimer(q.cfg.Wait(time.Now()))
+					due = timer.C
+				}
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			due = nil
+		case <-due:
+			due = nil
+		case <-q.stop:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+		if err := q.Flush(context.Background()); err != nil && q.cfg.Failed != nil {
+			q.cfg.Failed(err)
+		}
+	}
+}
+
+/**
+ * Flush sends every queued item now
+ *
+ * @param {context.Context} ctx - Context bounding the send
+ * @return {error} Error of Config.Send; the items are not queued again
+ */
+func (q *Queue[T]) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	items, dropped := q.items, q.dropped
+	q.items, q.dropped = nil, 0
+	q.mu.Unlock()
+	if len(items) == 0 && dropped == 0 {
+		return nil
+	}
+	return q.cfg.Send(ctx, items, dropped)
+}
+
+/**
+ * Shutdown stops the queue after sending the last items
+ *
+ * @param {context.Context} ctx - Context bounding the final send
+ * @return {error} Error of the final send
+ */
+func (q *Queue[T]) Shutdown(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+	select {
+	case <-q.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return q.Flush(ctx)
+}
@@ -0,0 +1,67 @@
+package batch
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestQueue tests that items wait for each other, that a full queue is sent
+// at once, that the items beyond Max are counted, and that Shutdown sends
+// what is still waiting
+func TestQueue(t *testing.T) {
+	var mu sync.Mutex
+	var sends [][]int
+	var drops []int
+	sent := make(chan struct{}, 10)
+	cfg := Config[int]{
+		Send: func(_ context.Context, items []int, dropped int) error {
+			mu.Lock()
+			sends, drops = append(sends, items), append(drops, dropped)
+			mu.Unlock()
+			sent <- struct{}{}
+			return nil
+		},
+		Wait: func(time.Time) time.Duration { return time.Hour },
+		Full: 2,
+	}
+	q := New(cfg)
+
+	q.Add(1)
+	q.Add(2) // Full: sent without waiting an hour
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("A full queue was not sent")
+	}
+
+	q.Add(3) // Waits for the others
+	if err := q.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Max, cfg.Full = 3, 0
+	q = New(cfg)
+	for item := 4; item <= 7; item++ {
+		q.Add(item) // 7 is beyond Max
+	}
+	if err := q.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := [][]int{{1, 2}, {3}, {4, 5, 6}}; !slices.EqualFunc(sends, want, slices.Equal) {
+		t.Errorf("Sends %v, want %v", sends, want)
+	}
+	if want := []int{0, 0, 1}; !slices.Equal(drops, want) {
+		t.Errorf("Dropped %v, want %v", drops, want)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"tp1/annuaire"
+	"tp1/server"
+)
+
+// completeContactsAction is the hidden action the completion scripts run to
+// complete a contact reference: "tp1 __contacts dup" prints the matching names
+const completeContactsAction = "__contacts"
+
+// completionShells are the shells "tp1 completion" writes scripts for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// referenceActions take a contact reference, completed with contact names
+var referenceActions = []string{"show", "delete", "update", "verify", "pin", "unpin", "history", "exists"}
+
+// Flags whose value is completed with files or directories
+var (
+	fileFlags      = []string{"file", "data", "tls-cert", "tls-key"}
+	directoryFlags = []string{"templates"}
+)
+
+// completionFlag describes a command-line flag for the completion scripts
+type completionFlag struct {
+	name    string
+	summary string   // First clause of the usage
+	value   bool     // False for boolean flags, given without a value
+	choices []string // Values offered after the flag, if they are few
+}
+
+// flagChoices lists the values offered after the flags taking one of a few
+func flagChoices() map[string][]string {
+	formats := slices.Concat(annuaire.ExportFormatNames(), annuaire.ImportFormatNames(), annuaire.CSVPresetNames())
+	slices.Sort(formats)
+	return map[string][]string{
+		"action":     visibleActions(),
+		"format":     slices.Compact(formats),
+		"dialect":    annuaire.DialectNames(),
+		"sort":       annuaire.SortKeys,
+		"conflict":   {"ours", "theirs", "newest"},
+		"storage":    {annuaire.FormatJSON, annuaire.FormatAppendLog},
+		"log-level":  {"debug", "info", "warn", "error"},
+		"access-log": server.AccessLogFormats,
+		"transform":  annuaire.ExportTransformNames(),
+		"fields":     append(slices.Clone(annuaire.ContactFields), "none"),
+	}
+}
+
+// visibleActions returns the built-in actions offered by completion, sorted
+func visibleActions() []string {
+	var actions []string
+	for action := range builtinActions {
+		if action != completeContactsAction {
+			actions = append(actions, action)
+		}
+	}
+	slices.Sort(actions)
+	return actions
+}
+
+// completionFlags describes every flag of the command line, sorted by name
+func completionFlags() []completionFlag {
+	choices := flagChoices()
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		boolean, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{
+			name:    f.Name,
+			summary: flagSummary(f.Usage),
+			value:   !ok || !boolean.IsBoolFlag(),
+			choices: choices[f.Name],
+		})
+	})
+	return flags
+}
+
+// flagSummary shortens a flag usage to its first clause, e.g. "Phone number"
+func flagSummary(usage string) string {
+	for _, separator := range []string{" (", "; ", ", e.g.", ": "} {
+		if i := strings.Index(usage, separator); i > 0 {
+			usage = usage[:i]
+		}
+	}
+	return usage
+}
+
+/**
+ * handleCompletionAction prints the completion script of a shell
+ *
+ * @param {string} shell - bash, zsh or fish
+ *
+ * The scripts are generated from the actions and flags of the command line,
+ * so they follow its changes. Contact references (show, delete, update...)
+ * and -name are completed with the names of the contacts, read when Tab is
+ * pressed through the hidden __contacts action
+ *
+ * Usage:
+ *   source <(tp1 completion bash)
+ *   tp1 completion fish > ~/.config/fish/completions/tp1.fish
+ */
+func handleCompletionAction(shell string) {
+	program := filepath.Base(os.Args[0])
+	switch shell {
+	case "bash":
+		writeBashCompletion(os.Stdout, program)
+	case "zsh":
+		writeZshCompletion(os.Stdout, program)
+	case "fish":
+		writeFishCompletion(os.Stdout, program)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: completion requires a shell: %s\n", strings.Join(completionShells, ", "))
+		os.Exit(exitInvalid)
+	}
+}
+
+/**
+ * handleCompleteContactsAction prints the last names starting with a prefix
+ *
+ * @param {*annuaire.Directory} dir - Directory whose contacts are listed
+ * @param {string} prefix - Start of the name typed, case-insensitive
+ *
+ * One name per line, sorted and without duplicates, for the completion scripts
+ */
+func handleCompleteContactsAction(dir *annuaire.Directory, prefix string) {
+	prefix = strings.ToLower(prefix)
+	var names []string
+	for _, c := range dir.ListContacts() {
+		if strings.HasPrefix(strings.ToLower(c.Name), prefix) {
+			names = append(names, c.Name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range slices.Compact(names) {
+		fmt.Println(name)
+	}
+}
+
+// writeBashCompletion writes the completion function of bash
+func writeBashCompletion(w io.Writer, program string) {
+	flags := completionFlags()
+	var names, valueFlags []string
+	for _, f := range flags {
+		names = append(names, "-"+f.name)
+		if f.value {
+			valueFlags = append(valueFlags, f.name)
+		}
+	}
+
+	fmt.Fprintf(w, "# bash completion for %s, generated by \"%s completion bash\"\n", program, program)
+	fmt.Fprintf(w, "# Load it with: source <(%s completion bash)\n\n", program)
+	fmt.Fprint(w, `_tp1_contacts() {
+    mapfile -t COMPREPLY < <("${COMP_WORDS[0]}" `+completeContactsAction+` "$1" 2>/dev/null)
+    COMPREPLY=("${COMPREPLY[@]// /\\ }")
+}
+
+_tp1() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local flag="${prev#-}"
+    flag="${flag#-}"
+    if [[ $prev == -* ]]; then
+        case "$flag" in
+            name) _tp1_contacts "$cur"; return ;;
+`)
+	fmt.Fprintf(w, "            %s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(fileFlags, "|"))
+	fmt.Fprintf(w, "            %s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", strings.Join(directoryFlags, "|"))
+	for _, f := range flags {
+		if f.choices != nil {
+			fmt.Fprintf(w, "            %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")); return ;;\n", f.name, strings.Join(f.choices, " "))
+		}
+	}
+	fmt.Fprintf(w, "            %s) return ;;\n", strings.Join(valueFlags, "|"))
+	fmt.Fprintf(w, `        esac
+    fi
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+
+    # Positional words: the action, then its contact reference or shell
+    local i word args=()
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word="${COMP_WORDS[i]}"
+        if [[ $word == -* ]]; then
+            word="${word#-}"
+            word="${word#-}"
+            [[ $word != *=* && " %s " == *" $word "* ]] && ((i++))
+        else
+            args+=("$word")
+        fi
+    done
+    case "${#args[@]}" in
+        0) COMPREPLY=($(compgen -W "%s" -- "$cur")) ;;
+        1)
+            case "${args[0]}" in
+                %s) _tp1_contacts "$cur" ;;
+                completion) COMPREPLY=($(compgen -W "%s" -- "$cur")) ;;
+            esac
+            ;;
+    esac
+}
+
+complete -F _tp1 %s
+`, strings.Join(names, " "), strings.Join(valueFlags, " "), strings.Join(visibleActions(), " "),
+		strings.Join(referenceActions, "|"), strings.Join(completionShells, " "), program)
+}
+
+// writeZshCompletion writes the completion function of zsh
+func writeZshCompletion(w io.Writer, program string) {
+	fmt.Fprintf(w, "#compdef %s\n", program)
+	fmt.Fprintf(w, "# zsh completion for %s, generated by \"%s completion zsh\"\n", program, program)
+	fmt.Fprintf(w, "# Load it with: source <(%s completion zsh), or save it as _%s in a directory of $fpath\n\n", program, program)
+	fmt.Fprint(w, `_tp1_contacts() {
+    local -a names
+    names=(${(f)"$(${words[1]} `+completeContactsAction+` "$PREFIX" 2>/dev/null)"})
+    compadd -U -- $names
+}
+
+_tp1() {
+    local state line
+    local -a actions
+    actions=(
+`)
+	for _, action := range visibleActions() {
+		fmt.Fprintf(w, "        %s\n", zshQuote(action+":"+builtinActions[action]))
+	}
+	fmt.Fprint(w, "    )\n    _arguments \\\n")
+	for _, f := range completionFlags() {
+		spec := "-" + f.name + "[" + zshEscape(f.summary) + "]"
+		switch {
+		case !f.value:
+		case f.name == "action":
+			spec += ":action:->action"
+		case f.name == "name":
+			spec += ":name:_tp1_contacts"
+		case slices.Contains(fileFlags, f.name):
+			spec += ":file:_files"
+		case slices.Contains(directoryFlags, f.name):
+			spec += ":directory:_files -/"
+		case f.choices != nil:
+			spec += ":" + f.name + ":(" + strings.Join(f.choices, " ") + ")"
+		default:
+			spec += ":" + f.name + ": "
+		}
+		fmt.Fprintf(w, "        %s \\\n", zshQuote(spec))
+	}
+	fmt.Fprintf(w, `        '1:action:->action' \
+        '2:argument:->argument'
+    case $state in
+        action) _describe -t actions action actions ;;
+        argument)
+            case $line[1] in
+                %s) _tp1_contacts ;;
+                completion) compadd %s ;;
+            esac
+            ;;
+    esac
+}
+
+if [[ $funcstack[1] == _tp1 ]]; then
+    _tp1 "$@"
+else
+    compdef _tp1 %s
+fi
+`, strings.Join(referenceActions, "|"), strings.Join(completionShells, " "), program)
+}
+
+// zshEscape protects the characters special in the description of an
+// _arguments option
+func zshEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(text)
+}
+
+// zshQuote quotes a word for zsh (and bash) in single quotes
+func zshQuote(text string) string {
+	return "'" + strings.ReplaceAll(text, "'", `'\''`) + "'"
+}
+
+// writeFishCompletion writes the completions of fish
+func writeFishCompletion(w io.Writer, program string) {
+	fmt.Fprintf(w, "# fish completion for %s, generated by \"%s completion fish\"\n", program, program)
+	fmt.Fprintf(w, "# Load it with: %s completion fish | source, or save it in ~/.config/fish/completions/%s.fish\n\n", program, program)
+	fmt.Fprint(w, `function __tp1_contacts
+    set -l cmd (commandline -opc)[1]
+    $cmd `+completeContactsAction+` (commandline -ct) 2>/dev/null
+end
+
+`)
+	fmt.Fprintf(w, "complete -c %s -f\n", program)
+	for _, action := range visibleActions() {
+		fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s -d %s\n", program, action, fishQuote(builtinActions[action]))
+	}
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from %s' -a '(__tp1_contacts)'\n", program, strings.Join(referenceActions, " "))
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from completion' -a '%s'\n", program, strings.Join(completionShells, " "))
+	for _, f := range completionFlags() {
+		line := fmt.Sprintf("complete -c %s -o %s -d %s", program, f.name, fishQuote(f.summary))
+		switch {
+		case !f.value:
+		case f.name == "name":
+			line += " -x -a '(__tp1_contacts)'"
+		case slices.Contains(fileFlags, f.name):
+			line += " -r -F"
+		case slices.Contains(directoryFlags, f.name):
+			line += " -x -a '(__fish_complete_directories)'"
+		case f.choices != nil:
+			line += " -x -a '" + strings.Join(f.choices, " ") + "'"
+		default:
+			line += " -x"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// fishQuote quotes a word for fish in single quotes
+func fishQuote(text string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(text) + "'"
+}
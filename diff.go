@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"tp1/annuaire"
+	"tp1/server"
+)
+
+// changeColumns are the columns of the table of changed contacts printed by diff
+var changeColumns = []tableColumn{{"ID", styleDim}, {"FIRST", ""}, {"NAME", styleBold}, {"FIELD", styleDim}, {"HERE", ""}, {"THERE", styleCyan}}
+
+/**
+ * handleDiffAction compares the directory with another copy
+ *
+ * @param {*annuaire.Directory} dir - Current directory
+ * @param {string} file - Other copy, in any import format, or "-" for standard input
+ * @param {string} dialect - Column/attribute preset for CSV and LDIF sources
+ *
+ * Lists the contacts only in the other copy, only here, and the ones that
+ * differ field by field. Like diff(1), the exit status is 1 when the copies
+ * differ, so scripts can test it
+ */
+func handleDiffAction(dir *annuaire.Directory, file, dialect string) {
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "Error: file path required for diff (-file)")
+		os.Exit(exitInvalid)
+	}
+	other, _, source := readSource(file, dialect)
+
+	diff := dir.Diff(other)
+	if diff.IsZero() {
+		notify("No difference with %s\n", source)
+		return
+	}
+	fmt.Printf("Differences with %s: %s\n", source, diff)
+	printDiff(diff, source)
+	os.Exit(exitDifferent)
+}
+
+// printDiff writes the tables of a diff against the given source
+func printDiff(diff annuaire.DirectoryDiff, source string) {
+	if len(diff.Added) > 0 {
+		fmt.Printf("\nOnly in %s:\n", source)
+		printContacts(diff.Added, nil)
+	}
+	if len(diff.Removed) > 0 {
+		fmt.Println("\nOnly here:")
+		printContacts(diff.Removed, nil)
+	}
+	if len(diff.Changed) > 0 {
+		fmt.Println("\nChanged:")
+		var rows [][]string
+		for _, change := range diff.Changed {
+			for _, field := range change.Fields {
+				rows = append(rows, []string{fmt.Sprintf("#%d", change.Before.ID), change.Before.First, change.Before.Name,
+					field, contactField(change.Before, field), contactField(change.After, field)})
+			}
+		}
+		printTable(os.Stdout, changeColumns, rows)
+	}
+}
+
+// contactField returns a field of a contact by its JSON name, for diff tables
+func contactField(c annuaire.Contact, field string) string {
+	switch field {
+	case "name":
+		return c.Name
+	case "first":
+		return c.First
+	case "phone":
+		return c.Phone
+	case "birthday":
+		return c.Birthday
+	case "address":
+		return c.Address.String()
+	case "code":
+		return c.Code
+	case "verify_by":
+		return c.VerifyBy
+	case "photo":
+		if c.Photo != "" {
+			return "(photo)"
+		}
+		return ""
+	case "tags":
+		return strings.Join(c.Tags, " ")
+	case "custom":
+		return annuaire.FormatCustomFields(c.Custom)
+	}
+	return ""
+}
+
+/**
+ * handleMergeAction brings the contacts of another copy into the directory
+ *
+ * @param {*annuaire.Directory} dir - Directory to merge into
+ * @param {string} file - Other copy, in any import format, or "-" for standard input
+ * @param {string} dialect - Column/attribute preset for CSV and LDIF sources
+ * @param {string} conflict - Version kept for contacts changed in both: "ours" (default), "theirs" or "newest"
+ *
+ * New contacts are added, contacts missing from the other copy are kept, and
+ * each conflict is listed with the version that won
+ */
+func handleMergeAction(dir *annuaire.Directory, file, dialect, conflict string) {
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "Error: file path required for merge (-file)")
+		os.Exit(exitInvalid)
+	}
+	policy, err := annuaire.ParseConflictPolicy(conflict)
+	if err != nil {
+		exitWithError(err)
+	}
+	other, _, source := readSource(file, dialect)
+
+	diff := dir.Diff(other)
+	result := dir.Merge(other, policy, source)
+	saveContacts(dir)
+
+	notify("Merged %s (%s)\n", source, result)
+	if len(diff.Changed) > 0 {
+		kept := "the values from here"
+		switch policy {
+		case annuaire.KeepTheirs:
+			kept = "the values from " + source
+		case annuaire.KeepNewest:
+			kept = "the values changed last"
+		}
+		fmt.Printf("%d conflicts resolved with -conflict=%s, keeping %s:\n", len(diff.Changed), policy, kept)
+		printDiff(annuaire.DirectoryDiff{Changed: diff.Changed}, source)
+	}
+}
+
+/**
+ * handleSyncAction exchanges changes with another instance running -server
+ *
+ * @param {*annuaire.Directory} dir - Local directory
+ * @param {string} remote - Address of the other instance, e.g.
+ *   http://host:8080, with user:password@ before the host when it has an
+ *   administrator
+ *
+ * Contacts changed on both sides keep the version changed last; deletions
+ * are not carried over. The local file is saved even when the push fails,
+ * so the pulled changes are not lost
+ */
+func handleSyncAction(dir *annuaire.Directory, remote string) {
+	if remote == "" {
+		fmt.Fprintln(os.Stderr, "Error: address of the other instance required for sync (-remote)")
+		os.Exit(exitInvalid)
+	}
+	host := remote
+	if u, err := url.Parse(remote); err == nil {
+		host = u.Redacted()
+	}
+
+	report, err := server.Sync(context.Background(), dir, remote)
+	if report.Pulled != (annuaire.ImportResult{}) {
+		saveContacts(dir)
+	}
+	if err != nil {
+		exitWithError(fmt.Errorf("sync with %s: %w", host, err))
+	}
+	notify("Pulled from %s: %s\n", host, report.Pulled)
+	notify("Pushed to %s: %s\n", host, report.Pushed)
+}
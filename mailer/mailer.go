@@ -0,0 +1,309 @@
+// Package mailer emails the modifications of the address book to configured
+// addresses, with the standard library only (net/smtp)
+//
+// A Notifier receives the changes of a directory through its hooks (see
+// annuaire.Directory.RegisterHook) and never blocks it: changes are queued,
+// then sent together a little after the first one, or once a day in a digest
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"tp1/annuaire"
+	"tp1/batch"
+)
+
+// DefaultAddr is the SMTP server used when none is configured, usually a
+// local relay
+const DefaultAddr = "localhost:25"
+
+// DefaultDelay is how long a change waits for the next ones, so that a
+// burst of changes (a clear, an import) goes in one mail
+const DefaultDelay = time.Minute
+
+// DefaultEvents are the events mailed when none are configured: contacts
+// appearing or disappearing, clears included, not every correction
+var DefaultEvents = []annuaire.Event{annuaire.OnAdd, annuaire.OnDelete}
+
+// maxQueue bounds the changes waiting for a mail; beyond it, while the SMTP
+// server is down or a digest is far away, changes are only counted
+const maxQueue = 1000
+
+// sendTimeout bounds one delivery, as net/smtp has no deadline of its own
+const sendTimeout = time.Minute
+
+// Config describes where and when notifications are sent
+type Config struct {
+	Addr     string           // SMTP server, "host:port" (empty: DefaultAddr)
+	Username string           // PLAIN authentication user (empty: no authentication)
+	Password string           // Password of Username
+	From     string           // Sender address, e.g. "tp1@example.com"
+	To       []string         // Recipient addresses, at least one
+	Events   []annuaire.Event // Events mailed, e.g. annuaire.OnAdd (nil: DefaultEvents)
+	Digest   string           // Local time "HH:MM" of a daily digest (empty: a mail per burst of changes)
+	Delay    time.Duration    // Wait after a change for the next ones, without Digest (zero: DefaultDelay)
+}
+
+// Change is one modification waiting for a mail
+type Change struct {
+	Profile string           // Address book changed, empty when only one is served
+	Event   annuaire.Event   // What happened to the contact
+	Contact annuaire.Contact // Contact concerned, as given to the hook
+	Time    time.Time        // When the change was notified
+}
+
+// sendFunc delivers a message, with the signature of smtp.SendMail
+type sendFunc func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+
+// Notifier mails the changes it is given in the background
+type Notifier struct {
+	addr   string
+	auth   smtp.Auth
+	from   string
+	to     []string
+	events []annuaire.Event
+	digest time.Time // Time of day of the digest (zero: no digest)
+	delay  time.Duration
+	send   sendFunc
+	queue  *batch.Queue[Change] // Changes waiting for the next mail
+}
+
+/**
+ * New starts a notifier
+ *
+ * @param {Config} cfg - SMTP server, addresses and schedule
+ * @return {*Notifier} Running notifier; pass its Notify method to the
+ *   directories, and call Shutdown before exiting so that the last changes
+ *   are sent
+ * @return {error} Returns an error for an invalid address, event or digest
+ *   time
+ */
+func New(cfg Config) (*Notifier, error) {
+	return newNotifier(cfg, smtp.SendMail)
+}
+
+// newNotifier is New with another delivery, for tests
+func newNotifier(cfg Config, send sendFunc) (*Notifier, error) {
+	n := &Notifier{
+		addr:   cfg.Addr,
+		from:   cfg.From,
+		events: cfg.Events,
+		delay:  cfg.Delay,
+		send:   send,
+	}
+	if n.addr == "" {
+		n.addr = DefaultAddr
+	}
+	host, _, err := net.SplitHostPort(n.addr)
+	if err != nil {
+		return nil, fmt.Errorf("SMTP server: invalid address %q (expected host:port)", n.addr)
+	}
+	if cfg.Username != "" {
+		n.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	if _, err := mail.ParseAddress(n.from); err != nil {
+		return nil, fmt.Errorf("notification sender: invalid address %q", n.from)
+	}
+	if len(cfg.To) == 0 {
+		return nil, errors.New("notification recipients: no address given")
+	}
+	for _, to := range cfg.To {
+		address, err := mail.ParseAddress(to)
+		if err != nil {
+			return nil, fmt.Errorf("notification recipient: invalid address %q", to)
+		}
+		n.to = append(n.to, address.Address)
+	}
+	if n.events == nil {
+		n.events = DefaultEvents
+	}
+	for _, event := range n.events {
+		if !slices.Contains(annuaire.Events, event) {
+			return nil, fmt.Errorf("notified events: unknown event %q (add, update, delete, import)", event)
+		}
+	}
+	if cfg.Digest != "" {
+		if n.digest, err = time.Parse("15:04", cfg.Digest); err != nil {
+			return nil, fmt.Errorf("digest time: invalid time %q (expected HH:MM)", cfg.Digest)
+		}
+	}
+	if n.delay <= 0 {
+		n.delay = DefaultDelay
+	}
+
+	n.queue = batch.New(batch.Config[Change]{
+		Send: n.mail,
+		Wait: n.wait,
+		Failed: func(err error) {
+			slog.Warn("cannot send the change notification", "server", n.addr, "error", err)
+		},
+		Max: maxQueue,
+	})
+	return n, nil
+}
+
+/**
+ * FromEnv creates the notifier configured by the environment
+ *
+ * @return {*Notifier} Running notifier, nil when TP1_NOTIFY_TO is not set
+ * @return {error} Returns an error for an invalid variable
+ *
+ * Variables read:
+ *   TP1_NOTIFY_TO     - comma-separated recipient addresses
+ *   TP1_NOTIFY_FROM   - sender address (default "tp1@" and the host name)
+ *   TP1_NOTIFY_EVENTS - comma-separated events mailed (default add,delete)
+ *   TP1_NOTIFY_DIGEST - "HH:MM" to send one daily digest instead of a mail per burst
+ *   TP1_SMTP_ADDR     - SMTP server host:port (default localhost:25)
+ *   TP1_SMTP_USER     - PLAIN authentication user, over STARTTLS unless the server is local
+ *   TP1_SMTP_PASSWORD - password of TP1_SMTP_USER
+ */
+func FromEnv() (*Notifier, error) {
+	to := splitList(os.Getenv("TP1_NOTIFY_TO"))
+	if len(to) == 0 {
+		return nil, nil
+	}
+	var events []annuaire.Event
+	for _, event := range splitList(os.Getenv("TP1_NOTIFY_EVENTS")) {
+		events = append(events, annuaire.Event(event))
+	}
+	cfg := Config{
+		Addr:     os.Getenv("TP1_SMTP_ADDR"),
+		Username: os.Getenv("TP1_SMTP_USER"),
+		Password: os.Getenv("TP1_SMTP_PASSWORD"),
+		From:     os.Getenv("TP1_NOTIFY_FROM"),
+		To:       to,
+		Events:   events,
+		Digest:   os.Getenv("TP1_NOTIFY_DIGEST"),
+	}
+	if cfg.From == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("TP1_NOTIFY_FROM: not set and no host name: %w", err)
+		}
+		cfg.From = "tp1@" + host
+	}
+	return New(cfg)
+}
+
+// splitList splits a comma-separated variable, nil when it is empty
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+/**
+ * Notify queues a change of an address book, if its event is mailed
+ *
+ * @param {string} profile - Address book changed, empty when only one is served
+ * @param {annuaire.Event} event - What happened, as registered with the hook
+ * @param {annuaire.Contact} contact - Contact given to the hook
+ *
+ * It only takes a lock and never waits for the SMTP server, so it can run
+ * as a directory hook
+ */
+func (n *Notifier) Notify(profile string, event annuaire.Event, contact annuaire.Contact) {
+	if !slices.Contains(n.events, event) {
+		return
+	}
+	n.queue.Add(Change{Profile: profile, Event: event, Contact: contact, Time: time.Now()})
+}
+
+// wait returns how long the first change queued at now waits for its mail:
+// the delay, or the time left until the next digest
+func (n *Notifier) wait(now time.Time) time.Duration {
+	if n.digest.IsZero() {
+		return n.delay
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), n.digest.Hour(), n.digest.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.Sub(now)
+}
+
+/**
+ * Flush mails every queued change now
+ *
+ * @param {context.Context} ctx - Context bounding the delivery
+ * @return {error} Delivery error; the changes of a failed mail are dropped,
+ *   as retrying could flood the recipients once the server is back
+ */
+func (n *Notifier) Flush(ctx context.Context) error {
+	return n.queue.Flush(ctx)
+}
+
+/**
+ * Shutdown stops the notifier after mailing the last changes
+ *
+ * @param {context.Context} ctx - Context bounding the final delivery
+ * @return {error} Error of the final delivery
+ */
+func (n *Notifier) Shutdown(ctx context.Context) error {
+	return n.queue.Shutdown(ctx)
+}
+
+// mail sends one mail listing changes, within sendTimeout
+func (n *Notifier) mail(ctx context.Context, changes []Change, dropped int) error {
+	msg := n.message(changes, dropped, time.Now())
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	sent := make(chan error, 1)
+	go func() {
+		sent <- n.send(n.addr, n.auth, n.from, n.to, msg)
+	}()
+	select {
+	case err := <-sent:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message formats the mail listing changes, oldest first, in local time
+func (n *Notifier) message(changes []Change, dropped int, now time.Time) []byte {
+	count := fmt.Sprintf("%d changes", len(changes)+dropped)
+	if len(changes)+dropped == 1 {
+		count = "1 change"
+	}
+	subject := "Address book: " + count
+	if !n.digest.IsZero() {
+		subject = fmt.Sprintf("Address book digest of %s: %s", now.Format(time.DateOnly), count)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	msg.WriteString("\r\n")
+	for _, change := range changes {
+		line := change.Time.Local().Format(time.DateTime) + "  "
+		if change.Profile != "" {
+			line += "[" + change.Profile + "] "
+		}
+		fmt.Fprintf(&msg, "%s%s %s %s\r\n", line, change.Event, change.Contact.First, change.Contact.Name)
+	}
+	if dropped > 0 {
+		fmt.Fprintf(&msg, "\r\n%d more changes are not listed, see the history\r\n", dropped)
+	}
+	return msg.Bytes()
+}
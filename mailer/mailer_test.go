@@ -0,0 +1,95 @@
+package mailer
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+	"tp1/annuaire"
+)
+
+// mailbox records the messages of a notifier instead of sending them
+type mailbox chan string
+
+func (m mailbox) send(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	m <- string(msg)
+	return nil
+}
+
+// contact returns a contact with a placeholder phone number
+func contact(first, name string) annuaire.Contact {
+	return annuaire.Contact{Name: name, First: first, Phone: "0123456789"}
+}
+
+// TestNotifyBatch tests that a burst of changes goes in one mail, without the
+// operations left out, and that Shutdown sends what is still waiting
+func TestNotifyBatch(t *testing.T) {
+	box := make(mailbox, 4)
+	n, err := newNotifier(Config{From: "tp1@example.com", To: []string{"Office <office@example.com>"}, Delay: 20 * time.Millisecond}, box.send)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.Notify("", annuaire.OnAdd, contact("Jean", "Dupont"))
+	n.Notify("", annuaire.OnUpdate, contact("Jean", "Dupont")) // Not in DefaultEvents
+	n.Notify("work", annuaire.OnDelete, contact("Paul", "Martin"))
+
+	var msg string
+	select {
+	case msg = <-box:
+	case <-time.After(5 * time.Second):
+		t.Fatal("No mail sent after the delay")
+	}
+	for _, want := range []string{"To: office@example.com\r\n", "Subject: Address book: 2 changes\r\n", "  add Jean Dupont\r\n", "  [work] delete Paul Martin\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Mail lacks %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "update") {
+		t.Errorf("Updates are not notified by default:\n%s", msg)
+	}
+
+	// Shutdown does not wait for the delay
+	n.delay = time.Hour
+	n.Notify("", annuaire.OnAdd, contact("Marie", "Durand"))
+	if err := n.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-box; !strings.Contains(msg, "add Marie Durand") {
+		t.Errorf("Shutdown did not send the last change:\n%s", msg)
+	}
+}
+
+// TestNotifyConfig tests the validation of the configuration and the digest schedule
+func TestNotifyConfig(t *testing.T) {
+	valid := Config{From: "tp1@example.com", To: []string{"office@example.com"}}
+	for name, change := range map[string]func(*Config){
+		"no recipient":  func(c *Config) { c.To = nil },
+		"bad sender":    func(c *Config) { c.From = "tp1" },
+		"bad server":    func(c *Config) { c.Addr = "smtp.example.com" },
+		"unknown event": func(c *Config) { c.Events = []annuaire.Event{"search"} },
+		"bad digest":    func(c *Config) { c.Digest = "25:00" },
+	} {
+		cfg := valid
+		change(&cfg)
+		if _, err := New(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	cfg := valid
+	cfg.Digest = "18:00"
+	n, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Shutdown(context.Background())
+	for now, want := range map[string]time.Duration{"17:00": time.Hour, "18:00": 24 * time.Hour, "19:30": 22*time.Hour + 30*time.Minute} {
+		clock, _ := time.Parse("15:04", now)
+		at := time.Date(2026, 3, 10, clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+		if got := n.wait(at); got != want {
+			t.Errorf("Digest wait at %s = %v, want %v", now, got, want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// plain is set by -plain: output is restricted to ASCII text, without emoji,
+// box drawing or decorative rules, for screen readers and scripts
+var plain bool
+
+// quiet is set by -quiet: only the requested data, warnings and errors are
+// printed, for cron jobs and scripts
+var quiet bool
+
+// plainReplacer spells out the symbols used by library output such as history summaries
+var plainReplacer = strings.NewReplacer("→", "->", "│", "|", "█", "#")
+
+// decorate prefixes a message with an emoji, unless plain output is requested
+func decorate(emoji, text string) string {
+	if plain {
+		return text
+	}
+	return emoji + " " + text
+}
+
+// notify prints a confirmation or a note such as "No contacts found",
+// unless quiet output is requested
+func notify(format string, args ...any) {
+	if !quiet {
+		fmt.Printf(format, args...)
+	}
+}
+
+// printable converts library output to ASCII when plain output is requested
+func printable(text string) string {
+	if plain {
+		return plainReplacer.Replace(text)
+	}
+	return text
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"tp1/annuaire"
+	"tp1/settings"
+)
+
+// pluginPrefix starts the executable name of every CLI plugin: "tp1 crm-push"
+// runs the first "tp1-crm-push" found on PATH, like git and kubectl plugins
+const pluginPrefix = "tp1-"
+
+// pluginAPIVersion is passed to plugins so they can detect contract changes;
+// version 2 wraps the data file in a versioned envelope
+const pluginAPIVersion = "2"
+
+// builtinActions lists the actions handled by main, which plugins cannot
+// override, with the summary shown by shell completion
+var builtinActions = map[string]string{
+	"add":                  "Add a contact",
+	"list":                 "List all contacts with their IDs",
+	"show":                 "Show every field of a contact",
+	"search":               "Search for contacts",
+	"delete":               "Delete a contact",
+	"update":               "Update a contact",
+	"verify":               "Confirm a contact is still accurate",
+	"export":               "Export the contacts to a file",
+	"import":               "Import contacts from a file",
+	"add-batch":            "Add the contacts of a file, reporting each one",
+	"diff":                 "Compare with another copy",
+	"merge":                "Add the contacts of another copy",
+	"sync":                 "Exchange changes with another instance",
+	"pin":                  "Pin a contact to the top of every listing",
+	"unpin":                "Remove a contact from the pinned list",
+	"sensitive":            "Show or set the fields masked in shares",
+	"tag":                  "Add or remove a tag on matching contacts",
+	"birthdays":            "List the upcoming birthdays",
+	"recent":               "List the contacts added or changed last",
+	"count":                "Print the number of contacts",
+	"exists":               "Tell by the exit status whether a contact exists",
+	"tui":                  "Browse, search and edit the contacts full screen",
+	"history":              "Show the recorded modifications",
+	"usage":                "Chart your own usage",
+	"stats":                "Show directory statistics",
+	"plugins":              "List the tp1-<command> plugins",
+	"profiles":             "List the address books",
+	"setup":                "First-run configuration",
+	"viewer":               "Add or list read-only web accounts",
+	"revoke":               "Remove a read-only web account",
+	"completion":           "Print a shell completion script",
+	completeContactsAction: "", // Hidden: called by the completion scripts
+}
+
+/**
+ * findPlugin looks for the executable implementing a subcommand
+ *
+ * @param {string} name - Subcommand, e.g. "crm-push"
+ * @return {string} Path of "tp1-crm-push" on PATH
+ * @return {bool} False if no such executable exists or the name is not a plain word
+ */
+func findPlugin(name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, "-") {
+		return "", false
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	return path, err == nil
+}
+
+/**
+ * runPlugin executes a plugin and exits with its status
+ *
+ * @param {string} path - Plugin executable, from findPlugin
+ * @param {[]string} args - Arguments following the subcommand, passed unchanged
+ * @param {string} dataFile - Contacts file selected by -data or TP1_DATA_FILE
+ *
+ * Contract (version 2):
+ * - Standard input, output and error are the terminal's
+ * - TP1_DATA_FILE, TP1_SETTINGS_FILE and TP1_HISTORY_FILE hold absolute paths;
+ *   the data file is the versioned JSON document written by "tp1 export",
+ *   {"version": 2, "exported_at": ..., "contacts": [...]} (see annuaire.Envelope)
+ * - TP1_PLUGIN_API is "2"
+ * - TP1_PLAIN is "1" when -plain was given, asking for ASCII-only output
+ * - The exit status is returned to the caller as is
+ */
+func runPlugin(path string, args []string, dataFile string) {
+	dataFile, err := filepath.Abs(dataFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitIO)
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(),
+		dataFileEnv+"="+dataFile,
+		"TP1_SETTINGS_FILE="+settings.PathFor(dataFile),
+		"TP1_HISTORY_FILE="+annuaire.HistoryPathFor(dataFile),
+		"TP1_PLUGIN_API="+pluginAPIVersion,
+	)
+	if plain {
+		cmd.Env = append(cmd.Env, "TP1_PLAIN=1")
+	}
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running plugin %s: %v\n", path, err)
+		os.Exit(exitIO)
+	}
+	os.Exit(0)
+}
+
+/**
+ * handlePluginsAction lists the plugins found on PATH
+ *
+ * Only the first executable of each name is listed, since it is the one
+ * that runs; plugins named like a built-in action are flagged as ignored
+ */
+func handlePluginsAction() {
+	seen := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), pluginPrefix)
+			if !ok || name == "" || seen[name] != "" {
+				continue
+			}
+			if path, err := exec.LookPath(filepath.Join(dir, entry.Name())); err == nil {
+				seen[name] = path
+			}
+		}
+	}
+
+	if len(seen) == 0 {
+		notify("No plugins found (executables named %s<command> on PATH)\n", pluginPrefix)
+		return
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("Plugins (%d):\n", len(names))
+	for _, name := range names {
+		note := ""
+		if _, builtin := builtinActions[name]; builtin {
+			note = " (ignored: built-in action)"
+		}
+		fmt.Printf("- %s: %s%s\n", name, seen[name], note)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"tp1/annuaire"
+	"tp1/server"
+	"tp1/settings"
+)
+
+// defaultProfile names the address book stored in the data file itself
+const defaultProfile = "default"
+
+// profilesDir holds one sub-directory per additional profile, next to the data file
+const profilesDir = "profiles"
+
+// profileNamePattern keeps profile names usable as directory names and in URLs
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+/**
+ * profileDataFile returns the contacts file of a profile
+ *
+ * @param {string} base - Data file of the default profile (see resolveDataFile)
+ * @param {string} profile - Profile name, e.g. "work" (empty or "default" for the main one)
+ * @return {string} e.g. "data/profiles/work/contacts.json" for base "data/contacts.json"
+ * @return {error} Returns an error for names that are not lowercase words
+ *
+ * Each profile has its own directory, so its settings and history stay
+ * separate from the other profiles
+ */
+func profileDataFile(base, profile string) (string, error) {
+	if profile == "" || profile == defaultProfile {
+		return base, nil
+	}
+	if !profileNamePattern.MatchString(profile) {
+		return "", fmt.Errorf("invalid profile name %q (lowercase letters, digits, - and _)", profile)
+	}
+	return filepath.Join(filepath.Dir(base), profilesDir, profile, filepath.Base(base)), nil
+}
+
+/**
+ * listProfiles returns the profiles found next to a data file
+ *
+ * @param {string} base - Data file of the default profile
+ * @return {[]string} "default" followed by the other profiles, sorted
+ */
+func listProfiles(base string) []string {
+	names := []string{defaultProfile}
+	entries, _ := os.ReadDir(filepath.Join(filepath.Dir(base), profilesDir))
+	var others []string
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != defaultProfile && profileNamePattern.MatchString(entry.Name()) {
+			others = append(others, entry.Name())
+		}
+	}
+	sort.Strings(others)
+	return append(names, others...)
+}
+
+/**
+ * serverProfiles builds the profiles served by the web interface
+ *
+ * @param {string} base - Data file of the default profile
+ * @param {string} selected - Profile shown first (from -profile), created if new
+ * @return {[]server.Profile} Every existing profile, the selected one first
+ */
+func serverProfiles(base, selected string) ([]server.Profile, error) {
+	if selected == "" {
+		selected = defaultProfile
+	}
+	if _, err := profileDataFile(base, selected); err != nil {
+		return nil, err
+	}
+
+	names := []string{selected}
+	for _, name := range listProfiles(base) {
+		if name != selected {
+			names = append(names, name)
+		}
+	}
+
+	profiles := make([]server.Profile, 0, len(names))
+	for _, name := range names {
+		file, _ := profileDataFile(base, name)
+		profiles = append(profiles, server.Profile{
+			Name:         name,
+			DataFile:     file,
+			SettingsFile: settings.PathFor(file),
+			HistoryFile:  annuaire.HistoryPathFor(file),
+		})
+	}
+	return profiles, nil
+}
+
+// handleProfilesAction lists the profiles, marking the one in use
+func handleProfilesAction(base, current string) {
+	if current == "" {
+		current = defaultProfile
+	}
+	for _, name := range listProfiles(base) {
+		marker := "  "
+		if name == current {
+			marker = "* "
+		}
+		file, _ := profileDataFile(base, name)
+		fmt.Printf("%s%s (%s)\n", marker, name, file)
+	}
+}
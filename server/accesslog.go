@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Access log formats accepted by Config.AccessLog
+const (
+	AccessLogCommon = "common" // NCSA Common Log Format followed by the duration in microseconds
+	AccessLogJSON   = "json"   // One JSON object per request
+)
+
+// AccessLogFormats lists the access log formats, for flag help and validation
+var AccessLogFormats = []string{AccessLogCommon, AccessLogJSON}
+
+// accessEntry is one served request, as written to the access log
+type accessEntry struct {
+	Time       time.Time `json:"time"`
+	Remote     string    `json:"remote"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+
+	duration time.Duration // Exact duration, DurationMS being rounded to the microsecond
+}
+
+// statusRecorder captures the status code and body size sent by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+/**
+ * accessLog records every request served by a handler
+ *
+ * @param {http.Handler} next - Handler whose requests are logged
+ * @param {string} format - AccessLogCommon or AccessLogJSON
+ * @param {io.Writer} out - Destination of the log, one line per request
+ * @return {http.Handler} Handler writing one line once each response is sent
+ * @return {error} Returns an error if the format is unknown
+ *
+ * Only the path is logged, not the query string, so that search terms and
+ * shared numbers do not end up in the log
+ */
+func accessLog(next http.Handler, format string, out io.Writer) (http.Handler, error) {
+	var write func(io.Writer, accessEntry) error
+	switch format {
+	case AccessLogCommon:
+		write = writeCommonEntry
+	case AccessLogJSON:
+		write = writeJSONEntry
+	default:
+		return nil, fmt.Errorf("unknown access log format %q (expected common or json)", format)
+	}
+
+	var mu sync.Mutex // Keeps lines of concurrent requests whole
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+
+		elapsed := time.Since(start)
+		entry := accessEntry{
+			Time:       start,
+			Remote:     remoteHost(r.RemoteAddr),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Proto:      r.Proto,
+			Status:     recorder.status,
+			Bytes:      recorder.bytes,
+			DurationMS: float64(elapsed.Microseconds()) / 1000,
+			duration:   elapsed,
+		}
+		if entry.Status == 0 {
+			// The handler wrote nothing: net/http answers 200 with an empty body
+			entry.Status = http.StatusOK
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// A failing log must not break the response, which is already sent
+		_ = write(out, entry)
+	}), nil
+}
+
+// writeCommonEntry writes an entry in Common Log Format, e.g.
+// 127.0.0.1 - - [16/Oct/2026:12:00:00 +0200] "GET /search HTTP/1.1" 200 5120 412
+func writeCommonEntry(out io.Writer, e accessEntry) error {
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.FormatInt(e.Bytes, 10)
+	}
+	_, err := fmt.Fprintf(out, "%s - - [%s] %q %d %s %d\n",
+		e.Remote, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method+" "+e.Path+" "+e.Proto,
+		e.Status, size, e.duration.Microseconds())
+	return err
+}
+
+// writeJSONEntry writes an entry as a single line of JSON
+func writeJSONEntry(out io.Writer, e accessEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(append(line, '\n'))
+	return err
+}
+
+// remoteHost strips the port from a remote address
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
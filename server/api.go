@@ -0,0 +1,539 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"tp1/annuaire"
+)
+
+// Page sizes of the JSON API
+const (
+	defaultPerPage = 20  // Contacts per page when ?per_page= is omitted
+	maxPerPage     = 100 // Larger ?per_page= values are rejected
+)
+
+// maxAPIBody bounds the JSON contacts sent to the API
+const maxAPIBody = 64 << 10 // 64 KB
+
+// Suggestion counts of GET /api/suggest
+const (
+	defaultSuggestions = 10 // Names returned when ?limit= is omitted
+	maxSuggestions     = 50 // Larger ?limit= values are rejected
+)
+
+// Limits of one POST /api/contacts/batch
+const (
+	maxBatchSize = 1000    // Contacts per request
+	maxBatchBody = 1 << 20 // 1 MB of JSON
+)
+
+// contactPage is the paginated envelope returned by GET /api/contacts
+type contactPage struct {
+	Total     int                `json:"total"`     // Contacts matching ?q=, on every page
+	Page      int                `json:"page"`      // Current page, starting at 1
+	PerPage   int                `json:"per_page"`  // Page size
+	Pages     int                `json:"pages"`     // Number of pages (0 when nothing matches)
+	Truncated bool               `json:"truncated"` // The search hit its limits: total counts only the matches kept
+	Contacts  []annuaire.Contact `json:"contacts"`  // Contacts of the current page
+	Links     pageLinks          `json:"links"`     // Ready-made URLs to navigate between pages
+}
+
+// pageLinks are the navigation URLs of a contactPage, keeping every other parameter
+type pageLinks struct {
+	Self  string `json:"self"`
+	First string `json:"first"`
+	Last  string `json:"last"`
+	Prev  string `json:"prev,omitempty"` // Absent on the first page
+	Next  string `json:"next,omitempty"` // Absent on the last page
+}
+
+/**
+ * handleAPIContacts lists contacts as paginated JSON
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - GET request with optional parameters:
+ *   q        - case-insensitive text contained in the name, first name or phone
+ *   name     - start of the last name, case-insensitive
+ *   first    - start of the first name, case-insensitive
+ *   phone    - start of the phone number, separators ignored
+ *   tag      - tag carried by the contact
+ *   sort     - name (default), phone or created
+ *   order    - asc (default) or desc
+ *   page     - page number, starting at 1
+ *   per_page - page size, 1 to 100 (default 20)
+ *
+ * Every search parameter given must match (see annuaire.SearchQuery).
+ * Sensitive fields are masked as in shares. Invalid parameters are answered
+ * with a 400 problem (see writeAPIError); a page past the end is empty, not
+ * an error
+ *
+ * The search is bounded by the limits of the settings (see
+ * settings.Settings.SearchLimits); when they are hit, "truncated" is true and
+ * the pages only cover the matches kept
+ *
+ * Usage:
+ *   curl 'http://localhost:8080/api/contacts?q=dup&sort=created&order=desc&page=2'
+ *   curl 'http://localhost:8080/api/contacts?name=Dupont&phone=06&tag=work'
+ */
+func (s *Server) handleAPIContacts(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		s.createAPIContact(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	sortKey := query.Get("sort")
+	if sortKey == "" {
+		sortKey = annuaire.SortByName
+	}
+	var descending bool
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		descending = true
+	default:
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid order %q (expected asc or desc)", query.Get("order")))
+		return
+	}
+	page, err := positiveParam(query, "page", 1, 0)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	perPage, err := positiveParam(query, "per_page", defaultPerPage, maxPerPage)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Mask first, so that the search parameters cannot probe hidden values
+	cfg := s.loadSettings()
+	criteria := annuaire.SearchQuery{Name: query.Get("name"), First: query.Get("first"), Phone: query.Get("phone"), Tag: query.Get("tag")}
+	found := s.traced(r).ScanContacts(func(c annuaire.Contact) bool {
+		masked := annuaire.MaskContact(c, cfg.SensitiveFields)
+		return masked.MatchesQuery(query.Get("q")) && criteria.Matches(masked)
+	}, cfg.SearchLimits())
+	matches := found.Contacts
+	for i := range matches {
+		matches[i] = annuaire.MaskContact(matches[i], cfg.SensitiveFields)
+	}
+	if err := annuaire.SortContactsBy(matches, sortKey, descending); err != nil {
+		writeAPIError(w, errorStatus(err), err.Error())
+		return
+	}
+
+	current := annuaire.Paginate(matches, page, perPage)
+	result := contactPage{
+		Total:     current.Total,
+		Page:      current.Number,
+		PerPage:   current.PerPage,
+		Pages:     current.Pages,
+		Truncated: found.Truncated,
+		Contacts:  current.Contacts,
+	}
+
+	link := func(n int) string {
+		values := url.Values{}
+		for key, v := range query {
+			values[key] = v
+		}
+		values.Set("page", strconv.Itoa(n))
+		values.Set("per_page", strconv.Itoa(perPage))
+		return basePathOf(r) + r.URL.Path + "?" + values.Encode()
+	}
+	result.Links = pageLinks{Self: link(page), First: link(1), Last: link(max(result.Pages, 1))}
+	if page > 1 {
+		result.Links.Prev = link(min(page-1, max(result.Pages, 1)))
+	}
+	if page < result.Pages {
+		result.Links.Next = link(page + 1)
+	}
+
+	slog.Debug("api list", "query", query.Get("q"), "total", result.Total, "page", page, "truncated", result.Truncated)
+	s.writeTaggedJSON(w, r, http.StatusOK, result, "")
+}
+
+/**
+ * createAPIContact adds the contact sent as JSON to POST /api/contacts
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - POST request whose body is a Contact; the
+ *   fields set by the server are ignored (see newAPIContact)
+ *
+ * Answers 201 with the stored contact and its Location, 400 when the body is
+ * not a JSON contact, 422 listing every invalid field, and 409 when the name
+ * and phone pair or the speed-dial code is taken
+ */
+func (s *Server) createAPIContact(w http.ResponseWriter, r *http.Request) {
+	var contact annuaire.Contact
+	if !decodeContact(w, r, &contact) {
+		return
+	}
+	cfg := s.loadSettings()
+	contact = newAPIContact(contact, cfg.VerificationPeriod())
+	if err := annuaire.ValidateContact(contact); err != nil {
+		writeAPIProblem(w, err)
+		return
+	}
+	// AddContacts returns the contact as stored, with its ID
+	results, _ := s.traced(r).AddContacts([]annuaire.Contact{contact})
+	if err := results[0].Err; err != nil {
+		writeAPIProblem(w, err)
+		return
+	}
+	contact = results[0].Contact
+
+	sensitive := cfg.SensitiveFields
+	w.Header().Set("Location", r.URL.Path+"/"+strconv.Itoa(contact.ID))
+	s.writeTaggedJSON(w, r, http.StatusCreated, annuaire.MaskContact(contact, sensitive), s.contactETag(contact, sensitive))
+}
+
+// newAPIContact prepares a contact sent to the API for creation, by
+// POST /api/contacts or the batch: the fields owned by the server are
+// reset, and the contact counts as confirmed today, like one added with the
+// form (see handleAdd), so that it is not due for review at once
+func newAPIContact(c annuaire.Contact, verifyMonths int) annuaire.Contact {
+	c.Photo = ""  // Photos go through NormalizePhoto (see handlePhoto)
+	c.Source = "" // Set by imports only
+	c.VerifyBy = annuaire.VerifyByDate(time.Now(), verifyMonths)
+	return c
+}
+
+/**
+ * handleAPIContact reads, changes or deletes one contact by its ID
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - Request on /api/contacts/{id}:
+ *   GET    - the contact, with sensitive fields masked, and its ETag; 304
+ *            when If-None-Match holds that ETag
+ *   PATCH  - JSON object of the fields to change, the others are kept
+ *   DELETE - removes the contact, answered with 204
+ *
+ * Unknown IDs are answered with 404, invalid fields with 422 and conflicts
+ * with 409, each as a problem (see writeAPIProblem). A PATCH or DELETE with
+ * an If-Match header is answered 412 unless it holds the current ETag, so
+ * that a client never overwrites a change it has not seen
+ *
+ * Usage:
+ *   curl -X PATCH -H 'X-CSRF-Token: ...' -H 'If-Match: "3f2a..."' -d '{"code": "1"}' \
+ *        http://localhost:8080/api/contacts/12
+ */
+func (s *Server) handleAPIContact(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("invalid contact ID %q", r.PathValue("id")))
+		return
+	}
+	contact, err := s.dir.ResolveContact("#" + strconv.Itoa(id))
+	if err != nil {
+		writeAPIProblem(w, err)
+		return
+	}
+	sensitive := s.loadSettings().SensitiveFields
+	etag := s.contactETag(contact, sensitive)
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.writeTaggedJSON(w, r, http.StatusOK, annuaire.MaskContact(contact, sensitive), etag)
+	case http.MethodPatch:
+		if !checkIfMatch(w, r, etag) {
+			return
+		}
+		photo := contact.Photo
+		if !decodeContact(w, r, &contact) {
+			return
+		}
+		contact.ID, contact.Photo = id, photo
+		if err := annuaire.ValidateContact(contact); err != nil {
+			writeAPIProblem(w, err)
+			return
+		}
+		if err := s.traced(r).SaveContact(contact); err != nil {
+			writeAPIProblem(w, err)
+			return
+		}
+		if saved, err := s.dir.ResolveContact("#" + strconv.Itoa(id)); err == nil {
+			contact = saved // As stored: normalized and dated
+		}
+		s.writeTaggedJSON(w, r, http.StatusOK, annuaire.MaskContact(contact, sensitive), s.contactETag(contact, sensitive))
+	case http.MethodDelete:
+		if !checkIfMatch(w, r, etag) {
+			return
+		}
+		if err := s.traced(r).DeleteContactByID(id); err != nil {
+			writeAPIProblem(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PATCH, DELETE")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// batchResult reports one contact of POST /api/contacts/batch
+type batchResult struct {
+	Index   int               `json:"index"`            // Position in the request, starting at 1
+	Status  string            `json:"status"`           // added, duplicate or invalid
+	Contact *annuaire.Contact `json:"contact"`          // Stored contact, absent when refused
+	Detail  string            `json:"detail,omitempty"` // Why the contact was refused
+	Errors  []fieldProblem    `json:"errors,omitempty"` // Invalid fields
+}
+
+// batchResponse is the body answering POST /api/contacts/batch
+type batchResponse struct {
+	Added      int           `json:"added"`
+	Duplicates int           `json:"duplicates"`
+	Invalid    int           `json:"invalid"`
+	Results    []batchResult `json:"results"` // One per contact, in request order
+}
+
+/**
+ * handleAPIBatch adds many contacts sent as a JSON array
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - POST request whose body is an array of at most
+ *   maxBatchSize contacts; the fields set by the server are ignored (see newAPIContact)
+ *
+ * The valid contacts are added even when others are refused (see
+ * annuaire.Directory.AddContacts), so the answer is 200 with one result per
+ * contact; only a body that is not an array of contacts is refused as a whole
+ *
+ * Usage:
+ *   curl -H 'X-CSRF-Token: ...' -d '[{"name": "Dupont", "first": "Jean", "phone": "0123456789"}]' \
+ *        http://localhost:8080/api/contacts/batch
+ */
+func (s *Server) handleAPIBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var contacts []annuaire.Contact
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&contacts); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON array of contacts: %v", err))
+		return
+	}
+	if len(contacts) > maxBatchSize {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("too many contacts: %d (at most %d per batch)", len(contacts), maxBatchSize))
+		return
+	}
+	cfg := s.loadSettings()
+	for i := range contacts {
+		contacts[i] = newAPIContact(contacts[i], cfg.VerificationPeriod())
+	}
+
+	results, _ := s.traced(r).AddContacts(contacts)
+	sensitive := cfg.SensitiveFields
+	response := batchResponse{
+		Added:      annuaire.CountAdds(results, annuaire.AddAdded),
+		Duplicates: annuaire.CountAdds(results, annuaire.AddDuplicate),
+		Invalid:    annuaire.CountAdds(results, annuaire.AddInvalid),
+		Results:    make([]batchResult, len(results)),
+	}
+	for i, result := range results {
+		response.Results[i] = batchResult{Index: i + 1, Status: result.Status}
+		if result.Err != nil {
+			response.Results[i].Detail, response.Results[i].Errors = describeError(result.Err)
+			continue
+		}
+		masked := annuaire.MaskContact(result.Contact, sensitive)
+		response.Results[i].Contact = &masked
+	}
+	slog.Debug("api batch", "added", response.Added, "duplicates", response.Duplicates, "invalid", response.Invalid)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// tagRequest is the body of POST /api/contacts/tags
+type tagRequest struct {
+	Filter string `json:"filter"` // Text contained in the name, first name or phone; empty matches all
+	Tag    string `json:"tag"`    // Tag the contacts must already carry; empty matches all
+	Add    string `json:"add"`    // Tag to add; exactly one of add and remove is required
+	Remove string `json:"remove"` // Tag to remove
+}
+
+/**
+ * handleAPITags adds or removes a tag on every contact of a search
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - POST request whose body is a tagRequest
+ *
+ * The answer counts the contacts matched and those actually modified (see
+ * annuaire.Directory.TagContacts); an invalid tag is answered with 422 and
+ * nothing changes. As for the listing, the filter applies to the masked
+ * contacts, so that it cannot probe hidden values
+ *
+ * Usage:
+ *   curl -H 'X-CSRF-Token: ...' -d '{"filter": "ACME", "add": "client"}' \
+ *        http://localhost:8080/api/contacts/tags
+ */
+func (s *Server) handleAPITags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var request tagRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON tag change: %v", err))
+		return
+	}
+	if (request.Add == "") == (request.Remove == "") {
+		writeAPIError(w, http.StatusBadRequest, "either add or remove is required")
+		return
+	}
+
+	sensitive := s.loadSettings().SensitiveFields
+	match := annuaire.ExportMatching(request.Filter, request.Tag)
+	change, err := s.traced(r).TagContacts(func(c annuaire.Contact) bool {
+		return match(annuaire.MaskContact(c, sensitive))
+	}, request.Add+request.Remove, request.Add != "")
+	if err != nil {
+		writeAPIProblem(w, err)
+		return
+	}
+	slog.Debug("api tags", "matched", change.Matched, "modified", change.Modified)
+	writeJSON(w, http.StatusOK, change)
+}
+
+// decodeContact reads a JSON contact body over the fields already in contact,
+// answering 400 and returning false when the body is not one
+func decodeContact(w http.ResponseWriter, r *http.Request, contact *annuaire.Contact) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(contact); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON contact: %v", err))
+		return false
+	}
+	return true
+}
+
+// suggestions is the body answering GET /api/suggest
+type suggestions struct {
+	Names []string `json:"names"` // Last and first names starting with ?q=, sorted
+}
+
+/**
+ * handleAPISuggest completes the name typed in the search box
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - GET request with q (start of a last or first
+ *   name, case-insensitive) and optional limit (1 to 50, default 10)
+ *
+ * An empty q suggests nothing. When last or first names are sensitive fields,
+ * nothing is suggested either: the completions would reveal the masked names
+ *
+ * Usage:
+ *   curl 'http://localhost:8080/api/suggest?q=du'
+ */
+func (s *Server) handleAPISuggest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	query := r.URL.Query()
+	limit, err := positiveParam(query, "limit", defaultSuggestions, maxSuggestions)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := suggestions{Names: []string{}}
+	sensitive := s.loadSettings().SensitiveFields
+	if prefix := strings.TrimSpace(query.Get("q")); prefix != "" && !slices.Contains(sensitive, "name") && !slices.Contains(sensitive, "first") {
+		result.Names = s.dir.SuggestNames(prefix, limit)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+/**
+ * handleAPIStats serves the summary of the stats command as JSON
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - GET request
+ *
+ * The figures are those of the /stats page (see Server.stats). Initials and
+ * phone prefixes are what masked values show anyway (see annuaire.MaskValue),
+ * so they are served whatever the sensitive fields
+ */
+func (s *Server) handleAPIStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.stats())
+}
+
+// positiveParam reads an optional integer query parameter of at least 1,
+// and at most limit when limit is not 0
+func positiveParam(query url.Values, name string, fallback, limit int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || (limit > 0 && n > limit) {
+		if limit > 0 {
+			return 0, fmt.Errorf("invalid %s %q (expected 1 to %d)", name, raw, limit)
+		}
+		return 0, fmt.Errorf("invalid %s %q (expected a positive number)", name, raw)
+	}
+	return n, nil
+}
+
+// writeJSON sends a value as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	encodeJSON(w, "application/json", status, value)
+}
+
+// encodeJSON sends a value as indented JSON with the given media type
+func encodeJSON(w http.ResponseWriter, contentType string, status int, value any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		slog.Warn("api write failed", "error", err)
+	}
+}
+
+/**
+ * errorStatus maps a directory error to the HTTP status answering it
+ *
+ * @param {error} err - Error returned by the annuaire package
+ * @return {int} 404 for ErrContactNotFound, 409 for ErrDuplicateContact and
+ *   ErrCodeInUse, 400 for ErrInvalidInput, 500 for anything else (storage...);
+ *   the JSON API answers invalid input with 422 instead (see writeAPIProblem)
+ */
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, annuaire.ErrContactNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, annuaire.ErrDuplicateContact), errors.Is(err, annuaire.ErrCodeInUse):
+		return http.StatusConflict
+	case errors.Is(err, annuaire.ErrInvalidInput):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
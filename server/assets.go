@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+)
+
+// embeddedAssets holds the page templates and the static files compiled into
+// the binary, so the server needs nothing next to it
+//
+//go:embed templates static
+var embeddedAssets embed.FS
+
+// defaultTemplates are the embedded templates, parsed once at startup
+var defaultTemplates = template.Must(parseTemplates(embeddedAssets, ""))
+
+// overlayFS reads files from a customization directory first, falling back
+// to the embedded ones for the files it does not override
+type overlayFS struct {
+	custom fs.FS
+	base   fs.FS
+}
+
+// Open opens the custom file if it exists, the base one otherwise
+func (o overlayFS) Open(name string) (fs.File, error) {
+	file, err := o.custom.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+	return o.base.Open(name)
+}
+
+// assetsFS returns the embedded assets, overridden by the files of dir if set
+func assetsFS(dir string) fs.FS {
+	if dir == "" {
+		return embeddedAssets
+	}
+	return overlayFS{custom: os.DirFS(dir), base: embeddedAssets}
+}
+
+/**
+ * parseTemplates parses every page template into one set
+ *
+ * @param {fs.FS} assets - Files laid out as templates/*.html and static/*
+ * @param {string} basePath - Prefix the link function adds to local paths,
+ *   e.g. "/contacts" (see WithBasePath; empty: served from the root)
+ * @return {*template.Template} Set whose templates are named after their
+ *   file, e.g. "home.html", with templateFuncs available in all of them
+ * @return {error} Returns an error naming the file that does not parse
+ *
+ * The list of pages comes from the embedded files, so a customization
+ * directory only needs the templates it changes
+ */
+func parseTemplates(assets fs.FS, basePath string) (*template.Template, error) {
+	names, err := fs.Glob(embeddedAssets, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	set := template.New("").Funcs(templateFuncs).Funcs(template.FuncMap{
+		"link": func(path string) string { return localLink(basePath, path) },
+	})
+	for _, name := range names {
+		content, err := fs.ReadFile(assets, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := set.New(path.Base(name)).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		}
+	}
+	return set, nil
+}
+
+/**
+ * loadTemplates reads the assets of a customization directory and parses its templates
+ *
+ * @param {string} dir - Directory overriding the embedded files (empty: embedded only)
+ * @param {string} basePath - Prefix of the local links of the pages (see parseTemplates)
+ * @return {fs.FS} Assets to serve /static/ from
+ * @return {*template.Template} Parsed page templates
+ * @return {error} Returns an error naming the template that does not parse
+ */
+func loadTemplates(dir, basePath string) (fs.FS, *template.Template, error) {
+	if dir == "" && basePath == "" {
+		return embeddedAssets, defaultTemplates, nil
+	}
+	if info, err := os.Stat(dir); dir != "" && (err != nil || !info.IsDir()) {
+		return nil, nil, fmt.Errorf("templates directory %s not found", dir)
+	}
+	assets := assetsFS(dir)
+	templates, err := parseTemplates(assets, basePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return assets, templates, nil
+}
+
+/**
+ * WithTemplatesDir customizes the pages with the files of a directory
+ *
+ * @param {string} dir - Directory laid out like the embedded assets:
+ *   templates/home.html, static/style.css...; missing files keep the default
+ * @return {Option} Option to pass to NewServer
+ *
+ * Since an Option cannot fail, a template that does not parse is logged and
+ * the embedded pages are used instead; Serve checks the directory at startup
+ * and refuses to start instead
+ */
+func WithTemplatesDir(dir string) Option {
+	return func(s *Server) {
+		assets, templates, err := loadTemplates(dir, "")
+		if err != nil {
+			slog.Error("ignoring custom templates", "dir", dir, "error", err)
+			return
+		}
+		s.assets, s.templates = assets, templates
+	}
+}
+
+// withTemplates shares assets and templates already parsed by Serve between
+// the servers of every profile
+func withTemplates(assets fs.FS, templates *template.Template) Option {
+	return func(s *Server) {
+		s.assets, s.templates = assets, templates
+	}
+}
+
+/**
+ * renderTemplate executes a page template and sends it
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*template.Template} templates - Page templates (see parseTemplates)
+ * @param {string} name - Template file name, e.g. "home.html"
+ * @param {int} status - HTTP status of the page
+ * @param {any} data - Data of the template
+ *
+ * The page is rendered in memory first, so that an execution error answers
+ * a clean 500 instead of a truncated page with a 200 status
+ */
+func renderTemplate(w http.ResponseWriter, templates *template.Template, name string, status int, data any) {
+	var page bytes.Buffer
+	if err := templates.ExecuteTemplate(&page, name, data); err != nil {
+		slog.Error("template execution failed", "template", name, "error", err)
+		http.Error(w, "Template execution error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if _, err := page.WriteTo(w); err != nil {
+		slog.Debug("page write failed", "template", name, "error", err)
+	}
+}
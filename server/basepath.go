@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// basePathKey is the context key of the path prefix a request was served under
+type basePathKey struct{}
+
+/**
+ * WithBasePath serves the web interface under a path prefix instead of the root
+ *
+ * @param {string} prefix - Path such as "/contacts" ("contacts/" is read the
+ *   same way; "" and "/" serve from the root)
+ * @return {Option} Option to pass to NewServer
+ *
+ * The server strips the prefix itself, and adds it to the links of its pages
+ * and to its redirects, so it can be mounted as is:
+ *
+ *   mux.Handle("/contacts/", server.NewServer(dir, server.WithBasePath("/contacts")))
+ */
+func WithBasePath(prefix string) Option {
+	return func(s *Server) {
+		s.basePath = cleanBasePath(prefix)
+	}
+}
+
+// cleanBasePath normalizes a base path to "/name", or "" for the root
+func cleanBasePath(prefix string) string {
+	if prefix = strings.Trim(prefix, "/"); prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// basePathOf returns the prefix the request was served under ("" at the root),
+// for the links written outside of templates, e.g. in JSON answers
+func basePathOf(r *http.Request) string {
+	prefix, _ := r.Context().Value(basePathKey{}).(string)
+	return prefix
+}
+
+// localLink prefixes a local path with the base path; fragments ("#a"),
+// relative and absolute URLs are returned unchanged
+func localLink(prefix, path string) string {
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") {
+		return prefix + path
+	}
+	return path
+}
+
+/**
+ * mountAt serves a handler written for the root under a path prefix
+ *
+ * @param {http.Handler} next - Handler whose routes and redirects start at "/"
+ * @param {string} prefix - Clean base path (see cleanBasePath; "" returns next)
+ * @return {http.Handler} Handler that strips the prefix from requests, answers
+ *   404 outside of it, and adds it to the Location of redirects
+ *
+ * The prefix alone ("/contacts") is redirected to the home page ("/contacts/")
+ */
+func mountAt(next http.Handler, prefix string) http.Handler {
+	if prefix == "" {
+		return next
+	}
+	stripped := http.StripPrefix(prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/") {
+			// A sibling path such as "/contactsfoo"
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(&prefixedWriter{ResponseWriter: w, prefix: prefix}, r.WithContext(context.WithValue(r.Context(), basePathKey{}, prefix)))
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+// prefixedWriter adds the base path to the local redirects of a response
+type prefixedWriter struct {
+	http.ResponseWriter
+	prefix string
+}
+
+// WriteHeader rewrites a Location such as "/login" to "/contacts/login"
+func (w *prefixedWriter) WriteHeader(status int) {
+	if location := w.Header().Get("Location"); location != "" {
+		w.Header().Set("Location", localLink(w.prefix, location))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *prefixedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// prefixTemplates parses the templates of the assets again with link adding
+// the base path, keeping the current ones if that fails (they parsed once already)
+func prefixTemplates(s *Server) {
+	templates, err := parseTemplates(s.assets, s.basePath)
+	if err != nil {
+		slog.Error("cannot prefix the links of the templates", "base_path", s.basePath, "error", err)
+		return
+	}
+	s.templates = templates
+}
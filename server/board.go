@@ -0,0 +1,120 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"tp1/annuaire"
+)
+
+// Seconds a board page stays on screen (see ?refresh=)
+const (
+	defaultBoardRefresh = 20
+	minBoardRefresh     = 5
+	maxBoardRefresh     = 3600
+)
+
+// boardPageSize is the number of contacts shown at once on the board, so
+// that the cards stay readable from across the lobby
+const boardPageSize = 24
+
+// boardData is the data passed to board.html
+type boardData struct {
+	Letters  []boardLetter      // Strip from A to Z and OtherInitial
+	Initial  string             // Initial shown, "" when no contact is on the board
+	Contacts []annuaire.Contact // Contacts of this page of the initial, masked
+	Page     int                // Page of the initial, from 1
+	Pages    int                // Pages of the initial
+	Count    int                // Contacts on the board, every initial included
+	Tag      string             // Tag the board is limited to, "" for every contact
+	Refresh  int                // Seconds before Next is shown
+	Next     string             // Local URL of the page shown next
+}
+
+// boardLetter is one letter of the strip at the top of the board
+type boardLetter struct {
+	Letter  string
+	Current bool // Letter shown
+	Empty   bool // No contact under this letter
+}
+
+/**
+ * handleBoard renders the display-only board meant for a wall screen
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer for HTML content
+ * @param {*http.Request} r - HTTP GET request with optional parameters:
+ *   ?letter=D starts at that initial, ?tag=staff keeps the contacts with that
+ *   tag, ?refresh=30 changes the seconds each page stays on screen
+ *
+ * The board shows the contacts of one initial in a large grid, at most
+ * boardPageSize at a time, then moves by itself to the next page or initial
+ * with contacts, wrapping from Z back to A. The page reloads itself with a
+ * meta refresh, so it needs no script and shows changes made meanwhile; it
+ * has no forms nor buttons. Sensitive fields are masked as on the share page
+ */
+func (s *Server) handleBoard(w http.ResponseWriter, r *http.Request) {
+	cfg := s.loadSettings()
+	tag := r.FormValue("tag")
+	refresh := defaultBoardRefresh
+	if n, err := strconv.Atoi(r.FormValue("refresh")); err == nil {
+		refresh = min(max(n, minBoardRefresh), maxBoardRefresh)
+	}
+
+	contacts := s.dir.ListContacts()
+	annuaire.SortContactsBy(contacts, annuaire.SortByName, false)
+	kept := contacts[:0]
+	for _, contact := range contacts {
+		if tag == "" || contact.HasTag(tag) {
+			kept = append(kept, annuaire.MaskContact(contact, cfg.SensitiveFields))
+		}
+	}
+	groups := annuaire.GroupByInitial(kept)
+
+	data := boardData{Count: len(kept), Tag: tag, Refresh: refresh, Page: 1, Pages: 1}
+	current := -1
+	if len(groups) > 0 {
+		// The letter asked for, or the next one with contacts
+		asked := max(0, slices.Index(annuaire.IndexLetters, strings.ToUpper(r.FormValue("letter"))))
+		current = slices.IndexFunc(groups, func(g annuaire.ContactGroup) bool {
+			return slices.Index(annuaire.IndexLetters, g.Initial) >= asked
+		})
+		current = max(0, current)
+		group := groups[current]
+		data.Initial = group.Initial
+		data.Pages = (len(group.Contacts) + boardPageSize - 1) / boardPageSize
+		if n, err := strconv.Atoi(r.FormValue("page")); err == nil && n >= 1 && n <= data.Pages {
+			data.Page = n
+		}
+		data.Contacts = group.Contacts[(data.Page-1)*boardPageSize : min(data.Page*boardPageSize, len(group.Contacts))]
+	}
+
+	// Next page of this initial, or first page of the next one
+	next := url.Values{}
+	if current >= 0 && data.Page < data.Pages {
+		next.Set("letter", data.Initial)
+		next.Set("page", strconv.Itoa(data.Page+1))
+	} else if current >= 0 {
+		next.Set("letter", groups[(current+1)%len(groups)].Initial)
+	}
+	if tag != "" {
+		next.Set("tag", tag)
+	}
+	if refresh != defaultBoardRefresh {
+		next.Set("refresh", strconv.Itoa(refresh))
+	}
+	data.Next = "/board"
+	if len(next) > 0 {
+		data.Next += "?" + next.Encode()
+	}
+
+	for _, letter := range annuaire.IndexLetters {
+		data.Letters = append(data.Letters, boardLetter{
+			Letter:  letter,
+			Current: letter == data.Initial,
+			Empty:   !slices.ContainsFunc(groups, func(g annuaire.ContactGroup) bool { return g.Initial == letter }),
+		})
+	}
+	renderTemplate(w, s.templates, "board.html", http.StatusOK, data)
+}
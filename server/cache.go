@@ -0,0 +1,62 @@
+package server
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// maxCachedQueries bounds the cache; search terms are user input, so the
+// number of distinct keys must not grow without limit
+const maxCachedQueries = 256
+
+// queryCache memoizes query results for one generation of a directory
+// Every entry is dropped as soon as the directory generation changes, so a
+// result is never served after a mutation
+type queryCache struct {
+	mu         sync.Mutex
+	generation uint64         // Directory generation the entries were computed for
+	entries    map[string]any // Results keyed by query
+}
+
+/**
+ * get returns the cached result of a query, computing it on a miss
+ *
+ * @param {uint64} generation - Current directory generation, read before computing
+ * @param {string} key - Query identifier, e.g. "search:Dupont"
+ * @param {func() any} compute - Computes the result on a miss (called without the lock)
+ * @return {any} Cached or freshly computed result, shared between callers:
+ *               it must be treated as read-only
+ */
+func (c *queryCache) get(generation uint64, key string, compute func() any) any {
+	c.mu.Lock()
+	if c.generation != generation || c.entries == nil {
+		c.generation = generation
+		c.entries = make(map[string]any)
+	}
+	if result, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		slog.Debug("query cache hit", "key", key, "generation", generation)
+		return result
+	}
+	c.mu.Unlock()
+
+	result := compute()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A mutation during compute makes the result stale: return it, but do not keep it
+	if c.generation == generation {
+		if len(c.entries) >= maxCachedQueries {
+			c.entries = make(map[string]any)
+		}
+		c.entries[key] = result
+	}
+	return result
+}
+
+// forget drops one entry, e.g. a result that must not be served again
+func (c *queryCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
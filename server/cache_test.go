@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"tp1/annuaire"
+)
+
+// TestQueryCache tests hits within a generation and invalidation across generations
+func TestQueryCache(t *testing.T) {
+	var cache queryCache
+	calls := 0
+	compute := func() any { calls++; return calls }
+
+	if cache.get(1, "q", compute) != 1 || cache.get(1, "q", compute) != 1 {
+		t.Error("Expected the second call to be served from the cache")
+	}
+	if cache.get(2, "q", compute) != 2 {
+		t.Error("Expected a new generation to recompute the result")
+	}
+}
+
+// TestSearchCacheInvalidation tests that a mutation is visible to a repeated search
+func TestSearchCacheInvalidation(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir)
+
+	search := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?name=Dupont", nil))
+		return rec.Body.String()
+	}
+
+	if !strings.Contains(search(), "No contact found matching: Dupont") {
+		t.Fatal("Expected no result before the contact is added")
+	}
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	if body := search(); !strings.Contains(body, "Contact found") {
+		t.Error("Cached search result survived a mutation")
+	}
+}
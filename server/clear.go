@@ -0,0 +1,159 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"tp1/annuaire"
+)
+
+// clearLifetime is how long a clear shown on /clear can be confirmed
+const clearLifetime = 5 * time.Minute
+
+// maxPendingClears bounds the confirmations kept in memory
+const maxPendingClears = 16
+
+// clearAllWord must be typed to confirm clearing every contact
+const clearAllWord = "CLEAR"
+
+// clearData is the data passed to clear.html
+type clearData struct {
+	Scope     annuaire.ClearScope // Contacts selected by the form
+	Count     int                 // Contacts the scope selects now
+	Total     int                 // Contacts in the directory
+	Tags      []annuaire.TagCount // Tags to choose from, most frequent first
+	Sources   []string            // Import files to choose from
+	Token     string              // Identifies the confirmation, empty when nothing matches
+	Word      string              // Word to type to clear every contact
+	Flash     *Flash              // Result of the previous attempt, if any
+	CSRFToken string              // Token embedded in the forms
+}
+
+// pendingClear is a scope shown on /clear, waiting for confirmation
+type pendingClear struct {
+	scope   annuaire.ClearScope
+	expires time.Time
+}
+
+// clearStore keeps the scopes between the confirmation page and the POST,
+// so that a lone POST cannot clear anything
+type clearStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingClear // Scopes by token
+}
+
+// newClearStore returns an empty store
+func newClearStore() *clearStore {
+	return &clearStore{pending: make(map[string]pendingClear)}
+}
+
+// add keeps a scope until it is confirmed or expired, and returns its token
+func (st *clearStore) add(scope annuaire.ClearScope) (string, error) {
+	token, err := newCSRFToken() // Same 256-bit random format
+	if err != nil {
+		return "", err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	for token, pending := range st.pending {
+		if now.After(pending.expires) {
+			delete(st.pending, token)
+		}
+	}
+	if len(st.pending) >= maxPendingClears {
+		return "", errors.New("too many clears waiting for confirmation, try again later")
+	}
+	st.pending[token] = pendingClear{scope: scope, expires: now.Add(clearLifetime)}
+	return token, nil
+}
+
+// take removes and returns a scope, which can therefore be confirmed only once
+func (st *clearStore) take(token string) (annuaire.ClearScope, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	pending, ok := st.pending[token]
+	delete(st.pending, token)
+	if !ok || time.Now().After(pending.expires) {
+		return annuaire.ClearScope{}, false
+	}
+	return pending.scope, true
+}
+
+/**
+ * handleClear chooses which contacts to remove, then removes them once confirmed
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - GET with optional "tag" and "source" to show the
+ *   confirmation page; POST with the "token" of that page, and "confirm" set
+ *   to clearAllWord when every contact is selected
+ *
+ * Clearing is always two steps: the page counts the contacts of the scope
+ * and hands out a one-time token, and only a POST carrying that token removes
+ * them, so that a single forged or replayed POST clears nothing. A scope
+ * limits the clear to a tag or to the contacts of one import; clearing
+ * everything also needs the word typed in. Viewers can neither see the page
+ * nor post it (see sessionGuard)
+ *
+ * Note: This operation only affects the in-memory data; a configured data file
+ * is only rewritten when the server shuts down
+ */
+func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
+	if readOnly(r) {
+		http.Error(w, "Read-only account: ask an administrator to clear contacts", http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		s.confirmClear(w, r)
+		return
+	}
+
+	scope := annuaire.ClearScope{Tag: strings.TrimSpace(r.FormValue("tag")), Source: r.FormValue("source")}
+	data := clearData{
+		Scope:     scope,
+		Count:     s.dir.CountScope(scope),
+		Total:     s.dir.ContactCount(),
+		Tags:      s.dir.Stats(0).Tags,
+		Sources:   s.dir.ImportSources(),
+		Word:      clearAllWord,
+		Flash:     popFlash(w, r),
+		CSRFToken: csrfToken(r),
+	}
+	if data.Count > 0 {
+		token, err := s.clears.add(scope)
+		if err != nil {
+			data.Flash = &Flash{Type: flashError, Text: "Error: " + err.Error()}
+		}
+		data.Token = token
+	}
+	renderTemplate(w, s.templates, "clear.html", http.StatusOK, data)
+}
+
+// confirmClear removes the contacts of the scope confirmed on /clear
+func (s *Server) confirmClear(w http.ResponseWriter, r *http.Request) {
+	scope, ok := s.clears.take(r.FormValue("token"))
+	if !ok {
+		setFlash(w, r, Flash{Type: flashError, Text: "Clear expired or not confirmed: choose the contacts to remove again"})
+		http.Redirect(w, r, "/clear", http.StatusSeeOther)
+		return
+	}
+	if scope.IsZero() && strings.TrimSpace(r.FormValue("confirm")) != clearAllWord {
+		setFlash(w, r, Flash{Type: flashError, Text: fmt.Sprintf("Type %s to remove every contact", clearAllWord)})
+		http.Redirect(w, r, "/clear", http.StatusSeeOther)
+		return
+	}
+
+	removed := s.traced(r).ClearContacts(scope)
+	slog.Info("contacts cleared", "user", loggedInUser(r), "scope", scope.String(), "removed", removed)
+	if scope.IsZero() {
+		redirectWithFlash(w, r, flashSuccess, "Local memory cleared successfully")
+		return
+	}
+	redirectWithFlash(w, r, flashSuccess, fmt.Sprintf("%d %s removed", removed, scope))
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CORS lets the pages of other origins, such as a separate single-page
+// application, call the JSON API under /api/
+type CORS struct {
+	Origins []string // Allowed origins, e.g. "http://localhost:5173"; "*" allows any origin, without cookies
+	Methods []string // Allowed methods (empty: defaultCORSMethods)
+}
+
+// defaultCORSMethods are the methods of the API routes
+var defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete}
+
+// corsMaxAge is how long browsers may cache a preflight answer, in seconds
+const corsMaxAge = 600
+
+// corsAnyOrigin allows every origin
+const corsAnyOrigin = "*"
+
+// Enabled reports whether any origin is allowed
+func (c CORS) Enabled() bool {
+	return len(c.Origins) > 0
+}
+
+/**
+ * ParseCORSList splits a comma-separated list of origins or methods
+ *
+ * @param {string} list - List such as "http://localhost:5173, https://app.example.com"
+ * @return {[]string} Trimmed values without trailing "/", nil for an empty list
+ */
+func ParseCORSList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSuffix(strings.TrimSpace(value), "/"); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+/**
+ * WithCORS answers the cross-origin requests of the API
+ *
+ * @param {CORS} cors - Allowed origins and methods
+ * @return {Option} Option to pass to NewServer
+ */
+func WithCORS(cors CORS) Option {
+	return func(s *Server) {
+		s.cors = cors
+	}
+}
+
+/**
+ * corsHandler adds the CORS headers to the answers of the API
+ *
+ * @param {http.Handler} next - Handler of the routes
+ * @param {CORS} cors - Allowed origins and methods (none returns next)
+ * @return {http.Handler} Handler answering preflight requests itself
+ *
+ * Listed origins may send the session cookies (Access-Control-Allow-Credentials),
+ * send conditional requests, and read the Location, ETag and X-CSRF-Token headers: a script of another origin
+ * cannot read the CSRF cookie, so it takes the token from any GET answer of
+ * the API. With "*", any origin may read the API, but without cookies.
+ * Preflight requests from other origins are refused with 403, and their
+ * other requests are served without CORS headers, which the browser blocks
+ */
+func corsHandler(next http.Handler, cors CORS) http.Handler {
+	if !cors.Enabled() {
+		return next
+	}
+	methods := defaultCORSMethods
+	if len(cors.Methods) > 0 {
+		methods = make([]string, len(cors.Methods))
+		for i, method := range cors.Methods {
+			methods[i] = strings.ToUpper(method)
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		listed := slices.Contains(cors.Origins, origin)
+		allowed := listed || slices.Contains(cors.Origins, corsAnyOrigin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !allowed {
+			if preflight {
+				writeAPIError(w, http.StatusForbidden, "origin "+origin+" is not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if listed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", corsAnyOrigin)
+		}
+		if !preflight {
+			w.Header().Set("Access-Control-Expose-Headers", "Location, ETag, "+csrfHeaderName)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !slices.Contains(methods, r.Header.Get("Access-Control-Request-Method")) {
+			writeAPIError(w, http.StatusForbidden, "method "+r.Header.Get("Access-Control-Request-Method")+" is not allowed")
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-Match, If-None-Match, traceparent, "+csrfHeaderName)
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// csrfCookieName is the cookie holding the per-session CSRF token
+const csrfCookieName = "tp1_csrf"
+
+// csrfFieldName is the hidden form field carrying the token back on POST
+const csrfFieldName = "csrf_token"
+
+// csrfHeaderName lets scripts send the token without a form body
+const csrfHeaderName = "X-CSRF-Token"
+
+// multipartMemory is how much of a multipart body is kept in memory when it
+// is parsed, for the CSRF check or by the import handler; the rest goes to
+// temporary files. The body itself is bounded by the upload limit (see limitBody)
+const multipartMemory = 10 << 20 // 10 MB
+
+// csrfContextKey stores the current token in the request context
+type csrfContextKey struct{}
+
+/**
+ * csrfProtect wraps a handler with double-submit CSRF protection
+ *
+ * @param {http.Handler} next - Handler to protect
+ * @return {http.Handler} Handler rejecting forged state-changing requests with 403
+ *
+ * How it works:
+ * - Every browser session receives a random token in a SameSite cookie
+ * - Pages embed the same token in a hidden field of each POST form
+ * - POST, PUT, PATCH and DELETE requests must send the token back (form field
+ *   or X-CSRF-Token header) and it must match the cookie
+ *
+ * A malicious site can make the browser send the cookie, but cannot read it
+ * to fill in the form field, so its forged requests are refused. GET answers
+ * of the API repeat the token in the X-CSRF-Token header, which the browser
+ * only lets the origins allowed by CORS read
+ */
+func csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+		if cookie, err := r.Cookie(csrfCookieName); err == nil && len(cookie.Value) == 64 {
+			token = cookie.Value
+		}
+
+		if isStateChanging(r.Method) {
+			if token == "" || !validCSRFToken(r, token) {
+				slog.Warn("rejected request with invalid CSRF token", "method", r.Method, "path", r.URL.Path)
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					writeAPIError(w, http.StatusForbidden, "invalid or missing CSRF token")
+				} else {
+					http.Error(w, "Invalid or missing CSRF token", http.StatusForbidden)
+				}
+				return
+			}
+		} else if token == "" {
+			// Start a session token on the first page view
+			var err error
+			if token, err = newCSRFToken(); err != nil {
+				http.Error(w, "Cannot create CSRF token", http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		if !isStateChanging(r.Method) && strings.HasPrefix(r.URL.Path, "/api/") {
+			// Scripts of other origins allowed by CORS cannot read the cookie
+			w.Header().Set(csrfHeaderName, token)
+		}
+
+		ctx := context.WithValue(r.Context(), csrfContextKey{}, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/**
+ * csrfToken returns the token to embed in the forms of the current page
+ *
+ * @param {*http.Request} r - Request that went through csrfProtect
+ * @return {string} The session token (empty if the middleware was not applied)
+ */
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfContextKey{}).(string)
+	return token
+}
+
+// isStateChanging reports whether a method may modify server state
+func isStateChanging(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// validCSRFToken compares the submitted token with the cookie in constant time
+func validCSRFToken(r *http.Request, expected string) bool {
+	submitted := r.Header.Get(csrfHeaderName)
+	if submitted == "" {
+		// Parse uploads before reading the field
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			if err := r.ParseMultipartForm(multipartMemory); err != nil {
+				return false
+			}
+		}
+		submitted = r.FormValue(csrfFieldName)
+	}
+	return subtle.ConstantTimeCompare([]byte(submitted), []byte(expected)) == 1
+}
+
+// newCSRFToken generates a random 256-bit token encoded as hex
+func newCSRFToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
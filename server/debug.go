@@ -0,0 +1,91 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"tp1/annuaire"
+)
+
+// DefaultDebugAddr is where the -debug endpoints listen unless told
+// otherwise: the loopback interface, out of reach of the network
+const DefaultDebugAddr = "localhost:6060"
+
+/**
+ * debugHandler serves the profiling and runtime endpoints
+ *
+ * @param {[]string} names - Profile names, in the order of dirs ("" for the default one)
+ * @param {[]*annuaire.Directory} dirs - Directories served, whose sizes are reported
+ * @return {http.Handler} Handler of:
+ *   /debug/pprof/ - net/http/pprof profiles (heap, goroutine, profile?seconds=30...)
+ *   /debug/vars   - expvar variables (cmdline, memstats), plus "goroutines"
+ *                   and "contacts", the number of contacts of each profile
+ *
+ * These pages reveal the command line and memory of the process, so they are
+ * served on their own listener only (see startDebugServer), never next to
+ * the web interface
+ *
+ * Usage:
+ *   go tool pprof http://localhost:6060/debug/pprof/heap
+ */
+func debugHandler(names []string, dirs []*annuaire.Directory) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/vars", func(w http.ResponseWriter, r *http.Request) {
+		contacts := make(map[string]int, len(dirs))
+		for i, dir := range dirs {
+			contacts[cmp.Or(names[i], "default")] = dir.ContactCount()
+		}
+		counts, _ := json.Marshal(contacts)
+
+		// Same output as expvar.Handler, with the figures of this server added
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\n%q: %s,\n%q: %d", "contacts", counts, "goroutines", runtime.NumGoroutine())
+		expvar.Do(func(kv expvar.KeyValue) {
+			fmt.Fprintf(w, ",\n%q: %s", kv.Key, kv.Value)
+		})
+		fmt.Fprintf(w, "\n}\n")
+	})
+	return mux
+}
+
+/**
+ * startDebugServer serves the debug endpoints on their own address
+ *
+ * @param {string} addr - Listen address, e.g. DefaultDebugAddr
+ * @param {http.Handler} handler - Debug endpoints (see debugHandler)
+ * @return {*http.Server} Running server, to close when the main one stops
+ * @return {error} Error if the address cannot be listened on
+ *
+ * The endpoints have no login: an address reachable from the network is
+ * logged as a warning, and should be protected by a firewall or a tunnel
+ */
+func startDebugServer(addr string, handler http.Handler) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("debug endpoints: %w", err)
+	}
+	if host, _, _ := net.SplitHostPort(ln.Addr().String()); !net.ParseIP(host).IsLoopback() {
+		slog.Warn("debug endpoints are reachable from the network without login", "addr", ln.Addr().String())
+	}
+	slog.Info("debug endpoints started", "url", "http://"+ln.Addr().String()+"/debug/pprof/")
+
+	// Profiles such as profile?seconds=30 take longer than the limits of the web interface
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: defaultReadTimeout}
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			slog.Error("debug endpoints stopped", "error", err)
+		}
+	}()
+	return srv, nil
+}
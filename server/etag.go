@@ -0,0 +1,119 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"tp1/annuaire"
+)
+
+// newETagKey returns the random key signing the ETags of a server, so that
+// an ETag cannot be recomputed from guessed values of masked fields; ETags
+// change on restart, which only costs clients one full answer
+func newETagKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// etag signs values into a strong entity tag, e.g. "\"3f2a...\""
+func (s *Server) etag(parts ...[]byte) string {
+	mac := hmac.New(sha256.New, s.etagKey)
+	for _, part := range parts {
+		mac.Write(part)
+		mac.Write([]byte{0}) // Keeps ("ab", "c") apart from ("a", "bc")
+	}
+	return `"` + hex.EncodeToString(mac.Sum(nil)[:16]) + `"`
+}
+
+/**
+ * contactETag returns the entity tag of one contact of the API
+ *
+ * @param {annuaire.Contact} c - Contact as stored, unmasked
+ * @param {[]string} sensitive - Fields masked in the answer
+ * @return {string} Tag changing with any field of the contact, masked or not,
+ *   and with the masked fields, so that a change hidden by the masks still
+ *   fails If-Match and a new mask still refreshes caches
+ */
+func (s *Server) contactETag(c annuaire.Contact, sensitive []string) string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		slog.Warn("cannot tag contact", "id", c.ID, "error", err)
+	}
+	return s.etag(data, []byte(strings.Join(sensitive, ",")))
+}
+
+/**
+ * writeTaggedJSON sends a value like writeJSON, with its entity tag
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - Request, whose If-None-Match may spare the body
+ * @param {int} status - Status of a full answer
+ * @param {any} value - Value to encode
+ * @param {string} etag - Tag of the value (empty: signed from the encoded body)
+ *
+ * A GET or HEAD whose If-None-Match holds the tag is answered 304 Not Modified
+ * without a body, so that clients can revalidate their cached copy cheaply
+ */
+func (s *Server) writeTaggedJSON(w http.ResponseWriter, r *http.Request, status int, value any, etag string) {
+	body, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	body = append(body, '\n')
+	if etag == "" {
+		etag = s.etag(body)
+	}
+	w.Header().Set("ETag", etag)
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) && matchesETag(r.Header.Get("If-None-Match"), etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		slog.Warn("api write failed", "error", err)
+	}
+}
+
+/**
+ * matchesETag reports whether an If-Match or If-None-Match header lists a tag
+ *
+ * @param {string} header - Comma-separated tags, or "*" for any
+ * @param {string} etag - Current tag of the resource
+ * @param {bool} weak - Whether W/ tags count (If-None-Match), or only
+ *   strong ones (If-Match, see RFC 9110)
+ * @return {bool} True when a listed tag matches
+ */
+func matchesETag(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch answers 412 Precondition Failed, and returns false, when the
+// request has an If-Match header that does not hold the current tag: the
+// contact changed since the client read it, and changing it would lose that
+// change. Requests without If-Match are not checked
+func checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || matchesETag(header, etag, false) {
+		return true
+	}
+	w.Header().Set("ETag", etag)
+	writeAPIError(w, http.StatusPreconditionFailed, "the contact changed since it was read: get it again, then retry")
+	return false
+}
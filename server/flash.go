@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// flashCookieName is the cookie carrying a message to the next page view
+const flashCookieName = "tp1_flash"
+
+// Flash message types, also used as CSS classes by the template
+const (
+	flashSuccess = "success"
+	flashError   = "error"
+)
+
+// Flash is a one-time status message shown after a redirect
+// Text is always rendered escaped; links are built by handlers, never read
+// from the request, so a crafted URL cannot inject markup into the page
+type Flash struct {
+	Type string     `json:"type"`           // flashSuccess or flashError
+	Text string     `json:"text"`           // Plain text message
+	Link *FlashLink `json:"link,omitempty"` // Optional action offered with the message
+}
+
+// FlashLink is a link displayed after the flash text
+type FlashLink struct {
+	Href  string `json:"href"`  // Local path, e.g. "/export?filename=contacts.json"
+	Label string `json:"label"` // Link text
+}
+
+/**
+ * setFlash stores a message to display on the next page view
+ *
+ * @param {http.ResponseWriter} w - Response that will carry the cookie
+ * @param {Flash} flash - Message to store
+ */
+func setFlash(w http.ResponseWriter, r *http.Request, flash Flash) {
+	data, err := json.Marshal(flash)
+	if err != nil {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(data),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+/**
+ * popFlash reads and clears the pending flash message
+ *
+ * @param {http.ResponseWriter} w - Response used to expire the cookie
+ * @param {*http.Request} r - Request that may carry a flash cookie
+ * @return {*Flash} The message, or nil when there is none or it is malformed
+ *
+ * The cookie is HttpOnly and only ever written by setFlash, but its content
+ * is still validated: unknown types are dropped and links must stay local
+ */
+func popFlash(w http.ResponseWriter, r *http.Request) *Flash {
+	cookie, err := r.Cookie(flashCookieName)
+	if err != nil {
+		return nil
+	}
+	http.SetCookie(w, &http.Cookie{Name: flashCookieName, Value: "", Path: "/", MaxAge: -1})
+
+	data, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	var flash Flash
+	if err := json.Unmarshal(data, &flash); err != nil || flash.Text == "" {
+		return nil
+	}
+	if flash.Type != flashSuccess && flash.Type != flashError {
+		return nil
+	}
+	if flash.Link != nil && !isLocalPath(flash.Link.Href) {
+		flash.Link = nil
+	}
+	return &flash
+}
+
+/**
+ * redirectWithFlash stores a message and sends the browser back to the home page
+ *
+ * @param {http.ResponseWriter} w - Response writer
+ * @param {*http.Request} r - Current request
+ * @param {string} kind - flashSuccess or flashError
+ * @param {string} text - Plain text message
+ */
+func redirectWithFlash(w http.ResponseWriter, r *http.Request, kind, text string) {
+	setFlash(w, r, Flash{Type: kind, Text: text})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// isLocalPath accepts absolute paths on this server ("/export") but not
+// protocol-relative ("//evil.test") or scheme URLs ("javascript:")
+func isLocalPath(href string) bool {
+	return strings.HasPrefix(href, "/") && !strings.HasPrefix(href, "//") && !strings.HasPrefix(href, "/\\")
+}
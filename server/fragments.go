@@ -0,0 +1,93 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Headers of the fetch requests sent by static/app.js; the names are the ones
+// of htmx, so the same endpoints also work with hx-post and hx-get attributes
+const (
+	fragmentHeader   = "HX-Request"     // "true" on a request wanting fragments
+	currentURLHeader = "HX-Current-URL" // Address of the page the request comes from
+)
+
+// fragmentTemplate renders the parts of home.html replaced after a fetch
+// request: every element carrying an id replaces the one of the page
+const fragmentTemplate = "updates"
+
+// isFragmentRequest reports whether the request wants the updated parts of
+// the page instead of a full page or a redirect
+func isFragmentRequest(r *http.Request) bool {
+	return r.Header.Get(fragmentHeader) == "true"
+}
+
+/**
+ * currentList returns the address of the list displayed by the browser
+ *
+ * @param {*http.Request} r - Fetch request sent from the main page
+ * @return {*url.URL} The home page or the search page, with its query (search
+ *   term and page number), so that the list refreshed after a change stays on
+ *   the same page
+ *
+ * The header is only used to rebuild local page links: any path other than
+ * the two list pages is replaced by the home page
+ */
+func currentList(r *http.Request) *url.URL {
+	current, err := url.Parse(r.Header.Get(currentURLHeader))
+	if err != nil {
+		return &url.URL{Path: "/"}
+	}
+	list := &url.URL{Path: "/", RawQuery: current.RawQuery}
+	if strings.TrimPrefix(current.Path, basePathOf(r)) == "/search" {
+		list.Path = "/search"
+	}
+	return list
+}
+
+/**
+ * reply ends a form submission with a message
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - Form submission
+ * @param {string} kind - flashSuccess or flashError
+ * @param {string} text - Message shown to the user
+ *
+ * A fetch request gets the message, statistics, search results and contact
+ * list rendered in place, so the page neither reloads nor carries the
+ * message in its URL. A plain form submission is redirected to the home page
+ * with a flash cookie, as before.
+ */
+func (s *Server) reply(w http.ResponseWriter, r *http.Request, kind, text string) {
+	status := http.StatusOK
+	if kind == flashError {
+		status = http.StatusBadRequest
+	}
+	s.replyWithStatus(w, r, status, kind, text)
+}
+
+// replyError ends a form submission refused by the directory; fetch requests
+// get the status matching the error (see errorStatus)
+func (s *Server) replyError(w http.ResponseWriter, r *http.Request, err error) {
+	s.replyWithStatus(w, r, errorStatus(err), flashError, fmt.Sprintf("Error: %v", err))
+}
+
+// replyWithStatus implements reply and replyError; status only applies to fetch requests
+func (s *Server) replyWithStatus(w http.ResponseWriter, r *http.Request, status int, kind, text string) {
+	if !isFragmentRequest(r) {
+		redirectWithFlash(w, r, kind, text)
+		return
+	}
+
+	list := currentList(r)
+	data := s.pageData(r, list)
+	if term := list.Query().Get("name"); list.Path == "/search" && term != "" {
+		opts := searchOptions(list.Query())
+		s.fillSearch(r.Context(), &data, term, opts) // Refresh the results the change may affect
+		data.Phonetic = opts.Phonetic
+	}
+	data.Message, data.MessageType = text, kind
+	renderTemplate(w, s.templates, fragmentTemplate, status, data)
+}
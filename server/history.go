@@ -0,0 +1,45 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"tp1/annuaire"
+)
+
+// maxHistoryRows bounds the history page; older entries stay in the log file
+const maxHistoryRows = 200
+
+// historyData is the data passed to historyTemplate
+type historyData struct {
+	Entries   []annuaire.HistoryEntry // Most recent entries first
+	Truncated bool                    // True if older entries were left out
+}
+
+/**
+ * handleHistory renders the audit log of the directory
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer for HTML content
+ * @param {*http.Request} r - HTTP GET request
+ *
+ * Every add, update, delete, import and clear is listed, newest first,
+ * whether it was made from the web interface or the CLI
+ */
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.dir.History()
+	if err != nil {
+		slog.Error("cannot read history", "error", err)
+		http.Error(w, "Cannot read history", http.StatusInternalServerError)
+		return
+	}
+
+	// Searches are kept for usage statistics but are not modifications
+	entries = slices.DeleteFunc(entries, func(e annuaire.HistoryEntry) bool { return !e.IsModification() })
+	slices.Reverse(entries)
+	data := historyData{Entries: entries}
+	if len(entries) > maxHistoryRows {
+		data.Entries, data.Truncated = entries[:maxHistoryRows], true
+	}
+
+	renderTemplate(w, s.templates, "history.html", http.StatusOK, data)
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"tp1/annuaire"
+)
+
+// importLifetime is how long a previewed import can be confirmed
+const importLifetime = 15 * time.Minute
+
+// maxPendingImports bounds the previews kept in memory; uploads are already
+// limited in size each (see WithMaxUploadSize)
+const maxPendingImports = 16
+
+// importData is the data passed to importTemplate
+type importData struct {
+	Filename  string                 // Name of the uploaded file
+	Preview   annuaire.ImportPreview // Rows and what confirming does with each
+	Token     string                 // Identifies the preview in the confirm form
+	Valid     int                    // Rows that will be imported
+	Added     int                    // Rows adding a contact
+	Updated   int                    // Rows overwriting a contact
+	Skipped   int                    // Rows left out as duplicates
+	Invalid   int                    // Rows refused by validation
+	CSRFToken string                 // Token embedded in the form
+}
+
+// pendingImport is an uploaded file read and checked, waiting for confirmation
+type pendingImport struct {
+	filename string
+	preview  annuaire.ImportPreview
+	expires  time.Time
+}
+
+// importStore keeps the previews between the upload and the confirmation
+type importStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingImport // Previews by token
+}
+
+// newImportStore returns an empty store
+func newImportStore() *importStore {
+	return &importStore{pending: make(map[string]pendingImport)}
+}
+
+/**
+ * add keeps a preview until it is confirmed, cancelled or expired
+ *
+ * @param {string} filename - Name of the uploaded file, for messages and the history
+ * @param {annuaire.ImportPreview} preview - Checked rows of the file
+ * @return {string} Random token to send back with the confirmation
+ * @return {error} Returns an error when too many previews are waiting
+ */
+func (st *importStore) add(filename string, preview annuaire.ImportPreview) (string, error) {
+	token, err := newCSRFToken() // Same 256-bit random format
+	if err != nil {
+		return "", err
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	now := time.Now()
+	for token, pending := range st.pending {
+		if now.After(pending.expires) {
+			delete(st.pending, token)
+		}
+	}
+	if len(st.pending) >= maxPendingImports {
+		return "", errors.New("too many imports waiting for confirmation, try again later")
+	}
+	st.pending[token] = pendingImport{filename: filename, preview: preview, expires: now.Add(importLifetime)}
+	return token, nil
+}
+
+// take removes and returns a preview, which can therefore be confirmed only once
+func (st *importStore) take(token string) (pendingImport, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	pending, ok := st.pending[token]
+	delete(st.pending, token)
+	if !ok || time.Now().After(pending.expires) {
+		return pendingImport{}, false
+	}
+	return pending, true
+}
+
+/**
+ * handleImportConfirm applies or cancels an import shown by handleImport
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer for redirect responses
+ * @param {*http.Request} r - HTTP POST request with "token" and "action"
+ *   ("confirm" or "cancel")
+ *
+ * Only the valid rows are imported, with the strategy chosen at upload. The
+ * rows were checked against the directory at preview time; a contact added
+ * in between is still handled by the strategy, never duplicated
+ */
+func (s *Server) handleImportConfirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	pending, ok := s.imports.take(r.FormValue("token"))
+	if !ok {
+		redirectWithFlash(w, r, flashError, "Import expired or already done: upload the file again")
+		return
+	}
+	if r.FormValue("action") != "confirm" {
+		redirectWithFlash(w, r, flashSuccess, fmt.Sprintf("Import of %s cancelled, nothing changed", pending.filename))
+		return
+	}
+
+	opts := annuaire.ImportOptions{Strategy: pending.preview.Strategy, RecordSource: true}
+	result := s.traced(r).ImportContacts(pending.preview.Valid(), opts, pending.filename)
+	text := fmt.Sprintf("Data imported successfully from %s (%d contacts loaded)", pending.filename, s.dir.ContactCount())
+	if opts.Strategy != annuaire.ImportReplace {
+		text = fmt.Sprintf("Data merged from %s: %s (%d contacts loaded)", pending.filename, result, s.dir.ContactCount())
+	}
+	if invalid := pending.preview.Count(annuaire.RowInvalid); invalid > 0 {
+		text += fmt.Sprintf(", %d invalid rows ignored", invalid)
+	}
+	// Offer a download of the imported data, built here rather than taken from the request
+	setFlash(w, r, Flash{
+		Type: flashSuccess,
+		Text: text,
+		Link: &FlashLink{Href: "/export?" + url.Values{"filename": {"contacts.json"}}.Encode(), Label: "Download a backup"},
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
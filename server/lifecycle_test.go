@@ -0,0 +1,645 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tp1/annuaire"
+	"tp1/server"
+	"tp1/server/servertest"
+	"tp1/settings"
+)
+
+// TestLifecycle drives add → search → export → import → delete through real HTTP
+func TestLifecycle(t *testing.T) {
+	srv := servertest.Start(t)
+
+	// Add
+	resp := srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	if body := servertest.ReadBody(t, resp); !strings.Contains(body, "Contact Jean Dupont added successfully") {
+		t.Fatalf("Add did not report success (status %d)", resp.StatusCode)
+	}
+	srv.PostForm("/add", url.Values{"name": {"Martin"}, "first": {"Paul"}, "phone": {"0600000000"}})
+
+	// Search
+	if body := srv.GetBody("/search?name=Dupont"); !strings.Contains(body, "Contact found") {
+		t.Error("Search did not find the added contact")
+	}
+
+	// Export
+	resp = srv.Get("/export?filename=backup.json")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Disposition"), "backup.json") {
+		t.Fatalf("Unexpected export response: %d %v", resp.StatusCode, resp.Header)
+	}
+	exported := servertest.ReadBody(t, resp)
+	var envelope struct {
+		Version  int                `json:"version"`
+		Contacts []annuaire.Contact `json:"contacts"`
+	}
+	if err := json.Unmarshal([]byte(exported), &envelope); err != nil || envelope.Version != annuaire.FormatVersion || len(envelope.Contacts) != 2 {
+		t.Fatalf("Export is not a 2-contact versioned document: %v\n%s", err, exported)
+	}
+
+	// Import previews the uploaded file, then replaces the directory once confirmed
+	resp = srv.PostFile("/import", "file", "backup.json", []byte(exported))
+	preview := servertest.ReadBody(t, resp)
+	token := regexp.MustCompile(`name="token" value="([^"]+)"`).FindStringSubmatch(preview)
+	if token == nil || !strings.Contains(preview, "Import 2 contacts") {
+		t.Fatalf("Import did not show a preview:\n%s", preview)
+	}
+	resp = srv.PostForm("/import/confirm", url.Values{"token": {token[1]}, "action": {"confirm"}})
+	if body := servertest.ReadBody(t, resp); !strings.Contains(body, "(2 contacts loaded)") {
+		t.Errorf("Import did not restore the exported contacts")
+	}
+
+	// Delete
+	srv.PostForm("/delete", url.Values{"name": {"Martin"}})
+	if body := srv.GetBody("/search?name=Martin"); !strings.Contains(body, "No contact found matching: Martin") {
+		t.Error("Deleted contact is still found")
+	}
+}
+
+// TestCompressedExport tests that a .gz download is compressed and can be uploaded back
+func TestCompressedExport(t *testing.T) {
+	srv := servertest.Start(t)
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+
+	resp := srv.Get("/export?filename=backup.json.gz")
+	exported := servertest.ReadBody(t, resp)
+	if resp.Header.Get("Content-Type") != "application/gzip" || !strings.HasPrefix(exported, "\x1f\x8b") {
+		t.Fatalf("Export is not compressed: %v", resp.Header)
+	}
+
+	resp = srv.PostFile("/import", "file", "backup.json.gz", []byte(exported))
+	if preview := servertest.ReadBody(t, resp); !strings.Contains(preview, "Import 1 contacts") {
+		t.Errorf("Compressed upload was not read:\n%s", preview)
+	}
+}
+
+// TestDocumentFormatExport tests that .yaml and .xml downloads use those formats and upload back
+func TestDocumentFormatExport(t *testing.T) {
+	srv := servertest.Start(t)
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+
+	for name, want := range map[string]string{"backup.yaml": "phone: \"0123456789\"", "backup.xml": "<phone>0123456789</phone>"} {
+		resp := srv.Get("/export?filename=" + name)
+		exported := servertest.ReadBody(t, resp)
+		if !strings.Contains(exported, want) {
+			t.Fatalf("%s: expected %q in:\n%s", name, want, exported)
+		}
+		resp = srv.PostFile("/import", "file", name, []byte(exported))
+		if preview := servertest.ReadBody(t, resp); !strings.Contains(preview, "Import 1 contacts") {
+			t.Errorf("%s upload was not read:\n%s", name, preview)
+		}
+	}
+}
+
+// TestPersistenceAcrossRestart tests that shutdown flushes the data file and startup reloads it
+func TestPersistenceAcrossRestart(t *testing.T) {
+	srv := servertest.Start(t)
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	if err := srv.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := os.Stat(srv.DataFile); err != nil {
+		t.Fatalf("Data file not written on shutdown: %v", err)
+	}
+
+	restarted := servertest.StartWithFiles(t, srv.DataFile, srv.SettingsFile)
+	if body := restarted.GetBody("/"); !strings.Contains(body, "Jean Dupont") {
+		t.Error("Restarted server lost the contact")
+	}
+}
+
+// TestForgedRequestRejected tests CSRF protection with a client that has no session
+func TestForgedRequestRejected(t *testing.T) {
+	srv := servertest.Start(t)
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+
+	resp, err := http.PostForm(srv.URL+"/clear", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a forged request, got %d", resp.StatusCode)
+	}
+	if body := srv.GetBody("/"); !strings.Contains(body, "Jean Dupont") {
+		t.Error("Forged request cleared the directory")
+	}
+}
+
+// TestHistoryPage tests that web modifications appear on /history, newest first
+func TestHistoryPage(t *testing.T) {
+	srv := servertest.Start(t)
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	srv.PostForm("/delete", url.Values{"name": {"Dupont"}})
+
+	body := srv.GetBody("/history")
+	deleted, added := strings.Index(body, "delete Jean Dupont"), strings.Index(body, "add Jean Dupont")
+	if deleted < 0 || added < 0 || deleted > added {
+		t.Errorf("History page does not list the delete before the add:\n%s", body)
+	}
+	if _, err := os.Stat(srv.HistoryFile); err != nil {
+		t.Errorf("History not written to the log file: %v", err)
+	}
+}
+
+// TestStatsPage tests the dashboard built from the contacts and the history
+func TestStatsPage(t *testing.T) {
+	srv := servertest.Start(t)
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	srv.PostForm("/add", url.Values{"name": {"Durand"}, "first": {"Marie"}, "phone": {"0612345678"}})
+
+	body := srv.GetBody("/stats")
+	for _, want := range []string{"<strong>2</strong> contacts", "<polyline points=", `<td class="label">D</td><td class="count">2</td>`, "add Marie Durand"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Stats page lacks %q:\n%s", want, body)
+		}
+	}
+	if recent := strings.Index(body, "add Marie Durand"); recent > strings.Index(body, "add Jean Dupont") {
+		t.Error("Recent activity should list the newest modification first")
+	}
+}
+
+// TestReviewQueue tests confirming and updating contacts from /review
+func TestReviewQueue(t *testing.T) {
+	data := filepath.Join(t.TempDir(), "contacts.json")
+	stale := `[{"id":1,"name":"Dupont","first":"Jean","phone":"0123456789","verify_by":"2000-01-01"},` +
+		`{"id":2,"name":"Martin","first":"Paul","phone":"0611111111"}]`
+	if err := os.WriteFile(data, []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv := servertest.StartWithFiles(t, data, filepath.Join(filepath.Dir(data), "settings.json"))
+
+	if body := srv.GetBody("/review"); !strings.Contains(body, "Review (2)") {
+		t.Fatalf("Expected both contacts in the queue:\n%s", body)
+	}
+	srv.PostForm("/review", url.Values{"id": {"1"}, "action": {"confirm"}})
+	resp := srv.PostForm("/review", url.Values{"id": {"2"}, "action": {"update"}, "phone": {"0622222222"}})
+
+	body := servertest.ReadBody(t, resp)
+	if !strings.Contains(body, "Review (0)") || !strings.Contains(body, "Paul Martin confirmed until") {
+		t.Errorf("Expected an empty queue after review:\n%s", body)
+	}
+	if body := srv.GetBody("/"); !strings.Contains(body, "0622222222") {
+		t.Error("Update from the review queue did not change the phone")
+	}
+}
+
+// TestProfiles checks that each profile has its own contacts and data file
+func TestProfiles(t *testing.T) {
+	tmp := t.TempDir()
+	var profiles []server.Profile
+	for _, name := range []string{"personal", "work"} {
+		dir := filepath.Join(tmp, "profiles", name)
+		profiles = append(profiles, server.Profile{
+			Name:         name,
+			DataFile:     filepath.Join(dir, "contacts.json"),
+			SettingsFile: filepath.Join(dir, "settings.json"),
+			HistoryFile:  filepath.Join(dir, "history.jsonl"),
+		})
+	}
+	srv := servertest.StartWithConfig(t, server.Config{Profiles: profiles})
+
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	if body := srv.GetBody("/profile?name=work"); strings.Contains(body, "Jean Dupont") {
+		t.Error("Contact of the personal profile shown in the work profile")
+	} else if !strings.Contains(body, `<option value="work" selected>`) {
+		t.Error("Work profile not selected after switching")
+	}
+	srv.PostForm("/add", url.Values{"name": {"Martin"}, "first": {"Marie"}, "phone": {"0987654321"}})
+
+	// Unknown names keep the current profile
+	if body := srv.GetBody("/profile?name=../secret"); !strings.Contains(body, "Marie Martin") {
+		t.Error("Unknown profile name changed the displayed profile")
+	}
+	if err := srv.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	for i, want := range []string{"Dupont", "Martin"} {
+		dir := annuaire.NewDirectory()
+		if err := dir.LoadDataFile(profiles[i].DataFile, annuaire.FormatJSON); err != nil {
+			t.Fatalf("Loading %s: %v", profiles[i].Name, err)
+		}
+		if contacts := dir.ListContacts(); len(contacts) != 1 || contacts[0].Name != want {
+			t.Errorf("Profile %s saved %v, want only %s", profiles[i].Name, contacts, want)
+		}
+	}
+}
+
+// TestOnChange tests that modifications of each profile reach Config.OnChange,
+// but not the loading of the data files at startup
+func TestOnChange(t *testing.T) {
+	tmp := t.TempDir()
+	var profiles []server.Profile
+	for _, name := range []string{"personal", "work"} {
+		profiles = append(profiles, server.Profile{Name: name, DataFile: filepath.Join(tmp, name, "contacts.json")})
+	}
+	existing := annuaire.NewDirectory()
+	existing.AddContact("Martin", "Paul", "0600000000")
+	if err := existing.SaveDataFile(profiles[0].DataFile); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var changes []string
+	srv := servertest.StartWithConfig(t, server.Config{Profiles: profiles, OnChange: func(profile string, event annuaire.Event, contact annuaire.Contact) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, profile+": "+string(event)+" "+contact.First+" "+contact.Name)
+	}})
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	srv.GetBody("/profile?name=work")
+	srv.PostForm("/add", url.Values{"name": {"Durand"}, "first": {"Marie"}, "phone": {"0611111111"}})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(changes, ", "); got != "personal: add Jean Dupont, work: add Marie Durand" {
+		t.Errorf("Unexpected changes: %s", got)
+	}
+}
+
+// TestFirstRunSetup tests the setup page until the configuration exists
+func TestFirstRunSetup(t *testing.T) {
+	tmp := t.TempDir()
+	bootstrapFile := filepath.Join(tmp, "config.json")
+	srv := servertest.StartWithConfig(t, server.Config{
+		DataFile:      filepath.Join(tmp, "contacts.json"),
+		HistoryFile:   filepath.Join(tmp, "history.jsonl"),
+		BootstrapFile: bootstrapFile,
+	})
+	if body := srv.GetBody("/"); !strings.Contains(body, `href="/setup"`) {
+		t.Error("Home page does not offer the first-run setup")
+	}
+
+	form := url.Values{
+		"region": {"BE"}, "language": {"fr"}, "data_file": {filepath.Join(tmp, "contacts.json")},
+		"storage": {annuaire.FormatJSON}, "admin_user": {"admin"}, "admin_password": {"short"},
+	}
+	if resp := srv.PostForm("/setup", form); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a short password, got %d", resp.StatusCode)
+	}
+	if _, err := os.Stat(bootstrapFile); err == nil {
+		t.Fatal("Invalid setup was written")
+	}
+
+	form.Set("admin_password", "correct horse")
+	srv.PostForm("/setup", form)
+	config, err := settings.LoadBootstrap(bootstrapFile)
+	if err != nil || config == nil {
+		t.Fatalf("Configuration not written: %v", err)
+	}
+	if config.Region != "BE" || !config.Admin.Check("admin", "correct horse") || config.Admin.Check("admin", "wrong password") {
+		t.Errorf("Unexpected configuration: %+v", config)
+	}
+
+	// The new administrator is required from now on
+	if body := srv.GetBody("/"); !strings.Contains(body, `action="/login"`) {
+		t.Fatal("Home page still open after the administrator was created")
+	}
+	srv.Login("admin", "correct horse")
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0612345678"}})
+	body := srv.GetBody("/")
+	if !strings.Contains(body, `href="tel:&#43;32612345678"`) {
+		t.Error("Phone link does not use the configured region")
+	}
+	if !strings.Contains(body, `<html lang="fr">`) || strings.Contains(body, `href="/setup"`) {
+		t.Error("Home page ignores the saved configuration")
+	}
+
+	// Once configured, the page must not let anyone replace the administrator
+	form.Set("admin_user", "intruder")
+	srv.PostForm("/setup", form)
+	if config, _ := settings.LoadBootstrap(bootstrapFile); config.Admin.User != "admin" {
+		t.Error("Setup page overwrote an existing configuration")
+	}
+}
+
+// TestTLS tests HTTPS serving with HSTS and secure cookies
+func TestTLS(t *testing.T) {
+	tmp := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, tmp)
+	srv := servertest.StartWithConfig(t, server.Config{
+		DataFile:    filepath.Join(tmp, "contacts.json"),
+		HistoryFile: filepath.Join(tmp, "history.jsonl"),
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+	})
+	if !strings.HasPrefix(srv.URL, "https://") {
+		t.Fatalf("Expected an HTTPS URL, got %s", srv.URL)
+	}
+
+	resp := srv.Get("/")
+	if resp.Header.Get("Strict-Transport-Security") == "" {
+		t.Error("HSTS header missing over TLS")
+	}
+	for _, cookie := range resp.Cookies() {
+		if !cookie.Secure {
+			t.Errorf("Cookie %s is not marked Secure over TLS", cookie.Name)
+		}
+	}
+
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	if body := srv.GetBody("/"); !strings.Contains(body, "Jean Dupont") {
+		t.Error("Contact not added over TLS")
+	}
+
+	// A certificate without its key is a configuration error, reported at startup
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Serve(context.Background(), ln, server.Config{TLSCertFile: certFile}); err == nil {
+		t.Error("Expected an error for a certificate without key")
+	}
+}
+
+// writeTestCertificate creates a self-signed certificate for 127.0.0.1
+func writeTestCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "tp1 test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// TestLogin tests the session login, logout and protection of every route
+func TestLogin(t *testing.T) {
+	tmp := t.TempDir()
+	admin, err := settings.NewAdminCredentials("admin", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := servertest.StartWithConfig(t, server.Config{
+		DataFile:    filepath.Join(tmp, "contacts.json"),
+		HistoryFile: filepath.Join(tmp, "history.jsonl"),
+		Admin:       admin,
+	})
+
+	resp := srv.Get("/search?name=Dupont")
+	if body := servertest.ReadBody(t, resp); resp.Request.URL.Path != "/login" || !strings.Contains(body, `name="next" value="/search?name=Dupont"`) {
+		t.Fatalf("Expected the login form remembering the page, got %s", resp.Request.URL)
+	}
+	if resp := srv.Get("/api/contacts"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 from the API, got %d", resp.StatusCode)
+	}
+	if resp := srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}}); resp.Request.URL.Path != "/login" {
+		t.Errorf("Unauthenticated POST not sent to login, got %s", resp.Request.URL)
+	}
+
+	if resp := srv.Login("admin", "wrong password"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong password, got %d", resp.StatusCode)
+	}
+	resp = srv.PostForm("/login", url.Values{"user": {"admin"}, "password": {"correct horse"}, "next": {"https://evil.example/"}})
+	if resp.Request.URL.Host != strings.TrimPrefix(srv.URL, "http://") || resp.Request.URL.Path != "/" {
+		t.Errorf("Login redirected outside the site: %s", resp.Request.URL)
+	}
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	body := srv.GetBody("/")
+	if !strings.Contains(body, "Jean Dupont") || !strings.Contains(body, "Log out (admin)") {
+		t.Error("Logged-in home page incomplete")
+	}
+	if resp := srv.Get("/api/contacts"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200 from the API once logged in, got %d", resp.StatusCode)
+	}
+
+	srv.PostForm("/logout", nil)
+	if resp := srv.Get("/"); resp.Request.URL.Path != "/login" {
+		t.Error("Session still open after logout")
+	}
+}
+
+// TestBasePath tests a server mounted under a path prefix, as behind a reverse proxy
+func TestBasePath(t *testing.T) {
+	tmp := t.TempDir()
+	admin, err := settings.NewAdminCredentials("admin", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := servertest.StartWithConfig(t, server.Config{
+		DataFile:     filepath.Join(tmp, "contacts.json"),
+		Admin:        admin,
+		BasePath:     "contacts/",
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: -1,
+	})
+
+	// Redirects stay under the prefix, and so does the page after logging in
+	if resp := srv.Get("/history"); resp.Request.URL.Path != "/contacts/login" || resp.Request.URL.Query().Get("next") != "/history" {
+		t.Fatalf("Expected the login page under the prefix, got %s", resp.Request.URL)
+	}
+	if resp := srv.Login("admin", "correct horse"); resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/contacts/" {
+		t.Fatalf("Expected the home page under the prefix, got %d %s", resp.StatusCode, resp.Request.URL)
+	}
+	resp := srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	body := servertest.ReadBody(t, resp)
+	if resp.Request.URL.Path != "/contacts/" || !strings.Contains(body, "Jean Dupont") {
+		t.Fatalf("Expected the contact added, got %s", resp.Request.URL)
+	}
+	for _, link := range []string{`href="/contacts/static/style.css"`, `action="/contacts/add"`, `href="/contacts/history"`, `src="/contacts/static/app.js"`} {
+		if !strings.Contains(body, link) {
+			t.Errorf("Link %s missing from the home page", link)
+		}
+	}
+	if resp := srv.Get("/static/style.css"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the stylesheet under the prefix, got %d", resp.StatusCode)
+	}
+	if body := srv.GetBody("/api/contacts"); !strings.Contains(body, `"self": "/contacts/api/contacts?`) {
+		t.Errorf("API links miss the prefix: %s", body)
+	}
+
+	root := strings.TrimSuffix(srv.URL, "/contacts")
+	if resp, err := srv.Client.Get(root + "/contacts"); err != nil || resp.Request.URL.Path != "/contacts/" {
+		t.Errorf("Expected the prefix alone to lead to the home page, got %v (%v)", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+	if resp, err := srv.Client.Get(root + "/"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 outside of the prefix, got %v (%v)", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+}
+
+// TestSync tests the two-way exchange with a protected instance, newest version winning
+func TestSync(t *testing.T) {
+	tmp := t.TempDir()
+	admin, err := settings.NewAdminCredentials("admin", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := servertest.StartWithConfig(t, server.Config{
+		DataFile:    filepath.Join(tmp, "contacts.json"),
+		HistoryFile: filepath.Join(tmp, "history.jsonl"),
+		Admin:       admin,
+	})
+	srv.Login("admin", "correct horse")
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+
+	// Here, Dupont was last changed long ago and Martin is new
+	dir := annuaire.NewDirectory()
+	dir.ReplaceContacts([]annuaire.Contact{
+		{ID: 1, Name: "Dupont", First: "Jean", Phone: "0123456789", Tags: []string{"old"}, UpdatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, Name: "Martin", First: "Paul", Phone: "0600000000", UpdatedAt: time.Now().UTC()},
+	})
+
+	remote, _ := url.Parse(srv.URL)
+	if _, err := server.Sync(context.Background(), dir, remote.String()); err == nil {
+		t.Error("Expected an error without credentials")
+	}
+	remote.User = url.UserPassword("admin", "wrong password")
+	if _, err := server.Sync(context.Background(), dir, remote.String()); err == nil || !strings.Contains(err.Error(), "login refused") {
+		t.Errorf("Expected a refused login, got %v", err)
+	}
+
+	remote.User = url.UserPassword("admin", "correct horse")
+	report, err := server.Sync(context.Background(), dir, remote.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Pulled.Updated != 1 || report.Pushed.Added != 1 || report.Pushed.Updated != 0 {
+		t.Errorf("Unexpected report: pulled %s, pushed %s", report.Pulled, report.Pushed)
+	}
+	if dupont, _ := dir.SearchContact("Dupont"); len(dupont.Tags) != 0 {
+		t.Errorf("The newer remote Dupont should win, got %+v", dupont)
+	}
+	theirs, err := annuaire.ReadJSON(strings.NewReader(srv.GetBody("/api/sync")))
+	if err != nil || len(theirs) != 2 || !dir.Diff(theirs).IsZero() {
+		t.Errorf("Both instances should hold the same contacts: %v %+v", err, theirs)
+	}
+}
+
+// TestViewerRole tests that viewer sessions can read but not modify
+func TestViewerRole(t *testing.T) {
+	tmp := t.TempDir()
+	config := settings.DefaultBootstrap(filepath.Join(tmp, "contacts.json"))
+	admin, err := settings.NewAdminCredentials("admin", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Admin = admin
+	if err := config.SetViewer("admin", "battery staple"); err == nil {
+		t.Error("A viewer must not take the administrator login")
+	}
+	if err := config.SetViewer("team", "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	bootstrapFile := filepath.Join(tmp, "config.json")
+	if err := config.Save(bootstrapFile); err != nil {
+		t.Fatal(err)
+	}
+	srv := servertest.StartWithConfig(t, server.Config{
+		DataFile:      filepath.Join(tmp, "contacts.json"),
+		HistoryFile:   filepath.Join(tmp, "history.jsonl"),
+		BootstrapFile: bootstrapFile,
+	})
+	srv.Login("admin", "correct horse")
+	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+	srv.PostForm("/logout", nil)
+
+	srv.Login("team", "battery staple")
+	body := srv.GetBody("/")
+	if !strings.Contains(body, "Jean Dupont") || strings.Contains(body, `action="/add"`) || strings.Contains(body, `href="/clear"`) {
+		t.Error("Viewer home page should list contacts without the modification forms")
+	}
+	if body := srv.GetBody("/search?name=Dupont"); !strings.Contains(body, "Jean Dupont") {
+		t.Error("Viewer cannot search")
+	}
+	for _, path := range []string{"/add", "/delete", "/clear", "/import/vcard"} {
+		if resp := srv.PostForm(path, url.Values{"name": {"Dupont"}, "first": {"Paul"}, "phone": {"0600000000"}}); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 for a viewer POST %s, got %d", path, resp.StatusCode)
+		}
+	}
+	if resp := srv.Get("/clear"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for a viewer GET /clear, got %d", resp.StatusCode)
+	}
+	if resp := srv.Get("/api/contacts"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Viewer cannot use the read-only API: %d", resp.StatusCode)
+	}
+	if body := srv.GetBody("/"); !strings.Contains(body, "Jean Dupont") {
+		t.Error("Viewer request modified the directory")
+	}
+}
+
+// TestServeTemplatesDir tests that custom templates are checked at startup
+func TestServeTemplatesDir(t *testing.T) {
+	tmp := t.TempDir()
+	custom := filepath.Join(tmp, "custom")
+	if err := os.MkdirAll(filepath.Join(custom, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(custom, "templates", "login.html"), []byte(`<p>Company login</p>`), 0644)
+
+	admin, err := settings.NewAdminCredentials("admin", "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := servertest.StartWithConfig(t, server.Config{
+		DataFile:     filepath.Join(tmp, "contacts.json"),
+		HistoryFile:  filepath.Join(tmp, "history.jsonl"),
+		Admin:        admin,
+		TemplatesDir: custom,
+	})
+	if body := srv.GetBody("/"); body != "<p>Company login</p>" {
+		t.Errorf("Custom login page not used: %q", body)
+	}
+
+	for name, content := range map[string]string{"broken": `{{if}}`, "missing": ""} {
+		dir := filepath.Join(tmp, name)
+		if content != "" {
+			os.MkdirAll(filepath.Join(dir, "templates"), 0755)
+			os.WriteFile(filepath.Join(dir, "templates", "home.html"), []byte(content), 0644)
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := server.Serve(context.Background(), ln, server.Config{TemplatesDir: dir}); err == nil {
+			t.Errorf("Expected a startup error for a %s templates directory", name)
+		}
+	}
+}
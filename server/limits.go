@@ -0,0 +1,69 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxUploadSize bounds request bodies (imported files, photos, sync
+// exports) unless WithMaxUploadSize or Config.MaxUploadSize says otherwise
+const DefaultMaxUploadSize = 10 << 20 // 10 MB
+
+// defaultMaxHeaderBytes bounds the request headers when Config leaves it at
+// zero; net/http would accept 1 MB, far more than a browser sends
+const defaultMaxHeaderBytes = 64 << 10 // 64 KB
+
+/**
+ * WithMaxUploadSize sets the largest request body the server accepts
+ *
+ * @param {int64} size - Limit in bytes (zero or less keeps DefaultMaxUploadSize)
+ * @return {Option} Option to pass to NewServer
+ *
+ * Larger requests are answered 413 Request Entity Too Large; the import
+ * form, photo uploads and sync pushes are all bound by it
+ */
+func WithMaxUploadSize(size int64) Option {
+	return func(s *Server) {
+		if size > 0 {
+			s.maxUploadSize = size
+		}
+	}
+}
+
+/**
+ * limitBody refuses request bodies larger than a limit
+ *
+ * @param {http.Handler} next - Handler reading the bodies
+ * @param {int64} limit - Largest body, in bytes
+ * @return {http.Handler} Handler answering 413 when the announced length is
+ *   over the limit, and failing reads past it for bodies of unknown length
+ */
+func limitBody(next http.Handler, limit int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			message := fmt.Sprintf("Request too large (at most %s)", formatSize(limit))
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeAPIError(w, http.StatusRequestEntityTooLarge, message)
+			} else {
+				http.Error(w, message, http.StatusRequestEntityTooLarge)
+			}
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// formatSize writes a byte count in the largest whole unit, e.g. "10 MB"
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20 && size%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", size>>20)
+	case size >= 1<<10 && size%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", size>>10)
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
@@ -0,0 +1,414 @@
+package server
+
+import (
+	"net/http"
+	"tp1/annuaire"
+)
+
+// openAPIVersion is the version of the JSON API described by openAPIDocument
+const openAPIVersion = "1.0.0"
+
+// swaggerUIVersion pins the Swagger UI assets loaded by the documentation page
+const swaggerUIVersion = "5.17.14"
+
+// apiDocsData is the data passed to apiDocsTemplate
+type apiDocsData struct {
+	Version string // Swagger UI release
+	SpecURL string // Location of the OpenAPI document
+}
+
+/**
+ * openAPIDocument describes the JSON API in OpenAPI 3
+ *
+ * @return {map[string]any} Document ready to be encoded as JSON
+ *
+ * It is built from the same constants as the handlers (sort keys, page
+ * sizes), so the specification cannot drift from the implementation
+ */
+func openAPIDocument() map[string]any {
+	str := map[string]any{"type": "string"}
+	contact := map[string]any{
+		"type":     "object",
+		"required": []string{"name", "first", "phone"},
+		"properties": map[string]any{
+			"id":       map[string]any{"type": "integer", "description": "Stable identifier"},
+			"name":     str,
+			"first":    str,
+			"phone":    map[string]any{"type": "string", "description": "Masked when phone is a sensitive field"},
+			"birthday": map[string]any{"type": "string", "description": `"YYYY-MM-DD", or "--MM-DD" when the year is unknown`},
+			"address": map[string]any{"type": "object", "description": "Postal address, every part optional; only city and country remain when address is a sensitive field",
+				"properties": map[string]any{"street": str, "city": str, "zip": str, "country": str}},
+			"code":      map[string]any{"type": "string", "description": "Speed-dial code"},
+			"verify_by": map[string]any{"type": "string", "format": "date", "description": "Date by which the contact must be confirmed again"},
+			"tags":      map[string]any{"type": "array", "items": str, "description": "Lower-cased labels of letters, digits, - and _"},
+			"custom": map[string]any{"type": "object", "additionalProperties": str, "maxProperties": 20,
+				"description": "Free-form fields by name, e.g. {\"badge number\": \"1234\"}; values are masked when custom is a sensitive field"},
+			"updated_at": map[string]any{"type": "string", "format": "date-time", "readOnly": true, "description": "Last change, used by sync; absent when unknown"},
+			"source":     map[string]any{"type": "string", "readOnly": true, "description": "File the contact was last imported from; absent when typed in"},
+		},
+	}
+	link := map[string]any{"type": "string", "format": "uri-reference"}
+	page := map[string]any{
+		"type":     "object",
+		"required": []string{"total", "page", "per_page", "pages", "truncated", "contacts", "links"},
+		"properties": map[string]any{
+			"total":     map[string]any{"type": "integer", "description": "Contacts matching q, on every page"},
+			"page":      map[string]any{"type": "integer", "minimum": 1},
+			"per_page":  map[string]any{"type": "integer", "minimum": 1, "maximum": maxPerPage},
+			"pages":     map[string]any{"type": "integer", "description": "0 when nothing matches"},
+			"truncated": map[string]any{"type": "boolean", "description": "The search hit its result or time limit; total only counts the matches kept"},
+			"contacts":  map[string]any{"type": "array", "items": ref("Contact")},
+			"links": map[string]any{
+				"type":     "object",
+				"required": []string{"self", "first", "last"},
+				"properties": map[string]any{
+					"self": link, "first": link, "last": link,
+					"prev": map[string]any{"type": "string", "format": "uri-reference", "description": "Absent on the first page"},
+					"next": map[string]any{"type": "string", "format": "uri-reference", "description": "Absent on the last page"},
+				},
+			},
+		},
+	}
+	count := map[string]any{"type": "integer", "minimum": 0}
+	stats := map[string]any{
+		"type":     "object",
+		"required": []string{"total", "with_birthday", "with_code", "with_photo", "tagged", "initials", "prefixes", "tags", "duplicates"},
+		"properties": map[string]any{
+			"total":         count,
+			"with_birthday": count,
+			"with_code":     count,
+			"with_photo":    count,
+			"tagged":        map[string]any{"type": "integer", "minimum": 0, "description": "Contacts with at least one tag"},
+			"initials": map[string]any{"type": "array", "description": "Contacts per initial of the last name, A to Z then #", "items": map[string]any{
+				"type": "object", "properties": map[string]any{"initial": str, "count": count},
+			}},
+			"prefixes": map[string]any{"type": "array", "description": "Most common phone prefixes (first two digits), most frequent first", "items": map[string]any{
+				"type": "object", "properties": map[string]any{"prefix": str, "count": count},
+			}},
+			"tags": map[string]any{"type": "array", "description": "Contacts per tag, most frequent first", "items": map[string]any{
+				"type": "object", "properties": map[string]any{"tag": str, "count": count},
+			}},
+			"duplicates": map[string]any{
+				"type":        "object",
+				"description": "Contacts sharing their first and last name, or their phone, with another one",
+				"properties":  map[string]any{"same_name": count, "same_phone": count},
+			},
+			"modified": map[string]any{"type": "string", "format": "date-time", "description": "Last write of the data file; absent when never saved"},
+		},
+	}
+	query := func(name, description string, schema map[string]any) map[string]any {
+		return map[string]any{"name": name, "in": "query", "required": false, "description": description, "schema": schema}
+	}
+	errorResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     map[string]any{problemContentType: map[string]any{"schema": ref("Problem")}},
+		}
+	}
+	contactResponse := func(description string) map[string]any {
+		return map[string]any{
+			"description": description,
+			"content":     map[string]any{"application/json": map[string]any{"schema": ref("Contact")}},
+		}
+	}
+	contactBody := map[string]any{
+		"required": true,
+		"content":  map[string]any{"application/json": map[string]any{"schema": ref("Contact")}},
+	}
+	fieldErrors := map[string]any{
+		"type": "array",
+		"items": map[string]any{
+			"type":       "object",
+			"required":   []string{"field", "message"},
+			"properties": map[string]any{"field": str, "message": str},
+		},
+	}
+	idParam := map[string]any{"name": "id", "in": "path", "required": true, "schema": map[string]any{"type": "integer"}}
+	header := func(name, description string) map[string]any {
+		return map[string]any{"name": name, "in": "header", "description": description, "schema": str}
+	}
+	ifNoneMatch := header("If-None-Match", "ETag of a cached copy, answered with 304 while it is current")
+	ifMatch := header("If-Match", "ETag of the contact as last read; any other current ETag is answered with 412")
+	notModified := map[string]any{"description": "The cached copy is current"}
+	changed := errorResponse("The contact changed since it was read (If-Match)")
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Go Directory API",
+			"version":     openAPIVersion,
+			"description": "Access to the contact directory. Sensitive fields are masked as in shares. Changes need the X-CSRF-Token header matching the tp1_csrf cookie.",
+		},
+		"paths": map[string]any{
+			"/api/contacts": map[string]any{
+				"get": map[string]any{
+					"operationId": "listContacts",
+					"summary":     "List contacts, one page at a time",
+					"parameters": []any{
+						query("q", "Case-insensitive text contained in the name, first name or phone", str),
+						query("name", "Start of the last name, case-insensitive; every search parameter given must match", str),
+						query("first", "Start of the first name, case-insensitive", str),
+						query("phone", "Start of the phone number, separators ignored", str),
+						query("tag", "Tag carried by the contact", str),
+						query("sort", "Sort key; created is the order of addition", map[string]any{"type": "string", "enum": annuaire.SortKeys, "default": annuaire.SortByName}),
+						query("order", "Sort direction", map[string]any{"type": "string", "enum": []string{"asc", "desc"}, "default": "asc"}),
+						query("page", "Page number; a page past the end is empty", map[string]any{"type": "integer", "minimum": 1, "default": 1}),
+						query("per_page", "Page size", map[string]any{"type": "integer", "minimum": 1, "maximum": maxPerPage, "default": defaultPerPage}),
+						ifNoneMatch,
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "One page of contacts",
+							"content":     map[string]any{"application/json": map[string]any{"schema": ref("ContactPage")}},
+						},
+						"304": notModified,
+						"400": errorResponse("Invalid parameter"),
+						"405": errorResponse("Method other than GET, HEAD or POST"),
+					},
+				},
+				"post": map[string]any{
+					"operationId": "createContact",
+					"summary":     "Add a contact; id and photo are ignored",
+					"requestBody": contactBody,
+					"responses": map[string]any{
+						"201": contactResponse("Contact added, its address is in Location"),
+						"400": errorResponse("Body is not a JSON contact"),
+						"409": errorResponse("Name and phone pair or speed-dial code already used"),
+						"422": errorResponse("Invalid fields, listed in errors"),
+					},
+				},
+			},
+			"/api/contacts/batch": map[string]any{
+				"post": map[string]any{
+					"operationId": "createContacts",
+					"summary":     "Add many contacts, keeping the valid ones even when others are refused",
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{"application/json": map[string]any{
+							"schema": map[string]any{"type": "array", "maxItems": maxBatchSize, "items": ref("Contact")},
+						}},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "One result per contact, in request order",
+							"content":     map[string]any{"application/json": map[string]any{"schema": ref("BatchResult")}},
+						},
+						"400": errorResponse("Body is not an array of contacts, or too many contacts"),
+					},
+				},
+			},
+			"/api/contacts/tags": map[string]any{
+				"post": map[string]any{
+					"operationId": "tagContacts",
+					"summary":     "Add or remove a tag on every contact matching a filter",
+					"requestBody": map[string]any{
+						"required": true,
+						"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"filter": map[string]any{"type": "string", "description": "Text contained in the name, first name or phone, case-insensitive; empty matches every contact"},
+								"tag":    map[string]any{"type": "string", "description": "Tag the contacts must already carry"},
+								"add":    map[string]any{"type": "string", "description": "Tag to add (exactly one of add and remove)"},
+								"remove": map[string]any{"type": "string", "description": "Tag to remove"},
+							},
+						}}},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Number of contacts matched, and of those whose tags changed",
+							"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+								"type":     "object",
+								"required": []string{"matched", "modified"},
+								"properties": map[string]any{
+									"matched":  map[string]any{"type": "integer"},
+									"modified": map[string]any{"type": "integer"},
+								},
+							}}},
+						},
+						"400": errorResponse("Body is not a tag change, or neither add nor remove is given"),
+						"422": errorResponse("Invalid tag, or a contact would have too many tags; nothing changed"),
+					},
+				},
+			},
+			"/api/suggest": map[string]any{
+				"get": map[string]any{
+					"operationId": "suggestNames",
+					"summary":     "Complete a last or first name; nothing is suggested when names are sensitive fields",
+					"parameters": []any{
+						query("q", "Start of the name, case-insensitive", str),
+						query("limit", "Maximum number of names", map[string]any{"type": "integer", "minimum": 1, "maximum": maxSuggestions, "default": defaultSuggestions}),
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Distinct names, sorted",
+							"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+								"type":       "object",
+								"required":   []string{"names"},
+								"properties": map[string]any{"names": map[string]any{"type": "array", "items": str}},
+							}}},
+						},
+						"400": errorResponse("Invalid limit"),
+					},
+				},
+			},
+			"/api/stats": map[string]any{
+				"get": map[string]any{
+					"operationId": "getStats",
+					"summary":     "Summarize the directory, like the stats command",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Totals, contacts per initial, common phone prefixes and possible duplicates",
+							"content":     map[string]any{"application/json": map[string]any{"schema": ref("Stats")}},
+						},
+					},
+				},
+			},
+			"/api/sync": map[string]any{
+				"get": map[string]any{
+					"operationId": "pullContacts",
+					"summary":     "Export every contact, unmasked, for another instance",
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "Versioned JSON export",
+							"content":     map[string]any{"application/json": map[string]any{"schema": ref("Export")}},
+						},
+						"403": errorResponse("Sync is disabled while sensitive fields are configured"),
+					},
+				},
+				"post": map[string]any{
+					"operationId": "pushContacts",
+					"summary":     "Merge another instance's export, keeping the version of each contact changed last; nothing is deleted",
+					"requestBody": map[string]any{
+						"required": true,
+						"content":  map[string]any{"application/json": map[string]any{"schema": ref("Export")}},
+					},
+					"responses": map[string]any{
+						"200": map[string]any{
+							"description": "What the merge did",
+							"content": map[string]any{"application/json": map[string]any{"schema": map[string]any{
+								"type":     "object",
+								"required": []string{"added", "updated", "skipped"},
+								"properties": map[string]any{
+									"added":   map[string]any{"type": "integer"},
+									"updated": map[string]any{"type": "integer", "description": "Contacts changed last in the pushed export"},
+									"skipped": map[string]any{"type": "integer", "description": "Differing contacts whose current version is newer"},
+								},
+							}}},
+						},
+						"400": errorResponse("Body is not a JSON export"),
+						"403": errorResponse("Sync is disabled while sensitive fields are configured"),
+					},
+				},
+			},
+			"/api/contacts/{id}": map[string]any{
+				"get": map[string]any{
+					"operationId": "getContact",
+					"parameters":  []any{idParam, ifNoneMatch},
+					"summary":     "Read one contact",
+					"responses": map[string]any{
+						"200": contactResponse("The contact, with its ETag"),
+						"304": notModified,
+						"404": errorResponse("No contact has this ID"),
+					},
+				},
+				"patch": map[string]any{
+					"operationId": "updateContact",
+					"parameters":  []any{idParam, ifMatch},
+					"summary":     "Change the fields present in the body, keeping the others",
+					"requestBody": contactBody,
+					"responses": map[string]any{
+						"200": contactResponse("The updated contact, with its new ETag"),
+						"400": errorResponse("Body is not a JSON contact"),
+						"404": errorResponse("No contact has this ID"),
+						"409": errorResponse("Name and phone pair or speed-dial code already used"),
+						"412": changed,
+						"422": errorResponse("Invalid fields, listed in errors"),
+					},
+				},
+				"delete": map[string]any{
+					"operationId": "deleteContact",
+					"parameters":  []any{idParam, ifMatch},
+					"summary":     "Delete one contact",
+					"responses": map[string]any{
+						"204": map[string]any{"description": "Contact deleted"},
+						"404": errorResponse("No contact has this ID"),
+						"412": changed,
+					},
+				},
+			},
+		},
+		"components": map[string]any{
+			"schemas": map[string]any{
+				"Contact":     contact,
+				"ContactPage": page,
+				"Export": map[string]any{
+					"type":     "object",
+					"required": []string{"version", "exported_at", "contacts"},
+					"properties": map[string]any{
+						"version":     map[string]any{"type": "integer", "maximum": annuaire.FormatVersion},
+						"exported_at": map[string]any{"type": "string", "format": "date-time"},
+						"contacts":    map[string]any{"type": "array", "items": ref("Contact")},
+					},
+				},
+				"Stats": stats,
+				"BatchResult": map[string]any{
+					"type":     "object",
+					"required": []string{"added", "duplicates", "invalid", "results"},
+					"properties": map[string]any{
+						"added":      map[string]any{"type": "integer"},
+						"duplicates": map[string]any{"type": "integer"},
+						"invalid":    map[string]any{"type": "integer"},
+						"results": map[string]any{
+							"type": "array",
+							"items": map[string]any{
+								"type":     "object",
+								"required": []string{"index", "status"},
+								"properties": map[string]any{
+									"index":   map[string]any{"type": "integer", "description": "Position in the request, starting at 1"},
+									"status":  map[string]any{"type": "string", "enum": []string{annuaire.AddAdded, annuaire.AddDuplicate, annuaire.AddInvalid}},
+									"contact": ref("Contact"),
+									"detail":  map[string]any{"type": "string", "description": "Why the contact was refused"},
+									"errors":  fieldErrors,
+								},
+							},
+						},
+					},
+				},
+				"Problem": map[string]any{
+					"type":        "object",
+					"description": "RFC 7807 problem details",
+					"required":    []string{"type", "title", "status"},
+					"properties": map[string]any{
+						"type":   str,
+						"title":  str,
+						"status": map[string]any{"type": "integer"},
+						"detail": str,
+						"errors": fieldErrors,
+					},
+				},
+			},
+		},
+	}
+}
+
+// ref points to a schema of the components section
+func ref(schema string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + schema}
+}
+
+// handleOpenAPI serves the OpenAPI document of the JSON API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, openAPIDocument())
+}
+
+// handleAPIDocs serves the Swagger UI page, loading the document relative to
+// itself so it also works when the server is mounted under a prefix
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	data := apiDocsData{Version: swaggerUIVersion, SpecURL: "openapi.json"}
+	renderTemplate(w, s.templates, "apidocs.html", http.StatusOK, data)
+}
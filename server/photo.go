@@ -0,0 +1,90 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"tp1/annuaire"
+)
+
+// maxPhotoSize bounds the uploaded avatar files, before they are scaled down
+const maxPhotoSize = 5 << 20 // 5 MB
+
+/**
+ * handlePhoto serves and changes the avatar of a contact
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - Request on /contact/{id}/photo: GET returns the
+ *   JPEG (404 without photo), POST stores the "photo" file field, or removes
+ *   the photo when "remove" is set
+ *
+ * The answer carries an ETag computed from the image and must be revalidated,
+ * so a changed photo shows up immediately while an unchanged one costs a 304
+ */
+func (s *Server) handlePhoto(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		setFlash(w, r, s.changePhoto(r, id))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contact, err := s.dir.ResolveContact("#" + strconv.Itoa(id))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	photo, err := contact.PhotoJPEG()
+	if err != nil || photo == nil {
+		http.NotFound(w, r)
+		return
+	}
+	sum := sha256.Sum256(photo)
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(photo))
+}
+
+// changePhoto applies a photo form and returns the message to display
+func (s *Server) changePhoto(r *http.Request, id int) Flash {
+	if r.FormValue("remove") != "" {
+		if err := s.traced(r).SetPhoto(id, nil); err != nil {
+			return Flash{Type: flashError, Text: fmt.Sprintf("Error: %v", err)}
+		}
+		return Flash{Type: flashSuccess, Text: "Photo removed"}
+	}
+
+	file, header, err := r.FormFile("photo")
+	if err != nil {
+		return Flash{Type: flashError, Text: fmt.Sprintf("File retrieval error: %v", err)}
+	}
+	defer file.Close()
+	if header.Size > maxPhotoSize {
+		return Flash{Type: flashError, Text: fmt.Sprintf("Error: photos are limited to %d MB", maxPhotoSize>>20)}
+	}
+
+	photo, err := annuaire.NormalizePhoto(io.LimitReader(file, maxPhotoSize))
+	if err != nil {
+		return Flash{Type: flashError, Text: fmt.Sprintf("Error: %v", err)}
+	}
+	if err := s.traced(r).SetPhoto(id, photo); err != nil {
+		return Flash{Type: flashError, Text: fmt.Sprintf("Error: %v", err)}
+	}
+	return Flash{Type: flashSuccess, Text: "Photo updated"}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"net/http"
+	"time"
+	"tp1/annuaire"
+)
+
+// printData is the data passed to print.html
+type printData struct {
+	Groups    []annuaire.ContactGroup // Contacts by initial of the last name, masked
+	Count     int                     // Contacts on the page
+	Tag       string                  // Tag the list is limited to, "" for every contact
+	PrintedAt string                  // Date and time printed in the page header
+	Masked    []string                // Names of the masked fields
+}
+
+/**
+ * handlePrint renders the contact list laid out for paper
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer for HTML content
+ * @param {*http.Request} r - HTTP GET request; ?tag=work prints only the
+ *   contacts with that tag
+ *
+ * Contacts are sorted by name and grouped under the initial of their last
+ * name, like a paper address book; a group is not split across pages when it
+ * fits on one. Fields marked as sensitive are masked as on the share page,
+ * since a printed copy stays on a desk. The browser's "Save as PDF" turns the
+ * page into a PDF
+ */
+func (s *Server) handlePrint(w http.ResponseWriter, r *http.Request) {
+	cfg := s.loadSettings()
+	tag := r.FormValue("tag")
+	contacts := s.dir.ListContacts()
+	annuaire.SortContactsBy(contacts, annuaire.SortByName, false)
+
+	kept := contacts[:0]
+	for _, contact := range contacts {
+		if tag == "" || contact.HasTag(tag) {
+			kept = append(kept, annuaire.MaskContact(contact, cfg.SensitiveFields))
+		}
+	}
+
+	renderTemplate(w, s.templates, "print.html", http.StatusOK, printData{
+		Groups:    annuaire.GroupByInitial(kept),
+		Count:     len(kept),
+		Tag:       tag,
+		PrintedAt: time.Now().Format("2006-01-02 15:04"),
+		Masked:    cfg.SensitiveFields,
+	})
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"tp1/annuaire"
+)
+
+// problemContentType is the media type of the API error bodies (RFC 7807)
+const problemContentType = "application/problem+json"
+
+// problem is the body of every JSON API error, in the RFC 7807 format
+type problem struct {
+	Type   string         `json:"type"`             // Always "about:blank": the status says it all
+	Title  string         `json:"title"`            // Text of the status, e.g. "Not Found"
+	Status int            `json:"status"`           // Same as the HTTP status
+	Detail string         `json:"detail,omitempty"` // What went wrong with this request
+	Errors []fieldProblem `json:"errors,omitempty"` // Invalid fields of the submitted contact
+}
+
+// fieldProblem is one invalid field of a problem
+type fieldProblem struct {
+	Field   string `json:"field"`   // JSON name of the field, e.g. "birthday"
+	Message string `json:"message"` // Why the value was refused
+}
+
+// writeAPIError sends a problem with the given status and detail
+func writeAPIError(w http.ResponseWriter, status int, detail string) {
+	writeProblem(w, problem{Status: status, Detail: detail})
+}
+
+/**
+ * writeAPIProblem answers an API request refused by the directory
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {error} err - Error returned by the annuaire package
+ *
+ * The status is the one of errorStatus, except for invalid input: the request
+ * itself was well-formed JSON, so it is answered with 422 and one entry of
+ * "errors" per *annuaire.FieldError found in err
+ */
+func writeAPIProblem(w http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	if status == http.StatusBadRequest {
+		status = http.StatusUnprocessableEntity
+	}
+	p := problem{Status: status}
+	p.Detail, p.Errors = describeError(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("api request failed", "error", err)
+		p.Detail = "internal error" // Storage details are for the logs
+	}
+	writeProblem(w, p)
+}
+
+// describeError returns the detail and field errors reporting err; several
+// invalid fields are summarized instead of joining their messages
+func describeError(err error) (string, []fieldProblem) {
+	fields := fieldProblems(err)
+	if len(fields) > 1 {
+		return "invalid contact fields", fields
+	}
+	return err.Error(), fields
+}
+
+// writeProblem fills in the type and title of a problem and sends it
+func writeProblem(w http.ResponseWriter, p problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	encodeJSON(w, problemContentType, p.Status, p)
+}
+
+// fieldProblems lists the field errors found in err, following errors.Join trees
+func fieldProblems(err error) []fieldProblem {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var problems []fieldProblem
+		for _, e := range joined.Unwrap() {
+			problems = append(problems, fieldProblems(e)...)
+		}
+		return problems
+	}
+	var fieldErr *annuaire.FieldError
+	if errors.As(err, &fieldErr) {
+		return []fieldProblem{{Field: fieldErr.Field, Message: fieldErr.Message}}
+	}
+	return nil
+}
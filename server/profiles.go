@@ -0,0 +1,69 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+)
+
+// profileCookieName remembers the address book chosen in the browser
+const profileCookieName = "tp1_profile"
+
+// Profile is one address book served by the web interface
+type Profile struct {
+	Name         string // Identifier shown in the selector, e.g. "work"
+	DataFile     string // Contacts file loaded at startup and flushed on shutdown
+	SettingsFile string // Settings of this profile (pinned contacts, sensitive fields)
+	HistoryFile  string // Audit log of this profile
+}
+
+/**
+ * WithProfiles shows the profile selector in the page header
+ *
+ * @param {string} current - Profile served by this Server
+ * @param {[]string} names - Every profile, in selector order
+ * @return {Option} Option to pass to NewServer
+ */
+func WithProfiles(current string, names []string) Option {
+	return func(s *Server) {
+		s.profile, s.profiles = current, names
+	}
+}
+
+// profileSwitcher routes each request to the Server of the chosen profile
+// The choice is a cookie set by /profile; the first profile is the default
+type profileSwitcher struct {
+	names    []string                // Profiles in selector order
+	handlers map[string]http.Handler // One Server per profile
+}
+
+/**
+ * ServeHTTP handles profile changes and forwards everything else
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - Request; GET /profile?name=work switches profile
+ *
+ * Switching only changes which address book the browser looks at, so it is a
+ * plain GET like a link; unknown names are ignored
+ */
+func (p *profileSwitcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/profile" {
+		if name := r.FormValue("name"); slices.Contains(p.names, name) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     profileCookieName,
+				Value:    name,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	name := p.names[0]
+	if cookie, err := r.Cookie(profileCookieName); err == nil && p.handlers[cookie.Value] != nil {
+		name = cookie.Value
+	}
+	p.handlers[name].ServeHTTP(w, r)
+}
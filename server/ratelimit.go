@@ -0,0 +1,121 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit bounds how many state-changing requests (POST, PUT, PATCH,
+// DELETE) a client IP address may send; the zero value disables the limit
+type RateLimit struct {
+	PerMinute int // Sustained requests per minute and per IP address
+	Burst     int // Requests allowed at once after a quiet period (at least 1)
+}
+
+// DefaultRateLimit leaves room for fast typing while stopping scripted floods
+var DefaultRateLimit = RateLimit{PerMinute: 60, Burst: 10}
+
+// rateLimitPruneInterval is how often buckets of quiet clients are forgotten
+const rateLimitPruneInterval = time.Minute
+
+// tokenBucket holds the remaining requests of one client
+type tokenBucket struct {
+	tokens float64   // Requests available, refilled over time up to the burst
+	last   time.Time // Time tokens was last updated
+}
+
+// rateLimiter keeps one token bucket per client IP address
+type rateLimiter struct {
+	limit RateLimit
+	now   func() time.Time // Clock, replaced in tests
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+// newRateLimiter creates a limiter, clamping the burst to at least one request
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	limit.Burst = max(limit.Burst, 1)
+	return &rateLimiter{limit: limit, now: time.Now, buckets: make(map[string]*tokenBucket)}
+}
+
+/**
+ * allow takes one token from the bucket of a client
+ *
+ * @param {string} key - Client IP address
+ * @return {bool} True if the request may proceed
+ * @return {time.Duration} When refused, time until the next token is available
+ */
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	perSecond := float64(l.limit.PerMinute) / 60
+	l.prune(now, perSecond)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(float64(l.limit.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*perSecond)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// prune forgets the clients whose bucket has refilled, since a new bucket
+// would be identical; it keeps memory bounded by the number of active clients
+func (l *rateLimiter) prune(now time.Time, perSecond float64) {
+	if now.Sub(l.lastPrune) < rateLimitPruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*perSecond >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+/**
+ * rateLimit limits the state-changing requests of each client IP address
+ *
+ * @param {http.Handler} next - Handler to protect
+ * @param {RateLimit} limit - Allowed requests; a zero PerMinute returns next unchanged
+ * @return {http.Handler} Handler answering 429 Too Many Requests with a
+ *   Retry-After header once a client exhausts its tokens
+ *
+ * Page views (GET, HEAD) are never limited. Clients are told apart by the
+ * address of the connection, so behind a reverse proxy all of them share one
+ * bucket and the limit should be enforced by the proxy instead
+ */
+func rateLimit(next http.Handler, limit RateLimit) http.Handler {
+	if limit.PerMinute <= 0 {
+		return next
+	}
+	limiter := newRateLimiter(limit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isStateChanging(r.Method) {
+			host := remoteHost(r.RemoteAddr)
+			if ok, wait := limiter.allow(host); !ok {
+				slog.Warn("rate limit exceeded", "remote", host, "method", r.Method, "path", r.URL.Path)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Too many requests, please retry later", http.StatusTooManyRequests)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"tp1/annuaire"
+)
+
+// reviewData is the data passed to reviewTemplate
+type reviewData struct {
+	Contacts  []annuaire.Contact // Contacts due for verification, most overdue first
+	Months    int                // Verification period from settings
+	Flash     *Flash             // Result of the previous confirmation, if any
+	CSRFToken string             // Token embedded in the forms
+}
+
+/**
+ * handleReview shows the verification queue and processes confirmations
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - GET to show the queue, POST with "id", "action" and "phone"
+ *
+ * POST actions:
+ * - confirm: the contact is still accurate and is due again in N months
+ * - update: the phone number is replaced, which also confirms the contact
+ */
+func (s *Server) handleReview(w http.ResponseWriter, r *http.Request) {
+	months := s.loadSettings().VerificationPeriod()
+
+	if r.Method == http.MethodPost {
+		setFlash(w, r, s.reviewContact(r, months))
+		http.Redirect(w, r, "/review", http.StatusSeeOther)
+		return
+	}
+
+	data := reviewData{
+		Contacts:  s.dir.NeedingVerification(time.Now()),
+		Months:    months,
+		Flash:     popFlash(w, r),
+		CSRFToken: csrfToken(r),
+	}
+	renderTemplate(w, s.templates, "review.html", http.StatusOK, data)
+}
+
+// reviewContact applies one review form and returns the message to display
+func (s *Server) reviewContact(r *http.Request, months int) Flash {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		return Flash{Type: flashError, Text: "Error: invalid contact ID"}
+	}
+	contact, err := s.dir.ResolveContact("#" + strconv.Itoa(id))
+	if err != nil {
+		return Flash{Type: flashError, Text: fmt.Sprintf("Error: %v", err)}
+	}
+
+	switch r.FormValue("action") {
+	case "confirm":
+	case "update":
+		contact.Phone = r.FormValue("phone")
+	default:
+		return Flash{Type: flashError, Text: "Error: unknown review action"}
+	}
+	contact.VerifyBy = annuaire.VerifyByDate(time.Now(), months)
+	if err := s.traced(r).SaveContact(contact); err != nil {
+		return Flash{Type: flashError, Text: fmt.Sprintf("Error: %v", err)}
+	}
+	return Flash{Type: flashSuccess, Text: fmt.Sprintf("%s %s confirmed until %s", contact.First, contact.Name, contact.VerifyBy)}
+}
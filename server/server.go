@@ -1,25 +1,134 @@
 package server
 
 import (
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 	"tp1/annuaire"
+	"tp1/settings"
 )
 
-// Global directory instance for managing contacts across all HTTP handlers
-// This singleton pattern allows all web requests to operate on the same contact data
-var dir *annuaire.Directory
+// Server serves the web interface for one contact directory
+// Each Server owns its routes, so several directories can be served
+// side by side or embedded in a larger application
+type Server struct {
+	dir           *annuaire.Directory // Directory shared by all HTTP handlers
+	settingsFile  string              // Settings file providing pinned contacts (empty: no settings)
+	mux           *http.ServeMux      // Routes of the web interface
+	cache         queryCache          // Query results of the current directory generation
+	profile       string              // Profile served, when several are (see WithProfiles)
+	profiles      []string            // Every profile offered by the header selector
+	bootstrapFile string              // Application configuration (see WithBootstrap; empty: no setup page)
+	dataFile      string              // Contacts file, proposed by the setup page and dated by /api/stats
+	assets        fs.FS               // Templates and static files (see WithTemplatesDir)
+	templates     *template.Template  // Page templates parsed from assets
+	imports       *importStore        // Uploaded files waiting for confirmation
+	clears        *clearStore         // Clears shown on /clear waiting for confirmation
+	basePath      string              // Path prefix the server is mounted under (see WithBasePath; empty: the root)
+	maxUploadSize int64               // Largest request body accepted (see WithMaxUploadSize)
+	cors          CORS                // Origins allowed to call the API (see WithCORS; none: same origin only)
+	etagKey       []byte              // Key signing the ETags of the API (see contactETag)
+}
+
+// Option customizes a Server created by NewServer
+type Option func(*Server)
+
+/**
+ * WithSettingsFile makes the server honor a settings file (pinned contacts,
+ * sensitive fields)
+ *
+ * @param {string} path - Settings file, read on every listing
+ * @return {Option} Option to pass to NewServer
+ */
+func WithSettingsFile(path string) Option {
+	return func(s *Server) {
+		s.settingsFile = path
+	}
+}
+
+/**
+ * NewServer creates the web interface handler for a directory
+ *
+ * @param {*annuaire.Directory} dir - Directory to display and modify
+ * @param {...Option} opts - Optional settings (see WithSettingsFile, WithBasePath)
+ * @return {http.Handler} Handler with its own mux, ready for http.Server or httptest
+ *
+ * The handler does not listen on any port and keeps no global state, so it
+ * can be mounted inside another application:
+ *
+ *   dir := annuaire.NewDirectory()
+ *   mux.Handle("/contacts/", server.NewServer(dir, server.WithBasePath("/contacts")))
+ */
+func NewServer(dir *annuaire.Directory, opts ...Option) http.Handler {
+	s := &Server{dir: dir, mux: http.NewServeMux(), assets: embeddedAssets, templates: defaultTemplates, imports: newImportStore(), clears: newClearStore(), maxUploadSize: DefaultMaxUploadSize, etagKey: newETagKey()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.basePath != "" {
+		prefixTemplates(s)
+	}
+
+	// Register HTTP route handlers for all web interface functionality
+	s.mux.HandleFunc("/", s.handleHome)                        // Main page with contact list and forms
+	s.mux.HandleFunc("/add", s.handleAdd)                      // POST: Add new contact
+	s.mux.HandleFunc("/search", s.handleSearch)                // GET: Search for contacts
+	s.mux.HandleFunc("/delete", s.handleDelete)                // POST: Delete contact
+	s.mux.HandleFunc("/export", s.handleExport)                // GET: Stream contacts as a JSON download
+	s.mux.HandleFunc("/import", s.handleImport)                // POST: Preview the import of an uploaded file
+	s.mux.HandleFunc("/import/confirm", s.handleImportConfirm) // POST: Apply or cancel a previewed import
+	s.mux.HandleFunc("/import/vcard", s.handleImportVCard)     // POST: Create contacts from pasted vCard text
+	s.mux.HandleFunc("/contact/{id}/photo", s.handlePhoto)     // GET: Avatar JPEG; POST: Upload or remove it
+	s.mux.HandleFunc("/clear", s.handleClear)                  // GET: Choose the contacts to clear (tag, source); POST: Clear them (token, confirm)
+	s.mux.HandleFunc("/share", s.handleShare)                  // GET: Read-only list with sensitive fields masked
+	s.mux.HandleFunc("/print", s.handlePrint)                  // GET: Alphabetical list for a paper copy (tag)
+	s.mux.HandleFunc("/board", s.handleBoard)                  // GET: Display-only grid cycling through the initials (letter, tag, refresh)
+	s.mux.HandleFunc("/history", s.handleHistory)              // GET: Audit log of modifications
+	s.mux.HandleFunc("/review", s.handleReview)                // GET/POST: Queue of contacts due for verification
+	s.mux.HandleFunc("/stats", s.handleStats)                  // GET: Dashboard with growth, tags, initials and recent activity
+	s.mux.HandleFunc("/api/contacts", s.handleAPIContacts)     // GET: Paginated JSON listing (q, sort, order, page, per_page); POST: Create
+	s.mux.HandleFunc("/api/contacts/{id}", s.handleAPIContact) // GET, PATCH, DELETE: One contact by ID
+	s.mux.HandleFunc("/api/contacts/batch", s.handleAPIBatch)  // POST: Create many contacts, one result each
+	s.mux.HandleFunc("/api/contacts/tags", s.handleAPITags)    // POST: Add or remove a tag on the contacts of a search
+	s.mux.HandleFunc("/api/suggest", s.handleAPISuggest)       // GET: Names starting with ?q=, for type-ahead
+	s.mux.HandleFunc("/api/stats", s.handleAPIStats)           // GET: Totals, initials, phone prefixes, duplicates
+	s.mux.HandleFunc("/api/sync", s.handleAPISync)             // GET: Full JSON export; POST: Merge another instance's export, newest wins
+	s.mux.HandleFunc("/api/openapi.json", s.handleOpenAPI)     // GET: OpenAPI 3 description of the JSON API
+	s.mux.HandleFunc("/api/docs", s.handleAPIDocs)             // GET: Swagger UI exploring /api/openapi.json
+	s.mux.HandleFunc("/setup", s.handleSetup)                  // GET/POST: First-run configuration (see WithBootstrap)
+	s.mux.Handle("/static/", http.FileServerFS(s.assets))      // GET: Stylesheet and scripts (see WithTemplatesDir)
+	return s
+}
+
+// ServeHTTP dispatches requests to the web interface routes, rejecting
+// state-changing requests that lack a valid CSRF token and oversized bodies
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mountAt(corsHandler(limitBody(csrfProtect(nameRoute(s.mux)), s.maxUploadSize), s.cors), s.basePath).ServeHTTP(w, r)
+}
 
 // Custom template functions for HTML rendering and data manipulation
 // These functions extend the default Go template functionality for better UI presentation
 var templateFuncs = template.FuncMap{
+	// tel builds a tel: link from a phone number and the configured region
+	"tel": telLink,
 	// substr extracts a substring and converts it to uppercase for avatar initials
 	"substr": func(s string, start, length int) string {
 		if start >= len(s) {
@@ -37,548 +146,6 @@ var templateFuncs = template.FuncMap{
 	},
 }
 
-// HTML template for the web interface
-const htmlTemplate = `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Go Directory - Web Interface</title>
-    <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/font-awesome/6.4.0/css/all.min.css">
-    <style>
-        * {
-            margin: 0;
-            padding: 0;
-            box-sizing: border-box;
-        }
-
-        body {
-            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            min-height: 100vh;
-            padding: 20px;
-        }
-
-        .container {
-            max-width: 1200px;
-            margin: 0 auto;
-            background: rgba(255, 255, 255, 0.95);
-            border-radius: 20px;
-            box-shadow: 0 20px 40px rgba(0, 0, 0, 0.1);
-            backdrop-filter: blur(10px);
-            overflow: hidden;
-        }
-
-        .header {
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: white;
-            padding: 30px;
-            text-align: center;
-            position: relative;
-        }
-
-        .header::after {
-            content: '';
-            position: absolute;
-            bottom: 0;
-            left: 0;
-            right: 0;
-            height: 4px;
-            background: linear-gradient(90deg, #ff6b6b, #4ecdc4, #45b7d1);
-        }
-
-        .header h1 {
-            font-size: 2.5rem;
-            font-weight: 300;
-            margin-bottom: 10px;
-        }
-
-        .header .subtitle {
-            font-size: 1.1rem;
-            opacity: 0.9;
-        }
-
-        .stats-card {
-            background: linear-gradient(135deg, #ff6b6b 0%, #ee5a52 100%);
-            color: white;
-            margin: 20px;
-            padding: 20px;
-            border-radius: 15px;
-            text-align: center;
-            box-shadow: 0 10px 30px rgba(255, 107, 107, 0.3);
-        }
-
-        .stats-card i {
-            font-size: 2rem;
-            margin-bottom: 10px;
-        }
-
-        .stats-number {
-            font-size: 2.5rem;
-            font-weight: bold;
-            margin: 10px 0;
-        }
-
-        .main-content {
-            padding: 30px;
-            display: grid;
-            grid-template-columns: 1fr 1fr;
-            gap: 30px;
-        }
-
-        .section-card {
-            background: white;
-            border-radius: 15px;
-            padding: 25px;
-            box-shadow: 0 10px 30px rgba(0, 0, 0, 0.08);
-            border: 1px solid rgba(0, 0, 0, 0.05);
-            transition: transform 0.3s ease, box-shadow 0.3s ease;
-        }
-
-        .section-card:hover {
-            transform: translateY(-5px);
-            box-shadow: 0 20px 40px rgba(0, 0, 0, 0.12);
-        }
-
-        .section-title {
-            display: flex;
-            align-items: center;
-            font-size: 1.4rem;
-            font-weight: 600;
-            color: #333;
-            margin-bottom: 20px;
-            padding-bottom: 10px;
-            border-bottom: 2px solid #f0f0f0;
-        }
-
-        .section-title i {
-            margin-right: 10px;
-            color: #667eea;
-        }
-
-        .form-group {
-            margin-bottom: 20px;
-        }
-
-        .input-group {
-            position: relative;
-            margin-bottom: 15px;
-        }
-
-        .input-group i {
-            position: absolute;
-            left: 15px;
-            top: 50%;
-            transform: translateY(-50%);
-            color: #999;
-        }
-
-        input[type="text"], input[type="file"] {
-            width: 100%;
-            padding: 15px 15px 15px 45px;
-            border: 2px solid #e0e0e0;
-            border-radius: 10px;
-            font-size: 1rem;
-            transition: border-color 0.3s ease, box-shadow 0.3s ease;
-        }
-
-        input[type="text"]:focus, input[type="file"]:focus {
-            outline: none;
-            border-color: #667eea;
-            box-shadow: 0 0 0 3px rgba(102, 126, 234, 0.1);
-        }
-
-        .btn {
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            color: white;
-            border: none;
-            padding: 12px 25px;
-            border-radius: 10px;
-            font-size: 1rem;
-            font-weight: 600;
-            cursor: pointer;
-            transition: all 0.3s ease;
-            display: inline-flex;
-            align-items: center;
-            gap: 8px;
-            text-decoration: none;
-        }
-
-        .btn:hover {
-            transform: translateY(-2px);
-            box-shadow: 0 10px 25px rgba(102, 126, 234, 0.3);
-        }
-
-        .btn-success {
-            background: linear-gradient(135deg, #4ecdc4 0%, #44a08d 100%);
-        }
-
-        .btn-success:hover {
-            box-shadow: 0 10px 25px rgba(78, 205, 196, 0.3);
-        }
-
-        .btn-danger {
-            background: linear-gradient(135deg, #ff6b6b 0%, #ee5a52 100%);
-        }
-
-        .btn-danger:hover {
-            box-shadow: 0 10px 25px rgba(255, 107, 107, 0.3);
-        }
-
-        .btn-small {
-            padding: 8px 15px;
-            font-size: 0.9rem;
-        }
-
-        .message {
-            padding: 15px 20px;
-            border-radius: 10px;
-            margin: 20px;
-            display: flex;
-            align-items: center;
-            gap: 10px;
-        }
-
-        .message.success {
-            background: linear-gradient(135deg, #d4edda 0%, #c3e6cb 100%);
-            color: #155724;
-            border-left: 4px solid #28a745;
-        }
-
-        .message.error {
-            background: linear-gradient(135deg, #f8d7da 0%, #f5c6cb 100%);
-            color: #721c24;
-            border-left: 4px solid #dc3545;
-        }
-
-        .contacts-grid {
-            grid-column: 1 / -1;
-            margin-top: 20px;
-        }
-
-        .contact-card {
-            background: linear-gradient(135deg, #f8f9fa 0%, #e9ecef 100%);
-            border-radius: 10px;
-            padding: 20px;
-            margin-bottom: 15px;
-            display: flex;
-            justify-content: space-between;
-            align-items: center;
-            transition: all 0.3s ease;
-            border-left: 4px solid #667eea;
-        }
-
-        .contact-card:hover {
-            transform: translateX(5px);
-            box-shadow: 0 5px 20px rgba(0, 0, 0, 0.1);
-        }
-
-        .contact-info {
-            display: flex;
-            align-items: center;
-            gap: 15px;
-        }
-
-        .contact-avatar {
-            width: 50px;
-            height: 50px;
-            background: linear-gradient(135deg, #667eea 0%, #764ba2 100%);
-            border-radius: 50%;
-            display: flex;
-            align-items: center;
-            justify-content: center;
-            color: white;
-            font-weight: bold;
-            font-size: 1.2rem;
-        }
-
-        .contact-details h3 {
-            color: #333;
-            margin-bottom: 5px;
-        }
-
-        .contact-details p {
-            color: #666;
-            display: flex;
-            align-items: center;
-            gap: 5px;
-        }
-
-        .search-result {
-            background: linear-gradient(135deg, #fff3cd 0%, #ffeaa7 100%);
-            border: 2px solid #ffc107;
-            border-radius: 10px;
-            padding: 20px;
-            margin: 20px 0;
-        }
-
-        .search-results {
-            background: linear-gradient(135deg, #fff3cd 0%, #ffeaa7 100%);
-            border: 2px solid #ffc107;
-            border-radius: 10px;
-            padding: 20px;
-            margin: 20px 0;
-        }
-
-        .search-results h3 {
-            margin-bottom: 15px;
-            color: #856404;
-        }
-
-        .file-management {
-            grid-column: 1 / -1;
-            background: linear-gradient(135deg, #f8f9fa 0%, #e9ecef 100%);
-            border-radius: 15px;
-            padding: 25px;
-            margin-top: 20px;
-        }
-
-        .file-actions {
-            display: grid;
-            grid-template-columns: repeat(auto-fit, minmax(300px, 1fr));
-            gap: 20px;
-            margin-top: 20px;
-        }
-
-        .file-card {
-            background: white;
-            border-radius: 10px;
-            padding: 20px;
-            box-shadow: 0 5px 15px rgba(0, 0, 0, 0.08);
-        }
-
-        .no-contacts {
-            text-align: center;
-            padding: 40px;
-            color: #666;
-            font-size: 1.1rem;
-        }
-
-        .no-contacts i {
-            font-size: 4rem;
-            color: #ddd;
-            margin-bottom: 20px;
-        }
-
-        @media (max-width: 768px) {
-            .main-content {
-                grid-template-columns: 1fr;
-                gap: 20px;
-                padding: 20px;
-            }
-            
-            .header h1 {
-                font-size: 2rem;
-            }
-            
-            .contact-card {
-                flex-direction: column;
-                align-items: flex-start;
-                gap: 15px;
-            }
-        }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header">
-            <h1><i class="fas fa-address-book"></i> Go Directory</h1>
-            <p class="subtitle">Modern Web Interface - Local Memory Management</p>
-        </div>
-        
-        <div class="stats-card">
-            <i class="fas fa-users"></i>
-            <div class="stats-number">{{.ContactCount}}</div>
-            <div>Contacts in memory</div>
-        </div>
-
-        {{if .Message}}
-            <div class="message {{.MessageType}}">
-                {{if eq .MessageType "success"}}
-                    <i class="fas fa-check-circle"></i>
-                {{else}}
-                    <i class="fas fa-exclamation-triangle"></i>
-                {{end}}
-                <span>{{.Message}}</span>
-            </div>
-        {{end}}
-
-        <div class="main-content">
-            <div class="section-card">
-                <h2 class="section-title">
-                    <i class="fas fa-user-plus"></i>
-                    Add Contact
-                </h2>
-                <form action="/add" method="POST">
-                    <div class="input-group">
-                        <i class="fas fa-user"></i>
-                        <input type="text" name="name" placeholder="Last Name" required>
-                    </div>
-                    <div class="input-group">
-                        <i class="fas fa-user"></i>
-                        <input type="text" name="first" placeholder="First Name" required>
-                    </div>
-                    <div class="input-group">
-                        <i class="fas fa-phone"></i>
-                        <input type="text" name="phone" placeholder="Phone Number" required>
-                    </div>
-                    <button type="submit" class="btn">
-                        <i class="fas fa-plus"></i>
-                        Add Contact
-                    </button>
-                </form>
-            </div>
-
-            <div class="section-card">
-                <h2 class="section-title">
-                    <i class="fas fa-search"></i>
-                    Search Contact
-                </h2>
-                <form action="/search" method="GET">
-                    <div class="input-group">
-                        <i class="fas fa-search"></i>
-                        <input type="text" name="name" placeholder="Search by name, first name, or phone number" required>
-                    </div>
-                    <button type="submit" class="btn">
-                        <i class="fas fa-search"></i>
-                        Search
-                    </button>
-                </form>
-            </div>
-        </div>
-
-        {{if .SearchResults}}
-        <div class="search-results">
-            <h3><i class="fas fa-user-check"></i> Search Results ({{len .SearchResults}} found)</h3>
-            {{range .SearchResults}}
-            <div class="contact-card" style="margin-top: 15px;">
-                <div class="contact-info">
-                    <div class="contact-avatar">
-                        {{substr .First 0 1}}{{substr .Name 0 1}}
-                    </div>
-                    <div class="contact-details">
-                        <h3>{{.First}} {{.Name}}</h3>
-                        <p><i class="fas fa-phone"></i> {{.Phone}}</p>
-                    </div>
-                </div>
-                <form action="/delete" method="POST">
-                    <input type="hidden" name="name" value="{{.Name}}">
-                    <button type="submit" class="btn btn-danger btn-small" onclick="return confirm('Are you sure you want to delete this contact?')">
-                        <i class="fas fa-trash"></i>
-                        Delete
-                    </button>
-                </form>
-            </div>
-            {{end}}
-        </div>
-        {{end}}
-
-        <div class="contacts-grid">
-            <div class="section-card">
-                <h2 class="section-title">
-                    <i class="fas fa-list"></i>
-                    Contact List
-                </h2>
-                {{if .Contacts}}
-                    {{range .Contacts}}
-                    <div class="contact-card">
-                        <div class="contact-info">
-                            <div class="contact-avatar">
-                                {{substr .First 0 1}}{{substr .Name 0 1}}
-                            </div>
-                            <div class="contact-details">
-                                <h3>{{.First}} {{.Name}}</h3>
-                                <p><i class="fas fa-phone"></i> {{.Phone}}</p>
-                            </div>
-                        </div>
-                        <form action="/delete" method="POST">
-                            <input type="hidden" name="name" value="{{.Name}}">
-                            <button type="submit" class="btn btn-danger btn-small" onclick="return confirm('Are you sure you want to delete this contact?')">
-                                <i class="fas fa-trash"></i>
-                                Delete
-                            </button>
-                        </form>
-                    </div>
-                    {{end}}
-                {{else}}
-                    <div class="no-contacts">
-                        <i class="fas fa-address-book"></i>
-                        <p>No contacts in directory</p>
-                        <p style="font-size: 0.9rem; margin-top: 10px;">Start by adding your first contact!</p>
-                    </div>
-                {{end}}
-            </div>
-        </div>
-
-        <div class="file-management">
-            <h2 class="section-title">
-                <i class="fas fa-file-archive"></i>
-                File Management
-            </h2>
-            
-            <div class="file-actions">
-                <div class="file-card">
-                    <h3><i class="fas fa-download"></i> Export Contacts</h3>
-                    <form action="/export" method="POST" style="margin-top: 15px;">
-                        <div class="input-group">
-                            <i class="fas fa-file-export"></i>
-                            <input type="text" name="filename" placeholder="File name" value="contacts_export.json" required>
-                        </div>
-                        <button type="submit" class="btn btn-success">
-                            <i class="fas fa-download"></i>
-                            Prepare Download
-                        </button>
-                    </form>
-                </div>
-                
-                <div class="file-card">
-                    <h3><i class="fas fa-upload"></i> Import Contacts</h3>
-                    <form action="/import" method="POST" enctype="multipart/form-data" style="margin-top: 15px;">
-                        <div class="input-group">
-                            <input type="file" name="file" accept=".json" required style="padding-left: 15px;">
-                        </div>
-                        <button type="submit" class="btn btn-success">
-                            <i class="fas fa-upload"></i>
-                            Import File
-                        </button>
-                    </form>
-                </div>
-                
-                <div class="file-card">
-                    <h3><i class="fas fa-broom"></i> Clear Memory</h3>
-                    <p style="color: #666; margin: 15px 0;">Delete all contacts from local memory</p>
-                    <form action="/clear" method="POST">
-                        <button type="submit" class="btn btn-danger" onclick="return confirm('Are you sure you want to clear local memory?')">
-                            <i class="fas fa-trash-alt"></i>
-                            Clear Memory
-                        </button>
-                    </form>
-                </div>
-            </div>
-        </div>
-    </div>
-
-    <script>
-        // Add some basic interactivity
-        document.addEventListener('DOMContentLoaded', function() {
-            // Auto-hide messages after 5 seconds
-            const messages = document.querySelectorAll('.message');
-            messages.forEach(message => {
-                setTimeout(() => {
-                    message.style.opacity = '0';
-                    message.style.transform = 'translateY(-20px)';
-                    setTimeout(() => {
-                        message.style.display = 'none';
-                    }, 300);
-                }, 5000);
-            });
-        });
-    </script>
-</body>
-</html>
-`
-
 /**
  * PageData represents the data structure passed to HTML templates
  *
@@ -586,27 +153,304 @@ const htmlTemplate = `
  * including contact lists, search results, messages, and statistics
  */
 type PageData struct {
-	Contacts      []annuaire.Contact // Complete list of all contacts for main display
-	SearchResult  *annuaire.Contact  // Single search result (maintained for backward compatibility)
-	SearchResults []annuaire.Contact // Multiple search results for enhanced search functionality
-	Message       string             // Status message to display to user (success/error/info)
-	MessageType   string             // CSS class type for message styling (success/error)
-	ContactCount  int                // Total number of contacts for statistics display
+	Contacts      []annuaire.Contact          // Complete list of all contacts for main display
+	SearchResult  *annuaire.Contact           // Single search result (maintained for backward compatibility)
+	SearchResults []SearchHit                 // Multiple search results, with the searched text highlighted
+	Message       string                      // Status message to display to user (success/error/info)
+	MessageType   string                      // CSS class type for message styling (success/error)
+	MessageLink   *FlashLink                  // Optional link shown after the message, built server-side
+	ContactCount  int                         // Total number of contacts for statistics display
+	PinnedCount   int                         // Number of pinned contacts at the start of Contacts
+	Birthdays     []annuaire.UpcomingBirthday // Birthdays between today and the end of the month
+	Recent        []annuaire.Contact          // Contacts added or changed last (see homeRecentCount)
+	CSRFToken     string                      // Token embedded in every POST form (see csrfProtect)
+	Profile       string                      // Profile displayed (empty with a single profile)
+	Profiles      []string                    // Profiles offered by the selector
+	Region        string                      // Phone region of national numbers, for tel: links
+	Language      string                      // Interface language (html lang attribute)
+	NeedsSetup    bool                        // Offer the first-run setup page
+	Truncated     bool                        // SearchResults is incomplete (see annuaire.SearchLimits)
+	Phonetic      bool                        // The search also matched names sounding like the term
+	User          string                      // Logged-in user, offered a logout button (empty: open access)
+	ReadOnly      bool                        // Viewer session: hide the forms that modify the directory
+	Sections      []ListSection               // Contacts of the current page, pinned first, then by initial
+	Index         []IndexEntry                // Letter bar linking to the sections
+	Pager         Pager                       // Page of the contact list shown (see webPerPage)
+}
+
+// SearchHit is a search result with the places where the searched text was found
+type SearchHit struct {
+	annuaire.Contact
+	Matches []annuaire.Match // See annuaire.FindMatches
+}
+
+/**
+ * Highlight returns a field of the contact with the searched text marked
+ *
+ * @param {string} field - "name", "first" or "phone"
+ * @return {template.HTML} Escaped value whose matches are in <mark> elements,
+ *   e.g. "<mark>Dup</mark>ont"
+ *
+ * Usage in a template: {{.Highlight "name"}}
+ */
+func (h SearchHit) Highlight(field string) template.HTML {
+	var value string
+	switch field {
+	case "name":
+		value = h.Name
+	case "first":
+		value = h.First
+	case "phone":
+		value = h.Phone
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range h.Matches {
+		if m.Field != field || m.Start < last || m.End > len(value) {
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(value[last:m.Start]))
+		b.WriteString("<mark>" + template.HTMLEscapeString(value[m.Start:m.End]) + "</mark>")
+		last = m.End
+	}
+	b.WriteString(template.HTMLEscapeString(value[last:]))
+	return template.HTML(b.String())
+}
+
+// ListSection is a group of the contact list under its own header
+type ListSection struct {
+	Initial  string             // Letter of the section (empty for the pinned one)
+	Anchor   string             // Fragment the letter bar links to (empty for the pinned one)
+	Pinned   bool               // Section of the pinned contacts
+	Contacts []annuaire.Contact // Contacts of the section, in display order
+}
+
+// IndexEntry is one letter of the alphabetical index bar
+type IndexEntry struct {
+	Letter string // Letter from A to Z, or annuaire.OtherInitial
+	Href   string // Link to the first contact filed under Letter, on its page (empty: none)
+}
+
+// webPerPage is the number of contacts per page of the web list
+const webPerPage = 25
+
+// Pager links the pages of the web contact list
+type Pager struct {
+	Page  int    // Current page, starting at 1
+	Pages int    // Number of pages; the controls are hidden when there is only one
+	Prev  string // Link to the previous page (empty on the first page)
+	Next  string // Link to the next page (empty on the last page)
 }
 
 /**
- * createTemplate creates an HTML template with custom functions
+ * listContacts paginates the contact list and groups the current page for display
  *
- * @return {*template.Template} Parsed template ready for execution
- * @return {error} Error if template parsing fails
+ * @param {*url.URL} page - Address of the list whose ?page= selects the page;
+ *   its path and other parameters (e.g. the search term) are kept in the links
+ * @param {[]annuaire.Contact} contacts - Contacts as returned by sortedContacts
+ * @param {int} pinnedCount - Number of pinned contacts at the start of contacts
+ * @return {[]ListSection} Sections of the page: pinned contacts, then one per initial
+ * @return {[]IndexEntry} Every letter of annuaire.IndexLetters, linked to the
+ *   page where its contacts start
+ * @return {Pager} Position in the list and links to the neighbouring pages
  *
- * This function combines the HTML template string with custom template functions
- * to create a fully functional template for web page rendering
+ * An invalid page number shows the first page, and one past the end the last
  */
-func createTemplate() (*template.Template, error) {
-	return template.New("home").Funcs(templateFuncs).Parse(htmlTemplate)
+func listContacts(page *url.URL, contacts []annuaire.Contact, pinnedCount int) ([]ListSection, []IndexEntry, Pager) {
+	number, err := strconv.Atoi(page.Query().Get("page"))
+	if err != nil || number < 1 {
+		number = 1
+	}
+	if pages := (len(contacts) + webPerPage - 1) / webPerPage; number > pages {
+		number = max(pages, 1)
+	}
+	current := annuaire.Paginate(contacts, number, webPerPage)
+
+	link := func(n int) string {
+		values := page.Query()
+		values.Set("page", strconv.Itoa(n))
+		return page.Path + "?" + values.Encode()
+	}
+	pager := Pager{Page: current.Number, Pages: current.Pages}
+	if current.Number > 1 {
+		pager.Prev = link(current.Number - 1)
+	}
+	if current.Number < current.Pages {
+		pager.Next = link(current.Number + 1)
+	}
+
+	// Pinned contacts come first in the complete list, so only the first pages hold some
+	pinnedOnPage := min(max(pinnedCount-current.Start(), 0), len(current.Contacts))
+	var sections []ListSection
+	if pinnedOnPage > 0 {
+		sections = append(sections, ListSection{Pinned: true, Contacts: current.Contacts[:pinnedOnPage]})
+	}
+	for _, group := range annuaire.GroupByInitial(current.Contacts[pinnedOnPage:]) {
+		sections = append(sections, ListSection{Initial: group.Initial, Anchor: letterAnchor(group.Initial), Contacts: group.Contacts})
+	}
+
+	// The index covers the whole list: each letter links to the page of its first contact
+	firstPage := make(map[string]int)
+	for i := pinnedCount; i < len(contacts); i++ {
+		if initial := annuaire.Initial(contacts[i].Name); firstPage[initial] == 0 {
+			firstPage[initial] = i/webPerPage + 1
+		}
+	}
+	index := make([]IndexEntry, len(annuaire.IndexLetters))
+	for i, letter := range annuaire.IndexLetters {
+		index[i] = IndexEntry{Letter: letter}
+		switch first := firstPage[letter]; {
+		case first == current.Number:
+			index[i].Href = "#" + letterAnchor(letter)
+		case first > 0:
+			index[i].Href = link(first) + "#" + letterAnchor(letter)
+		}
+	}
+	return sections, index, pager
+}
+
+// letterAnchor returns the fragment identifier of the section of an initial
+func letterAnchor(initial string) string {
+	if initial == annuaire.OtherInitial {
+		return "letter-other"
+	}
+	return "letter-" + initial
 }
 
+/**
+ * sortedContacts lists all contacts with pinned ones first
+ *
+ * @return {[]annuaire.Contact} Contacts in display order
+ * @return {int} Number of pinned contacts at the start of the slice
+ *
+ * Settings are read on every call so pins changed from the CLI show up
+ * without restarting the server; the sort itself is cached until the
+ * directory or the pinned list changes
+ */
+func (s *Server) sortedContacts() ([]annuaire.Contact, int) {
+	// Read the generation first: a mutation after this point only makes the
+	// cache drop the result instead of storing it under the wrong generation
+	generation := s.dir.Generation()
+	pinned := s.loadSettings().Pinned
+
+	type sorted struct {
+		contacts    []annuaire.Contact
+		pinnedCount int
+	}
+	result := s.cache.get(generation, fmt.Sprintf("sorted:%v", pinned), func() any {
+		contacts := s.dir.ListContacts()
+		return sorted{contacts, annuaire.SortContacts(contacts, pinned)}
+	}).(sorted)
+
+	// Callers may modify their copy (the share page masks fields in place)
+	return slices.Clone(result.contacts), result.pinnedCount
+}
+
+// filterContacts is a cached FilterContactsWith; a search stopped by its
+// time budget is not kept, so that the next attempt can complete
+func (s *Server) filterContacts(ctx context.Context, term string, opts annuaire.SearchOptions, limits annuaire.SearchLimits) annuaire.SearchResult {
+	generation := s.dir.Generation()
+	key := fmt.Sprintf("search:%d:%v:%s", limits.MaxResults, opts.Phonetic, term)
+	result := s.cache.get(generation, key, func() any {
+		return tracedDirectory{Directory: s.dir, ctx: ctx}.FilterContactsWith(term, opts, limits)
+	}).(annuaire.SearchResult)
+	if result.TimedOut {
+		s.cache.forget(key)
+	}
+	result.Contacts = slices.Clone(result.Contacts)
+	return result
+}
+
+// homeRecentCount is the number of contacts of the "Recently added" panel
+const homeRecentCount = 5
+
+// recentContacts is a cached RecentContacts, changing with every mutation only
+func (s *Server) recentContacts() []annuaire.Contact {
+	return s.cache.get(s.dir.Generation(), "recent", func() any {
+		return s.dir.RecentContacts(homeRecentCount)
+	}).([]annuaire.Contact)
+}
+
+// upcomingBirthdays is a cached UpcomingBirthdays; the date is part of the key
+// since the result changes at midnight even without any mutation
+func (s *Server) upcomingBirthdays(now time.Time) []annuaire.UpcomingBirthday {
+	generation := s.dir.Generation()
+	key := "birthdays:" + now.Format("2006-01-02")
+	return s.cache.get(generation, key, func() any {
+		return s.dir.UpcomingBirthdays(daysLeftInMonth(now))
+	}).([]annuaire.UpcomingBirthday)
+}
+
+/**
+ * loadSettings reads the settings file configured with WithSettingsFile
+ *
+ * @return {*settings.Settings} Current settings (empty when none are configured
+ *                              or the file cannot be read)
+ */
+func (s *Server) loadSettings() *settings.Settings {
+	if s.settingsFile == "" {
+		return &settings.Settings{}
+	}
+	cfg, err := settings.Load(s.settingsFile)
+	if err != nil {
+		slog.Warn("cannot load settings", "file", s.settingsFile, "error", err)
+		return &settings.Settings{}
+	}
+	return cfg
+}
+
+// Config holds the settings used to run the web server
+// The zero value serves on port 8080 with a clean, memory-only directory
+type Config struct {
+	Addr           string                                                               // Listen address such as ":8080" (defaults to ":8080")
+	DataFile       string                                                               // Contacts file loaded at startup and flushed on shutdown (empty: memory only)
+	SettingsFile   string                                                               // Settings file read on each listing for pinned contacts (optional)
+	HistoryFile    string                                                               // Audit log of modifications (optional, in memory when empty)
+	Storage        annuaire.Storage                                                     // File access for the data file (optional, e.g. fault injection)
+	Format         string                                                               // Data file format, annuaire.FormatJSON (default) or annuaire.FormatAppendLog
+	Profiles       []Profile                                                            // Address books served side by side, the first by default (replaces the three files above)
+	BootstrapFile  string                                                               // Application configuration; enables the first-run setup page while it does not exist
+	TLSCertFile    string                                                               // PEM certificate (chain); with TLSKeyFile, serves HTTPS instead of HTTP
+	TLSKeyFile     string                                                               // PEM private key of TLSCertFile
+	AccessLog      string                                                               // Access log format, AccessLogCommon or AccessLogJSON (empty: no access log)
+	AccessLogOut   io.Writer                                                            // Destination of the access log (defaults to standard output)
+	RateLimit      RateLimit                                                            // Per-IP limit of POST requests (zero value: unlimited, see DefaultRateLimit)
+	Admin          *settings.AdminCredentials                                           // Account required to log in (default: the one of BootstrapFile; none: open access)
+	TemplatesDir   string                                                               // Directory overriding templates/*.html and static/* (see WithTemplatesDir)
+	BasePath       string                                                               // Path prefix of every page, e.g. "/contacts" behind a reverse proxy (empty: the root, see WithBasePath)
+	ReadTimeout    time.Duration                                                        // Limit for reading a request, body included (zero: defaultReadTimeout, negative: none)
+	WriteTimeout   time.Duration                                                        // Limit for writing a response (zero: defaultWriteTimeout, negative: none)
+	IdleTimeout    time.Duration                                                        // Limit for keeping an idle keep-alive connection open (zero: defaultIdleTimeout, negative: none)
+	MaxHeaderBytes int                                                                  // Largest request headers accepted (zero: defaultMaxHeaderBytes)
+	MaxUploadSize  int64                                                                // Largest request body accepted, e.g. an imported file (zero: DefaultMaxUploadSize)
+	CORS           CORS                                                                 // Origins allowed to call the API from their pages (zero value: same origin only)
+	DebugAddr      string                                                               // Address of the pprof and expvar endpoints, e.g. DefaultDebugAddr (empty: not served)
+	OnChange       func(profile string, event annuaire.Event, contact annuaire.Contact) // Called for every change of an address book, e.g. (*mailer.Notifier).Notify (nil: none; see annuaire.Directory.RegisterHook)
+}
+
+// Timeouts of the HTTP server when Config leaves them at zero; uploads and
+// exports of large address books stay well within them, while a client
+// sending its request slowly (slowloris) cannot hold a connection for long
+const (
+	defaultReadTimeout  = 30 * time.Second
+	defaultWriteTimeout = 60 * time.Second
+	defaultIdleTimeout  = 2 * time.Minute
+)
+
+// timeout returns the configured limit, its default when zero, or no limit when negative
+func timeout(configured, fallback time.Duration) time.Duration {
+	switch {
+	case configured < 0:
+		return 0
+	case configured == 0:
+		return fallback
+	}
+	return configured
+}
+
+// shutdownTimeout bounds how long in-flight requests may take to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
 /**
  * StartServer initializes and starts the HTTP web server on port 8080
  *
@@ -615,26 +459,230 @@ func createTemplate() (*template.Template, error) {
  * - Registering all HTTP route handlers for web interface functionality
  * - Starting the HTTP server and listening for incoming connections
  *
- * The server will panic if it fails to bind to port 8080 or encounters
- * other critical startup errors
+ * The process exits if the server fails to bind to port 8080 or encounters
+ * other critical startup errors. Use StartServerWithContext for a server
+ * that can be stopped and persists its contacts.
  */
 func StartServer() {
-	// Initialize empty directory (no automatic loading for web interface)
-	// This gives users a clean slate and explicit control over data loading
-	dir = annuaire.NewDirectory()
+	if err := StartServerWithContext(context.Background(), Config{}); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	// Register HTTP route handlers for all web interface functionality
-	http.HandleFunc("/", handleHome)              // Main page with contact list and forms
-	http.HandleFunc("/add", handleAdd)            // POST: Add new contact
-	http.HandleFunc("/search", handleSearch)      // GET: Search for contacts
-	http.HandleFunc("/delete", handleDelete)      // POST: Delete contact
-	http.HandleFunc("/export", handleExport)      // POST: Export contacts to JSON
-	http.HandleFunc("/import", handleImport)      // POST: Import contacts from JSON
-	http.HandleFunc("/clear", handleClear)        // POST: Clear all contacts from memory
-	http.HandleFunc("/download/", handleDownload) // GET: Download exported files
-
-	fmt.Println("Server started on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+/**
+ * StartServerWithContext runs the web server until the context is cancelled
+ * or the process receives SIGINT/SIGTERM, then shuts it down gracefully
+ *
+ * @param {context.Context} ctx - Context controlling the server lifetime
+ * @param {Config} cfg - Listen address and optional data file
+ * @return {error} Error if loading, listening or the final flush fails (nil on clean shutdown)
+ *
+ * Lifecycle:
+ * - Loads cfg.DataFile into memory when it is set and exists
+ * - Serves requests until a signal arrives or ctx is done
+ * - Stops accepting connections and waits for in-flight requests (bounded by shutdownTimeout)
+ * - Writes the in-memory contacts back to cfg.DataFile before returning
+ *
+ * Usage:
+ *   err := server.StartServerWithContext(ctx, server.Config{Addr: ":8080", DataFile: "data/contacts.json"})
+ */
+func StartServerWithContext(ctx context.Context, cfg Config) error {
+	if cfg.Addr == "" {
+		cfg.Addr = ":8080"
+	}
+
+	// Translate termination signals into context cancellation
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	scheme, host := "http", "localhost"
+	if cfg.TLSCertFile != "" {
+		scheme = "https"
+	}
+	// ":8080" listens on every interface, reachable as localhost
+	if h, port, err := net.SplitHostPort(ln.Addr().String()); err == nil {
+		if addrHost, _, _ := net.SplitHostPort(cfg.Addr); addrHost != "" {
+			host = h
+		}
+		host = net.JoinHostPort(host, port)
+	}
+	fmt.Printf("Server started on %s://%s%s/\n", scheme, host, cleanBasePath(cfg.BasePath))
+
+	return Serve(ctx, ln, cfg)
+}
+
+/**
+ * Serve runs the web server on an existing listener until the context is done
+ *
+ * @param {context.Context} ctx - Context controlling the server lifetime
+ * @param {net.Listener} ln - Listener to accept connections from (closed on return)
+ * @param {Config} cfg - Data and settings files, or profiles (cfg.Addr is ignored)
+ * @return {error} Error if loading, serving or the final flush fails (nil on clean shutdown)
+ *
+ * With several cfg.Profiles, each gets its own Directory and the browser
+ * picks one with GET /profile?name=...; all of them are saved on shutdown
+ *
+ * With cfg.TLSCertFile and cfg.TLSKeyFile, connections are served over TLS
+ * with HSTS and secure cookies; the files are reloaded when they change
+ *
+ * Once an administrator exists (cfg.Admin or the one of cfg.BootstrapFile),
+ * every page requires a login; sessions are kept in memory, shared by all
+ * profiles and lost on restart
+ *
+ * With cfg.TemplatesDir, the page templates are parsed once here and a
+ * template that does not parse is returned as an error
+ *
+ * With cfg.AccessLog, every request is logged to cfg.AccessLogOut once served,
+ * including the ones refused by cfg.RateLimit
+ *
+ * With cfg.BasePath, every page, redirect and link is under that prefix, and
+ * other paths answer 404; cfg.ReadTimeout, cfg.WriteTimeout, cfg.IdleTimeout,
+ * cfg.MaxHeaderBytes and cfg.MaxUploadSize bound each request and connection
+ * (see defaultReadTimeout)
+ *
+ * With cfg.CORS, the pages of the listed origins may call the API (see corsHandler)
+ *
+ * Every request is traced once an exporter is set (see tracing.SetExporter),
+ * continuing the trace of callers sending a traceparent header
+ *
+ * With cfg.DebugAddr, the pprof profiles and expvar variables are served on
+ * that second address, without login (see debugHandler)
+ *
+ * This is StartServerWithContext without the signal handling, so tests and
+ * embedding applications can listen on an ephemeral port ("127.0.0.1:0") and
+ * keep control of the process:
+ *
+ *   ln, _ := net.Listen("tcp", "127.0.0.1:0")
+ *   go server.Serve(ctx, ln, server.Config{DataFile: filepath.Join(tmp, "contacts.json")})
+ */
+func Serve(ctx context.Context, ln net.Listener, cfg Config) error {
+	tlsConfig, err := tlsConfigFor(cfg)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+	// Parse the pages once for every profile, failing before serving anything
+	basePath := cleanBasePath(cfg.BasePath)
+	assets, templates, err := loadTemplates(cfg.TemplatesDir, basePath)
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	profiles := cfg.Profiles
+	if len(profiles) == 0 {
+		profiles = []Profile{{DataFile: cfg.DataFile, SettingsFile: cfg.SettingsFile, HistoryFile: cfg.HistoryFile}}
+	}
+
+	dirs := make([]*annuaire.Directory, len(profiles))
+	switcher := &profileSwitcher{handlers: make(map[string]http.Handler, len(profiles))}
+	for i, profile := range profiles {
+		dir := annuaire.NewDirectory()
+		dir.SetStorage(cfg.Storage)
+		if profile.DataFile != "" {
+			// A missing data file simply means there is nothing to restore yet
+			if err := (tracedDirectory{Directory: dir, ctx: ctx, storage: cfg.Storage}).LoadDataFile(profile.DataFile, cfg.Format); err != nil {
+				ln.Close()
+				return fmt.Errorf("loading %s: %w", profile.DataFile, err)
+			}
+		}
+		// Set after loading, so that a restart is not recorded as an import
+		dir.SetHistoryFile(profile.HistoryFile)
+		if cfg.OnChange != nil {
+			name := profile.Name
+			if len(profiles) == 1 {
+				name = "" // Nothing to tell apart
+			}
+			for _, event := range annuaire.Events {
+				dir.RegisterHook(event, func(contact annuaire.Contact) { cfg.OnChange(name, event, contact) })
+			}
+		}
+
+		dirs[i] = dir
+		switcher.names = append(switcher.names, profile.Name)
+		switcher.handlers[profile.Name] = NewServer(dir, WithSettingsFile(profile.SettingsFile), WithBootstrap(cfg.BootstrapFile, profile.DataFile), withTemplates(assets, templates), WithMaxUploadSize(cfg.MaxUploadSize))
+	}
+	if len(profiles) > 1 {
+		for i, profile := range profiles {
+			switcher.handlers[profile.Name] = NewServer(dirs[i], WithSettingsFile(profile.SettingsFile), WithBootstrap(cfg.BootstrapFile, profile.DataFile), withTemplates(assets, templates), WithMaxUploadSize(cfg.MaxUploadSize), WithProfiles(profile.Name, switcher.names))
+		}
+	}
+
+	if cfg.DebugAddr != "" {
+		debugServer, err := startDebugServer(cfg.DebugAddr, debugHandler(switcher.names, dirs))
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		defer debugServer.Close()
+	}
+
+	guard := newSessionGuard(switcher, cfg.Admin, cfg.BootstrapFile, templates)
+	if guard.accounts() == nil {
+		slog.Warn("no administrator configured, the web interface is open to anyone on the network; run \"tp1 setup\"")
+	}
+	// Preflight requests carry no cookie, so they are answered before the login check
+	handler := traceRequests(rateLimit(mountAt(corsHandler(guard, cfg.CORS), basePath), cfg.RateLimit))
+	if cfg.AccessLog != "" {
+		out := cfg.AccessLogOut
+		if out == nil {
+			out = os.Stdout
+		}
+		if handler, err = accessLog(handler, cfg.AccessLog, out); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+		handler = strictTransport(handler)
+	}
+	srv := &http.Server{
+		Handler:        handler,
+		ReadTimeout:    timeout(cfg.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:   timeout(cfg.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:    timeout(cfg.IdleTimeout, defaultIdleTimeout),
+		MaxHeaderBytes: cmp.Or(cfg.MaxHeaderBytes, defaultMaxHeaderBytes),
+	}
+
+	// Serve in the background so this goroutine can wait for a stop request
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		// The listener failed before any shutdown was requested
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("graceful shutdown incomplete", "error", err)
+	}
+
+	// Persist the in-memory state once no handler can modify it anymore;
+	// every profile is saved even if one fails
+	var saveErr error
+	for i, profile := range profiles {
+		if profile.DataFile == "" {
+			continue
+		}
+		if err := (tracedDirectory{Directory: dirs[i], ctx: ctx, storage: cfg.Storage}).SaveDataFile(profile.DataFile); err != nil {
+			saveErr = errors.Join(saveErr, fmt.Errorf("saving %s: %w", profile.DataFile, err))
+			continue
+		}
+		slog.Info("contacts saved", "file", profile.DataFile)
+	}
+	return saveErr
 }
 
 /**
@@ -649,32 +697,28 @@ func StartServer() {
  * - Success/error messages from redirected operations
  * - All interactive forms for contact management
  */
-func handleHome(w http.ResponseWriter, r *http.Request) {
-	// Create template instance with custom functions
-	tmpl, err := createTemplate()
-	if err != nil {
-		http.Error(w, "Template error", http.StatusInternalServerError)
-		return
-	}
-
+func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 	// Prepare data structure for template rendering
-	data := PageData{
-		Contacts:     dir.ListContacts(), // Get all contacts for main display
-		ContactCount: dir.ContactCount(), // Get statistics for header display
-	}
-
-	// Check for messages in URL parameters (from redirected operations)
-	if msg := r.URL.Query().Get("message"); msg != "" {
-		data.Message = msg
-		data.MessageType = r.URL.Query().Get("type")
-		// Default to success message type if not specified
-		if data.MessageType == "" {
-			data.MessageType = "success"
-		}
+	data := s.pageData(r, r.URL)
+	data.Birthdays = s.upcomingBirthdays(time.Now()) // Remaining birthdays of the month
+	data.Recent = s.recentContacts()                 // Contacts entered or changed last
+
+	// Show the message left by a redirected operation; the URL is never used,
+	// so a crafted link cannot display arbitrary text or markup
+	if flash := popFlash(w, r); flash != nil {
+		data.Message = flash.Text
+		data.MessageType = flash.Type
+		data.MessageLink = flash.Link
 	}
 
 	// Execute template with prepared data and send to client
-	tmpl.Execute(w, data)
+	renderTemplate(w, s.templates, "home.html", http.StatusOK, data)
+}
+
+// daysLeftInMonth counts the days after today until the end of its month
+func daysLeftInMonth(now time.Time) int {
+	lastDay := time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location())
+	return lastDay.Day() - now.Day()
 }
 
 /**
@@ -687,9 +731,10 @@ func handleHome(w http.ResponseWriter, r *http.Request) {
  * - Validates HTTP method (POST only)
  * - Extracts contact data from form fields
  * - Attempts to add contact to directory
- * - Redirects back to home page with success/error message
+ * - Redirects back to home page with success/error message, or answers the
+ *   updated parts of the page to a fetch request (see reply)
  */
-func handleAdd(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleAdd(w http.ResponseWriter, r *http.Request) {
 	// Enforce POST method for data modification operations
 	if r.Method != "POST" {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -701,23 +746,41 @@ func handleAdd(w http.ResponseWriter, r *http.Request) {
 	first := r.FormValue("first") // First name from form
 	phone := r.FormValue("phone") // Phone number from form
 
-	// Attempt to add contact to directory with validation
-	err := dir.AddContact(name, first, phone)
-
-	// Prepare redirect URL with appropriate success/error message
-	redirectURL := "/"
+	// Attempt to add contact to directory with validation; the date input
+	// submits YYYY-MM-DD, which is the stored birthday format; a contact
+	// entered by hand counts as confirmed today
+	err := s.traced(r).InsertContact(annuaire.Contact{
+		Name: name, First: first, Phone: phone, Birthday: r.FormValue("birthday"),
+		Address: annuaire.Address{
+			Street: strings.TrimSpace(r.FormValue("street")), Zip: strings.TrimSpace(r.FormValue("zip")),
+			City: strings.TrimSpace(r.FormValue("city")), Country: strings.TrimSpace(r.FormValue("country")),
+		},
+		Custom:   customFields(r),
+		VerifyBy: annuaire.VerifyByDate(time.Now(), s.loadSettings().VerificationPeriod()),
+	})
+
+	// Redirect back to home page with a one-time success/error message
 	if err != nil {
 		// Format error message for user display
-		message := fmt.Sprintf("Error: %v", err)
-		redirectURL = fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-	} else {
-		// Format success message with contact details
-		message := fmt.Sprintf("Contact %s %s added successfully to local memory", first, name)
-		redirectURL = fmt.Sprintf("/?message=%s&type=success", url.QueryEscape(message))
+		s.replyError(w, r, err)
+		return
 	}
+	// Format success message with contact details
+	s.reply(w, r, flashSuccess, fmt.Sprintf("Contact %s %s added successfully to local memory", first, name))
+}
 
-	// Redirect back to home page to display result
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+// customFields pairs the repeated custom_key and custom_value fields of the
+// add form, skipping the rows left empty; a row without a value is dropped
+// when the contact is stored, and a value without a name is an error
+func customFields(r *http.Request) map[string]string {
+	keys, values := r.PostForm["custom_key"], r.PostForm["custom_value"]
+	fields := make(map[string]string, len(keys))
+	for i, key := range keys {
+		if i < len(values) && (strings.TrimSpace(key) != "" || strings.TrimSpace(values[i]) != "") {
+			fields[key] = values[i]
+		}
+	}
+	return fields
 }
 
 /**
@@ -730,90 +793,101 @@ func handleAdd(w http.ResponseWriter, r *http.Request) {
  * - Accepts search terms from query parameters
  * - Uses FilterContacts to find all matching contacts
  * - Displays search results alongside the main contact list
- * - Provides detailed debug output for troubleshooting search issues
+ * - Logs search activity at debug level only (see the -log-level flag)
  */
-func handleSearch(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 	searchTerm := r.FormValue("name")
+	data := s.pageData(r, r.URL) // Show all contacts alongside search results
+	opts := searchOptions(r.Form)
+	data.Phonetic = opts.Phonetic // Keep the box checked
 
-	// DEBUG: Print comprehensive search debugging information
-	// This debug block helps developers troubleshoot search functionality issues
-	fmt.Printf("=== SEARCH DEBUG START ===\n")
-	fmt.Printf("Search term received: '%s'\n", searchTerm)
-	fmt.Printf("Total contacts in directory: %d\n", dir.ContactCount())
+	// Process search request if search term is provided
+	if searchTerm != "" {
+		s.dir.RecordSearch(searchTerm)
+		s.fillSearch(r.Context(), &data, searchTerm, opts)
+	}
 
-	// DEBUG: Display all contacts currently in the directory for verification
-	// This helps identify data issues or contact storage problems
-	contacts := dir.ListContacts()
-	fmt.Printf("--- All Contacts in Directory ---\n")
-	for i, contact := range contacts {
-		fmt.Printf("Contact %d: '%s' '%s' - '%s'\n", i+1, contact.First, contact.Name, contact.Phone)
+	// Execute template with search results and contact data; a fetch request
+	// only gets the parts of the page that changed
+	name := "home.html"
+	if isFragmentRequest(r) {
+		name = fragmentTemplate
 	}
-	fmt.Printf("--- End Contact List ---\n")
+	renderTemplate(w, s.templates, name, http.StatusOK, data)
+}
 
-	// Create template for rendering search results
-	tmpl, _ := createTemplate()
+/**
+ * pageData prepares the fields shared by the main page and its fragments
+ *
+ * @param {*http.Request} r - Request, for the CSRF token and the session
+ * @param {*url.URL} list - Address of the list displayed, for its page links
+ * @return {PageData} Contacts, statistics and locale; messages and search
+ *   results are left to the caller
+ */
+func (s *Server) pageData(r *http.Request, list *url.URL) PageData {
+	contacts, pinnedCount := s.sortedContacts() // Pinned contacts first, then alphabetical
 	data := PageData{
-		Contacts:     contacts,           // Show all contacts alongside search results
-		ContactCount: dir.ContactCount(), // Display current statistics
+		Contacts:     contacts,             // Get all contacts for main display
+		ContactCount: s.dir.ContactCount(), // Get statistics for header display
+		PinnedCount:  pinnedCount,          // Highlight pinned contacts
+		CSRFToken:    csrfToken(r),         // Protect the page forms
+		Profile:      s.profile,            // Address book displayed
+		Profiles:     s.profiles,           // Offer the other address books
+		User:         loggedInUser(r),      // Offer to log out
+		ReadOnly:     readOnly(r),          // Hide the forms of a viewer
 	}
+	data.Sections, data.Index, data.Pager = listContacts(list, contacts, pinnedCount)
+	s.fillLocale(&data)
+	return data
+}
 
-	// Process search request if search term is provided
-	if searchTerm != "" {
-		// DEBUG: Log the start of search processing
-		fmt.Printf("Processing search for term: '%s'\n", searchTerm)
-
-		// Use FilterContacts to get all matching contacts (not just first match)
-		searchResults := dir.FilterContacts(searchTerm)
-
-		// DEBUG: Report search results for verification
-		fmt.Printf("Search completed. Found %d results:\n", len(searchResults))
-		for i, result := range searchResults {
-			fmt.Printf("  Result %d: %s %s - %s\n", i+1, result.First, result.Name, result.Phone)
-		}
+// searchOptions reads the matching mode of the search form
+func searchOptions(form url.Values) annuaire.SearchOptions {
+	return annuaire.SearchOptions{Phonetic: form.Get("phonetic") != ""}
+}
 
-		if len(searchResults) > 0 {
-			// Store search results for template display
-			data.SearchResults = searchResults
-			// Maintain backward compatibility by setting first result as SearchResult
-			data.SearchResult = &searchResults[0]
-
-			// Set appropriate success message based on result count
-			if len(searchResults) == 1 {
-				data.Message = "Contact found"
-			} else {
-				data.Message = fmt.Sprintf("%d contacts found", len(searchResults))
-			}
-			data.MessageType = "success"
-
-			// DEBUG: Log template data being prepared
-			fmt.Printf("Template data prepared:\n")
-			fmt.Printf("  SearchResults count: %d\n", len(searchResults))
-			fmt.Printf("  Message: '%s'\n", data.Message)
-			fmt.Printf("  MessageType: '%s'\n", data.MessageType)
-		} else {
-			// No results found - prepare error message
-			data.Message = fmt.Sprintf("No contact found matching: %s", searchTerm)
-			data.MessageType = "error"
-
-			// DEBUG: Log no-match scenario for troubleshooting
-			fmt.Printf("No matches found for search term: '%s'\n", searchTerm)
-			fmt.Printf("This could indicate:\n")
-			fmt.Printf("  - Search term doesn't match any contact exactly\n")
-			fmt.Printf("  - Case sensitivity issues\n")
-			fmt.Printf("  - Contact data structure problems\n")
-		}
+/**
+ * fillSearch sets the search results of a page and the message describing them
+ *
+ * @param {context.Context} ctx - Request context, cancelling a long search
+ * @param {*PageData} data - Page to fill
+ * @param {string} searchTerm - Name, first name or phone number searched
+ * @param {annuaire.SearchOptions} opts - Matching mode chosen in the form
+ */
+func (s *Server) fillSearch(ctx context.Context, data *PageData, searchTerm string, opts annuaire.SearchOptions) {
+	// Use FilterContacts to get all matching contacts (not just first match),
+	// within the limits of the settings so a broad query stays cheap
+	limits := s.loadSettings().SearchLimits()
+	result := s.filterContacts(ctx, searchTerm, opts, limits)
+	searchResults := make([]SearchHit, len(result.Contacts))
+	for i, c := range result.Contacts {
+		searchResults[i] = SearchHit{Contact: c, Matches: result.Matches[i]}
 	}
-
-	// DEBUG: Final debug output before template execution
-	fmt.Printf("=== SEARCH DEBUG END ===\n\n")
-
-	// Execute template with search results and contact data
-	if err := tmpl.Execute(w, data); err != nil {
-		// DEBUG: Log template execution errors for debugging
-		fmt.Printf("TEMPLATE EXECUTION ERROR: %v\n", err)
-		fmt.Printf("Data structure passed to template: %+v\n", data)
-		http.Error(w, "Template execution error", http.StatusInternalServerError)
-		return
+	slog.Debug("web search", "term", searchTerm, "results", len(searchResults), "truncated", result.Truncated)
+
+	if len(searchResults) > 0 {
+		// Store search results for template display
+		data.SearchResults = searchResults
+		// Maintain backward compatibility by setting first result as SearchResult
+		data.SearchResult = &searchResults[0].Contact
+
+		// Set appropriate success message based on result count
+		switch {
+		case result.TimedOut:
+			data.Message = fmt.Sprintf("Search stopped after %s: showing the %d contacts found so far", limits.Timeout, len(searchResults))
+		case result.Truncated:
+			data.Message = fmt.Sprintf("More than %d contacts found: showing the first %d, refine the search", limits.MaxResults, len(searchResults))
+		case len(searchResults) == 1:
+			data.Message = "Contact found"
+		default:
+			data.Message = fmt.Sprintf("%d contacts found", len(searchResults))
+		}
+		data.MessageType = "success"
+		data.Truncated = result.Truncated
+	} else {
+		// No results found - prepare error message
+		data.Message = fmt.Sprintf("No contact found matching: %s", searchTerm)
+		data.MessageType = "error"
 	}
 }
 
@@ -821,241 +895,257 @@ func handleSearch(w http.ResponseWriter, r *http.Request) {
  * handleDelete processes POST requests to delete contacts
  *
  * @param {http.ResponseWriter} w - HTTP response writer for redirect responses
- * @param {*http.Request} r - HTTP request containing contact name to delete
+ * @param {*http.Request} r - HTTP request containing the ID of the contact to
+ *   delete, or only its name for older forms and scripts
  *
  * This handler:
  * - Validates HTTP method (POST only)
- * - Extracts contact name from form data
- * - Attempts to delete contact from directory
- * - Redirects back to home page with success/error message
+ * - Extracts contact ID and name from form data
+ * - Attempts to delete contact from directory; a name shared by several
+ *   contacts deletes none of them and lists the candidates
+ * - Redirects back to home page with success/error message, or answers the
+ *   updated parts of the page to a fetch request (see reply)
  */
-func handleDelete(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 	// Enforce POST method for data modification operations
 	if r.Method != "POST" {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	// Extract contact name to delete from form data
+	// Extract contact to delete from form data
 	name := r.FormValue("name")
 
-	// Attempt to delete contact from directory
-	err := dir.DeleteContact(name)
+	// Attempt to delete contact from directory, by ID when the form has one
+	var err error
+	if idText := r.FormValue("id"); idText != "" {
+		id, convErr := strconv.Atoi(idText)
+		if convErr != nil {
+			s.replyError(w, r, fmt.Errorf("invalid contact ID %q: %w", idText, annuaire.ErrInvalidInput))
+			return
+		}
+		var contact annuaire.Contact
+		if contact, err = s.dir.ResolveContact("#" + idText); err == nil {
+			name = contact.First + " " + contact.Name
+			err = s.traced(r).DeleteContactByID(id)
+		}
+	} else {
+		err = s.traced(r).DeleteContact(name)
+	}
 
-	// Prepare redirect URL with appropriate success/error message
-	redirectURL := "/"
+	// Redirect back to home page with a one-time success/error message
 	if err != nil {
 		// Format error message for user display
-		message := fmt.Sprintf("Error: %v", err)
-		redirectURL = fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-	} else {
-		// Format success message with deleted contact name
-		message := fmt.Sprintf("Contact %s deleted successfully from local memory", name)
-		redirectURL = fmt.Sprintf("/?message=%s&type=success", url.QueryEscape(message))
+		s.replyError(w, r, err)
+		return
 	}
-
-	// Redirect back to home page to display result
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	// Format success message with deleted contact name
+	s.reply(w, r, flashSuccess, fmt.Sprintf("Contact %s deleted successfully from local memory", name))
 }
 
 /**
- * handleExport prepares contact data for download as JSON file
+ * handleExport streams the contact directory as a downloadable JSON file
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer receiving the JSON document
+ * @param {*http.Request} r - HTTP GET request with optional "filename" and "filter" parameters
  *
  * This handler:
- * - Validates HTTP method (POST only)
- * - Extracts or defaults the filename for export
- * - Creates a temporary directory for export files
- * - Exports the contact directory to a JSON file
- * - Redirects with a download link or error message
+ * - Accepts GET requests only (the export form submits with GET)
+ * - Keeps only the contacts matching "filter" like the search box, when it is set
+ * - Encodes the contacts straight into the response
+ * - Suggests the requested filename through Content-Disposition (path components dropped)
+ * - Compresses the download with gzip when the filename ends with ".gz"
+ * - Leaves out the fields marked as sensitive in settings
+ *
+ * Nothing is written to disk, so there is no temporary file to clean up and
+ * no second request that could race with its deletion
  */
-func handleExport(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	filename := r.FormValue("filename")
-	if filename == "" {
+	// Only keep the base name: the filename is a download hint, never a path
+	filename := path.Base(strings.ReplaceAll(r.FormValue("filename"), `\`, "/"))
+	if filename == "" || filename == "." || filename == "/" {
 		filename = "contacts_export.json"
 	}
 
-	// Create temp directory if it doesn't exist
-	tempDir := "temp"
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		message := "Error creating temporary directory"
-		redirectURL := fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
-		return
+	// Match the masked values, so that the filter cannot probe hidden fields
+	sensitive := s.loadSettings().SensitiveFields
+	matches := annuaire.ExportMatching(r.FormValue("filter"), "")
+	contacts := slices.DeleteFunc(s.dir.ListContacts(), func(c annuaire.Contact) bool {
+		return !matches(annuaire.MaskContact(c, sensitive))
+	})
+
+	// A ".yaml" or ".xml" name selects that document format; redaction is
+	// only defined for JSON, so masked fields keep JSON downloads
+	format := annuaire.FormatForName(filename)
+	if _, ok := downloadTypes[format]; !ok || len(sensitive) > 0 {
+		format = "json"
 	}
 
-	// Full path of temporary file
-	tempFile := filepath.Join(tempDir, filename)
-
-	err := dir.ExportToJSON(tempFile)
-
-	// Prepare redirect URL with message
-	redirectURL := "/"
-	if err != nil {
-		message := fmt.Sprintf("Export error: %v", err)
-		redirectURL = fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-	} else {
-		downloadURL := fmt.Sprintf("/download/%s", filename)
-		message := fmt.Sprintf(`Export successful! <a href="%s" class="download-btn">Download %s</a>`, downloadURL, filename)
-		redirectURL = fmt.Sprintf("/?message=%s&type=success", url.QueryEscape(message))
+	// Set download headers; a ".gz" name asks for a compressed download,
+	// like ExportToJSON
+	compress := annuaire.IsCompressedName(filename)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Type", downloadTypes[format])
+	if compress {
+		w.Header().Set("Content-Type", "application/gzip")
 	}
 
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
-}
-
-// handleDownload serves exported files for download
-// Automatically deletes temporary files after serving
-func handleDownload(w http.ResponseWriter, r *http.Request) {
-	// Extract filename from URL
-	filename := r.URL.Path[len("/download/"):]
-
-	// Full file path
-	filepath := filepath.Join("temp", filename)
-
-	// Check if file exists
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
-		http.Error(w, "File not found", http.StatusNotFound)
+	if r.Method == http.MethodHead {
 		return
 	}
 
-	// Open file
-	file, err := os.Open(filepath)
-	if err != nil {
-		http.Error(w, "Error opening file", http.StatusInternalServerError)
-		return
+	var out io.Writer = w
+	if compress {
+		zw := gzip.NewWriter(w)
+		defer zw.Close()
+		out = zw
 	}
-	defer file.Close()
 
-	// Set download headers
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-	w.Header().Set("Content-Type", "application/json")
-
-	// Copy file content to response
-	_, err = io.Copy(w, file)
+	// Use the same layout as ExportToJSON so downloads can be re-imported anywhere;
+	// web users are not administrators, so sensitive fields are left out
+	var err error
+	if len(sensitive) > 0 {
+		pipeline := annuaire.ExportPipeline{Encoder: annuaire.RedactedJSONEncoder(sensitive)}
+		err = pipeline.Run(contacts, out)
+	} else {
+		err = s.dir.ExportContacts(contacts, format, out)
+	}
+	// The status is already sent: a failure can only be logged
 	if err != nil {
-		http.Error(w, "Download error", http.StatusInternalServerError)
-		return
+		slog.Warn("export write failed", "error", err)
 	}
+}
 
-	// Delete temporary file after download
-	go func() {
-		os.Remove(filepath)
-	}()
+// downloadTypes are the content types of the formats offered by handleExport
+var downloadTypes = map[string]string{
+	"json": "application/json",
+	"yaml": "application/yaml",
+	"xml":  "application/xml",
 }
 
 /**
- * handleImport processes uploaded JSON files and imports contact data
+ * handleImport reads an uploaded file and shows what importing it would do
  *
  * This handler:
  * - Validates HTTP method (POST only)
  * - Parses the multipart form data containing the file
- * - Creates a temporary file for the uploaded content
- * - Imports contact data from the JSON file into the directory
- * - Redirects with success/error message
+ * - Reads the contacts straight from the upload (JSON, YAML, XML, CSV, LDIF
+ *   or vCard, by file extension), without a temporary file, and
+ *   checks every row against the directory for the chosen "strategy"
+ * - Renders a preview with per-row errors and duplicate warnings; nothing is
+ *   imported until the preview is confirmed (see handleImportConfirm)
  */
-func handleImport(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
 	// Parse multipart form
-	err := r.ParseMultipartForm(10 << 20) // 10 MB max
+	err := r.ParseMultipartForm(multipartMemory)
+	if err != nil {
+		redirectWithFlash(w, r, flashError, fmt.Sprintf("Form parsing error: %v", err))
+		return
+	}
+
+	// The strategy selector defaults to a full replace, as before it existed
+	strategy, err := annuaire.ParseImportStrategy(r.FormValue("strategy"))
 	if err != nil {
-		message := fmt.Sprintf("Form parsing error: %v", err)
-		redirectURL := fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		redirectWithFlash(w, r, flashError, fmt.Sprintf("Import error: %v", err))
 		return
 	}
 
 	// Get uploaded file
 	file, header, err := r.FormFile("file")
 	if err != nil {
-		message := fmt.Sprintf("File retrieval error: %v", err)
-		redirectURL := fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		redirectWithFlash(w, r, flashError, fmt.Sprintf("File retrieval error: %v", err))
 		return
 	}
 	defer file.Close()
 
-	// Create temporary file
-	tempDir := "temp"
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		message := "Error creating temporary directory"
-		redirectURL := fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
-		return
+	// Read the upload directly; the extension of its name selects the reader,
+	// unless the form names the application a CSV was exported from
+	name, dialect := header.Filename, r.FormValue("dialect")
+	if format := r.FormValue("format"); format != "" {
+		preset, ok := annuaire.LookupCSVPreset(format)
+		if !ok {
+			redirectWithFlash(w, r, flashError, fmt.Sprintf("Import error: unknown format %q", format))
+			return
+		}
+		name, dialect = "upload.csv", preset.Name
 	}
-
-	tempFile := filepath.Join(tempDir, "import_"+header.Filename)
-	dst, err := os.Create(tempFile)
+	contacts, _, err := annuaire.ReadContacts(file, name, dialect)
 	if err != nil {
-		message := fmt.Sprintf("Temporary file creation error: %v", err)
-		redirectURL := fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		redirectWithFlash(w, r, flashError, fmt.Sprintf("Import error from %s: %v", header.Filename, err))
 		return
 	}
-	defer dst.Close()
-	defer os.Remove(tempFile) // Clean up temporary file
-
-	// Copy uploaded file content
-	_, err = io.Copy(dst, file)
+	preview := s.dir.PreviewImport(contacts, annuaire.ImportOptions{Strategy: strategy})
+	token, err := s.imports.add(header.Filename, preview)
 	if err != nil {
-		message := fmt.Sprintf("File copy error: %v", err)
-		redirectURL := fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		redirectWithFlash(w, r, flashError, fmt.Sprintf("Import error: %v", err))
 		return
 	}
 
-	// Close file before importing
-	dst.Close()
-
-	// Import data
-	err = dir.ImportFromJSON(tempFile)
-
-	// Prepare redirect URL with message
-	redirectURL := "/"
-	if err != nil {
-		message := fmt.Sprintf("Import error from %s: %v", header.Filename, err)
-		redirectURL = fmt.Sprintf("/?message=%s&type=error", url.QueryEscape(message))
-	} else {
-		message := fmt.Sprintf("Data imported successfully from %s (%d contacts loaded)", header.Filename, dir.ContactCount())
-		redirectURL = fmt.Sprintf("/?message=%s&type=success", url.QueryEscape(message))
-	}
-
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	renderTemplate(w, s.templates, "import.html", http.StatusOK, importData{
+		Filename:  header.Filename,
+		Preview:   preview,
+		Token:     token,
+		Valid:     len(preview.Valid()),
+		Added:     preview.Count(annuaire.RowAdd),
+		Updated:   preview.Count(annuaire.RowUpdate),
+		Skipped:   preview.Count(annuaire.RowSkip),
+		Invalid:   preview.Count(annuaire.RowInvalid),
+		CSRFToken: csrfToken(r),
+	})
 }
 
 /**
- * handleClear removes all contacts from local memory
+ * handleImportVCard creates contacts from vCard text pasted into the form
  *
  * @param {http.ResponseWriter} w - HTTP response writer for redirect responses
- * @param {*http.Request} r - HTTP request (POST method required)
- *
- * This handler provides a complete reset functionality by:
- * - Creating a new empty directory instance
- * - Replacing the global directory variable
- * - Redirecting with success confirmation message
+ * @param {*http.Request} r - HTTP POST request with the "vcard" form field
  *
- * Note: This operation only affects the in-memory data, not any saved files
+ * This handler covers scanned badges: a phone or QR reader decodes the
+ * badge into vCard text, which is pasted here. Unlike a file import it is
+ * additive: each card is added to the existing contacts, and cards that are
+ * incomplete or already present are counted in the result message.
  */
-func handleClear(w http.ResponseWriter, r *http.Request) {
-	// Enforce POST method for data modification operations
+func (s *Server) handleImportVCard(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 		return
 	}
 
-	// Replace global directory with new empty instance
-	// This effectively clears all contacts from memory
-	dir = annuaire.NewDirectory()
+	contacts, report, err := annuaire.ReadVCards(strings.NewReader(r.FormValue("vcard")))
+	if err == nil && report.Imported == 0 && report.Skipped == 0 {
+		err = errors.New("no BEGIN:VCARD block found")
+	}
+	if err != nil {
+		redirectWithFlash(w, r, flashError, fmt.Sprintf("vCard error: %v", err))
+		return
+	}
+
+	// Add the cards to the existing contacts, counting the ones refused
+	results, _ := s.traced(r).AddContacts(contacts)
+	added, duplicates := annuaire.CountAdds(results, annuaire.AddAdded), annuaire.CountAdds(results, annuaire.AddDuplicate)
+	report.Skipped += annuaire.CountAdds(results, annuaire.AddInvalid)
 
-	// Prepare success message and redirect to home page
-	message := "Local memory cleared successfully"
-	redirectURL := fmt.Sprintf("/?message=%s&type=success", url.QueryEscape(message))
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	message := fmt.Sprintf("%d contact(s) created from vCard", added)
+	if duplicates > 0 {
+		message += fmt.Sprintf(", %d already present", duplicates)
+	}
+	if report.Skipped > 0 {
+		message += fmt.Sprintf(", %d incomplete card(s) skipped", report.Skipped)
+	}
+	messageType := flashSuccess
+	if added == 0 {
+		messageType = flashError
+	}
+	redirectWithFlash(w, r, messageType, message)
 }
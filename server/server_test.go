@@ -0,0 +1,1704 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tp1/annuaire"
+	"tp1/settings"
+	"tp1/tracing"
+)
+
+// postForm sends a form to the handler, optionally with the CSRF cookie and field
+func postForm(handler http.Handler, path string, form url.Values, token string) *httptest.ResponseRecorder {
+	if token != "" {
+		form.Set(csrfFieldName, token)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if token != "" {
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// sessionToken performs a page view and returns the CSRF token it issued
+func sessionToken(t *testing.T, handler http.Handler) string {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == csrfCookieName {
+			return cookie.Value
+		}
+	}
+	t.Fatal("No CSRF cookie issued on page view")
+	return ""
+}
+
+// TestNewServerHandler tests the handler through httptest without any global state
+func TestNewServerHandler(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+
+	form := url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}}
+	rec := postForm(handler, "/add", form, token)
+
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("Expected redirect after add, got %d", rec.Code)
+	}
+	if dir.ContactCount() != 1 {
+		t.Fatalf("Expected the contact in the injected directory, got %d contacts", dir.ContactCount())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), "Jean Dupont") {
+		t.Error("Home page does not list the added contact")
+	}
+}
+
+// TestCSRFRejectsForgedPost tests that mutating requests need a matching token
+func TestCSRFRejectsForgedPost(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	handler := NewServer(dir)
+
+	// No token at all, as sent by a cross-site form
+	if rec := postForm(handler, "/clear", url.Values{}, ""); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without token, got %d", rec.Code)
+	}
+
+	// Cookie present but the form carries another value
+	form := url.Values{csrfFieldName: {"forged"}}
+	req := httptest.NewRequest(http.MethodPost, "/clear", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: sessionToken(t, handler)})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 with mismatched token, got %d", rec.Code)
+	}
+
+	if dir.ContactCount() != 1 {
+		t.Error("Forged request modified the directory")
+	}
+}
+
+// TestPrintPage tests the alphabetical groups, the tag filter and masking of /print
+func TestPrintPage(t *testing.T) {
+	settingsFile := filepath.Join(t.TempDir(), settings.FileName)
+	cfg := &settings.Settings{SensitiveFields: []string{"phone"}}
+	if err := cfg.Save(settingsFile); err != nil {
+		t.Fatal(err)
+	}
+	dir := annuaire.NewDirectory()
+	dir.InsertContact(annuaire.Contact{Name: "Martin", First: "Lucie", Phone: "0700000001", Tags: []string{"work"}})
+	dir.InsertContact(annuaire.Contact{Name: "Émile", First: "Paul", Phone: "0700000002"})
+	dir.InsertContact(annuaire.Contact{Name: "Dupont", First: "Jean", Phone: "0612345612", Tags: []string{"work"}})
+	handler := NewServer(dir, WithSettingsFile(settingsFile))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/print", nil))
+	body := rec.Body.String()
+	d, e, m := strings.Index(body, "<h2>D</h2>"), strings.Index(body, "<h2>E</h2>"), strings.Index(body, "<h2>M</h2>")
+	if d < 0 || !(d < e && e < m) {
+		t.Errorf("Expected the D, E and M groups in order:\n%s", body)
+	}
+	if strings.Contains(body, "0612345612") || !strings.Contains(body, "06 ** ** ** 12") {
+		t.Error("Sensitive phone printed unmasked")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/print?tag=work", nil))
+	body = rec.Body.String()
+	if !strings.Contains(body, "tagged work (2)") || strings.Contains(body, "Émile") {
+		t.Errorf("Tag filter not applied:\n%s", body)
+	}
+}
+
+// TestBoard tests the wall board: one initial at a time, masked, cycling
+func TestBoard(t *testing.T) {
+	settingsFile := filepath.Join(t.TempDir(), settings.FileName)
+	cfg := &settings.Settings{SensitiveFields: []string{"phone"}}
+	if err := cfg.Save(settingsFile); err != nil {
+		t.Fatal(err)
+	}
+	dir := annuaire.NewDirectory()
+	dir.InsertContact(annuaire.Contact{Name: "Martin", First: "Lucie", Phone: "0700000001", Tags: []string{"staff"}})
+	dir.InsertContact(annuaire.Contact{Name: "Dupont", First: "Jean", Phone: "0612345612", Tags: []string{"staff"}})
+	dir.InsertContact(annuaire.Contact{Name: "Durand", First: "Paul", Phone: "0700000002"})
+	for i := range 30 {
+		dir.AddContact("Zola", fmt.Sprintf("Émile%02d", i), fmt.Sprintf("01000000%02d", i))
+	}
+	handler := NewServer(dir, WithSettingsFile(settingsFile))
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: %d", target, rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	body := get("/board")
+	if !strings.Contains(body, "Jean Dupont") || !strings.Contains(body, "Paul Durand") || strings.Contains(body, "Lucie") {
+		t.Errorf("The board should start with the D contacts only:\n%s", body)
+	}
+	if !strings.Contains(body, `content="20;url=/board?letter=M"`) || strings.Contains(body, "<form") || strings.Contains(body, "<button") {
+		t.Errorf("The board should move on to M by itself, without controls:\n%s", body)
+	}
+	if strings.Contains(body, "0612345612") || !strings.Contains(body, "06 ** ** ** 12") {
+		t.Error("Sensitive phone shown unmasked on the board")
+	}
+
+	// A letter without contacts shows the next one; a long letter is paged
+	if body := get("/board?letter=n&refresh=1"); !strings.Contains(body, "Émile00 Zola") || strings.Contains(body, "Émile24 Zola") ||
+		!strings.Contains(body, `content="5;url=/board?letter=Z&amp;page=2&amp;refresh=5"`) {
+		t.Errorf("Expected the first page of Z, refreshed after 5 seconds:\n%s", body)
+	}
+	if body := get("/board?letter=Z&page=2"); !strings.Contains(body, "Émile29 Zola") || !strings.Contains(body, "url=/board?letter=D") {
+		t.Errorf("The last page of Z should lead back to D:\n%s", body)
+	}
+	if body := get("/board?letter=M&tag=staff"); !strings.Contains(body, "Lucie Martin") || !strings.Contains(body, "2 contacts") ||
+		!strings.Contains(body, "url=/board?letter=D&amp;tag=staff") {
+		t.Errorf("The tag should restrict the board and be kept when cycling:\n%s", body)
+	}
+}
+
+// TestSensitiveFieldsMasked tests the share page and web export with a sensitive phone
+func TestSensitiveFieldsMasked(t *testing.T) {
+	settingsFile := filepath.Join(t.TempDir(), settings.FileName)
+	cfg := &settings.Settings{SensitiveFields: []string{"phone"}}
+	if err := cfg.Save(settingsFile); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0612345612")
+	handler := NewServer(dir, WithSettingsFile(settingsFile))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/share", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "06 ** ** ** 12") || strings.Contains(body, "0612345612") {
+		t.Errorf("Share page does not mask the phone:\n%s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export", nil))
+	if body := rec.Body.String(); strings.Contains(body, "phone") || !strings.Contains(body, "Dupont") {
+		t.Errorf("Web export should leave out the phone:\n%s", body)
+	}
+
+	// The filter matches masked values, so it cannot reveal the hidden digits
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export?filter=061234", nil))
+	if body := rec.Body.String(); strings.Contains(body, "Dupont") {
+		t.Errorf("Filter should not match the hidden phone:\n%s", body)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export?filter=dup", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "Dupont") {
+		t.Errorf("Filter should match the name:\n%s", body)
+	}
+
+	// Sync copies every field, so it is refused rather than masked
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/sync", nil))
+	if rec.Code != http.StatusForbidden || strings.Contains(rec.Body.String(), "Dupont") {
+		t.Errorf("Expected sync to be refused, got %d:\n%s", rec.Code, rec.Body)
+	}
+}
+
+// TestFlashMessage tests that messages come from the flash cookie, not the URL
+func TestFlashMessage(t *testing.T) {
+	handler := NewServer(annuaire.NewDirectory())
+
+	// A crafted link must not display anything
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?message=%3Cscript%3Ealert(1)%3C/script%3E&type=success", nil))
+	if body := rec.Body.String(); strings.Contains(body, "alert(1)") {
+		t.Error("Message query parameter is still rendered")
+	}
+
+	token := sessionToken(t, handler)
+	rec = postForm(handler, "/add", url.Values{"name": {"<b>Dupont</b>"}, "first": {"Jean"}, "phone": {"0123456789"}}, token)
+	var flash *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == flashCookieName {
+			flash = cookie
+		}
+	}
+	if flash == nil || rec.Header().Get("Location") != "/" {
+		t.Fatalf("Expected a flash cookie and a plain redirect, got %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(flash)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	if !strings.Contains(body, "Contact Jean &lt;b&gt;Dupont&lt;/b&gt; added successfully") {
+		t.Errorf("Flash message missing or not escaped:\n%s", body)
+	}
+	if cookies := rec.Result().Cookies(); len(cookies) == 0 || cookies[len(cookies)-1].MaxAge >= 0 {
+		t.Error("Flash cookie should be cleared once displayed")
+	}
+}
+
+// TestBirthdayPanel tests the add form birthday and the home page panel
+func TestBirthdayPanel(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+
+	today := time.Now().Format("2006-01-02")
+	postForm(handler, "/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}, "birthday": {today}}, token)
+	postForm(handler, "/add", url.Values{"name": {"Martin"}, "first": {"Paul"}, "phone": {"0987654321"}, "birthday": {"12/04/1985"}}, token)
+	if dir.ContactCount() != 1 {
+		t.Fatalf("Expected the invalid birthday to be rejected, got %d contacts", dir.ContactCount())
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "Birthdays this month") || !strings.Contains(body, "today!") {
+		t.Errorf("Birthday panel missing today's birthday")
+	}
+}
+
+// TestAddressLink tests the address fields of the add form and the map link of the contact card
+func TestAddressLink(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+
+	postForm(handler, "/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"},
+		"street": {" 12 rue de la Paix "}, "zip": {"75002"}, "city": {"Paris"}}, token)
+	contact, err := dir.ResolveContact("Dupont")
+	if err != nil || contact.Address != (annuaire.Address{Street: "12 rue de la Paix", Zip: "75002", City: "Paris"}) {
+		t.Fatalf("Address not stored: %+v (%v)", contact.Address, err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `href="https://www.openstreetmap.org/search?query=12&#43;rue&#43;de&#43;la&#43;Paix%2C&#43;75002&#43;Paris"`) ||
+		!strings.Contains(body, ">12 rue de la Paix, 75002 Paris</a>") {
+		t.Errorf("Map link missing from the contact card:\n%s", body)
+	}
+}
+
+// TestWithBasePath tests a handler mounted under a prefix of another mux
+func TestWithBasePath(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	mux := http.NewServeMux()
+	mux.Handle("/contacts/", NewServer(dir, WithBasePath("/contacts")))
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/contacts/", nil))
+	if body := rec.Body.String(); rec.Code != http.StatusOK || !strings.Contains(body, `action="/contacts/search"`) || !strings.Contains(body, `data-suggest="/contacts/api/suggest"`) {
+		t.Fatalf("Expected the home page with prefixed links, got %d:\n%s", rec.Code, body)
+	}
+	token := ""
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == csrfCookieName {
+			token = cookie.Value
+		}
+	}
+	rec = postForm(mux, "/contacts/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}}, token)
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/contacts/" {
+		t.Errorf("Expected a redirect to /contacts/, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if len(dir.ListContacts()) != 1 {
+		t.Error("Contact not added through the prefix")
+	}
+}
+
+// TestCORS tests the cross-origin answers of the API
+func TestCORS(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir, WithCORS(CORS{Origins: []string{"http://localhost:5173"}}))
+	request := func(method, path, origin string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodOptions, "/api/contacts/1", "http://localhost:5173", http.Header{"Access-Control-Request-Method": {"PATCH"}})
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "http://localhost:5173" ||
+		!strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), "PATCH") || !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), csrfHeaderName) {
+		t.Errorf("Unexpected preflight answer %d %v", rec.Code, rec.Header())
+	}
+	if rec := request(http.MethodOptions, "/api/contacts", "http://localhost:5173", http.Header{"Access-Control-Request-Method": {"PUT"}}); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a method not allowed, got %d", rec.Code)
+	}
+	if rec := request(http.MethodOptions, "/api/contacts", "https://evil.example", http.Header{"Access-Control-Request-Method": {"GET"}}); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for another origin, got %d", rec.Code)
+	}
+
+	rec = request(http.MethodGet, "/api/contacts", "http://localhost:5173", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Credentials") != "true" ||
+		rec.Header().Get(csrfHeaderName) == "" || !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), csrfHeaderName) {
+		t.Errorf("Unexpected API answer %d %v", rec.Code, rec.Header())
+	}
+	if rec := request(http.MethodGet, "/api/contacts", "https://evil.example", nil); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Another origin was allowed: %v", rec.Header())
+	}
+	if rec := request(http.MethodGet, "/", "http://localhost:5173", nil); rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Pages must not be shared with other origins: %v", rec.Header())
+	}
+
+	handler = NewServer(dir, WithCORS(CORS{Origins: ParseCORSList("*")}))
+	if rec := request(http.MethodGet, "/api/contacts", "https://any.example", nil); rec.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("Expected any origin without credentials, got %v", rec.Header())
+	}
+}
+
+// TestCustomFieldsForm tests the key/value rows of the add form
+func TestCustomFieldsForm(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `name="custom_key"`) || !strings.Contains(body, "data-add-custom") {
+		t.Fatalf("Custom field editor missing from the add form:\n%s", body)
+	}
+
+	postForm(handler, "/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"},
+		"custom_key": {"badge number", "", "floor"}, "custom_value": {"1234", "", " 3 "}}, token)
+	contact, err := dir.ResolveContact("Dupont")
+	if err != nil || !reflect.DeepEqual(contact.Custom, map[string]string{"badge number": "1234", "floor": "3"}) {
+		t.Fatalf("Custom fields not stored: %v (%v)", contact.Custom, err)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `<p class="contact-custom">badge number: 1234</p>`) {
+		t.Errorf("Custom fields missing from the contact card:\n%s", body)
+	}
+
+	// A value without a name is refused
+	postForm(handler, "/add", url.Values{"name": {"Martin"}, "first": {"Paul"}, "phone": {"0698765432"},
+		"custom_key": {" "}, "custom_value": {"orphan"}}, token)
+	if _, err := dir.ResolveContact("Martin"); err == nil {
+		t.Error("Expected a value without a name to be refused")
+	}
+}
+
+// TestAPIContactsPagination tests filtering, sorting and the pagination envelope
+func TestAPIContactsPagination(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	for i, name := range []string{"Dupont", "Durand", "Martin", "Duval", "Bernard"} {
+		if err := dir.AddContact(name, "Jean", "010000000"+strconv.Itoa(i)); err != nil {
+			t.Fatalf("AddContact(%s): %v", name, err)
+		}
+	}
+	handler := NewServer(dir)
+
+	get := func(target string) (int, contactPage) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var page contactPage
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+				t.Fatalf("GET %s: invalid JSON: %v", target, err)
+			}
+		}
+		return rec.Code, page
+	}
+
+	code, page := get("/api/contacts?q=du&sort=name&order=desc&per_page=2")
+	if code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if page.Total != 3 || page.Pages != 2 || len(page.Contacts) != 2 {
+		t.Fatalf("Expected 3 matches on 2 pages, got total=%d pages=%d len=%d", page.Total, page.Pages, len(page.Contacts))
+	}
+	if page.Contacts[0].Name != "Duval" || page.Contacts[1].Name != "Durand" {
+		t.Errorf("Unexpected order on page 1: %v", page.Contacts)
+	}
+	if page.Links.Prev != "" || page.Links.Next == "" {
+		t.Errorf("Page 1 links: %+v", page.Links)
+	}
+
+	_, page = get(page.Links.Next)
+	if page.Page != 2 || len(page.Contacts) != 1 || page.Contacts[0].Name != "Dupont" || page.Links.Next != "" {
+		t.Errorf("Unexpected page 2: %+v", page)
+	}
+
+	if _, page = get("/api/contacts?page=9"); page.Total != 5 || len(page.Contacts) != 0 {
+		t.Errorf("Expected an empty page past the end, got %+v", page)
+	}
+	for _, target := range []string{"/api/contacts?sort=birthday", "/api/contacts?order=up", "/api/contacts?page=0", "/api/contacts?per_page=1000"} {
+		if code, _ := get(target); code != http.StatusBadRequest {
+			t.Errorf("GET %s: expected 400, got %d", target, code)
+		}
+	}
+
+	// Search parameters combine with AND
+	durand, _ := dir.ResolveContact("Durand")
+	durand.Tags = []string{"work"}
+	if err := dir.SaveContact(durand); err != nil {
+		t.Fatal(err)
+	}
+	for target, want := range map[string]int{
+		"/api/contacts?first=jean&phone=01":       5,
+		"/api/contacts?name=du&tag=work":          1,
+		"/api/contacts?q=jean&name=du&phone=0100": 3,
+		"/api/contacts?name=du&tag=family":        0,
+	} {
+		if _, page = get(target); page.Total != want {
+			t.Errorf("GET %s: expected %d matches, got %d", target, want, page.Total)
+		}
+	}
+}
+
+// TestOpenAPIDocument tests that the served specification matches the handlers
+func TestOpenAPIDocument(t *testing.T) {
+	handler := NewServer(annuaire.NewDirectory())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			Parameters []struct {
+				Name   string `json:"name"`
+				Schema struct {
+					Enum    []string `json:"enum"`
+					Maximum int      `json:"maximum"`
+				} `json:"schema"`
+			} `json:"parameters"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid OpenAPI JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got %q", doc.OpenAPI)
+	}
+
+	params := map[string]bool{}
+	for _, param := range doc.Paths["/api/contacts"]["get"].Parameters {
+		params[param.Name] = true
+		if param.Name == "sort" && strings.Join(param.Schema.Enum, ",") != strings.Join(annuaire.SortKeys, ",") {
+			t.Errorf("Documented sort keys %v, handler accepts %v", param.Schema.Enum, annuaire.SortKeys)
+		}
+		if param.Name == "per_page" && param.Schema.Maximum != maxPerPage {
+			t.Errorf("Documented per_page maximum %d, handler accepts %d", param.Schema.Maximum, maxPerPage)
+		}
+	}
+	for _, name := range []string{"q", "name", "first", "phone", "tag", "sort", "order", "page", "per_page"} {
+		if !params[name] {
+			t.Errorf("Parameter %s of /api/contacts is not documented", name)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "SwaggerUIBundle") || !strings.Contains(body, `"openapi.json"`) {
+		t.Error("Documentation page does not load the specification in Swagger UI")
+	}
+}
+
+// TestSearchLimits tests that web and API searches report truncated results
+func TestSearchLimits(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	for i := range 5 {
+		if err := dir.AddContact("Dupont", "Jean"+strconv.Itoa(i), "010000000"+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	settingsFile := filepath.Join(t.TempDir(), "settings.json")
+	if err := (&settings.Settings{SearchMaxResults: 2}).Save(settingsFile); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewServer(dir, WithSettingsFile(settingsFile))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/search?name=Dupont", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "More than 2 contacts found") || !strings.Contains(body, "(2+ found)") {
+		t.Error("Web search does not report the truncated result")
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/contacts?q=dupont", nil))
+	var page contactPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if !page.Truncated || page.Total != 2 || len(page.Contacts) != 2 {
+		t.Errorf("Expected 2 truncated API results, got total=%d truncated=%v", page.Total, page.Truncated)
+	}
+}
+
+// TestAccessLog tests the access log middleware in both formats
+func TestAccessLog(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	})
+
+	var common strings.Builder
+	logged, err := accessLog(handler, AccessLogCommon, &common)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/search?q=secret", nil)
+	req.RemoteAddr = "192.0.2.7:51234"
+	logged.ServeHTTP(httptest.NewRecorder(), req)
+	line := common.String()
+	if !strings.HasPrefix(line, "192.0.2.7 - - [") || !strings.Contains(line, `] "GET /search HTTP/1.1" 200 5 `) {
+		t.Errorf("Unexpected common log line: %q", line)
+	}
+	if strings.Contains(line, "secret") {
+		t.Errorf("Query string should not be logged: %q", line)
+	}
+
+	var buf strings.Builder
+	logged, err = accessLog(handler, AccessLogJSON, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/missing", nil))
+	var entry accessEntry
+	if err := json.Unmarshal([]byte(buf.String()), &entry); err != nil {
+		t.Fatalf("Invalid JSON log line %q: %v", buf.String(), err)
+	}
+	if entry.Method != http.MethodPost || entry.Path != "/missing" || entry.Status != http.StatusNotFound || entry.Remote != "192.0.2.1" {
+		t.Errorf("Unexpected JSON entry: %+v", entry)
+	}
+
+	if _, err := accessLog(handler, "xml", &buf); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// TestRateLimit tests the per-IP token bucket on state-changing requests
+func TestRateLimit(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if got := rateLimit(handler, RateLimit{}); got == nil {
+		t.Fatal("Zero limit should return the handler")
+	}
+
+	limited := rateLimit(handler, RateLimit{PerMinute: 60, Burst: 2})
+	send := func(method, remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/add", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		limited.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range 2 {
+		if rec := send(http.MethodPost, "192.0.2.1:1000"); rec.Code != http.StatusNoContent {
+			t.Fatalf("Request %d within the burst refused: %d", i+1, rec.Code)
+		}
+	}
+	rec := send(http.MethodPost, "192.0.2.1:1001")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429 after the burst, got %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", rec.Header().Get("Retry-After"))
+	}
+	if rec := send(http.MethodGet, "192.0.2.1:1002"); rec.Code != http.StatusNoContent {
+		t.Errorf("GET should not be limited, got %d", rec.Code)
+	}
+	if rec := send(http.MethodPost, "192.0.2.2:1000"); rec.Code != http.StatusNoContent {
+		t.Errorf("Another client should have its own bucket, got %d", rec.Code)
+	}
+
+	// Tokens come back over time, and idle buckets are pruned
+	now := time.Now()
+	limiter := newRateLimiter(RateLimit{PerMinute: 60, Burst: 1})
+	limiter.now = func() time.Time { return now }
+	if ok, _ := limiter.allow("a"); !ok {
+		t.Fatal("First request refused")
+	}
+	if ok, wait := limiter.allow("a"); ok || wait != time.Second {
+		t.Errorf("Expected a refusal for 1s, got %v %v", ok, wait)
+	}
+	now = now.Add(time.Second)
+	if ok, _ := limiter.allow("a"); !ok {
+		t.Error("Token not refilled after 1s")
+	}
+	now = now.Add(2 * rateLimitPruneInterval)
+	limiter.allow("b")
+	if _, ok := limiter.buckets["a"]; ok {
+		t.Error("Idle bucket not pruned")
+	}
+}
+
+// TestTemplatesDir tests the embedded assets and their customization directory
+func TestTemplatesDir(t *testing.T) {
+	get := func(handler http.Handler, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir)
+	if rec := get(handler, "/static/style.css"); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), ".contact-card") {
+		t.Errorf("Embedded stylesheet not served: %d", rec.Code)
+	}
+	if body := get(handler, "/").Body.String(); !strings.Contains(body, `href="/static/style.css"`) {
+		t.Error("Home page does not link the stylesheet")
+	}
+
+	custom := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(custom, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(custom, "static"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(custom, "templates", "home.html"), []byte(`<h1>Our directory: {{.ContactCount}} contacts</h1>`), 0644)
+	os.WriteFile(filepath.Join(custom, "static", "app.js"), []byte(`// custom`), 0644)
+
+	handler = NewServer(dir, WithTemplatesDir(custom))
+	if body := get(handler, "/").Body.String(); body != "<h1>Our directory: 0 contacts</h1>" {
+		t.Errorf("Custom home template not used: %q", body)
+	}
+	if body := get(handler, "/static/app.js").Body.String(); body != "// custom" {
+		t.Errorf("Custom static file not served: %q", body)
+	}
+	if body := get(handler, "/share").Body.String(); !strings.Contains(body, "<!DOCTYPE html>") {
+		t.Error("Templates missing from the directory should keep the embedded version")
+	}
+
+	// A template that does not parse falls back to the embedded pages
+	os.WriteFile(filepath.Join(custom, "templates", "home.html"), []byte(`{{if}}`), 0644)
+	handler = NewServer(dir, WithTemplatesDir(custom))
+	if body := get(handler, "/").Body.String(); !strings.Contains(body, `href="/static/style.css"`) {
+		t.Error("Broken custom template not replaced by the embedded one")
+	}
+}
+
+// TestOfflineAssets tests that the main page loads nothing from the network
+// and that every icon it uses is defined by the local icon stylesheet
+func TestOfflineAssets(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewServer(annuaire.NewDirectory()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+	for _, attr := range regexp.MustCompile(`(?:href|src)="(https?:)?//[^"]*"`).FindAllString(body, -1) {
+		t.Errorf("Home page loads an external resource: %s", attr)
+	}
+
+	css, err := fs.ReadFile(embeddedAssets, "static/icons.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(embeddedAssets, "static/fonts/fontawesome-webfont.woff2"); err != nil {
+		t.Errorf("Icon font not embedded: %v", err)
+	}
+	templates, err := fs.Glob(embeddedAssets, "templates/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range templates {
+		content, _ := fs.ReadFile(embeddedAssets, name)
+		for _, icon := range regexp.MustCompile(`\bfa-[a-z0-9-]+`).FindAllString(string(content), -1) {
+			if !strings.Contains(string(css), "."+icon+":before") {
+				t.Errorf("%s uses %s, missing from static/icons.css", name, icon)
+			}
+		}
+	}
+}
+
+// TestRecentPanel tests that the home page lists the contacts changed last, newest first
+func TestRecentPanel(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0100000001")
+	dir.AddContact("Bernard", "Luc", "0100000002")
+	handler := NewServer(dir)
+	home := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		body := rec.Body.String()
+		start := strings.Index(body, "Recently added or changed")
+		if start < 0 {
+			t.Fatal("Home page lacks the recent panel")
+		}
+		return body[start : start+strings.Index(body[start:], "</ul>")]
+	}
+
+	if panel := home(); !(strings.Index(panel, "Luc Bernard") < strings.Index(panel, "Jean Dupont")) || !strings.Contains(panel, `href="/search?name=Bernard"`) {
+		t.Errorf("Unexpected recent panel: %s", panel)
+	}
+	time.Sleep(time.Millisecond) // Dates the change after the additions
+	if err := dir.UpdateContact("Dupont", "Jean", "0100000003"); err != nil {
+		t.Fatal(err)
+	}
+	if panel := home(); strings.Index(panel, "Jean Dupont") > strings.Index(panel, "Luc Bernard") {
+		t.Errorf("Changed contact is not first: %s", panel)
+	}
+}
+
+// TestAlphabeticalIndex tests the grouping of the contact list under its initials
+func TestAlphabeticalIndex(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0100000001")
+	dir.AddContact("Bernard", "Luc", "0100000002")
+	dir.AddContact("Émile", "Anne", "0100000003")
+	dir.AddContact("3M", "Support", "0100000004")
+
+	rec := httptest.NewRecorder()
+	NewServer(dir).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+
+	for _, want := range []string{`<a href="#letter-B">B</a>`, `<span>A</span>`, `<a href="#letter-other">#</a>`, `id="letter-E">E</h3>`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Home page lacks %s", want)
+		}
+	}
+	b, d, e := strings.Index(body, `id="letter-B"`), strings.Index(body, `id="letter-D"`), strings.Index(body, `id="letter-E"`)
+	if !(b < d && d < e && strings.LastIndex(body, "Anne Émile") > e) { // Also in the recent panel, above
+		t.Error("Sections are not in alphabetical order")
+	}
+
+	sections, index, _ := listContacts(&url.URL{Path: "/"}, []annuaire.Contact{{Name: "Zola"}, {Name: "Adam"}}, 1)
+	if len(sections) != 2 || !sections[0].Pinned || sections[0].Contacts[0].Name != "Zola" || sections[1].Initial != "A" {
+		t.Errorf("Pinned contacts should come first in their own section: %+v", sections)
+	}
+	if len(index) != len(annuaire.IndexLetters) || index[0].Href != "#letter-A" || index[25].Href != "" {
+		t.Errorf("Pinned contacts should not be indexed: %+v", index)
+	}
+}
+
+// TestWebPagination tests the pages of the web contact list
+func TestWebPagination(t *testing.T) {
+	var contacts []annuaire.Contact
+	for i := range 60 {
+		name := "Alpha"
+		if i >= 30 {
+			name = "Zulu"
+		}
+		contacts = append(contacts, annuaire.Contact{Name: name, First: fmt.Sprintf("N%02d", i)})
+	}
+	list := func(target string) ([]ListSection, []IndexEntry, Pager) {
+		page, err := url.Parse(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return listContacts(page, contacts, 0)
+	}
+	count := func(sections []ListSection) int {
+		n := 0
+		for _, section := range sections {
+			n += len(section.Contacts)
+		}
+		return n
+	}
+
+	sections, index, pager := list("/")
+	if count(sections) != webPerPage || pager.Page != 1 || pager.Pages != 3 || pager.Prev != "" || pager.Next != "/?page=2" {
+		t.Errorf("Unexpected first page: %d contacts, %+v", count(sections), pager)
+	}
+	if index[0].Href != "#letter-A" || index[25].Href != "/?page=2#letter-Z" {
+		t.Errorf("Index should link to the page of each letter: A=%q Z=%q", index[0].Href, index[25].Href)
+	}
+
+	sections, _, pager = list("/search?name=a&page=3")
+	if count(sections) != 10 || pager.Prev != "/search?name=a&page=2" || pager.Next != "" {
+		t.Errorf("Unexpected last page: %d contacts, %+v", count(sections), pager)
+	}
+	if _, _, pager = list("/?page=99"); pager.Page != 3 {
+		t.Errorf("A page past the end should show the last one, got %d", pager.Page)
+	}
+	if _, _, pager = list("/?page=abc"); pager.Page != 1 {
+		t.Errorf("An invalid page should show the first one, got %d", pager.Page)
+	}
+
+	// Through the handler, with the controls
+	dir := annuaire.NewDirectory()
+	for i := range 30 {
+		dir.AddContact("Dupont", fmt.Sprintf("Jean%02d", i), fmt.Sprintf("01000000%02d", i))
+	}
+	rec := httptest.NewRecorder()
+	NewServer(dir).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?page=2", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "Page 2 of 2") || !strings.Contains(body, `href="/?page=1"`) || strings.Contains(body, "Jean00 Dupont") || !strings.Contains(body, "Jean29 Dupont") {
+		t.Error("Second page of the handler is wrong")
+	}
+}
+
+// TestFragmentUpdates tests the partial answers to the fetch requests of the main page
+func TestFragmentUpdates(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	for i := range 30 {
+		dir.AddContact("Dupont", fmt.Sprintf("Jean%02d", i), fmt.Sprintf("01000000%02d", i))
+	}
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	send := func(method, target string, form url.Values, current string) *httptest.ResponseRecorder {
+		var body io.Reader
+		if form != nil {
+			form.Set(csrfFieldName, token)
+			body = strings.NewReader(form.Encode())
+		}
+		req := httptest.NewRequest(method, target, body)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, "true")
+		req.Header.Set(currentURLHeader, current)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(http.MethodPost, "/add", url.Values{"name": {"<b>Martin</b>"}, "first": {"Paul"}, "phone": {"0600000000"}}, "http://example.test/?page=2")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || rec.Header().Get("Location") != "" || strings.Contains(body, "<!DOCTYPE html>") {
+		t.Fatalf("Expected fragments instead of a redirect or a page, got %d", rec.Code)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == flashCookieName {
+			t.Error("Fragments should carry the message themselves, not a flash cookie")
+		}
+	}
+	for _, id := range []string{"message-area", "stats", "search-results", "contact-list"} {
+		if !strings.Contains(body, `id="`+id+`"`) {
+			t.Errorf("Fragment %s missing", id)
+		}
+	}
+	if !strings.Contains(body, "Contact Paul &lt;b&gt;Martin&lt;/b&gt; added successfully") || !strings.Contains(body, "Page 2 of 2") || !strings.Contains(body, `href="/?page=1"`) {
+		t.Errorf("Fragments should show the message and stay on the current page:\n%s", body)
+	}
+
+	if rec := send(http.MethodPost, "/add", url.Values{"name": {""}}, "http://example.test/"); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `class="message error"`) {
+		t.Errorf("A refused addition should answer 400 with an error message, got %d", rec.Code)
+	}
+
+	// Deleting from the search page refreshes its results; a foreign path is not kept
+	rec = send(http.MethodPost, "/delete", url.Values{"name": {"<b>Martin</b>"}}, "http://example.test/search?name=Jean01")
+	if body := rec.Body.String(); !strings.Contains(body, "deleted successfully") || !strings.Contains(body, "Jean01 Dupont") || !strings.Contains(body, "Search Results (1 found)") {
+		t.Errorf("Search results should be refreshed after a deletion:\n%s", body)
+	}
+	if body := send(http.MethodPost, "/delete", url.Values{"name": {"Dupont"}}, "http://evil.test//evil.test/x?page=2").Body.String(); strings.Contains(body, "evil.test") {
+		t.Error("Page links should only be built from the list pages")
+	}
+
+	// Thirty contacts share the last name: the forms delete by ID
+	rec = send(http.MethodPost, "/delete", url.Values{"name": {"Dupont"}}, "http://example.test/")
+	if body := rec.Body.String(); rec.Code != http.StatusBadRequest || !strings.Contains(body, "matches 30 contacts") || dir.ContactCount() != 30 {
+		t.Errorf("Deleting a shared name should delete nothing and list the candidates, got %d:\n%s", rec.Code, body)
+	}
+	jean05, _ := dir.ResolveContact("Jean05 Dupont")
+	rec = send(http.MethodPost, "/delete", url.Values{"id": {strconv.Itoa(jean05.ID)}, "name": {"Dupont"}}, "http://example.test/")
+	if body := rec.Body.String(); !strings.Contains(body, "Contact Jean05 Dupont deleted successfully") || dir.ContactCount() != 29 {
+		t.Errorf("Deleting by ID should delete that contact only:\n%s", body)
+	}
+
+	rec = send(http.MethodGet, "/search?name=Jean02", nil, "http://example.test/")
+	if body := rec.Body.String(); strings.Contains(body, "<!DOCTYPE html>") || !strings.Contains(body, "Search Results (1 found)") {
+		t.Errorf("A fetch search should only answer the fragments:\n%s", body)
+	}
+}
+
+// TestMaxUploadSize tests that oversized bodies are refused before they are read
+func TestMaxUploadSize(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir, WithMaxUploadSize(1<<10))
+	token := sessionToken(t, handler)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField(csrfFieldName, token)
+	part, _ := writer.CreateFormFile("file", "contacts.csv")
+	part.Write([]byte("name,first,phone\n" + strings.Repeat("Dupont,Jean,0123456789\n", 100)))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), "at most 1 KB") {
+		t.Errorf("Expected 413 for a 2 KB upload, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A body of unknown length fails once past the limit
+	req = httptest.NewRequest(http.MethodPost, "/api/sync", strings.NewReader(`{"contacts": [`+strings.Repeat(`{"name": "Dupont"},`, 100)+`]}`))
+	req.ContentLength = -1
+	req.Header.Set(csrfHeaderName, token)
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest || dir.ContactCount() != 0 {
+		t.Errorf("Expected the truncated push to be refused, got %d with %d contacts", rec.Code, dir.ContactCount())
+	}
+
+	if rec := postForm(handler, "/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}}, token); rec.Code != http.StatusSeeOther {
+		t.Errorf("Expected small forms to be accepted, got %d", rec.Code)
+	}
+}
+
+// TestImportPreview tests that an upload is only imported once its preview is confirmed
+func TestImportPreview(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	upload := func(filename, content, strategy string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField(csrfFieldName, token)
+		writer.WriteField("strategy", strategy)
+		part, _ := writer.CreateFormFile("file", filename)
+		part.Write([]byte(content))
+		writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/import", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	previewToken := regexp.MustCompile(`name="token" value="([^"]+)"`)
+
+	csv := "name,first,phone\nDupont,Jean,0123456789\nMartin,,0600000000\n<b>Durand</b>,Marie,0611111111\n"
+	rec := upload("contacts.csv", csv, "merge-skip-duplicates")
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || dir.ContactCount() != 1 {
+		t.Fatalf("The upload should only be previewed, got %d with %d contacts", rec.Code, dir.ContactCount())
+	}
+	for _, want := range []string{"1 to add", "1 already present", "already in the directory as Jean Dupont", "&lt;b&gt;Durand&lt;/b&gt;", "Import 2 contacts"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Preview lacks %q", want)
+		}
+	}
+	if strings.Contains(body, "Martin") {
+		t.Error("Rows missing a required field are skipped by the CSV reader and should not be listed")
+	}
+	match := previewToken.FindStringSubmatch(body)
+	if match == nil {
+		t.Fatal("Preview has no confirm token")
+	}
+
+	rec = postForm(handler, "/import/confirm", url.Values{"token": {match[1]}, "action": {"confirm"}}, token)
+	if rec.Code != http.StatusSeeOther || dir.ContactCount() != 2 {
+		t.Errorf("Confirming should import the new contact, got %d with %d contacts", rec.Code, dir.ContactCount())
+	}
+	postForm(handler, "/import/confirm", url.Values{"token": {match[1]}, "action": {"confirm"}}, token)
+	if dir.ContactCount() != 2 {
+		t.Error("A preview should only be confirmed once")
+	}
+
+	// Invalid JSON rows are listed with their errors, and cancelling changes nothing
+	body = upload("backup.json", `[{"name":"Leroy","first":"Luc","phone":"0622222222","birthday":"1990-13-40"},{"name":"Petit","first":"Lea","phone":"0633333333"}]`, "replace").Body.String()
+	for _, want := range []string{"1 invalid and ignored", "The 2 current contacts will be removed", `class="error"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Preview lacks %q", want)
+		}
+	}
+	match = previewToken.FindStringSubmatch(body)
+	postForm(handler, "/import/confirm", url.Values{"token": {match[1]}, "action": {"cancel"}}, token)
+	if dir.ContactCount() != 2 {
+		t.Error("Cancelling should leave the directory unchanged")
+	}
+}
+
+// TestClearConfirmation tests that /clear needs the token of its page, and
+// that a scope only removes the contacts of a tag or of one import
+func TestClearConfirmation(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.ImportContacts([]annuaire.Contact{
+		{Name: "Dupont", First: "Jean", Phone: "0123456789", Tags: []string{"work"}},
+		{Name: "Martin", First: "Paul", Phone: "0698765432"},
+	}, annuaire.ImportOptions{RecordSource: true}, "team.csv")
+	dir.InsertContact(annuaire.Contact{Name: "Durand", First: "Marie", Phone: "0611111111", Tags: []string{"work"}})
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	confirmToken := regexp.MustCompile(`name="token" value="([^"]+)"`)
+	page := func(query string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/clear"+query, nil))
+		match := confirmToken.FindStringSubmatch(rec.Body.String())
+		if match == nil {
+			t.Fatalf("/clear%s has no confirm token:\n%s", query, rec.Body.String())
+		}
+		return match[1]
+	}
+
+	// A lone POST, even with a valid CSRF token, clears nothing
+	if rec := postForm(handler, "/clear", url.Values{}, token); rec.Code != http.StatusSeeOther || dir.ContactCount() != 3 {
+		t.Fatalf("POST without confirmation: got %d with %d contacts", rec.Code, dir.ContactCount())
+	}
+
+	// Every contact needs the word typed in
+	all := page("")
+	postForm(handler, "/clear", url.Values{"token": {all}}, token)
+	if dir.ContactCount() != 3 {
+		t.Fatal("Clearing every contact without typing the word should change nothing")
+	}
+
+	// Contacts of an import carrying a tag, then the token cannot be reused
+	scoped := page("?tag=work&source=team.csv")
+	postForm(handler, "/clear", url.Values{"token": {scoped}}, token)
+	if names := contactNames(dir); names != "Durand,Martin" {
+		t.Errorf("Clearing the work contacts of team.csv left %s", names)
+	}
+	dir.InsertContact(annuaire.Contact{Name: "Dupont", First: "Jean", Phone: "0123456789", Tags: []string{"work"}})
+	postForm(handler, "/clear", url.Values{"token": {scoped}}, token)
+	if dir.ContactCount() != 3 {
+		t.Error("A confirmation should only be used once")
+	}
+
+	postForm(handler, "/clear", url.Values{"token": {page("")}, "confirm": {"CLEAR"}}, token)
+	if dir.ContactCount() != 0 {
+		t.Errorf("Typing the word should clear every contact, %d left", dir.ContactCount())
+	}
+}
+
+// contactNames returns the sorted last names of the directory, comma-separated
+func contactNames(dir *annuaire.Directory) string {
+	var names []string
+	for _, c := range dir.ListContacts() {
+		names = append(names, c.Name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ",")
+}
+
+// TestContactPhoto tests uploading, serving and removing a contact avatar
+func TestContactPhoto(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	upload := func(content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		writer.WriteField(csrfFieldName, token)
+		part, _ := writer.CreateFormFile("photo", "me.png")
+		part.Write(content)
+		writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/contact/1/photo", &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(target, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/contact/1/photo", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("A contact without photo should answer 404, got %d", rec.Code)
+	}
+	if body := get("/", "").Body.String(); strings.Contains(body, `src="/contact/1/photo"`) {
+		t.Error("Initials should be shown without a photo")
+	}
+
+	var picture bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	if err := png.Encode(&picture, img); err != nil {
+		t.Fatal(err)
+	}
+	if rec := upload(picture.Bytes()); rec.Code != http.StatusSeeOther {
+		t.Fatalf("Upload should redirect, got %d", rec.Code)
+	}
+	rec := get("/contact/1/photo", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("Expected the JPEG avatar, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if config, _, err := image.DecodeConfig(rec.Body); err != nil || config.Width != annuaire.PhotoSize {
+		t.Errorf("Avatar should be resized to %d pixels: %+v (%v)", annuaire.PhotoSize, config, err)
+	}
+	if again := get("/contact/1/photo", rec.Header().Get("ETag")); again.Code != http.StatusNotModified {
+		t.Errorf("An unchanged photo should answer 304, got %d", again.Code)
+	}
+	if body := get("/", "").Body.String(); !strings.Contains(body, `<img class="contact-avatar" src="/contact/1/photo"`) {
+		t.Error("The list should show the photo instead of the initials")
+	}
+
+	if rec := upload([]byte("not an image")); rec.Code != http.StatusSeeOther {
+		t.Errorf("A refused upload should redirect with a message, got %d", rec.Code)
+	}
+	if contact, _ := dir.ResolveContact("Dupont"); contact.Photo == "" {
+		t.Error("A refused upload should keep the previous photo")
+	}
+
+	postForm(handler, "/contact/1/photo", url.Values{"remove": {"1"}}, token)
+	if rec := get("/contact/1/photo", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("A removed photo should answer 404, got %d", rec.Code)
+	}
+}
+
+// TestErrorStatus tests the HTTP status of directory errors on fetch requests
+func TestErrorStatus(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	send := func(path string, form url.Values) int {
+		form.Set(csrfFieldName, token)
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(fragmentHeader, "true")
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := send("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}}); code != http.StatusConflict {
+		t.Errorf("Duplicate contact: got %d, want 409", code)
+	}
+	if code := send("/add", url.Values{"name": {"Martin"}, "first": {"Paul"}, "phone": {"06"}, "birthday": {"soon"}}); code != http.StatusBadRequest {
+		t.Errorf("Invalid birthday: got %d, want 400", code)
+	}
+	if code := send("/delete", url.Values{"name": {"Martin"}}); code != http.StatusNotFound {
+		t.Errorf("Unknown contact: got %d, want 404", code)
+	}
+	if code := errorStatus(errors.New("disk full")); code != http.StatusInternalServerError {
+		t.Errorf("Other errors: got %d, want 500", code)
+	}
+}
+
+// TestAPIProblems tests the statuses and problem bodies of the contact API
+func TestAPIProblems(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.InsertContact(annuaire.Contact{Name: "Dupont", First: "Jean", Phone: "0123456789", Code: "1"})
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	send := func(method, path, body string) (*httptest.ResponseRecorder, problem) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(csrfHeaderName, token)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		var p problem
+		if rec.Code >= 400 {
+			if ct := rec.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("%s %s: Content-Type %q, want %q", method, path, ct, problemContentType)
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil || p.Status != rec.Code || p.Title == "" {
+				t.Errorf("%s %s: invalid problem %s", method, path, rec.Body)
+			}
+		}
+		return rec, p
+	}
+
+	rec, _ := send(http.MethodPost, "/api/contacts", `{"name": "Martin", "first": "Paul", "phone": "0600000000"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Create: got %d, want 201: %s", rec.Code, rec.Body)
+	}
+	var created annuaire.Contact
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if location := rec.Header().Get("Location"); location != "/api/contacts/"+strconv.Itoa(created.ID) {
+		t.Errorf("Unexpected Location %q for contact %d", location, created.ID)
+	}
+
+	rec, p := send(http.MethodPost, "/api/contacts", `{"name": "Durand", "birthday": "soon"}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Invalid fields: got %d, want 422", rec.Code)
+	}
+	var fields []string
+	for _, field := range p.Errors {
+		fields = append(fields, field.Field)
+	}
+	if strings.Join(fields, ",") != "first,phone,birthday" {
+		t.Errorf("Invalid fields reported as %v", fields)
+	}
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPost, "/api/contacts", `{"name": "Dupont", "first": "Jean", "phone": "0123456789"}`, http.StatusConflict},
+		{http.MethodPost, "/api/contacts", `{"name": `, http.StatusBadRequest},
+		{http.MethodPost, "/api/contacts", `{"nom": "Dupont"}`, http.StatusBadRequest},
+		{http.MethodGet, "/api/contacts/999", "", http.StatusNotFound},
+		{http.MethodGet, "/api/contacts/abc", "", http.StatusNotFound},
+		{http.MethodPatch, "/api/contacts/" + strconv.Itoa(created.ID), `{"code": "1"}`, http.StatusConflict},
+		{http.MethodPatch, "/api/contacts/" + strconv.Itoa(created.ID), `{"code": "a-b"}`, http.StatusUnprocessableEntity},
+		{http.MethodPatch, "/api/contacts/" + strconv.Itoa(created.ID), `{"first": "Pierre"}`, http.StatusOK},
+		{http.MethodPut, "/api/contacts/" + strconv.Itoa(created.ID), `{}`, http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/api/contacts/" + strconv.Itoa(created.ID), "", http.StatusNoContent},
+		{http.MethodDelete, "/api/contacts/" + strconv.Itoa(created.ID), "", http.StatusNotFound},
+		{http.MethodGet, "/api/contacts?order=up", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec, _ := send(tt.method, tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("%s %s %s: got %d, want %d", tt.method, tt.path, tt.body, rec.Code, tt.want)
+		}
+	}
+	if c, err := dir.ResolveContact("Martin"); err == nil {
+		t.Errorf("Deleted contact still present: %v", c)
+	}
+
+	// Forged API changes are refused with a problem too
+	req := httptest.NewRequest(http.MethodDelete, "/api/contacts/1", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("Request without CSRF token: got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
+
+// TestAPIETags tests that the API tags its answers, answers 304 to current
+// copies, and refuses changes based on an outdated copy
+func TestAPIETags(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.InsertContact(annuaire.Contact{Name: "Dupont", First: "Jean", Phone: "0123456789"})
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	send := func(method, path, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		maps.Copy(req.Header, header)
+		req.Header.Set(csrfHeaderName, token)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, path := range []string{"/api/contacts", "/api/contacts/1"} {
+		rec := send(http.MethodGet, path, "", nil)
+		etag := rec.Header().Get("ETag")
+		if rec.Code != http.StatusOK || !strings.HasPrefix(etag, `"`) {
+			t.Fatalf("GET %s: got %d with ETag %q", path, rec.Code, etag)
+		}
+		if again := send(http.MethodGet, path, "", http.Header{"If-None-Match": {`"other", W/` + etag}}); again.Code != http.StatusNotModified || again.Body.Len() != 0 {
+			t.Errorf("GET %s with a current copy: got %d %q, want 304", path, again.Code, again.Body)
+		}
+		if again := send(http.MethodGet, path, "", http.Header{"If-None-Match": {`"other"`}}); again.Code != http.StatusOK {
+			t.Errorf("GET %s with an outdated copy: got %d, want 200", path, again.Code)
+		}
+	}
+
+	read := send(http.MethodGet, "/api/contacts/1", "", nil).Header().Get("ETag")
+	rec := send(http.MethodPatch, "/api/contacts/1", `{"first": "Paul"}`, http.Header{"If-Match": {read}})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == read || rec.Header().Get("ETag") == "" {
+		t.Fatalf("PATCH with the current ETag: got %d with ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	updated := rec.Header().Get("ETag")
+	if got := send(http.MethodGet, "/api/contacts/1", "", nil).Header().Get("ETag"); got != updated {
+		t.Errorf("GET after PATCH: ETag %q, want %q", got, updated)
+	}
+
+	// A second client still holding the first copy would lose the change
+	for _, method := range []string{http.MethodPatch, http.MethodDelete} {
+		if rec := send(method, "/api/contacts/1", `{"first": "Pierre"}`, http.Header{"If-Match": {read}}); rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s with an outdated ETag: got %d, want 412", method, rec.Code)
+		}
+	}
+	if rec := send(http.MethodPatch, "/api/contacts/1", `{"first": "Pierre"}`, http.Header{"If-Match": {"W/" + updated}}); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PATCH with a weak ETag: got %d, want 412", rec.Code)
+	}
+	if c, _ := dir.ResolveContact("#1"); c.First != "Paul" {
+		t.Errorf("Refused changes were applied: %+v", c)
+	}
+	if rec := send(http.MethodDelete, "/api/contacts/1", "", http.Header{"If-Match": {"*"}}); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE with If-Match *: got %d, want 204", rec.Code)
+	}
+}
+
+// TestAPIBatch tests that a batch reports each contact and keeps the valid ones
+func TestAPIBatch(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/contacts/batch", strings.NewReader(body))
+		req.Header.Set(csrfHeaderName, token)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(`[
+		{"name": "Martin", "first": "Paul", "phone": "0600000000"},
+		{"name": "Dupont", "first": "Jean", "phone": "0123456789"},
+		{"name": "Durand", "birthday": "soon"}
+	]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var response batchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if response.Added != 1 || response.Duplicates != 1 || response.Invalid != 1 || len(response.Results) != 3 {
+		t.Fatalf("Unexpected counts: %+v", response)
+	}
+	if added := response.Results[0]; added.Contact == nil || added.Contact.ID == 0 {
+		t.Errorf("Added contact should be returned with its ID: %+v", added)
+	}
+	if invalid := response.Results[2]; invalid.Status != annuaire.AddInvalid || len(invalid.Errors) != 3 || invalid.Contact != nil {
+		t.Errorf("Invalid contact should list its 3 fields: %+v", invalid)
+	}
+	if dir.ContactCount() != 2 {
+		t.Errorf("Expected 2 contacts, got %d", dir.ContactCount())
+	}
+
+	if rec := post(`{"name": "Martin"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Object instead of array: got %d, want 400", rec.Code)
+	}
+	if rec := post("[" + strings.Repeat(`{},`, maxBatchSize) + "{}]"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Too many contacts: got %d, want 400", rec.Code)
+	}
+}
+
+// TestAPIOwnedFields tests that a contact created through the API, alone or
+// in a batch, cannot set the fields owned by the server, and is not due for
+// review before the verification period
+func TestAPIOwnedFields(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(csrfHeaderName, token)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	const owned = `"source": "forged.csv", "verify_by": "zzz"`
+	if rec := post("/api/contacts", `{"name": "Dupont", "first": "Jean", "phone": "0123456789", `+owned+`}`); rec.Code != http.StatusCreated {
+		t.Fatalf("Create: got %d: %s", rec.Code, rec.Body)
+	} else if rec.Header().Get("Location") != "/api/contacts/1" || !strings.Contains(rec.Body.String(), `"id": 1,`) {
+		t.Errorf("Create should answer the stored contact: %s %s", rec.Header().Get("Location"), rec.Body)
+	}
+	if rec := post("/api/contacts/batch", `[{"name": "Martin", "first": "Paul", "phone": "0600000000", `+owned+`}]`); rec.Code != http.StatusOK {
+		t.Fatalf("Batch: got %d: %s", rec.Code, rec.Body)
+	}
+
+	want := annuaire.VerifyByDate(time.Now(), (&settings.Settings{}).VerificationPeriod())
+	for _, c := range dir.ListContacts() {
+		if c.Source != "" || c.VerifyBy != want {
+			t.Errorf("%s: source %q and verify_by %q, want none and %s", c.Name, c.Source, c.VerifyBy, want)
+		}
+	}
+	if due := dir.NeedingVerification(time.Now()); len(due) != 0 {
+		t.Errorf("New contacts should not be due for review: %v", due)
+	}
+	if sources := dir.ImportSources(); len(sources) != 0 {
+		t.Errorf("No import source should be recorded, got %v", sources)
+	}
+}
+
+// TestAPITags tests adding and removing a tag on the contacts of a search
+func TestAPITags(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	dir.AddContact("Dupuis", "Anne", "0600000000")
+	dir.AddContact("Martin", "Paul", "0700000000")
+	handler := NewServer(dir)
+	token := sessionToken(t, handler)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/contacts/tags", strings.NewReader(body))
+		req.Header.Set(csrfHeaderName, token)
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, tt := range []struct {
+		body string
+		want annuaire.TagChange
+	}{
+		{`{"filter": "dup", "add": "Client"}`, annuaire.TagChange{Matched: 2, Modified: 2}},
+		{`{"tag": "client", "add": "client"}`, annuaire.TagChange{Matched: 2}},
+		{`{"filter": "anne", "tag": "client", "remove": "client"}`, annuaire.TagChange{Matched: 1, Modified: 1}},
+	} {
+		rec := post(tt.body)
+		var got annuaire.TagChange
+		if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &got) != nil || got != tt.want {
+			t.Errorf("%s: got %d %s, want %+v", tt.body, rec.Code, rec.Body, tt.want)
+		}
+	}
+	if c, _ := dir.SearchContact("Dupont"); !c.HasTag("client") {
+		t.Errorf("Dupont should be tagged: %v", c.Tags)
+	}
+	if c, _ := dir.SearchContact("Dupuis"); c.HasTag("client") {
+		t.Errorf("Dupuis should not be tagged: %v", c.Tags)
+	}
+
+	for body, want := range map[string]int{
+		`{"filter": "dup"}`:                                  http.StatusBadRequest,
+		`{"add": "a", "remove": "b"}`:                        http.StatusBadRequest,
+		`{"filter": "dup", "add": "client", "color": "red"}`: http.StatusBadRequest,
+		`{"add": "bad tag"}`:                                 http.StatusUnprocessableEntity,
+	} {
+		if rec := post(body); rec.Code != want {
+			t.Errorf("%s: got %d, want %d", body, rec.Code, want)
+		}
+	}
+}
+
+// TestAPISuggest tests name completion and its silence on sensitive names
+func TestAPISuggest(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "01")
+	dir.AddContact("Durand", "Marie", "02")
+	dir.AddContact("Martin", "Dumas", "03")
+	settingsFile := filepath.Join(t.TempDir(), settings.FileName)
+	handler := NewServer(dir, WithSettingsFile(settingsFile))
+	suggest := func(target string) (int, []string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var result suggestions
+		json.Unmarshal(rec.Body.Bytes(), &result)
+		return rec.Code, result.Names
+	}
+
+	if code, names := suggest("/api/suggest?q=du&limit=2"); code != http.StatusOK || strings.Join(names, ",") != "Dumas,Dupont" {
+		t.Errorf("Expected Dumas and Dupont, got %d %v", code, names)
+	}
+	if code, names := suggest("/api/suggest?q="); code != http.StatusOK || names == nil || len(names) != 0 {
+		t.Errorf("Empty prefix should suggest an empty list, got %d %v", code, names)
+	}
+	if code, _ := suggest("/api/suggest?q=du&limit=500"); code != http.StatusBadRequest {
+		t.Errorf("Limit over %d: got %d, want 400", maxSuggestions, code)
+	}
+
+	cfg := &settings.Settings{SensitiveFields: []string{"name"}}
+	if err := cfg.Save(settingsFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, names := suggest("/api/suggest?q=du"); len(names) != 0 {
+		t.Errorf("Sensitive names should not be suggested, got %v", names)
+	}
+}
+
+// TestPhoneticSearch tests the sound-alike box of the search form
+func TestPhoneticSearch(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0123456789")
+	handler := NewServer(dir)
+	search := func(target string) string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Body.String()
+	}
+
+	if body := search("/search?name=Dupon"); !strings.Contains(body, "No contact found matching: Dupon") {
+		t.Error("Exact search should not find Dupon")
+	}
+	body := search("/search?name=Dupon&phonetic=1")
+	if !strings.Contains(body, "Contact found") {
+		t.Error("Phonetic search should find Dupont")
+	}
+	if !strings.Contains(body, `name="phonetic" value="1" checked`) {
+		t.Error("Phonetic box should stay checked")
+	}
+	if !strings.Contains(body, "<h3>Jean <mark>Dupon</mark>t</h3>") {
+		t.Error("The part of the name typed should be highlighted")
+	}
+	if body := search("/search?name=0123456789"); !strings.Contains(body, "<mark>0123456789</mark>") {
+		t.Error("The phone number searched should be highlighted")
+	}
+}
+
+// TestSearchHitHighlight tests that highlighting escapes the contact fields
+func TestSearchHitHighlight(t *testing.T) {
+	hit := SearchHit{Contact: annuaire.Contact{Name: "<b>Dupont</b>", First: "Jean"}, Matches: annuaire.FindMatches(annuaire.Contact{Name: "<b>Dupont</b>"}, "dup", annuaire.SearchOptions{})}
+	if got := hit.Highlight("name"); got != "&lt;b&gt;<mark>Dup</mark>ont&lt;/b&gt;" {
+		t.Errorf("Highlight(name) = %q", got)
+	}
+	if got := hit.Highlight("first"); got != "Jean" {
+		t.Errorf("Highlight(first) = %q", got)
+	}
+}
+
+// TestAPIStats tests the summary served to the dashboard
+func TestAPIStats(t *testing.T) {
+	dir := annuaire.NewDirectory()
+	dir.AddContact("Dupont", "Jean", "0612345678")
+	dir.AddContact("Durand", "Marie", "0145678901")
+	dataFile := filepath.Join(t.TempDir(), "contacts.json")
+	handler := NewServer(dir, WithBootstrap("", dataFile))
+	get := func() annuaire.Stats {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		var stats annuaire.Stats
+		if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+			t.Fatalf("Invalid JSON: %v", err)
+		}
+		return stats
+	}
+
+	stats := get()
+	if stats.Total != 2 || len(stats.Initials) != 1 || stats.Initials[0].Count != 2 || len(stats.Prefixes) != 2 || !stats.Modified.IsZero() {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// A change is seen at once, and so is a save
+	dir.AddContact("Martin", "Luc", "0699999999")
+	if err := dir.SaveDataFile(dataFile); err != nil {
+		t.Fatal(err)
+	}
+	if stats = get(); stats.Total != 3 || stats.Modified.IsZero() {
+		t.Errorf("Stats after a change and a save: %+v", stats)
+	}
+}
+
+// TestDebugHandler tests that the debug endpoints serve the profiles and the
+// runtime variables with the size of each profile
+func TestDebugHandler(t *testing.T) {
+	home, work := annuaire.NewDirectory(), annuaire.NewDirectory()
+	work.InsertContact(annuaire.Contact{Name: "Dupont", First: "Jean", Phone: "0123456789"})
+	handler := debugHandler([]string{"", "work"}, []*annuaire.Directory{home, work})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	var vars struct {
+		Contacts   map[string]int  `json:"contacts"`
+		Goroutines int             `json:"goroutines"`
+		MemStats   json.RawMessage `json:"memstats"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("Invalid /debug/vars: %v\n%s", err, rec.Body)
+	}
+	if !reflect.DeepEqual(vars.Contacts, map[string]int{"default": 0, "work": 1}) || vars.Goroutines == 0 || len(vars.MemStats) == 0 {
+		t.Errorf("Unexpected variables: %+v", vars)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/heap?debug=1", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "heap profile") {
+		t.Errorf("Heap profile: got %d", rec.Code)
+	}
+}
+
+// TestTraceRequests tests that requests are traced by route, continuing the
+// trace of the caller, with the directory operations as children, and that
+// the data file is traced down to the files written and read
+func TestTraceRequests(t *testing.T) {
+	var mu sync.Mutex
+	var spans []struct {
+		TraceID, SpanID, ParentSpanID, Name string
+	}
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct{ TraceID, SpanID, ParentSpanID, Name string }
+				}
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, resource := range request.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				for _, span := range scope.Spans {
+					spans = append(spans, struct{ TraceID, SpanID, ParentSpanID, Name string }(span))
+				}
+			}
+		}
+	}))
+	defer collector.Close()
+	exporter := tracing.NewExporter(collector.URL, "tp1", nil, time.Second)
+	tracing.SetExporter(exporter)
+	defer tracing.SetExporter(nil)
+
+	dir := annuaire.NewDirectory()
+	dir.InsertContact(annuaire.Contact{Name: "Dupont", First: "Jean", Phone: "0123456789"})
+	srv := NewServer(dir)
+	token := sessionToken(t, srv) // Before tracing the requests
+	handler := traceRequests(srv)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	traceparent := http.Header{"Traceparent": {"00-" + traceID + "-00f067aa0ba902b7-01"}}
+	for _, path := range []string{"/api/contacts?q=dup", "/api/contacts/1"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header = traceparent.Clone()
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	form := url.Values{"name": {"Martin"}, "first": {"Paul"}, "phone": {"0698765432"}, csrfFieldName: {token}}
+	req := httptest.NewRequest(http.MethodPost, "/add", strings.NewReader(form.Encode()))
+	req.Header = traceparent.Clone()
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	traced := tracedDirectory{Directory: dir, ctx: tracing.Extract(context.Background(), traceparent)}
+	dataFile := filepath.Join(t.TempDir(), "contacts.json")
+	if err := traced.SaveDataFile(dataFile); err != nil {
+		t.Fatal(err)
+	}
+	traced.Directory = annuaire.NewDirectory()
+	if err := traced.LoadDataFile(dataFile, ""); err != nil || traced.ContactCount() != 2 {
+		t.Fatalf("Reloading %s: %v, %d contacts", dataFile, err, traced.ContactCount())
+	}
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]string) // Span ID to name
+	for _, span := range spans {
+		names[span.SpanID] = span.Name
+		if span.TraceID != traceID {
+			t.Errorf("Span %q is not in the trace of the caller: %s", span.Name, span.TraceID)
+		}
+	}
+	var got []string
+	for _, span := range spans {
+		got = append(got, span.Name+" < "+cmp.Or(names[span.ParentSpanID], span.ParentSpanID))
+	}
+	want := []string{"annuaire.ScanContacts < GET /api/contacts", "GET /api/contacts < 00f067aa0ba902b7", "GET /api/contacts/{id} < 00f067aa0ba902b7",
+		"annuaire.InsertContact < POST /add", "POST /add < 00f067aa0ba902b7",
+		"storage.WriteFile < annuaire.SaveDataFile", "annuaire.SaveDataFile < 00f067aa0ba902b7",
+		"storage.ReadFile < annuaire.LoadDataFile", "annuaire.LoadDataFile < 00f067aa0ba902b7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Spans %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,294 @@
+// Package servertest runs the complete web server in-process for black-box tests
+//
+// Unlike httptest.NewRecorder around server.NewServer, it goes through a real
+// listener, real cookies and the real startup/shutdown path, including the
+// data file flush, so tests exercise what a browser would see:
+//
+//	srv := servertest.Start(t)
+//	srv.PostForm("/add", url.Values{"name": {"Dupont"}, "first": {"Jean"}, "phone": {"0123456789"}})
+//	body := srv.GetBody("/search?name=Dupont")
+package servertest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tp1/server"
+)
+
+// csrfCookieName and csrfFieldName mirror the server's CSRF protection
+const (
+	csrfCookieName = "tp1_csrf"
+	csrfFieldName  = "csrf_token"
+)
+
+// Server is a running web server bound to an ephemeral local port
+type Server struct {
+	URL          string       // Base URL such as "http://127.0.0.1:41234", with the base path of the configuration
+	DataFile     string       // Contacts file in the test's temp directory
+	SettingsFile string       // Settings file in the test's temp directory
+	HistoryFile  string       // Audit log in the test's temp directory
+	Client       *http.Client // Client keeping cookies (CSRF session, flash messages)
+
+	t      testing.TB
+	cancel context.CancelFunc
+	done   chan error
+}
+
+/**
+ * Start launches the server with an empty temp data directory
+ *
+ * @param {testing.TB} t - Test owning the server; it is stopped during cleanup
+ * @return {*Server} Running server
+ */
+func Start(t testing.TB) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	return StartWithFiles(t, filepath.Join(dir, "contacts.json"), filepath.Join(dir, "settings.json"))
+}
+
+/**
+ * StartWithFiles launches the server on existing data and settings files
+ *
+ * @param {testing.TB} t - Test owning the server; it is stopped during cleanup
+ * @param {string} dataFile - Contacts file loaded at startup and written on Stop
+ * @param {string} settingsFile - Settings file (may not exist)
+ * @return {*Server} Running server
+ *
+ * The audit log is kept next to the data file. Use it to restart a server
+ * on the files left by a previous one
+ */
+func StartWithFiles(t testing.TB, dataFile, settingsFile string) *Server {
+	t.Helper()
+	historyFile := filepath.Join(filepath.Dir(dataFile), "history.jsonl")
+	return StartWithConfig(t, server.Config{DataFile: dataFile, SettingsFile: settingsFile, HistoryFile: historyFile})
+}
+
+/**
+ * StartWithConfig launches the server with an explicit configuration
+ *
+ * @param {testing.TB} t - Test owning the server; it is stopped during cleanup
+ * @param {server.Config} cfg - Files, storage, profiles and TLS (cfg.Addr is ignored)
+ * @return {*Server} Running server; with profiles, its file fields are those of the first one
+ */
+func StartWithConfig(t testing.TB, cfg server.Config) *Server {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("servertest: listen: %v", err)
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("servertest: cookie jar: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		URL:          "http://" + ln.Addr().String(),
+		DataFile:     cfg.DataFile,
+		SettingsFile: cfg.SettingsFile,
+		HistoryFile:  cfg.HistoryFile,
+		Client: &http.Client{
+			Jar:     jar,
+			Timeout: 10 * time.Second,
+		},
+		t:      t,
+		cancel: cancel,
+		done:   make(chan error, 1),
+	}
+
+	// With TLS, trust the server certificate itself, as a browser would after
+	// the user accepted it
+	if cfg.TLSCertFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCertFile)
+		if err != nil {
+			t.Fatalf("servertest: reading certificate: %v", err)
+		}
+		roots := x509.NewCertPool()
+		roots.AppendCertsFromPEM(pem)
+		s.URL = "https://" + ln.Addr().String()
+		s.Client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	}
+	// Paths given to Get and PostForm are relative to the base path
+	if base := strings.Trim(cfg.BasePath, "/"); base != "" {
+		s.URL += "/" + base
+	}
+	if len(cfg.Profiles) > 0 {
+		s.DataFile, s.SettingsFile, s.HistoryFile = cfg.Profiles[0].DataFile, cfg.Profiles[0].SettingsFile, cfg.Profiles[0].HistoryFile
+	}
+
+	go func() {
+		s.done <- server.Serve(ctx, ln, cfg)
+	}()
+	t.Cleanup(func() {
+		if s.cancel != nil {
+			s.Stop()
+		}
+	})
+	return s
+}
+
+/**
+ * Stop shuts the server down and waits for the data file to be written
+ *
+ * @return {error} Error returned by server.Serve (nil on clean shutdown)
+ *
+ * Stop may be called explicitly to inspect the data file; cleanup then does nothing
+ */
+func (s *Server) Stop() error {
+	s.t.Helper()
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	s.cancel = nil
+
+	select {
+	case err := <-s.done:
+		return err
+	case <-time.After(15 * time.Second):
+		s.t.Fatal("servertest: server did not stop")
+		return nil
+	}
+}
+
+/**
+ * Get sends a GET request and fails the test on transport errors
+ *
+ * @param {string} path - Path and query, e.g. "/search?name=Dupont"
+ * @return {*http.Response} Response with its body fully read into Body
+ */
+func (s *Server) Get(path string) *http.Response {
+	s.t.Helper()
+	return s.do(http.NewRequest(http.MethodGet, s.URL+path, nil))
+}
+
+// GetBody sends a GET request and returns the response body as a string
+func (s *Server) GetBody(path string) string {
+	s.t.Helper()
+	return ReadBody(s.t, s.Get(path))
+}
+
+/**
+ * PostForm submits a form with the session CSRF token, like the web pages do
+ *
+ * @param {string} path - Form action, e.g. "/add"
+ * @param {url.Values} form - Form fields (the token is added)
+ * @return {*http.Response} Final response after following redirects
+ */
+func (s *Server) PostForm(path string, form url.Values) *http.Response {
+	s.t.Helper()
+	values := url.Values{csrfFieldName: {s.CSRFToken()}}
+	for key, v := range form {
+		values[key] = v
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL+path, bytes.NewBufferString(values.Encode()))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return s.do(req, err)
+}
+
+/**
+ * Login opens a session through the login form
+ *
+ * @param {string} user - Administrator login
+ * @param {string} password - Administrator password
+ * @return {*http.Response} Final response: the home page on success, the form with 401 otherwise
+ */
+func (s *Server) Login(user, password string) *http.Response {
+	s.t.Helper()
+	return s.PostForm("/login", url.Values{"user": {user}, "password": {password}})
+}
+
+/**
+ * PostFile uploads a file as multipart form data with the session CSRF token
+ *
+ * @param {string} path - Form action, e.g. "/import"
+ * @param {string} field - File field name
+ * @param {string} filename - File name sent to the server
+ * @param {[]byte} content - File content
+ * @return {*http.Response} Final response after following redirects
+ */
+func (s *Server) PostFile(path, field, filename string, content []byte) *http.Response {
+	s.t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField(csrfFieldName, s.CSRFToken())
+	part, err := writer.CreateFormFile(field, filename)
+	if err != nil {
+		s.t.Fatalf("servertest: multipart: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req, err := http.NewRequest(http.MethodPost, s.URL+path, &body)
+	if err == nil {
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+	}
+	return s.do(req, err)
+}
+
+/**
+ * CSRFToken returns the session token, opening a session first if needed
+ *
+ * @return {string} Token to send with state-changing requests
+ */
+func (s *Server) CSRFToken() string {
+	s.t.Helper()
+	base, _ := url.Parse(s.URL)
+	for i := 0; i < 2; i++ {
+		for _, cookie := range s.Client.Jar.Cookies(base) {
+			if cookie.Name == csrfCookieName {
+				return cookie.Value
+			}
+		}
+		// The first page view starts the session
+		s.Get("/")
+	}
+	s.t.Fatal("servertest: no CSRF cookie received")
+	return ""
+}
+
+// do sends a request and buffers its body so callers never leak connections
+func (s *Server) do(req *http.Request, err error) *http.Response {
+	s.t.Helper()
+	if err != nil {
+		s.t.Fatalf("servertest: request: %v", err)
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		s.t.Fatalf("servertest: %s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("servertest: reading %s: %v", req.URL.Path, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp
+}
+
+// ReadBody returns the (buffered) body of a response obtained from a Server
+func ReadBody(t testing.TB, resp *http.Response) string {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("servertest: reading body: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,280 @@
+package server
+
+import (
+	"context"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"tp1/settings"
+)
+
+// sessionCookieName is the cookie holding the session of a logged-in user
+const sessionCookieName = "tp1_session"
+
+// sessionLifetime is how long a login lasts before the password is asked again
+const sessionLifetime = 12 * time.Hour
+
+// loginData is the data passed to loginTemplate
+type loginData struct {
+	User      string // Login typed before a failed attempt
+	Next      string // Page to open once logged in
+	Error     string // Why the previous attempt was refused
+	LoggedOut bool   // Confirm a logout
+	CSRFToken string // Token embedded in the form
+}
+
+// session is an open login
+type session struct {
+	user    string
+	role    string // settings.RoleAdmin or settings.RoleViewer
+	expires time.Time
+}
+
+// sessionKey is the context key of the session of an authenticated request
+type sessionKey struct{}
+
+// sessionGuard requires a login before forwarding requests, once an
+// administrator is configured; it serves /login and /logout itself
+type sessionGuard struct {
+	next          http.Handler
+	admin         *settings.AdminCredentials // Fixed administrator (nil: read from bootstrapFile)
+	bootstrapFile string                     // Application configuration holding the accounts
+	templates     *template.Template         // Page templates, for login.html
+	login         http.Handler               // CSRF-protected handleLogin
+	logout        http.Handler               // CSRF-protected handleLogout
+
+	mu       sync.Mutex
+	sessions map[string]session // Open sessions by cookie value
+}
+
+/**
+ * newSessionGuard protects a handler with a login page
+ *
+ * @param {http.Handler} next - Handler reached once logged in
+ * @param {*settings.AdminCredentials} admin - Administrator account, nil to
+ *   use the one of the configuration file
+ * @param {string} bootstrapFile - Configuration file, read on each request so
+ *   that an administrator created by the setup page applies immediately
+ * @param {*template.Template} templates - Page templates (see parseTemplates)
+ * @return {*sessionGuard} Handler; without any administrator, access stays open
+ */
+func newSessionGuard(next http.Handler, admin *settings.AdminCredentials, bootstrapFile string, templates *template.Template) *sessionGuard {
+	g := &sessionGuard{next: next, admin: admin, bootstrapFile: bootstrapFile, templates: templates, sessions: make(map[string]session)}
+	g.login = csrfProtect(http.HandlerFunc(g.handleLogin))
+	g.logout = csrfProtect(http.HandlerFunc(g.handleLogout))
+	return g
+}
+
+/**
+ * accounts returns the accounts allowed to log in
+ *
+ * @return {*settings.Bootstrap} Configuration whose Admin and Viewers are the
+ *   accounts (Admin replaced by the fixed one), nil when no administrator is
+ *   configured and access is open
+ */
+func (g *sessionGuard) accounts() *settings.Bootstrap {
+	config := &settings.Bootstrap{}
+	if g.bootstrapFile != "" {
+		loaded, err := settings.LoadBootstrap(g.bootstrapFile)
+		if err != nil {
+			// Fail closed: a broken configuration must not open the directory
+			slog.Error("cannot load configuration", "file", g.bootstrapFile, "error", err)
+			return &settings.Bootstrap{Admin: &settings.AdminCredentials{}}
+		}
+		if loaded != nil {
+			config = loaded
+		}
+	}
+	if g.admin != nil {
+		config.Admin = g.admin
+	}
+	if config.Admin == nil {
+		return nil
+	}
+	return config
+}
+
+/**
+ * ServeHTTP forwards authenticated requests and sends the others to /login
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - Request; pages are redirected to the login form,
+ *   JSON API calls get 401 Unauthorized
+ *
+ * Viewer sessions only reach pages: their POST, PUT, PATCH and DELETE
+ * requests are answered 403 Forbidden before any handler runs
+ */
+func (g *sessionGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if g.accounts() == nil {
+		g.next.ServeHTTP(w, r)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/login":
+		g.login.ServeHTTP(w, r)
+		return
+	case "/logout":
+		g.logout.ServeHTTP(w, r)
+		return
+	}
+
+	if s, ok := g.session(r); ok {
+		if s.role == settings.RoleViewer && isStateChanging(r.Method) {
+			slog.Warn("read-only account denied", "user", s.user, "method", r.Method, "path", r.URL.Path)
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				writeAPIError(w, http.StatusForbidden, "read-only account")
+			} else {
+				http.Error(w, "Read-only account: ask an administrator to make this change", http.StatusForbidden)
+			}
+			return
+		}
+		g.next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, s)))
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeAPIError(w, http.StatusUnauthorized, "login required")
+		return
+	}
+	target := "/login"
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		target += "?next=" + url.QueryEscape(r.URL.RequestURI())
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// session returns the session of the cookie, if it is still open
+func (g *sessionGuard) session(r *http.Request) (session, bool) {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return session{}, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.sessions[cookie.Value]
+	if !ok || time.Now().After(s.expires) {
+		delete(g.sessions, cookie.Value)
+		return session{}, false
+	}
+	return s, true
+}
+
+/**
+ * handleLogin shows the login form and opens a session
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - GET to show the form, POST with user, password
+ *   and next (page to return to)
+ *
+ * Both the administrator and the viewers log in here, the role being found
+ * from the login. A new session token is created on every login, so a token
+ * planted before cannot be reused; failed attempts answer 401 and are
+ * throttled like any POST by the rate limit
+ */
+func (g *sessionGuard) handleLogin(w http.ResponseWriter, r *http.Request) {
+	data := loginData{
+		Next:      localRedirect(r.FormValue("next")),
+		LoggedOut: r.URL.Query().Has("logged_out"),
+		CSRFToken: csrfToken(r),
+	}
+
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		data.User = r.FormValue("user")
+		accounts := g.accounts()
+		if accounts == nil {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		if role, ok := accounts.Authenticate(data.User, r.FormValue("password")); ok {
+			token, err := newCSRFToken() // Same 256-bit random format
+			if err != nil {
+				http.Error(w, "Cannot create session", http.StatusInternalServerError)
+				return
+			}
+			g.mu.Lock()
+			g.pruneSessions()
+			g.sessions[token] = session{user: data.User, role: role, expires: time.Now().Add(sessionLifetime)}
+			g.mu.Unlock()
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     sessionCookieName,
+				Value:    token,
+				Path:     "/",
+				MaxAge:   int(sessionLifetime.Seconds()),
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+			slog.Info("login", "user", data.User, "role", role, "remote", remoteHost(r.RemoteAddr))
+			http.Redirect(w, r, data.Next, http.StatusSeeOther)
+			return
+		}
+		slog.Warn("failed login", "user", data.User, "remote", remoteHost(r.RemoteAddr))
+		data.Error = "Invalid login or password"
+		status = http.StatusUnauthorized
+	}
+
+	renderTemplate(w, g.templates, "login.html", status, data)
+}
+
+// handleLogout closes the session of the browser (POST only, so that a link
+// or image cannot log the user out)
+func (g *sessionGuard) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		g.mu.Lock()
+		delete(g.sessions, cookie.Value)
+		g.mu.Unlock()
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/login?logged_out=1", http.StatusSeeOther)
+}
+
+// pruneSessions forgets expired sessions; the caller holds g.mu
+func (g *sessionGuard) pruneSessions() {
+	now := time.Now()
+	for token, s := range g.sessions {
+		if now.After(s.expires) {
+			delete(g.sessions, token)
+		}
+	}
+}
+
+// localRedirect keeps a redirect target only if it stays on this site
+func localRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") || strings.HasPrefix(target, "/login") {
+		return "/"
+	}
+	return target
+}
+
+// loggedInUser returns the user of an authenticated request, empty when
+// access is open
+func loggedInUser(r *http.Request) string {
+	s, _ := r.Context().Value(sessionKey{}).(session)
+	return s.user
+}
+
+// readOnly reports whether the request comes from a viewer, whose pages hide
+// the forms that would be refused
+func readOnly(r *http.Request) bool {
+	s, _ := r.Context().Value(sessionKey{}).(session)
+	return s.role == settings.RoleViewer
+}
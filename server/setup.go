@@ -0,0 +1,140 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"tp1/annuaire"
+	"tp1/settings"
+)
+
+// setupData is the data passed to setupTemplate
+type setupData struct {
+	Config    *settings.Bootstrap // Values shown in the form
+	AdminUser string              // Login typed before a failed attempt
+	Path      string              // Configuration file written on submit
+	Regions   []string            // Choices of the region selector
+	Languages []string            // Choices of the language selector
+	Storages  []string            // Choices of the storage selector
+	Error     string              // Why the previous submission was refused
+	CSRFToken string              // Token embedded in the form
+}
+
+/**
+ * WithBootstrap enables the first-run setup page and the configured locale
+ *
+ * @param {string} path - Application configuration file (see settings.BootstrapPath)
+ * @param {string} dataFile - Contacts file proposed by the setup form
+ * @return {Option} Option to pass to NewServer
+ */
+func WithBootstrap(path, dataFile string) Option {
+	return func(s *Server) {
+		s.bootstrapFile, s.dataFile = path, dataFile
+	}
+}
+
+/**
+ * loadBootstrap reads the configuration set with WithBootstrap
+ *
+ * @return {*settings.Bootstrap} Current configuration, nil when the setup was
+ *                               not run yet, disabled or unreadable
+ */
+func (s *Server) loadBootstrap() *settings.Bootstrap {
+	if s.bootstrapFile == "" {
+		return nil
+	}
+	config, err := settings.LoadBootstrap(s.bootstrapFile)
+	if err != nil {
+		slog.Warn("cannot load configuration", "file", s.bootstrapFile, "error", err)
+		return nil
+	}
+	return config
+}
+
+// needsSetup reports whether the first-run setup page should be offered
+func (s *Server) needsSetup() bool {
+	if s.bootstrapFile == "" {
+		return false
+	}
+	config, err := settings.LoadBootstrap(s.bootstrapFile)
+	return config == nil && err == nil
+}
+
+/**
+ * handleSetup shows and processes the first-run setup form
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer
+ * @param {*http.Request} r - GET to show the form, POST to write the configuration
+ *
+ * The page is only available while no configuration exists: afterwards it
+ * would let anyone replace the administrator, so changes go through
+ * "tp1 setup" on the machine instead
+ */
+func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
+	if !s.needsSetup() {
+		redirectWithFlash(w, r, flashError, "Setup already done; run \"tp1 setup\" on the server to change it")
+		return
+	}
+
+	data := setupData{
+		Config:    settings.DefaultBootstrap(s.dataFile),
+		Path:      s.bootstrapFile,
+		Regions:   annuaire.Regions(),
+		Languages: settings.Languages,
+		Storages:  []string{annuaire.FormatJSON, annuaire.FormatAppendLog},
+		CSRFToken: csrfToken(r),
+	}
+
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		data.Config = &settings.Bootstrap{
+			Region:   r.FormValue("region"),
+			Language: r.FormValue("language"),
+			DataFile: r.FormValue("data_file"),
+			Storage:  r.FormValue("storage"),
+		}
+		data.AdminUser = r.FormValue("admin_user")
+
+		admin, err := settings.NewAdminCredentials(data.AdminUser, r.FormValue("admin_password"))
+		if err == nil {
+			data.Config.Admin = admin
+			err = data.Config.Save(s.bootstrapFile)
+		}
+		if err == nil {
+			slog.Info("configuration saved", "file", s.bootstrapFile, "region", data.Config.Region)
+			redirectWithFlash(w, r, flashSuccess, fmt.Sprintf("Configuration saved to %s", s.bootstrapFile))
+			return
+		}
+		data.Error = fmt.Sprintf("Error: %v", err)
+		status = http.StatusBadRequest
+	}
+
+	renderTemplate(w, s.templates, "setup.html", status, data)
+}
+
+// dialablePhone matches the phone numbers worth turning into a tel: link
+var dialablePhone = regexp.MustCompile(`^\+?[0-9]+$`)
+
+// telLink returns a tel: URL for a phone number in the given region, or an
+// empty URL when the number cannot be dialed (letters, extensions)
+func telLink(phone, region string) template.URL {
+	if region == "" {
+		region = annuaire.DefaultRegion
+	}
+	number := annuaire.InternationalPhone(phone, region)
+	if !dialablePhone.MatchString(number) {
+		return ""
+	}
+	return template.URL("tel:" + number)
+}
+
+// fillLocale sets the region, language and setup banner of a page
+func (s *Server) fillLocale(data *PageData) {
+	data.Region, data.Language = annuaire.DefaultRegion, settings.Languages[0]
+	if config := s.loadBootstrap(); config != nil {
+		data.Region, data.Language = config.Region, config.Language
+	}
+	data.NeedsSetup = s.needsSetup()
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net/http"
+	"tp1/annuaire"
+)
+
+// shareData is the data passed to shareTemplate
+type shareData struct {
+	Contacts []annuaire.Contact // Contacts with sensitive fields already masked
+	Masked   []string           // Names of the masked fields
+}
+
+/**
+ * handleShare renders a read-only view of the directory for sharing
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer for HTML content
+ * @param {*http.Request} r - HTTP GET request
+ *
+ * Contacts are listed in the usual order (pinned first) and every field marked
+ * as sensitive in settings is masked, e.g. "06 ** ** ** 12" for a phone
+ */
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	cfg := s.loadSettings()
+	contacts, _ := s.sortedContacts()
+	for i := range contacts {
+		contacts[i] = annuaire.MaskContact(contacts[i], cfg.SensitiveFields)
+	}
+
+	data := shareData{Contacts: contacts, Masked: cfg.SensitiveFields}
+	renderTemplate(w, s.templates, "share.html", http.StatusOK, data)
+}
@@ -0,0 +1,151 @@
+// Add some basic interactivity
+document.addEventListener('DOMContentLoaded', function() {
+    hideMessages(document);
+
+    // Forms marked data-fragment (add, search, delete) are sent with fetch:
+    // the server answers the updated parts of the page, which replace the
+    // elements with the same id, so the page does not reload
+    document.addEventListener('submit', function(event) {
+        const form = event.target;
+        if (!form.matches('form[data-fragment]')) {
+            return;
+        }
+        event.preventDefault();
+        submitFragment(form);
+    });
+
+    // Inputs with data-suggest offer the names starting with what is typed
+    document.querySelectorAll('input[data-suggest]').forEach(input => {
+        let timer;
+        input.addEventListener('input', function() {
+            clearTimeout(timer);
+            timer = setTimeout(() => suggestNames(input), 150);
+        });
+    });
+
+    // The custom field editor of the add form: rows of name and value inputs
+    // sent as repeated custom_key and custom_value fields
+    document.addEventListener('click', function(event) {
+        const add = event.target.closest('[data-add-custom]');
+        const remove = event.target.closest('[data-remove-custom]');
+        if (add) {
+            const rows = add.parentElement.querySelector('.custom-field-rows');
+            const row = rows.lastElementChild.cloneNode(true);
+            row.querySelectorAll('input').forEach(input => input.value = '');
+            rows.append(row);
+            row.querySelector('input').focus();
+        } else if (remove) {
+            resetCustomRow(remove.closest('.custom-field-row'));
+        }
+    });
+
+    // Going back after a search shows the previous results again
+    window.addEventListener('popstate', function() {
+        window.location.reload();
+    });
+});
+
+// Auto-hide messages after 5 seconds
+function hideMessages(root) {
+    root.querySelectorAll('.message').forEach(message => {
+        setTimeout(() => {
+            message.style.opacity = '0';
+            message.style.transform = 'translateY(-20px)';
+            setTimeout(() => {
+                message.style.display = 'none';
+            }, 300);
+        }, 5000);
+    });
+}
+
+// Send a form with fetch and swap the fragments of the answer into the page
+async function submitFragment(form) {
+    const method = form.method.toUpperCase();
+    const params = new URLSearchParams(new FormData(form));
+    let url = form.action;
+    const options = {
+        method: method,
+        headers: {'HX-Request': 'true', 'HX-Current-URL': window.location.href}
+    };
+    if (method === 'GET') {
+        url += '?' + params.toString();
+    } else {
+        options.body = params;
+    }
+
+    let response;
+    try {
+        response = await fetch(url, options);
+    } catch (error) {
+        showError('Network error: ' + error.message);
+        return;
+    }
+    // A redirect means the session ended: follow it to the login page
+    if (response.redirected) {
+        window.location.href = response.url;
+        return;
+    }
+
+    const text = await response.text();
+    const answer = document.createElement('template');
+    answer.innerHTML = text;
+    const parts = Array.from(answer.content.children).filter(part => part.id);
+    if (parts.length === 0) {
+        // Plain text errors (read-only account, rate limit...) have no fragments
+        showError(text.trim() || response.statusText);
+        return;
+    }
+    parts.forEach(part => {
+        const current = document.getElementById(part.id);
+        if (current) {
+            current.replaceWith(part);
+            hideMessages(part);
+        }
+    });
+
+    if (method === 'GET') {
+        history.pushState(null, '', url);
+    } else if (response.ok) {
+        form.reset();
+        form.querySelectorAll('.custom-field-row').forEach(resetCustomRow);
+    }
+}
+
+// Remove a row of the custom field editor, emptying the last one instead
+function resetCustomRow(row) {
+    if (row.parentElement.children.length > 1) {
+        row.remove();
+    } else {
+        row.querySelectorAll('input').forEach(input => input.value = '');
+    }
+}
+
+// Fill the datalist of an input with the names completing its value
+async function suggestNames(input) {
+    const list = document.getElementById(input.getAttribute('list'));
+    const prefix = input.value.trim();
+    if (!list || prefix === '') {
+        list?.replaceChildren();
+        return;
+    }
+    try {
+        const response = await fetch(input.dataset.suggest + '?' + new URLSearchParams({q: prefix}));
+        if (!response.ok || input.value.trim() !== prefix) {
+            return; // Failed, or already typing something else
+        }
+        const answer = await response.json();
+        list.replaceChildren(...answer.names.map(name => new Option(name)));
+    } catch (error) {
+        // Suggestions are a convenience: the search works without them
+    }
+}
+
+// Display a message built in the browser in the message area
+function showError(text) {
+    const area = document.getElementById('message-area');
+    const message = document.createElement('div');
+    message.className = 'message error';
+    message.textContent = text;
+    area.replaceChildren(message);
+    hideMessages(area);
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"tp1/annuaire"
+)
+
+// Period and length of the lists of the stats page
+const (
+	statsDays   = 30 // Days of the growth chart, today included
+	statsRecent = 10 // Modifications listed as recent activity
+)
+
+// Size of the growth chart, in SVG user units
+const (
+	chartWidth  = 600
+	chartHeight = 150
+)
+
+// statsData is the data passed to stats.html
+type statsData struct {
+	Stats    annuaire.Stats          // Figures of GET /api/stats
+	Growth   growthChart             // Directory size over the last statsDays days
+	Initials []chartBar              // Contacts per initial of the last name
+	Tags     []chartBar              // Contacts per tag, most frequent first
+	Recent   []annuaire.HistoryEntry // Latest modifications, newest first
+}
+
+// chartBar is one bar of a horizontal bar chart
+type chartBar struct {
+	Label   string
+	Count   int
+	Percent int // Length relative to the longest bar, 1 to 100
+}
+
+// growthChart is a line chart of the directory size, one point per day
+type growthChart struct {
+	Points   string // SVG polyline points, in a chartWidth x chartHeight box
+	From, To string // Dates of the first and last points
+	Max      int    // Size at the top of the chart
+	Width    int
+	Height   int
+}
+
+/**
+ * stats returns the figures of the directory, computed once per generation
+ *
+ * @return {annuaire.Stats} Summary of the current contacts, Modified included
+ */
+func (s *Server) stats() annuaire.Stats {
+	stats := s.cache.get(s.dir.Generation(), "stats", func() any {
+		return s.dir.Stats(annuaire.DefaultTopPrefixes)
+	}).(annuaire.Stats)
+	stats.Modified = s.dir.LastSaved(s.dataFile)
+	return stats
+}
+
+/**
+ * handleStats renders the statistics dashboard
+ *
+ * @param {http.ResponseWriter} w - HTTP response writer for HTML content
+ * @param {*http.Request} r - HTTP GET request
+ *
+ * The page shows the size of the directory over the last 30 days, rebuilt
+ * from the audit log, the contacts per tag and per initial, and the latest
+ * modifications. Charts are inline SVG drawn on the server, so the page
+ * needs no script
+ */
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	entries, err := s.dir.History()
+	if err != nil {
+		slog.Error("cannot read history", "error", err)
+		http.Error(w, "Cannot read history", http.StatusInternalServerError)
+		return
+	}
+
+	data := statsData{Stats: s.stats()}
+	data.Growth = newGrowthChart(annuaire.AnalyzeUsage(entries, time.Now(), statsDays).Days)
+	for _, initial := range data.Stats.Initials {
+		data.Initials = append(data.Initials, chartBar{Label: initial.Initial, Count: initial.Count})
+	}
+	for _, tag := range data.Stats.Tags {
+		data.Tags = append(data.Tags, chartBar{Label: tag.Tag, Count: tag.Count})
+	}
+	scaleBars(data.Initials)
+	scaleBars(data.Tags)
+
+	// Searches are kept for usage statistics but are not modifications
+	entries = slices.DeleteFunc(entries, func(e annuaire.HistoryEntry) bool { return !e.IsModification() })
+	slices.Reverse(entries)
+	data.Recent = entries[:min(len(entries), statsRecent)]
+
+	renderTemplate(w, s.templates, "stats.html", http.StatusOK, data)
+}
+
+// scaleBars sets the length of each bar relative to the longest one
+func scaleBars(bars []chartBar) {
+	largest := 0
+	for _, bar := range bars {
+		largest = max(largest, bar.Count)
+	}
+	for i := range bars {
+		bars[i].Percent = max(1, bars[i].Count*100/largest)
+	}
+}
+
+// newGrowthChart plots the directory size at the end of each day
+func newGrowthChart(days []annuaire.UsageDay) growthChart {
+	chart := growthChart{Width: chartWidth, Height: chartHeight, Max: 1}
+	if len(days) == 0 {
+		return chart
+	}
+	for _, day := range days {
+		chart.Max = max(chart.Max, day.Contacts)
+	}
+	step := float64(chartWidth) / float64(max(len(days)-1, 1))
+	points := make([]string, len(days))
+	for i, day := range days {
+		y := float64(chartHeight) - float64(day.Contacts)*chartHeight/float64(chart.Max)
+		points[i] = fmt.Sprintf("%.1f,%.1f", float64(i)*step, y)
+	}
+	chart.Points = strings.Join(points, " ")
+	chart.From, chart.To = days[0].Day.Format("2006-01-02"), days[len(days)-1].Day.Format("2006-01-02")
+	return chart
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// hstsMaxAge tells browsers to keep using HTTPS for a year once they saw it
+const hstsMaxAge = 365 * 24 * time.Hour
+
+// certReloader serves a certificate pair from disk, reloading it when the
+// files change, so that a renewed certificate (certbot, a cron job) is picked
+// up without restarting the server
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time // Latest modification time of the two files when loaded
+}
+
+/**
+ * tlsConfigFor builds the TLS configuration of the server
+ *
+ * @param {Config} cfg - Server configuration with TLSCertFile and TLSKeyFile
+ * @return {*tls.Config} Configuration to wrap the listener with, nil for plain HTTP
+ * @return {error} Returns an error if only one file is given or the pair cannot be loaded
+ */
+func tlsConfigFor(cfg Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("TLS needs both a certificate and a key file")
+	}
+
+	reloader := &certReloader{certFile: cfg.TLSCertFile, keyFile: cfg.TLSKeyFile}
+	// Fail at startup rather than on the first handshake
+	if _, err := reloader.getCertificate(nil); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}, nil
+}
+
+// getCertificate returns the current certificate, reloading the files if they
+// are newer; a failed reload keeps serving the previous certificate
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	modTime, err := latestModTime(c.certFile, c.keyFile)
+	if err == nil && c.cert != nil && !modTime.After(c.modTime) {
+		return c.cert, nil
+	}
+
+	cert, loadErr := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err = errors.Join(err, loadErr); err != nil {
+		if c.cert != nil {
+			slog.Warn("keeping previous TLS certificate", "cert", c.certFile, "error", err)
+			return c.cert, nil
+		}
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	if c.cert != nil {
+		slog.Info("TLS certificate reloaded", "cert", c.certFile)
+	}
+	c.cert, c.modTime = &cert, modTime
+	return c.cert, nil
+}
+
+// latestModTime returns the most recent modification time of the given files
+func latestModTime(paths ...string) (time.Time, error) {
+	var latest time.Time
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// strictTransport adds the HSTS header to every response served over TLS
+func strictTransport(next http.Handler) http.Handler {
+	value := fmt.Sprintf("max-age=%d", int(hstsMaxAge.Seconds()))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Strict-Transport-Security", value)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"tp1/annuaire"
+	"tp1/tracing"
+)
+
+/**
+ * traceRequests records a server span for every request
+ *
+ * @param {http.Handler} next - Handler of every request, login and limits included
+ * @return {http.Handler} Handler continuing the trace of callers that send a
+ *   traceparent header; spans are named after the method until nameRoute
+ *   gives them the matched route
+ *
+ * Nothing is recorded while tracing is off (see tracing.SetExporter)
+ */
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method, tracing.KindServer,
+			tracing.String("http.request.method", r.Method),
+			tracing.String("url.path", r.URL.Path),
+			tracing.String("user_agent.original", r.UserAgent()))
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetError(httpError(status))
+		}
+	})
+}
+
+// httpError describes a failed answer as the error of its span
+type httpError int
+
+func (status httpError) Error() string {
+	return http.StatusText(int(status))
+}
+
+// nameRoute names the span of a request after the route it matches, e.g.
+// "PATCH /api/contacts/{id}", so that traces group by route and not by ID
+func nameRoute(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if span := tracing.SpanFromContext(r.Context()); span != nil {
+			if _, pattern := mux.Handler(r); pattern != "" {
+				// Patterns may start with a method, e.g. "GET /contacts"
+				route := pattern[strings.Index(pattern, "/"):]
+				span.SetName(r.Method + " " + route)
+				span.SetAttributes(tracing.String("http.route", route))
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// tracedDirectory is the directory as seen by one request: every method
+// changing the contacts, searching them or reading and writing the data file
+// records a span, child of the span of the request. The other methods are
+// those of the directory, untraced
+type tracedDirectory struct {
+	*annuaire.Directory
+	ctx     context.Context
+	storage annuaire.Storage // Storage of the directory, set by Serve only (see LoadDataFile)
+}
+
+// traced returns the directory of a request, see tracedDirectory
+func (s *Server) traced(r *http.Request) tracedDirectory {
+	return tracedDirectory{Directory: s.dir, ctx: r.Context()}
+}
+
+// span runs a method of the directory in a span named after it, e.g.
+// "annuaire.SaveContact", recording its error
+func (t tracedDirectory) span(method string, call func(ctx context.Context) error, attrs ...tracing.Attr) error {
+	ctx, span := tracing.Start(t.ctx, "annuaire."+method, tracing.KindInternal, attrs...)
+	defer span.End()
+	err := call(ctx)
+	span.SetError(err)
+	return err
+}
+
+// InsertContact is annuaire.Directory.InsertContact in a span
+func (t tracedDirectory) InsertContact(c annuaire.Contact) error {
+	return t.span("InsertContact", func(context.Context) error { return t.Directory.InsertContact(c) })
+}
+
+// SaveContact is annuaire.Directory.SaveContact in a span
+func (t tracedDirectory) SaveContact(c annuaire.Contact) error {
+	return t.span("SaveContact", func(context.Context) error { return t.Directory.SaveContact(c) }, tracing.Int("annuaire.id", c.ID))
+}
+
+// DeleteContact is annuaire.Directory.DeleteContact in a span
+func (t tracedDirectory) DeleteContact(name string) error {
+	return t.span("DeleteContact", func(context.Context) error { return t.Directory.DeleteContact(name) })
+}
+
+// DeleteContactByID is annuaire.Directory.DeleteContactByID in a span
+func (t tracedDirectory) DeleteContactByID(id int) error {
+	return t.span("DeleteContactByID", func(context.Context) error { return t.Directory.DeleteContactByID(id) }, tracing.Int("annuaire.id", id))
+}
+
+// SetPhoto is annuaire.Directory.SetPhoto in a span
+func (t tracedDirectory) SetPhoto(id int, photo []byte) error {
+	return t.span("SetPhoto", func(context.Context) error { return t.Directory.SetPhoto(id, photo) },
+		tracing.Int("annuaire.id", id), tracing.Int("annuaire.photo_bytes", len(photo)))
+}
+
+// AddContacts is annuaire.Directory.AddContacts in a span
+func (t tracedDirectory) AddContacts(contacts []annuaire.Contact) (results []annuaire.AddResult, err error) {
+	err = t.span("AddContacts", func(context.Context) error {
+		results, err = t.Directory.AddContacts(contacts)
+		return err
+	}, tracing.Int("annuaire.contacts", len(contacts)))
+	return results, err
+}
+
+// TagContacts is annuaire.Directory.TagContacts in a span
+func (t tracedDirectory) TagContacts(match annuaire.ExportFilter, tag string, add bool) (change annuaire.TagChange, err error) {
+	err = t.span("TagContacts", func(context.Context) error {
+		change, err = t.Directory.TagContacts(match, tag, add)
+		return err
+	})
+	return change, err
+}
+
+// ClearContacts is annuaire.Directory.ClearContacts in a span
+func (t tracedDirectory) ClearContacts(scope annuaire.ClearScope) (removed int) {
+	t.span("ClearContacts", func(context.Context) error {
+		removed = t.Directory.ClearContacts(scope)
+		return nil
+	}, tracing.String("annuaire.scope", scope.String()))
+	return removed
+}
+
+// ImportContacts is annuaire.Directory.ImportContacts in a span
+func (t tracedDirectory) ImportContacts(contacts []annuaire.Contact, opts annuaire.ImportOptions, source string) (result annuaire.ImportResult) {
+	t.span("ImportContacts", func(context.Context) error {
+		result = t.Directory.ImportContacts(contacts, opts, source)
+		return nil
+	}, tracing.Int("annuaire.contacts", len(contacts)), tracing.String("annuaire.strategy", string(opts.Strategy)))
+	return result
+}
+
+// Merge is annuaire.Directory.Merge in a span
+func (t tracedDirectory) Merge(other []annuaire.Contact, policy annuaire.ConflictPolicy, source string) (result annuaire.ImportResult) {
+	t.span("Merge", func(context.Context) error {
+		result = t.Directory.Merge(other, policy, source)
+		return nil
+	}, tracing.Int("annuaire.contacts", len(other)), tracing.String("annuaire.policy", string(policy)))
+	return result
+}
+
+// searchSpan runs a search in a span recording how far it went
+func (t tracedDirectory) searchSpan(method string, search func(ctx context.Context) annuaire.SearchResult) (result annuaire.SearchResult) {
+	ctx, span := tracing.Start(t.ctx, "annuaire."+method, tracing.KindInternal)
+	defer span.End()
+	result = search(ctx)
+	span.SetAttributes(tracing.Int("annuaire.scanned", result.Scanned), tracing.Int("annuaire.matches", len(result.Contacts)),
+		tracing.Bool("annuaire.truncated", result.Truncated))
+	return result
+}
+
+// ScanContacts is annuaire.Directory.ScanContacts in a span, within the request
+func (t tracedDirectory) ScanContacts(match func(annuaire.Contact) bool, limits annuaire.SearchLimits) annuaire.SearchResult {
+	return t.searchSpan("ScanContacts", func(ctx context.Context) annuaire.SearchResult {
+		return t.Directory.ScanContacts(ctx, match, limits)
+	})
+}
+
+// FilterContactsWith is annuaire.Directory.FilterContactsWith in a span, within the request
+func (t tracedDirectory) FilterContactsWith(term string, opts annuaire.SearchOptions, limits annuaire.SearchLimits) annuaire.SearchResult {
+	return t.searchSpan("FilterContactsWith", func(ctx context.Context) annuaire.SearchResult {
+		return t.Directory.FilterContactsWith(ctx, term, opts, limits)
+	})
+}
+
+// withFiles runs a call with the storage traced as children of ctx. The
+// storage of the directory is swapped for the duration, which is safe
+// because Serve loads and saves the data file only before serving and after
+// the last request
+func (t tracedDirectory) withFiles(ctx context.Context, call func() error) error {
+	next := t.storage
+	if next == nil {
+		next = annuaire.OSStorage{}
+	}
+	t.Directory.SetStorage(tracedStorage{next: next, ctx: ctx})
+	defer t.Directory.SetStorage(t.storage)
+	return call()
+}
+
+// LoadDataFile is annuaire.Directory.LoadDataFile in a span, with the files read
+func (t tracedDirectory) LoadDataFile(dataFile, format string) error {
+	return t.span("LoadDataFile", func(ctx context.Context) error {
+		return t.withFiles(ctx, func() error { return t.Directory.LoadDataFile(dataFile, format) })
+	}, tracing.String("file.path", dataFile))
+}
+
+// SaveDataFile is annuaire.Directory.SaveDataFile in a span, with the files written
+func (t tracedDirectory) SaveDataFile(dataFile string) error {
+	return t.span("SaveDataFile", func(ctx context.Context) error {
+		return t.withFiles(ctx, func() error { return t.Directory.SaveDataFile(dataFile) })
+	}, tracing.String("file.path", dataFile))
+}
+
+// tracedStorage records a span for every file read or written, child of the
+// span of ctx; like annuaire.FaultyStorage, it wraps the storage doing the work
+type tracedStorage struct {
+	next annuaire.Storage
+	ctx  context.Context
+}
+
+// ReadFile reads a file in a span recording its size
+func (s tracedStorage) ReadFile(name string) ([]byte, error) {
+	_, span := tracing.Start(s.ctx, "storage.ReadFile", tracing.KindInternal, tracing.String("file.path", name))
+	defer span.End()
+	data, err := s.next.ReadFile(name)
+	span.SetAttributes(tracing.Int("file.size", len(data)))
+	span.SetError(err)
+	return data, err
+}
+
+// WriteFile writes a file in a span recording its size
+func (s tracedStorage) WriteFile(name string, data []byte) error {
+	_, span := tracing.Start(s.ctx, "storage.WriteFile", tracing.KindInternal, tracing.String("file.path", name), tracing.Int("file.size", len(data)))
+	defer span.End()
+	err := s.next.WriteFile(name, data)
+	span.SetError(err)
+	return err
+}
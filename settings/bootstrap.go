@@ -0,0 +1,303 @@
+package settings
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"tp1/annuaire"
+)
+
+// BootstrapFileName is the application configuration written by the first-run setup
+const BootstrapFileName = "config.json"
+
+// BootstrapEnv names the environment variable overriding the configuration path
+const BootstrapEnv = "TP1_CONFIG_FILE"
+
+// Languages lists the interface languages that can be configured
+var Languages = []string{"en", "fr"}
+
+// Password hashing parameters of AdminCredentials
+const (
+	passwordIterations = 600000 // PBKDF2-HMAC-SHA256 rounds, as recommended by OWASP
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+	minPasswordLength  = 8
+)
+
+// Bootstrap is the application-wide configuration written once by the
+// first-run setup (tp1 setup, or the /setup web page)
+// Unlike Settings, it is not tied to a contacts file: it says where that file is
+type Bootstrap struct {
+	Region   string              `json:"region"`            // Phone region of national numbers, e.g. "FR" (see annuaire.Regions)
+	Language string              `json:"language"`          // Interface language, one of Languages
+	DataFile string              `json:"data_file"`         // Contacts file, used when neither -data nor TP1_DATA_FILE is set
+	Storage  string              `json:"storage"`           // annuaire.FormatJSON or annuaire.FormatAppendLog, unless -storage is given
+	Admin    *AdminCredentials   `json:"admin,omitempty"`   // Administrator account (nil: none configured)
+	Viewers  []*AdminCredentials `json:"viewers,omitempty"` // Read-only accounts of the web interface (see RoleViewer)
+}
+
+// Roles of the accounts that can log in to the web interface
+const (
+	RoleAdmin  = "admin"  // Full access
+	RoleViewer = "viewer" // Lists, searches and exports, but cannot modify anything
+)
+
+// AdminCredentials identify an account (the administrator or a viewer);
+// only a salted hash of the password is kept
+type AdminCredentials struct {
+	User       string `json:"user"`
+	Salt       string `json:"salt"`       // Base64 random salt
+	Hash       string `json:"hash"`       // Base64 PBKDF2-HMAC-SHA256 of the password
+	Iterations int    `json:"iterations"` // Rounds used for Hash, so they can be raised later
+}
+
+/**
+ * BootstrapPath returns the location of the application configuration
+ *
+ * @return {string} $TP1_CONFIG_FILE, or tp1/config.json in the user configuration directory
+ * @return {error} Returns an error when neither is available
+ */
+func BootstrapPath() (string, error) {
+	if env := os.Getenv(BootstrapEnv); env != "" {
+		return env, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "tp1", BootstrapFileName), nil
+}
+
+/**
+ * DefaultBootstrap returns the values proposed by the setup
+ *
+ * @param {string} dataFile - Contacts file currently in use
+ * @return {*Bootstrap} Configuration without administrator
+ */
+func DefaultBootstrap(dataFile string) *Bootstrap {
+	return &Bootstrap{
+		Region:   annuaire.DefaultRegion,
+		Language: Languages[0],
+		DataFile: dataFile,
+		Storage:  annuaire.FormatJSON,
+	}
+}
+
+/**
+ * LoadBootstrap reads the application configuration
+ *
+ * @param {string} path - Configuration file (see BootstrapPath)
+ * @return {*Bootstrap} Loaded configuration, nil when the setup has not been run yet
+ * @return {error} Returns an error if the file exists but cannot be read or is invalid
+ */
+func LoadBootstrap(path string) (*Bootstrap, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var b Bootstrap
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := b.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &b, nil
+}
+
+/**
+ * Save writes the configuration, readable by the current user only
+ *
+ * @param {string} path - Configuration file (parent directories are created)
+ * @return {error} Returns an error if the configuration is invalid or cannot be written
+ */
+func (b *Bootstrap) Save(path string) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Validate checks every field, so that a bad setup is refused before being written
+func (b *Bootstrap) Validate() error {
+	if err := annuaire.ValidateRegion(b.Region); err != nil {
+		return err
+	}
+	if !slices.Contains(Languages, b.Language) {
+		return fmt.Errorf("unsupported language %q (expected one of: %s)", b.Language, strings.Join(Languages, ", "))
+	}
+	if strings.TrimSpace(b.DataFile) == "" {
+		return errors.New("data file is required")
+	}
+	if b.Storage != annuaire.FormatJSON && b.Storage != annuaire.FormatAppendLog {
+		return fmt.Errorf("unknown storage %q (expected %s or %s)", b.Storage, annuaire.FormatJSON, annuaire.FormatAppendLog)
+	}
+	if b.Admin != nil && !b.Admin.complete() {
+		return errors.New("incomplete administrator credentials")
+	}
+	for i, viewer := range b.Viewers {
+		if viewer == nil || !viewer.complete() {
+			return errors.New("incomplete viewer credentials")
+		}
+		if (b.Admin != nil && viewer.User == b.Admin.User) || slices.ContainsFunc(b.Viewers[:i], func(v *AdminCredentials) bool { return v.User == viewer.User }) {
+			return fmt.Errorf("login %q is used by several accounts", viewer.User)
+		}
+	}
+	return nil
+}
+
+// complete reports whether every field of the credentials is set
+func (a *AdminCredentials) complete() bool {
+	return a.User != "" && a.Hash != "" && a.Salt != "" && a.Iterations >= 1
+}
+
+/**
+ * SetViewer adds a read-only account, or changes the password of an existing one
+ *
+ * @param {string} user - Viewer login, different from the administrator's
+ * @param {string} password - Clear-text password, at least 8 characters
+ * @return {error} Returns an error for an invalid login or password
+ */
+func (b *Bootstrap) SetViewer(user, password string) error {
+	viewer, err := NewAdminCredentials(user, password)
+	if err != nil {
+		return err
+	}
+	if b.Admin != nil && viewer.User == b.Admin.User {
+		return fmt.Errorf("%q is the administrator login", viewer.User)
+	}
+	b.RemoveViewer(viewer.User)
+	b.Viewers = append(b.Viewers, viewer)
+	return nil
+}
+
+// RemoveViewer deletes a read-only account and reports whether it existed
+func (b *Bootstrap) RemoveViewer(user string) bool {
+	count := len(b.Viewers)
+	b.Viewers = slices.DeleteFunc(b.Viewers, func(v *AdminCredentials) bool { return v.User == user })
+	return len(b.Viewers) != count
+}
+
+/**
+ * Authenticate checks a login against the administrator and the viewers
+ *
+ * @param {string} user - Login typed by the user
+ * @param {string} password - Password typed by the user
+ * @return {string} RoleAdmin or RoleViewer
+ * @return {bool} False when the login is unknown or the password wrong
+ *
+ * Exactly one password hash is computed whatever the login, so the response
+ * time does not reveal which logins exist
+ */
+func (b *Bootstrap) Authenticate(user, password string) (string, bool) {
+	if b.Admin == nil {
+		return "", false
+	}
+	for _, viewer := range b.Viewers {
+		if viewer.User == user {
+			return RoleViewer, viewer.Check(user, password)
+		}
+	}
+	return RoleAdmin, b.Admin.Check(user, password)
+}
+
+// Environment variables giving the administrator account, e.g. in a container
+// where the configuration file is read-only; they take precedence over it
+const (
+	AdminUserEnv     = "TP1_ADMIN_USER"
+	AdminPasswordEnv = "TP1_ADMIN_PASSWORD"
+)
+
+/**
+ * AdminFromEnv reads the administrator account from the environment
+ *
+ * @return {*AdminCredentials} Hashed credentials, nil when neither variable is set
+ * @return {error} Returns an error if only one variable is set or the password is too short
+ */
+func AdminFromEnv() (*AdminCredentials, error) {
+	user, password := os.Getenv(AdminUserEnv), os.Getenv(AdminPasswordEnv)
+	if user == "" && password == "" {
+		return nil, nil
+	}
+	if user == "" || password == "" {
+		return nil, fmt.Errorf("%s and %s must be set together", AdminUserEnv, AdminPasswordEnv)
+	}
+	return NewAdminCredentials(user, password)
+}
+
+/**
+ * NewAdminCredentials hashes a password for storage in the configuration
+ *
+ * @param {string} user - Administrator login
+ * @param {string} password - Clear-text password, at least 8 characters
+ * @return {*AdminCredentials} Credentials with a fresh random salt
+ * @return {error} Returns an error for an empty login or a short password
+ */
+func NewAdminCredentials(user, password string) (*AdminCredentials, error) {
+	user = strings.TrimSpace(user)
+	if user == "" {
+		return nil, errors.New("administrator login is required")
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("password must have at least %d characters", minPasswordLength)
+	}
+
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminCredentials{
+		User:       user,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Hash:       base64.StdEncoding.EncodeToString(key),
+		Iterations: passwordIterations,
+	}, nil
+}
+
+/**
+ * Check verifies a login attempt
+ *
+ * @param {string} user - Login typed by the user
+ * @param {string} password - Password typed by the user
+ * @return {bool} True only if both match; the comparison takes constant time
+ */
+func (a *AdminCredentials) Check(user, password string) bool {
+	salt, err := base64.StdEncoding.DecodeString(a.Salt)
+	if err != nil {
+		return false
+	}
+	want, err := base64.StdEncoding.DecodeString(a.Hash)
+	if err != nil || len(want) == 0 {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, a.Iterations, len(want))
+	if err != nil {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.User))
+	return subtle.ConstantTimeCompare(got, want)&userOK == 1
+}
@@ -0,0 +1,142 @@
+package settings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+	"tp1/annuaire"
+)
+
+// FileName is the name of the settings file stored next to the contacts file
+const FileName = "settings.json"
+
+// Settings holds the administrator preferences shared by the CLI and the web server
+// They live in their own file so that importing or exporting contacts never
+// overwrites them
+type Settings struct {
+	Pinned           []annuaire.ContactRef `json:"pinned,omitempty"`             // Contacts listed first everywhere, in this order
+	SensitiveFields  []string              `json:"sensitive_fields,omitempty"`   // Fields masked in shares and left out of non-admin exports
+	VerifyMonths     int                   `json:"verify_months,omitempty"`      // Months before a confirmed contact is due again (0: annuaire.DefaultVerifyMonths)
+	SearchMaxResults int                   `json:"search_max_results,omitempty"` // Matches returned by one web or API search (0: annuaire.DefaultMaxResults)
+	SearchTimeout    string                `json:"search_timeout,omitempty"`     // Time budget of one search, e.g. "500ms" (empty: annuaire.DefaultSearchTimeout)
+}
+
+/**
+ * PathFor returns the settings file location associated with a contacts file
+ *
+ * @param {string} dataFile - Path of the contacts JSON file
+ * @return {string} Path of settings.json in the same directory
+ */
+func PathFor(dataFile string) string {
+	return filepath.Join(filepath.Dir(dataFile), FileName)
+}
+
+/**
+ * Load reads settings from disk
+ *
+ * @param {string} path - Settings file path
+ * @return {*Settings} Loaded settings (empty settings if the file does not exist yet)
+ * @return {error} Returns an error if the file exists but cannot be read or parsed
+ */
+func Load(path string) (*Settings, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Settings{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s Settings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+/**
+ * Save writes the settings to disk with indentation for easy manual editing
+ *
+ * @param {string} path - Settings file path (parent directories are created)
+ * @return {error} Returns an error if the file cannot be written
+ */
+func (s *Settings) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+/**
+ * VerificationPeriod returns the number of months a confirmed contact stays trusted
+ *
+ * @return {int} The configured period, or annuaire.DefaultVerifyMonths when unset
+ */
+func (s *Settings) VerificationPeriod() int {
+	if s.VerifyMonths > 0 {
+		return s.VerifyMonths
+	}
+	return annuaire.DefaultVerifyMonths
+}
+
+/**
+ * SearchLimits returns the bounds applied to one web or API search
+ *
+ * @return {annuaire.SearchLimits} Configured limits, with defaults for unset
+ *                                 or invalid values
+ */
+func (s *Settings) SearchLimits() annuaire.SearchLimits {
+	limits := annuaire.SearchLimits{MaxResults: annuaire.DefaultMaxResults, Timeout: annuaire.DefaultSearchTimeout}
+	if s.SearchMaxResults > 0 {
+		limits.MaxResults = s.SearchMaxResults
+	}
+	if timeout, err := time.ParseDuration(s.SearchTimeout); err == nil && timeout > 0 {
+		limits.Timeout = timeout
+	}
+	return limits
+}
+
+/**
+ * Pin appends a contact to the end of the pinned list
+ *
+ * @param {annuaire.ContactRef} ref - Contact to pin
+ * @return {bool} False if the contact was already pinned
+ */
+func (s *Settings) Pin(ref annuaire.ContactRef) bool {
+	if s.IsPinned(ref) {
+		return false
+	}
+	s.Pinned = append(s.Pinned, ref)
+	return true
+}
+
+/**
+ * Unpin removes a contact from the pinned list
+ *
+ * @param {annuaire.ContactRef} ref - Contact to unpin
+ * @return {bool} False if the contact was not pinned
+ */
+func (s *Settings) Unpin(ref annuaire.ContactRef) bool {
+	for i, pinned := range s.Pinned {
+		if pinned == ref {
+			s.Pinned = append(s.Pinned[:i], s.Pinned[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IsPinned reports whether a contact is in the pinned list
+func (s *Settings) IsPinned(ref annuaire.ContactRef) bool {
+	for _, pinned := range s.Pinned {
+		if pinned == ref {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"tp1/annuaire"
+	"tp1/settings"
+)
+
+// region is the phone region of national numbers, set by main from the configuration
+var region = annuaire.DefaultRegion
+
+// loadBootstrap reads the application configuration written by "tp1 setup"
+// A broken file is reported but does not prevent using the CLI
+func loadBootstrap(path string) *settings.Bootstrap {
+	if path == "" {
+		return nil
+	}
+	config, err := settings.LoadBootstrap(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring configuration: %v\n", err)
+		return nil
+	}
+	return config
+}
+
+/**
+ * handleSetupAction runs the first-run wizard writing the application configuration
+ *
+ * @param {string} path - Configuration file (see settings.BootstrapPath)
+ * @param {*settings.Bootstrap} current - Existing configuration, nil on first run
+ * @param {string} dataFile - Contacts file proposed when there is no configuration yet
+ *
+ * Each question shows the current value between brackets; an empty answer
+ * keeps it. Running it again changes the configuration, and an empty
+ * password keeps the existing administrator
+ */
+func handleSetupAction(path string, current *settings.Bootstrap, dataFile string) {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "Error: no configuration directory available, set "+settings.BootstrapEnv)
+		os.Exit(exitIO)
+	}
+	config := settings.DefaultBootstrap(dataFile)
+	if current != nil {
+		copied := *current
+		config = &copied
+	}
+
+	in := stdinReader()
+	fmt.Printf("Configuration file: %s\n", path)
+	config.Region = strings.ToUpper(ask(in, "Default phone region ("+strings.Join(annuaire.Regions(), ", ")+")", config.Region))
+	config.Language = ask(in, "Language ("+strings.Join(settings.Languages, ", ")+")", config.Language)
+	config.DataFile = ask(in, "Contacts file", config.DataFile)
+	config.Storage = ask(in, "Storage ("+annuaire.FormatJSON+", "+annuaire.FormatAppendLog+")", config.Storage)
+
+	user := ""
+	if config.Admin != nil {
+		user = config.Admin.User
+	}
+	user = ask(in, "Administrator login", user)
+	// The standard library cannot disable the terminal echo
+	password := ask(in, "Administrator password (visible while typing; empty keeps the current one)", "")
+	if password != "" || config.Admin == nil || user != config.Admin.User {
+		admin, err := settings.NewAdminCredentials(user, password)
+		if err != nil {
+			exitWithUsageError(err)
+		}
+		config.Admin = admin
+	}
+
+	if err := config.Save(path); err != nil {
+		exitWithError(err)
+	}
+	fmt.Printf("Configuration saved to %s\n", path)
+}
+
+/**
+ * handleViewerAction adds a read-only account of the web interface
+ *
+ * @param {string} path - Configuration file (see settings.BootstrapPath)
+ * @param {*settings.Bootstrap} config - Existing configuration, nil before "tp1 setup"
+ * @param {string} user - Viewer login; empty lists the viewers
+ *
+ * The password is read from standard input; for an existing viewer it replaces the old one
+ */
+func handleViewerAction(path string, config *settings.Bootstrap, user string) {
+	if config == nil || config.Admin == nil {
+		fmt.Fprintln(os.Stderr, "Error: run \"tp1 setup\" first to create the administrator")
+		os.Exit(exitInvalid)
+	}
+	if user == "" {
+		if len(config.Viewers) == 0 {
+			notify("No read-only accounts\n")
+			return
+		}
+		for _, viewer := range config.Viewers {
+			fmt.Println(viewer.User)
+		}
+		return
+	}
+
+	// The standard library cannot disable the terminal echo
+	password := ask(stdinReader(), "Password for "+user+" (visible while typing)", "")
+	if err := config.SetViewer(user, password); err != nil {
+		exitWithUsageError(err)
+	}
+	if err := config.Save(path); err != nil {
+		exitWithError(err)
+	}
+	notify("Read-only account %s saved to %s\n", user, path)
+}
+
+// handleRevokeAction removes a read-only account of the web interface
+func handleRevokeAction(path string, config *settings.Bootstrap, user string) {
+	if user == "" {
+		fmt.Fprintln(os.Stderr, "Error: -name is required for revoke")
+		os.Exit(exitInvalid)
+	}
+	if config == nil || !config.RemoveViewer(user) {
+		fmt.Fprintf(os.Stderr, "Error: no read-only account %s\n", user)
+		os.Exit(exitNotFound)
+	}
+	if err := config.Save(path); err != nil {
+		exitWithError(err)
+	}
+	notify("Read-only account %s removed\n", user)
+}
+
+// ask prints a question with its default value and reads one line;
+// an empty answer or the end of input returns the default
+func ask(in *bufio.Reader, question, fallback string) string {
+	if fallback != "" {
+		fmt.Printf("%s [%s]: ", question, fallback)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	answer, err := in.ReadString('\n')
+	if answer = strings.TrimSpace(answer); answer == "" || (err != nil && err != io.EOF) {
+		return fallback
+	}
+	return answer
+}
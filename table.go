@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"tp1/annuaire"
+)
+
+// colors is set when tables may use ANSI colors: standard output is a
+// terminal, and neither -plain, -no-color nor the NO_COLOR variable asks
+// for monochrome output
+var colors bool
+
+// maxCellWidth bounds the characters shown per table cell, so that one long
+// name or list of tags does not push the other columns off the screen
+const maxCellWidth = 30
+
+// ANSI styles of the table columns
+const (
+	styleBold  = "\x1b[1m"
+	styleDim   = "\x1b[2m"
+	styleCyan  = "\x1b[36m"
+	styleReset = "\x1b[0m"
+)
+
+// tableColumn is a column of printTable: its heading and the style of its cells
+type tableColumn struct {
+	title string
+	style string // ANSI style, used only when colors is set
+}
+
+// contactColumns are the columns of the contact tables of list and search
+var contactColumns = []tableColumn{{"ID", styleDim}, {"FIRST", ""}, {"NAME", styleBold}, {"PHONE", styleCyan}, {"DETAILS", styleDim}}
+
+/**
+ * printTable writes rows as aligned columns under a heading line
+ *
+ * @param {io.Writer} w - Destination, usually os.Stdout
+ * @param {[]tableColumn} columns - Headings and styles
+ * @param {[][]string} rows - Cells, one slice per row in the order of columns
+ *
+ * Cells longer than maxCellWidth characters are cut with an ellipsis ("..."
+ * with -plain). Widths are counted in characters, not bytes, so accented
+ * names stay aligned; the styles are applied after padding, so the escape
+ * sequences never shift a column
+ */
+func printTable(w io.Writer, columns []tableColumn, rows [][]string) {
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = utf8.RuneCountInString(column.title)
+	}
+	cut := make([][]string, len(rows))
+	for r, row := range rows {
+		cut[r] = make([]string, len(row))
+		for i, cell := range row {
+			cut[r][i] = truncateCell(cell)
+			widths[i] = max(widths[i], utf8.RuneCountInString(cut[r][i]))
+		}
+	}
+
+	line := func(cells []string, style func(i int) string) {
+		var b strings.Builder
+		b.WriteString("  ")
+		for i, cell := range cells {
+			if i < len(cells)-1 {
+				cell += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+			if s := style(i); colors && s != "" && strings.TrimSpace(cell) != "" {
+				cell = s + cell + styleReset
+			}
+			b.WriteString(cell)
+		}
+		fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+	}
+
+	titles := make([]string, len(columns))
+	for i, column := range columns {
+		titles[i] = column.title
+	}
+	line(titles, func(int) string { return styleBold })
+	for _, row := range cut {
+		line(row, func(i int) string { return columns[i].style })
+	}
+}
+
+// truncateCell shortens a cell to maxCellWidth characters, ending with an ellipsis
+func truncateCell(cell string) string {
+	if utf8.RuneCountInString(cell) <= maxCellWidth {
+		return cell
+	}
+	ellipsis := "…"
+	if plain {
+		ellipsis = "..."
+	}
+	runes := []rune(cell)
+	return string(runes[:maxCellWidth-utf8.RuneCountInString(ellipsis)]) + ellipsis
+}
+
+// contactRow returns the cells of a contact under contactColumns; details are
+// joined in the last column
+func contactRow(c annuaire.Contact, details ...string) []string {
+	return []string{fmt.Sprintf("#%d", c.ID), c.First, c.Name, c.Phone, strings.Join(details, ", ")}
+}
+
+// contactDetails lists the optional fields of a contact worth showing in a table
+func contactDetails(c annuaire.Contact, pinned bool) []string {
+	var details []string
+	if pinned {
+		details = append(details, "pinned")
+	}
+	if c.Code != "" {
+		details = append(details, "code "+c.Code)
+	}
+	if c.Birthday != "" {
+		details = append(details, "born "+c.Birthday)
+	}
+	if c.Address.City != "" {
+		details = append(details, "in "+c.Address.City)
+	}
+	if len(c.Tags) > 0 {
+		details = append(details, "tags "+strings.Join(c.Tags, " "))
+	}
+	return details
+}
+
+// printContacts writes contacts as a table on standard output; pinned
+// reports which ones are marked as pinned (nil: none)
+func printContacts(contacts []annuaire.Contact, pinned func(annuaire.ContactRef) bool) {
+	rows := make([][]string, len(contacts))
+	for i, contact := range contacts {
+		rows[i] = contactRow(contact, contactDetails(contact, pinned != nil && pinned(contact.Ref()))...)
+	}
+	printTable(os.Stdout, contactColumns, rows)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal reports whether a file is an interactive terminal rather than a
+// pipe, a regular file or another character device such as /dev/null: only a
+// terminal answers the TCGETS request for its settings
+func isTerminal(f *os.File) bool {
+	var settings syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&settings)))
+	return errno == 0
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// isTerminal reports whether a file is an interactive terminal rather than a
+// pipe or a regular file. Without the Linux terminal request, a character
+// device counts only when TERM names a terminal, which leaves out /dev/null
+// in scripts and services
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "" && os.Getenv("TERM") != "dumb"
+}
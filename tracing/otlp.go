@@ -0,0 +1,310 @@
+package tracing
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"tp1/batch"
+)
+
+// Limits of the export queue: spans are sent exportInterval after the first
+// one ended, or as soon as maxBatch of them are waiting; beyond maxQueue, while the collector is
+// down or slow, new spans are dropped rather than filling the memory
+const (
+	exportInterval = 5 * time.Second
+	maxBatch       = 512
+	maxQueue       = 4 * maxBatch
+)
+
+// defaultExportTimeout bounds one export request (OTEL_EXPORTER_OTLP_TIMEOUT)
+const defaultExportTimeout = 10 * time.Second
+
+// Exporter sends ended spans in batches to an OTLP collector over HTTP, in
+// the JSON encoding of the protocol (OTLP/HTTP JSON, usually on port 4318)
+type Exporter struct {
+	endpoint string            // Full URL, e.g. "http://localhost:4318/v1/traces"
+	headers  map[string]string // Extra headers, e.g. an API key of the observability vendor
+	service  string            // service.name of the resource
+	client   *http.Client
+	queue    *batch.Queue[*Span] // Ended spans waiting for the next export
+}
+
+/**
+ * NewExporter starts sending spans to an OTLP collector
+ *
+ * @param {string} endpoint - Traces URL of the collector, e.g. "http://localhost:4318/v1/traces"
+ * @param {string} service - Name of the service in the traces, e.g. "tp1"
+ * @param {map[string]string} headers - Extra request headers (may be nil)
+ * @param {time.Duration} timeout - Limit of one export request
+ * @return {*Exporter} Running exporter; pass it to SetExporter, and call
+ *   Shutdown before exiting so that the last spans are sent
+ */
+func NewExporter(endpoint, service string, headers map[string]string, timeout time.Duration) *Exporter {
+	e := &Exporter{
+		endpoint: endpoint,
+		headers:  headers,
+		service:  service,
+		client:   &http.Client{Timeout: timeout},
+	}
+	e.queue = batch.New(batch.Config[*Span]{
+		Send: e.send,
+		Wait: func(time.Time) time.Duration { return exportInterval },
+		Failed: func(err error) {
+			slog.Warn("cannot export traces", "endpoint", e.endpoint, "error", err)
+		},
+		Max:  maxQueue,
+		Full: maxBatch,
+	})
+	return e
+}
+
+/**
+ * ExporterFromEnv creates the exporter configured by the standard
+ * OpenTelemetry environment variables
+ *
+ * @param {string} service - Service name unless OTEL_SERVICE_NAME is set
+ * @return {*Exporter} Running exporter, nil when no endpoint is set or tracing
+ *   is disabled (OTEL_SDK_DISABLED=true, OTEL_TRACES_EXPORTER=none)
+ * @return {error} Returns an error for an invalid variable or a protocol
+ *   other than http/json
+ *
+ * Variables read (the TRACES ones take precedence):
+ *   OTEL_EXPORTER_OTLP_ENDPOINT        - collector base URL, "/v1/traces" is appended
+ *   OTEL_EXPORTER_OTLP_TRACES_ENDPOINT - full traces URL, used as is
+ *   OTEL_EXPORTER_OTLP_HEADERS         - "key=value,key2=value2", values URL-encoded
+ *   OTEL_EXPORTER_OTLP_TIMEOUT         - export timeout in milliseconds (default 10000)
+ *   OTEL_EXPORTER_OTLP_PROTOCOL        - must be http/json when set
+ *   OTEL_SERVICE_NAME                  - service.name of the traces
+ */
+func ExporterFromEnv(service string) (*Exporter, error) {
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled {
+		return nil, nil
+	}
+	switch kind := os.Getenv("OTEL_TRACES_EXPORTER"); kind {
+	case "none":
+		return nil, nil
+	case "", "otlp":
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER: unsupported exporter %q (otlp or none)", kind)
+	}
+
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		if base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); base != "" {
+			endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+		}
+	}
+	if endpoint == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("OTLP endpoint: invalid URL %q", endpoint)
+	}
+	if protocol := envOr("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/json" {
+		return nil, fmt.Errorf("OTLP protocol %q is not supported, set OTEL_EXPORTER_OTLP_PROTOCOL=http/json", protocol)
+	}
+
+	headers := make(map[string]string)
+	for _, variable := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS"} {
+		for _, pair := range strings.Split(os.Getenv(variable), ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, value, found := strings.Cut(pair, "=")
+			decoded, err := url.QueryUnescape(strings.TrimSpace(value))
+			if key = strings.TrimSpace(key); !found || key == "" || err != nil {
+				return nil, fmt.Errorf("%s: invalid header %q (expected key=value)", variable, pair)
+			}
+			headers[key] = decoded
+		}
+	}
+
+	timeout := defaultExportTimeout
+	if text := envOr("OTEL_EXPORTER_OTLP_TRACES_TIMEOUT", "OTEL_EXPORTER_OTLP_TIMEOUT"); text != "" {
+		ms, err := strconv.Atoi(text)
+		if err != nil || ms <= 0 {
+			return nil, fmt.Errorf("OTLP timeout: invalid number of milliseconds %q", text)
+		}
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	service = cmp.Or(os.Getenv("OTEL_SERVICE_NAME"), service)
+	slog.Info("tracing enabled", "endpoint", endpoint, "service", service)
+	return NewExporter(endpoint, service, headers, timeout), nil
+}
+
+// envOr returns the first variable set among names
+func envOr(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+/**
+ * Flush sends every queued span now
+ *
+ * @param {context.Context} ctx - Context bounding the export requests
+ * @return {error} First export error; the spans of a failed batch are dropped,
+ *   as a collector that refuses them would refuse them again
+ */
+func (e *Exporter) Flush(ctx context.Context) error {
+	return e.queue.Flush(ctx)
+}
+
+/**
+ * Shutdown stops the exporter after sending the last spans
+ *
+ * @param {context.Context} ctx - Context bounding the final export
+ * @return {error} Error of the final export
+ */
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return e.queue.Shutdown(ctx)
+}
+
+// send exports queued spans in batches of at most maxBatch
+func (e *Exporter) send(ctx context.Context, spans []*Span, dropped int) error {
+	if dropped > 0 {
+		slog.Warn("trace spans dropped, the export queue was full", "dropped", dropped)
+	}
+	var firstErr error
+	for len(spans) > 0 {
+		part := spans[:min(len(spans), maxBatch)]
+		spans = spans[len(part):]
+		if err := e.export(ctx, part); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// OTLP/HTTP JSON request body (ExportTraceServiceRequest); IDs are hexadecimal
+// and 64-bit integers are strings, as the JSON encoding of OTLP requires
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []otlpAttr `json:"attributes,omitempty"`
+		Status            otlpStatus `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 2 for an error, 0 (unset) otherwise
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		IntValue    string  `json:"intValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+	}
+)
+
+// otlpStatusError is the OTLP status code of a failed span
+const otlpStatusError = 2
+
+// otlpAttrs converts attributes to their OTLP form
+func otlpAttrs(attrs []Attr) []otlpAttr {
+	converted := make([]otlpAttr, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case int64:
+			value.IntValue = strconv.FormatInt(v, 10)
+		case bool:
+			value.BoolValue = &v
+		default:
+			text := fmt.Sprint(v)
+			value.StringValue = &text
+		}
+		converted = append(converted, otlpAttr{Key: attr.Key, Value: value})
+	}
+	return converted
+}
+
+// export sends one batch of spans to the collector
+func (e *Exporter) export(ctx context.Context, spans []*Span) error {
+	converted := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.context.traceID[:]),
+			SpanID:            hex.EncodeToString(s.context.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        otlpAttrs(s.attrs),
+		}
+		if s.failed {
+			span.Status = otlpStatus{Code: otlpStatusError, Message: s.message}
+		}
+		s.mu.Unlock()
+		if s.parentID != ([8]byte{}) {
+			span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		converted = append(converted, span)
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttrs([]Attr{String("service.name", e.service)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "tp1/tracing"}, Spans: converted}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector answered %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
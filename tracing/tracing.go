@@ -0,0 +1,234 @@
+// Package tracing records OpenTelemetry spans and sends them to an OTLP
+// collector (see Exporter), with the standard library only
+//
+// Spans follow the W3C Trace Context: a request carrying a traceparent header
+// continues the trace of its caller, so that a request through the API can be
+// followed end-to-end in the observability stack. Without an exporter (see
+// SetExporter), Start returns a nil *Span, whose methods do nothing, so
+// instrumented code costs almost nothing when tracing is off
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind tells what a span measures, with the values of OTLP
+type Kind int
+
+const (
+	KindInternal Kind = 1 // Operation inside the process, e.g. a directory search
+	KindServer   Kind = 2 // Incoming request
+	KindClient   Kind = 3 // Outgoing request
+)
+
+// Attr is a span attribute; the value is a string, int64 or bool
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute, e.g. String("http.route", "/api/contacts")
+func String(key, value string) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// Int returns an integer attribute, e.g. Int("http.response.status_code", 200)
+func Int(key string, value int) Attr {
+	return Attr{Key: key, Value: int64(value)}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attr {
+	return Attr{Key: key, Value: value}
+}
+
+// spanContext identifies a span across processes (see Extract and Inject)
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+// Span is one timed operation of a trace; a nil *Span is a valid span
+// that records nothing
+type Span struct {
+	exporter *Exporter
+	context  spanContext
+	parentID [8]byte // Zero for the root span of a trace
+	kind     Kind
+	start    time.Time
+
+	mu      sync.Mutex
+	name    string
+	end     time.Time
+	attrs   []Attr
+	failed  bool
+	message string // Error description when failed
+}
+
+// exporter receives the ended spans; nil while tracing is off
+var exporter atomic.Pointer[Exporter]
+
+// SetExporter starts sending the spans ended from now on to an exporter
+// (nil turns tracing off)
+func SetExporter(e *Exporter) {
+	exporter.Store(e)
+}
+
+// Context keys of the current span and of the remote parent
+type (
+	spanKey   struct{}
+	remoteKey struct{}
+)
+
+/**
+ * Start begins a span, child of the span of the context if any
+ *
+ * @param {context.Context} ctx - Context of the caller, possibly carrying a
+ *   span (see Start) or a remote parent (see Extract)
+ * @param {string} name - Low-cardinality name, e.g. "GET /api/contacts/{id}"
+ * @param {Kind} kind - What the span measures
+ * @param {...Attr} attrs - Attributes known at the start
+ * @return {context.Context} Context carrying the new span, for its children
+ * @return {*Span} Span to End, nil when tracing is off or the caller did not
+ *   sample the trace
+ *
+ * Usage:
+ *   ctx, span := tracing.Start(r.Context(), "annuaire.Search", tracing.KindInternal)
+ *   defer span.End()
+ */
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attr) (context.Context, *Span) {
+	e := exporter.Load()
+	if e == nil {
+		return ctx, nil
+	}
+	var parentContext spanContext
+	if parent := SpanFromContext(ctx); parent != nil {
+		parentContext = parent.context
+	} else if remote, ok := ctx.Value(remoteKey{}).(spanContext); ok {
+		if !remote.sampled {
+			return ctx, nil // The caller does not keep this trace
+		}
+		parentContext = remote
+	}
+
+	span := &Span{exporter: e, kind: kind, name: name, start: time.Now(), attrs: attrs, parentID: parentContext.spanID}
+	span.context.traceID = parentContext.traceID
+	if span.context.traceID == ([16]byte{}) {
+		rand.Read(span.context.traceID[:])
+	}
+	rand.Read(span.context.spanID[:])
+	span.context.sampled = true
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the current span of a context (nil if none)
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SetName renames a span whose operation is only known later, e.g. the
+// route of a request once it has been matched
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+// SetAttributes adds attributes to a span
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError marks a span as failed with err (nil is ignored)
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failed, s.message = true, err.Error()
+}
+
+// End closes a span and queues it for export; later calls do nothing
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.exporter.queue.Add(s)
+}
+
+// TraceID returns the trace of a span as 32 hexadecimal digits ("" for a nil
+// span), e.g. to log it next to the request
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.context.traceID[:])
+}
+
+// traceparentHeader is the W3C Trace Context header:
+// "00-<trace id>-<parent span id>-<flags>"
+const traceparentHeader = "traceparent"
+
+/**
+ * Extract reads the remote parent of an incoming request
+ *
+ * @param {context.Context} ctx - Context of the request
+ * @param {http.Header} header - Request headers, whose traceparent is read
+ * @return {context.Context} Context whose next span continues the trace of
+ *   the caller; ctx itself when the header is missing or invalid
+ */
+func Extract(ctx context.Context, header http.Header) context.Context {
+	parts := strings.Split(strings.TrimSpace(header.Get(traceparentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return ctx
+	}
+	var remote spanContext
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 || !decodeID(remote.traceID[:], parts[1]) || !decodeID(remote.spanID[:], parts[2]) {
+		return ctx
+	}
+	remote.sampled = flags[0]&1 == 1
+	return context.WithValue(ctx, remoteKey{}, remote)
+}
+
+// decodeID fills an ID from its hexadecimal form, refusing the all-zero ID
+func decodeID(id []byte, text string) bool {
+	if len(text) != 2*len(id) || strings.Trim(text, "0") == "" {
+		return false
+	}
+	_, err := hex.Decode(id, []byte(text))
+	return err == nil
+}
+
+// Inject adds the traceparent header of the current span to an outgoing
+// request, so that the called service continues the trace
+func Inject(ctx context.Context, header http.Header) {
+	if span := SpanFromContext(ctx); span != nil {
+		header.Set(traceparentHeader, "00-"+span.TraceID()+"-"+hex.EncodeToString(span.context.spanID[:])+"-01")
+	}
+}
@@ -0,0 +1,159 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector is an OTLP/HTTP JSON endpoint keeping the spans it receives
+type collector struct {
+	mu      sync.Mutex
+	spans   []otlpSpan
+	service string
+	headers http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request otlpRequest
+	if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&request) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = r.Header
+	for _, resource := range request.ResourceSpans {
+		c.service = *resource.Resource.Attributes[0].Value.StringValue
+		for _, scope := range resource.ScopeSpans {
+			c.spans = append(c.spans, scope.Spans...)
+		}
+	}
+}
+
+// startCollector sends the spans of the test to a new collector
+func startCollector(t *testing.T) (*collector, *Exporter) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	e := NewExporter(srv.URL+"/v1/traces", "tp1-test", map[string]string{"Authorization": "Bearer secret"}, time.Second)
+	SetExporter(e)
+	t.Cleanup(func() {
+		SetExporter(nil)
+		e.Shutdown(context.Background())
+		srv.Close()
+	})
+	return c, e
+}
+
+// TestSpansExport tests that spans reach the collector with their parents,
+// attributes and errors
+func TestSpansExport(t *testing.T) {
+	c, e := startCollector(t)
+
+	ctx, parent := Start(context.Background(), "GET /api/contacts", KindServer, String("url.path", "/api/contacts"))
+	_, child := Start(ctx, "annuaire.ScanContacts", KindInternal)
+	child.SetAttributes(Int("annuaire.matches", 3), Bool("annuaire.truncated", false))
+	child.SetError(errors.New("out of time"))
+	child.End()
+	parent.End()
+	parent.End() // Ending twice exports once
+	if err := e.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.spans) != 2 || c.service != "tp1-test" || c.headers.Get("Authorization") != "Bearer secret" {
+		t.Fatalf("Collector got %d spans for %q (headers %v)", len(c.spans), c.service, c.headers)
+	}
+	gotChild, gotParent := c.spans[0], c.spans[1]
+	if gotChild.TraceID != gotParent.TraceID || gotChild.ParentSpanID != gotParent.SpanID || gotParent.ParentSpanID != "" {
+		t.Errorf("Child %+v is not linked to parent %+v", gotChild, gotParent)
+	}
+	if gotParent.Kind != KindServer || gotParent.Name != "GET /api/contacts" || *gotParent.Attributes[0].Value.StringValue != "/api/contacts" {
+		t.Errorf("Unexpected parent %+v", gotParent)
+	}
+	if gotChild.Status.Code != otlpStatusError || gotChild.Status.Message != "out of time" ||
+		gotChild.Attributes[0].Value.IntValue != "3" || *gotChild.Attributes[1].Value.BoolValue {
+		t.Errorf("Unexpected child %+v", gotChild)
+	}
+}
+
+// TestPropagation tests that traceparent headers carry traces across requests
+func TestPropagation(t *testing.T) {
+	startCollector(t)
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	ctx, span := Start(Extract(context.Background(), http.Header{"Traceparent": {"00-" + traceID + "-00f067aa0ba902b7-01"}}), "GET", KindServer)
+	if span.TraceID() != traceID {
+		t.Errorf("Trace %q not continued: %q", traceID, span.TraceID())
+	}
+	header := http.Header{}
+	Inject(ctx, header)
+	if _, next := Start(Extract(context.Background(), header), "GET", KindServer); next.TraceID() != traceID {
+		t.Errorf("Injected header %q does not continue the trace", header.Get(traceparentHeader))
+	}
+
+	if _, span := Start(Extract(context.Background(), http.Header{"Traceparent": {"00-" + traceID + "-00f067aa0ba902b7-00"}}), "GET", KindServer); span != nil {
+		t.Error("A trace not sampled by the caller was recorded")
+	}
+	for _, invalid := range []string{"", "00-" + traceID + "-00f067aa0ba902b7", "00-" + traceID + "00-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01", "ff-" + traceID + "-00f067aa0ba902b7-01", "00-" + traceID + "-xyz-01"} {
+		if _, span := Start(Extract(context.Background(), http.Header{"Traceparent": {invalid}}), "GET", KindServer); span == nil || span.TraceID() == traceID {
+			t.Errorf("Invalid traceparent %q: want a new trace", invalid)
+		}
+	}
+}
+
+// TestTracingOff tests that nothing is recorded without an exporter
+func TestTracingOff(t *testing.T) {
+	ctx, span := Start(context.Background(), "GET", KindServer)
+	span.SetAttributes(String("key", "value"))
+	span.SetError(errors.New("ignored"))
+	span.End()
+	if span != nil || SpanFromContext(ctx) != nil || span.TraceID() != "" {
+		t.Error("A span was recorded without exporter")
+	}
+}
+
+// TestExporterFromEnv tests the OTEL_* variables
+func TestExporterFromEnv(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		enabled bool
+		wantErr bool
+	}{
+		{map[string]string{}, false, false},
+		{map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318"}, true, false},
+		{map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://localhost:4318/v1/traces", "OTEL_EXPORTER_OTLP_HEADERS": "api-key=a%20b"}, true, false},
+		{map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_SDK_DISABLED": "true"}, false, false},
+		{map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_TRACES_EXPORTER": "none"}, false, false},
+		{map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_TRACES_EXPORTER": "zipkin"}, false, true},
+		{map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "localhost:4318"}, false, true},
+		{map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4317", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"}, false, true},
+		{map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_EXPORTER_OTLP_HEADERS": "api-key"}, false, true},
+		{map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318", "OTEL_EXPORTER_OTLP_TIMEOUT": "soon"}, false, true},
+	}
+	for _, tt := range tests {
+		for _, name := range []string{"OTEL_SDK_DISABLED", "OTEL_TRACES_EXPORTER", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+			"OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TIMEOUT"} {
+			t.Setenv(name, tt.env[name])
+		}
+		e, err := ExporterFromEnv("tp1")
+		if (err != nil) != tt.wantErr || (e != nil) != tt.enabled {
+			t.Errorf("%v: got exporter %v, error %v", tt.env, e != nil, err)
+		}
+		if e != nil {
+			if e.endpoint != "http://localhost:4318/v1/traces" {
+				t.Errorf("%v: endpoint %q", tt.env, e.endpoint)
+			}
+			if key, ok := e.headers["api-key"]; ok && key != "a b" {
+				t.Errorf("%v: header %q", tt.env, key)
+			}
+			e.Shutdown(context.Background())
+		}
+	}
+}
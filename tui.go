@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"tp1/annuaire"
+)
+
+// ANSI sequences of the full-screen interface
+const (
+	screenEnter   = "\x1b[?1049h\x1b[?25l" // Alternate screen, hidden cursor
+	screenLeave   = "\x1b[?25h\x1b[?1049l" // Back to the shell screen and cursor
+	screenClear   = "\x1b[H\x1b[2J"
+	styleSelected = "\x1b[7m" // Reverse video, visible even without colors
+)
+
+// Key names returned by splitKeys for the control keys and escape
+// sequences; any other key is the character typed
+var tuiKeys = map[string]string{
+	"\x1b[A": "up", "\x1bOA": "up",
+	"\x1b[B": "down", "\x1bOB": "down",
+	"\x1b[5~": "pgup", "\x1b[6~": "pgdn",
+	"\x1b[H": "home", "\x1bOH": "home", "\x1b[1~": "home",
+	"\x1b[F": "end", "\x1bOF": "end", "\x1b[4~": "end",
+	"\x1b[3~": "delete",
+	"\x1b[Z":  "backtab",
+	"\x1b":    "esc",
+	"\r":      "enter", "\n": "enter",
+	"\t":   "tab",
+	"\x7f": "backspace", "\x08": "backspace",
+	"\x15": "ctrl-u",
+	"\x03": "ctrl-c", "\x04": "ctrl-d",
+}
+
+// tuiFieldLabels are the inputs of the add/edit dialog, in order
+var tuiFieldLabels = []string{"First name", "Last name", "Phone", "Birthday", "Address", "Code", "Tags"}
+
+// tuiDialog is the form adding a contact, or editing the contact id
+type tuiDialog struct {
+	id     int      // 0 adds a contact
+	values []string // One per tuiFieldLabels entry
+	focus  int      // Index of the field being typed in
+	err    string   // Why the last save was refused
+}
+
+// tui is the state of the full-screen interface of "tp1 tui"
+type tui struct {
+	dir          *annuaire.Directory
+	out          *bufio.Writer
+	pinned       []annuaire.ContactRef
+	verifyMonths int
+
+	contacts  []annuaire.Contact // Shown: every contact, or the search matches, sorted
+	cursor    int                // Index of the selected contact in contacts
+	offset    int                // Index of the first contact on screen
+	query     string             // Search typed after "/"
+	searching bool               // Keys go to the search line
+	deleting  bool               // The deletion of the selected contact awaits y/N
+	dialog    *tuiDialog
+	status    string // Outcome of the last action
+}
+
+/**
+ * handleTUIAction runs the full-screen terminal interface
+ *
+ * @param {*annuaire.Directory} dir - Directory browsed and edited
+ * @param {[]annuaire.ContactRef} pinned - Contacts listed first
+ * @param {int} verifyMonths - Months before an added or edited contact is due for verification
+ *
+ * The interface lists the contacts in a scrollable table and is driven by
+ * the keyboard: arrows, Page Up/Down, Home/End (or j, k, g, G) move, "/"
+ * searches as you type, "a" adds, Enter or "e" edits, "d" deletes after a
+ * y/N question and "q" quits. Every change is saved to the data file at once,
+ * like the other commands. The terminal is switched to raw mode with stty,
+ * so a Unix terminal is required
+ */
+func handleTUIAction(dir *annuaire.Directory, pinned []annuaire.ContactRef, verifyMonths int) {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		fmt.Fprintln(os.Stderr, "Error: tui needs an interactive terminal")
+		os.Exit(exitInvalid)
+	}
+	saved, err := stty("-g")
+	if err == nil {
+		_, err = stty("raw", "-echo")
+	}
+	if err != nil {
+		exitWithUsageError(fmt.Errorf("cannot switch the terminal to raw mode: %w", err))
+	}
+
+	t := &tui{dir: dir, out: bufio.NewWriter(os.Stdout), pinned: pinned, verifyMonths: verifyMonths}
+	t.out.WriteString(screenEnter)
+	defer func() {
+		t.out.WriteString(screenLeave)
+		t.out.Flush()
+		stty(saved)
+	}()
+
+	t.reload()
+	input := make([]byte, 256)
+	for {
+		t.render()
+		n, err := os.Stdin.Read(input)
+		if err != nil {
+			return
+		}
+		// Keys typed quickly or pasted arrive together
+		for _, key := range splitKeys(string(input[:n])) {
+			if !t.handleKey(key) {
+				return
+			}
+		}
+	}
+}
+
+// stty runs stty on the terminal of standard input and returns its output
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// terminalSize returns the rows and columns of the terminal, 24x80 if unknown
+func terminalSize() (int, int) {
+	out, err := stty("size")
+	if rows, cols, found := strings.Cut(out, " "); err == nil && found {
+		r, rowsErr := strconv.Atoi(rows)
+		c, colsErr := strconv.Atoi(cols)
+		if rowsErr == nil && colsErr == nil && r > 0 && c > 0 {
+			return r, c
+		}
+	}
+	return 24, 80
+}
+
+// splitKeys cuts the bytes read from the terminal into keys, naming the
+// control keys and escape sequences found in tuiKeys
+func splitKeys(input string) []string {
+	var keys []string
+	for input != "" {
+		n := keyLength(input)
+		key := input[:n]
+		if name, ok := tuiKeys[key]; ok {
+			key = name
+		}
+		keys = append(keys, key)
+		input = input[n:]
+	}
+	return keys
+}
+
+// keyLength returns the length of the key starting input: an escape
+// sequence, a control character or one UTF-8 character
+func keyLength(input string) int {
+	switch {
+	case strings.HasPrefix(input, "\x1bO") && len(input) > 2:
+		return 3
+	case strings.HasPrefix(input, "\x1b["):
+		// Parameters, then a final byte between '@' and '~'
+		if i := strings.IndexFunc(input[2:], func(r rune) bool { return r >= '@' && r <= '~' }); i >= 0 {
+			return i + 3
+		}
+		return len(input)
+	}
+	_, size := utf8.DecodeRuneInString(input)
+	return size
+}
+
+// typed returns the character of a key, empty for control keys
+func typed(key string) string {
+	if r, _ := utf8.DecodeRuneInString(key); key == "" || !unicode.IsPrint(r) || utf8.RuneCountInString(key) > 1 {
+		return ""
+	}
+	return key
+}
+
+// reload reads the contacts shown again, keeping the selected one selected
+func (t *tui) reload() {
+	selected := -1
+	if t.cursor < len(t.contacts) {
+		selected = t.contacts[t.cursor].ID
+	}
+	if t.query == "" {
+		t.contacts = t.dir.ListContacts()
+	} else {
+		t.contacts = t.dir.ScanContacts(context.Background(), func(c annuaire.Contact) bool {
+			return annuaire.FindMatches(c, t.query, annuaire.SearchOptions{}) != nil
+		}, annuaire.SearchLimits{}).Contacts
+	}
+	annuaire.SortContacts(t.contacts, t.pinned)
+	t.selectWhere(func(c annuaire.Contact) bool { return c.ID == selected })
+}
+
+// selectWhere moves the cursor to the first contact shown accepted by match,
+// or keeps it within the list when there is none
+func (t *tui) selectWhere(match func(annuaire.Contact) bool) {
+	if i := slices.IndexFunc(t.contacts, match); i >= 0 {
+		t.cursor = i
+	}
+	t.cursor = max(0, min(t.cursor, len(t.contacts)-1))
+}
+
+// handleKey applies a key press and reports whether the interface goes on
+func (t *tui) handleKey(key string) bool {
+	switch {
+	case key == "ctrl-c":
+		return false
+	case t.dialog != nil:
+		t.handleDialogKey(key)
+		return true
+	case t.deleting:
+		t.deleting = false
+		if key == "y" || key == "Y" {
+			t.deleteSelected()
+		} else {
+			t.status = "Nothing deleted"
+		}
+		return true
+	case t.searching:
+		t.handleSearchKey(key)
+		return true
+	}
+
+	t.status = ""
+	page := max(1, t.listHeight()-1)
+	switch key {
+	case "q", "ctrl-d":
+		return false
+	case "esc":
+		if t.query == "" {
+			return false
+		}
+		t.query = ""
+		t.reload()
+	case "up", "k":
+		t.cursor = max(0, t.cursor-1)
+	case "down", "j":
+		t.cursor = min(len(t.contacts)-1, t.cursor+1)
+	case "pgup":
+		t.cursor = max(0, t.cursor-page)
+	case "pgdn":
+		t.cursor = min(len(t.contacts)-1, t.cursor+page)
+	case "home", "g":
+		t.cursor = 0
+	case "end", "G":
+		t.cursor = len(t.contacts) - 1
+	case "/":
+		t.searching = true
+	case "a":
+		t.dialog = &tuiDialog{values: make([]string, len(tuiFieldLabels))}
+	case "enter", "e":
+		if c, ok := t.selected(); ok {
+			t.dialog = &tuiDialog{id: c.ID, values: []string{
+				c.First, c.Name, c.Phone, c.Birthday, addressValue(c.Address), c.Code, strings.Join(c.Tags, ", "),
+			}}
+		}
+	case "d", "delete":
+		_, t.deleting = t.selected()
+	}
+	t.cursor = max(0, t.cursor)
+	return true
+}
+
+// handleSearchKey edits the search line; the list follows each key typed
+func (t *tui) handleSearchKey(key string) {
+	switch key {
+	case "enter", "down", "tab":
+		t.searching = false
+	case "esc":
+		t.searching = false
+		t.query = ""
+	case "backspace":
+		_, size := utf8.DecodeLastRuneInString(t.query)
+		t.query = t.query[:len(t.query)-size]
+	case "ctrl-u":
+		t.query = ""
+	default:
+		t.query += typed(key)
+	}
+	t.reload()
+}
+
+// handleDialogKey edits the fields of the dialog, saving it on Enter
+func (t *tui) handleDialogKey(key string) {
+	d := t.dialog
+	switch key {
+	case "esc":
+		t.dialog = nil
+	case "enter":
+		t.saveDialog()
+	case "tab", "down":
+		d.focus = (d.focus + 1) % len(d.values)
+	case "backtab", "up":
+		d.focus = (d.focus + len(d.values) - 1) % len(d.values)
+	case "backspace":
+		_, size := utf8.DecodeLastRuneInString(d.values[d.focus])
+		d.values[d.focus] = d.values[d.focus][:len(d.values[d.focus])-size]
+	case "ctrl-u":
+		d.values[d.focus] = ""
+	default:
+		d.values[d.focus] += typed(key)
+	}
+}
+
+// saveDialog adds or updates the contact of the dialog, which stays open
+// with the error when a field is refused
+func (t *tui) saveDialog() {
+	d := t.dialog
+	var contact annuaire.Contact
+	if d.id != 0 {
+		var err error
+		if contact, err = t.dir.ResolveContact(fmt.Sprintf("#%d", d.id)); err != nil {
+			d.err = err.Error()
+			return
+		}
+	}
+	address, err := annuaire.ParseAddress(d.values[4])
+	if err != nil {
+		d.err = err.Error()
+		return
+	}
+	contact.First, contact.Name, contact.Phone = strings.TrimSpace(d.values[0]), strings.TrimSpace(d.values[1]), strings.TrimSpace(d.values[2])
+	contact.Birthday, contact.Address, contact.Code = strings.TrimSpace(d.values[3]), address, strings.TrimSpace(d.values[5])
+	contact.Tags = annuaire.ParseTags(d.values[6])
+	contact.VerifyBy = annuaire.VerifyByDate(time.Now(), t.verifyMonths)
+
+	// Both validate every field before changing anything
+	verb := "updated"
+	if d.id == 0 {
+		verb, err = "added", t.dir.InsertContact(contact)
+	} else {
+		err = t.dir.SaveContact(contact)
+	}
+	if err != nil {
+		d.err = err.Error()
+		return
+	}
+	t.dialog = nil
+	t.save(fmt.Sprintf("Contact %s %s %s", contact.First, contact.Name, verb))
+	t.reload()
+	t.selectWhere(func(c annuaire.Contact) bool { return c.Name == contact.Name && c.Phone == contact.Phone })
+}
+
+// deleteSelected deletes the selected contact, once confirmed
+func (t *tui) deleteSelected() {
+	c, ok := t.selected()
+	if !ok {
+		return
+	}
+	if err := t.dir.DeleteContactByID(c.ID); err != nil {
+		t.status = "Error: " + err.Error()
+		return
+	}
+	t.save(fmt.Sprintf("Contact %s %s deleted", c.First, c.Name))
+	t.reload()
+}
+
+// save writes the data file after a change and reports the outcome
+func (t *tui) save(done string) {
+	if err := t.dir.SaveDataFile(dataFile); err != nil {
+		t.status = fmt.Sprintf("Error saving %s: %v", dataFile, err)
+		return
+	}
+	t.status = done
+}
+
+// selected returns the contact under the cursor, if the list is not empty
+func (t *tui) selected() (annuaire.Contact, bool) {
+	if t.cursor < len(t.contacts) {
+		return t.contacts[t.cursor], true
+	}
+	return annuaire.Contact{}, false
+}
+
+// addressValue writes an address the way the dialog and -address read it
+func addressValue(a annuaire.Address) string {
+	return strings.TrimRight(strings.Join([]string{a.Street, a.Zip, a.City, a.Country}, ";"), ";")
+}
+
+// listHeight is the number of contacts fitting on screen, below the title
+// and table heading and above the status and help lines
+func (t *tui) listHeight() int {
+	rows, _ := terminalSize()
+	return max(1, rows-4)
+}
+
+// render draws the whole screen: raw mode needs "\r\n" line endings
+func (t *tui) render() {
+	rows, cols := terminalSize()
+	rows = max(rows, 5)
+	height := rows - 4
+	var lines []string
+
+	title := fmt.Sprintf("tp1 - %d contacts", t.dir.ContactCount())
+	if t.query != "" || t.searching {
+		title += fmt.Sprintf(" - %d matching %q", len(t.contacts), t.query)
+	}
+	lines = append(lines, title)
+
+	var help string
+	if t.dialog != nil {
+		lines = append(lines, t.dialogLines()...)
+		help = "Tab/Up/Down: field  Enter: save  Esc: cancel  Ctrl-U: clear field"
+	} else {
+		lines = append(lines, fmt.Sprintf("%-6s %-24s %-18s %s", "ID", "NAME", "PHONE", "DETAILS"))
+		t.offset = min(max(t.offset, t.cursor-height+1), t.cursor)
+		t.offset = max(0, t.offset)
+		for i := t.offset; i < len(t.contacts) && i < t.offset+height; i++ {
+			c := t.contacts[i]
+			line := fmt.Sprintf("%-6s %s %s %s", fmt.Sprintf("#%d", c.ID), fit(c.First+" "+c.Name, 24), fit(c.Phone, 18),
+				strings.Join(contactDetails(c, slices.Contains(t.pinned, c.Ref())), ", "))
+			if i == t.cursor {
+				line = styleSelected + fit(line, cols) + styleReset
+			}
+			lines = append(lines, line)
+		}
+		if len(t.contacts) == 0 {
+			lines = append(lines, "No contacts found")
+		}
+		help = "Up/Down/PgUp/PgDn: move  /: search  a: add  Enter/e: edit  d: delete  q: quit"
+	}
+
+	for len(lines) < rows-2 {
+		lines = append(lines, "")
+	}
+	switch {
+	case t.searching:
+		lines = append(lines[:rows-2], "Search: "+t.query+"_")
+	case t.deleting:
+		c, _ := t.selected()
+		lines = append(lines[:rows-2], fmt.Sprintf("Delete %s %s (%s)? [y/N]", c.First, c.Name, c.Phone))
+	case t.dialog != nil && t.dialog.err != "":
+		lines = append(lines[:rows-2], "Error: "+t.dialog.err)
+	default:
+		lines = append(lines[:rows-2], t.status)
+	}
+	lines = append(lines, help)
+
+	t.out.WriteString(screenClear)
+	for i, line := range lines {
+		if !strings.HasPrefix(line, styleSelected) {
+			line = strings.TrimRight(fit(line, cols), " ")
+		}
+		if i == 0 && colors {
+			line = styleBold + line + styleReset
+		}
+		t.out.WriteString(line)
+		if i < len(lines)-1 {
+			t.out.WriteString("\r\n")
+		}
+	}
+	t.out.Flush()
+}
+
+// dialogLines draws the add/edit dialog, the focused field marked with ">";
+// a refused save is explained on the status line
+func (t *tui) dialogLines() []string {
+	d := t.dialog
+	title := "Add a contact"
+	if d.id != 0 {
+		title = fmt.Sprintf("Edit contact #%d", d.id)
+	}
+	lines := []string{"", "  " + title, ""}
+	for i, label := range tuiFieldLabels {
+		marker, cursor := " ", ""
+		if i == d.focus {
+			marker, cursor = ">", "_"
+		}
+		lines = append(lines, fmt.Sprintf(" %s %-11s %s%s", marker, label+":", d.values[i], cursor))
+	}
+	return append(lines, "", "    Birthday YYYY-MM-DD, address street;zip;city;country, tags separated by commas")
+}
+
+// fit pads or cuts text to width characters
+func fit(text string, width int) string {
+	if n := utf8.RuneCountInString(text); n <= width {
+		return text + strings.Repeat(" ", width-n)
+	}
+	return string([]rune(text)[:width])
+}